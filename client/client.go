@@ -0,0 +1,178 @@
+// Package client is a typed Go client for the admin endpoints the webhook serves next to its metrics.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+)
+
+const (
+	stalenessPath     = "staleness"
+	domainCheckPath   = "domains/check"
+	domainPricingPath = "domains/pricing"
+	cutoverPath       = "cutover"
+	ownershipPath     = "ownership"
+)
+
+// Client calls the admin endpoints of a webhook.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	username   string
+	password   string
+}
+
+// Option configures optional behaviour of the Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces the HTTP client used for all calls, e.g. to configure TLS.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBasicAuth authenticates all calls with basic auth as configured in the --tls-config of the webhook.
+func WithBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// Error is returned when an admin endpoint answers with a status other than 200.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("admin API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// New creates a client for the admin endpoints served at baseURL, the metrics listen address of the webhook
+// (e.g. http://localhost:8889).
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL '%s': %v", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL '%s': scheme and host are required", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// get calls the endpoint at the path relative to the base URL and decodes the JSON response into result.
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, result)
+}
+
+// do calls the endpoint with the method and decodes the JSON response into result.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, result interface{}) error {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %v", err)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call admin API: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("unable to decode response of %s: %v", path, err)
+	}
+	return nil
+}
+
+// StaleRecords returns the managed records not written for longer than olderThan.
+// An olderThan of 0 uses the --stale-after threshold of the webhook.
+func (c *Client) StaleRecords(ctx context.Context, olderThan time.Duration) ([]porkbun.StaleRecord, error) {
+	query := url.Values{}
+	if olderThan > 0 {
+		query.Set("olderThan", olderThan.String())
+	}
+	var stale []porkbun.StaleRecord
+	if err := c.get(ctx, stalenessPath, query, &stale); err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// CheckDomain checks whether a domain can be registered.
+func (c *Client) CheckDomain(ctx context.Context, domain string) (*porkbun.DomainAvailability, error) {
+	var availability porkbun.DomainAvailability
+	if err := c.get(ctx, domainCheckPath, url.Values{"domain": {domain}}, &availability); err != nil {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// DomainPricing returns the Porkbun prices of the given TLDs, or of all TLDs if none are given.
+func (c *Client) DomainPricing(ctx context.Context, tlds ...string) (map[string]porkbun.DomainPrice, error) {
+	var pricing map[string]porkbun.DomainPrice
+	if err := c.get(ctx, domainPricingPath, url.Values{"tld": tlds}, &pricing); err != nil {
+		return nil, err
+	}
+	return pricing, nil
+}
+
+// Cutovers returns the configured cutover groups with the color each was last switched to.
+func (c *Client) Cutovers(ctx context.Context) ([]porkbun.CutoverStatus, error) {
+	var status []porkbun.CutoverStatus
+	if err := c.get(ctx, cutoverPath, nil, &status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Cutover switches the records of the group to the targets of the color, blue or green. If the cutover fails,
+// the webhook rolls the records back and an *Error describing the failure is returned.
+func (c *Client) Cutover(ctx context.Context, group string, color string) (*porkbun.CutoverStatus, error) {
+	var status porkbun.CutoverStatus
+	if err := c.do(ctx, http.MethodPost, cutoverPath, url.Values{"group": {group}, "to": {color}}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// TransferOwnership moves the records of the names from the external-dns owner ID from to the owner ID to by
+// rewriting their registry TXT records, and returns the transferred records.
+func (c *Client) TransferOwnership(ctx context.Context, from string, to string, names ...string) ([]porkbun.OwnershipTransfer, error) {
+	var transfers []porkbun.OwnershipTransfer
+	if err := c.do(ctx, http.MethodPost, ownershipPath, url.Values{"from": {from}, "to": {to}, "name": names}, &transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
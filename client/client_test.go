@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	p, err := porkbun.NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+
+	var query string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/staleness", func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "ops" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		query = r.URL.RawQuery
+		p.StalenessHandler(w, r)
+	})
+	mux.HandleFunc("/admin/domains/check", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"domain":"example.net","available":true,"price":"9.68","premium":false}`))
+	})
+	mux.HandleFunc("/admin/domains/pricing", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"net":{"registration":"9.68","renewal":"11.48","transfer":"11.48"}}`))
+	})
+	mux.HandleFunc("/admin/cutover", p.CutoverHandler)
+	mux.HandleFunc("/admin/ownership", p.OwnershipHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c, err := New(server.URL+"/admin", WithBasicAuth("ops", "secret"))
+	assert.NoError(t, err)
+
+	stale, err := c.StaleRecords(context.TODO(), 30*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, stale)
+	assert.Equal(t, "olderThan=720h0m0s", query)
+
+	availability, err := c.CheckDomain(context.TODO(), "example.net")
+	assert.NoError(t, err)
+	assert.Equal(t, &porkbun.DomainAvailability{Domain: "example.net", Available: true, Price: "9.68"}, availability)
+	assert.Equal(t, "domain=example.net", query)
+
+	pricing, err := c.DomainPricing(context.TODO(), "net")
+	assert.NoError(t, err)
+	assert.Equal(t, "11.48", pricing["net"].Renewal)
+	assert.Equal(t, "tld=net", query)
+
+	cutovers, err := c.Cutovers(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, cutovers)
+	_, err = c.Cutover(context.TODO(), "web", porkbun.CutoverGreen)
+	var cutoverErr *Error
+	assert.True(t, errors.As(err, &cutoverErr))
+	assert.Equal(t, http.StatusNotFound, cutoverErr.StatusCode)
+
+	_, err = c.TransferOwnership(context.TODO(), "cluster-a", "cluster-b", "www.example.com")
+	var ownershipErr *Error
+	assert.True(t, errors.As(err, &ownershipErr))
+	assert.Contains(t, ownershipErr.Message, "not available in dry run")
+
+	// errors of the admin API are returned with their status
+	c, err = New(server.URL + "/admin/")
+	assert.NoError(t, err)
+	_, err = c.StaleRecords(context.TODO(), 0)
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "unauthorized", apiErr.Message)
+
+	_, err = New("localhost:8889")
+	assert.Error(t, err)
+}
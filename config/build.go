@@ -0,0 +1,8 @@
+//go:build !lite
+
+package config
+
+// validateBuild rejects settings the build cannot honor, the full build supports all settings.
+func validateBuild(_ *Config) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build lite
+
+package config
+
+import "errors"
+
+// validateBuild rejects settings the lite build cannot honor.
+func validateBuild(c *Config) error {
+	var errs []error
+	if c.TLSConfig != "" {
+		errs = append(errs, errors.New("--tls-config: TLS and basic auth are not supported in the lite build"))
+	}
+	if c.SingleListener {
+		errs = append(errs, errors.New("--single-listener: the lite build has no metrics or admin endpoints to serve"))
+	}
+	return errors.Join(errs...)
+}
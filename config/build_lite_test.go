@@ -0,0 +1,39 @@
+//go:build lite
+
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLite(t *testing.T) {
+	cfg := Default()
+	cfg.Provider.DomainFilter = []string{"example.com"}
+	cfg.Provider.APIKey = "key"
+	cfg.Provider.APISecret = "secret"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.TLSConfig = "web-config.yml"
+	assert.ErrorContains(t, cfg.Validate(), "--tls-config")
+
+	cfg.TLSConfig = ""
+	cfg.SingleListener = true
+	assert.ErrorContains(t, cfg.Validate(), "--single-listener")
+}
@@ -0,0 +1,46 @@
+//go:build !lite
+
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSingleListener(t *testing.T) {
+	cfg := Default()
+	cfg.Provider.DomainFilter = []string{"example.com"}
+	cfg.Provider.APIKey = "key"
+	cfg.Provider.APISecret = "secret"
+
+	// a single listener needs no separate metrics address, but a prefix that does not shadow the webhook
+	cfg.MetricsListenAddress = cfg.ListenAddress
+	cfg.SingleListener = true
+	assert.NoError(t, cfg.Validate())
+	for _, prefix := range []string{"admin", "/admin/", "/", "/records"} {
+		cfg.AdminPathPrefix = prefix
+		assert.ErrorContains(t, cfg.Validate(), "--admin-path-prefix", prefix)
+	}
+
+	cfg.AdminPathPrefix = "/admin"
+	cfg.AdminUsername = "ops"
+	assert.ErrorContains(t, cfg.Validate(), "--admin-password")
+	cfg.AdminPassword = "secret"
+	assert.NoError(t, cfg.Validate())
+}
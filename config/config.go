@@ -0,0 +1,237 @@
+// Package config parses the command line of the Porkbun webhook into a validated configuration.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"github.com/prometheus/common/promslog"
+)
+
+// Commands of the webhook.
+const (
+	CommandServe         = "serve"
+	CommandReplay        = "replay"
+	CommandApplyTemplate = "apply-template"
+	CommandDecrypt       = "decrypt"
+)
+
+// Config is the complete configuration of the webhook: the command, the servers and the provider.
+type Config struct {
+	Command     string
+	ReplayFile  string
+	DecryptFile string
+
+	TemplateName    string
+	TemplateZone    string
+	TemplateVars    map[string]string
+	TemplatePreview bool
+
+	LogLevel             string
+	ListenAddress        string
+	MetricsListenAddress string
+	TLSConfig            string
+	SingleListener       bool
+	AdminPathPrefix      string
+	AdminUsername        string
+	AdminPassword        string
+	LandingPage          bool
+	MetricsOnly          bool
+
+	Provider porkbun.Config
+}
+
+// webhookPaths are the paths served to external-dns, the admin path prefix must not shadow them.
+var webhookPaths = []string{"/healthz", "/readyz", "/records", "/adjustendpoints"}
+
+// Default returns a configuration with all defaults applied.
+func Default() *Config {
+	return &Config{
+		Command:              CommandServe,
+		LogLevel:             "info",
+		ListenAddress:        ":8888",
+		MetricsListenAddress: ":8889",
+		AdminPathPrefix:      "/admin",
+		LandingPage:          true,
+		Provider:             porkbun.DefaultConfig(),
+	}
+}
+
+// Parse parses the command line into a validated configuration.
+// All invalid or missing settings are reported at once in the returned error.
+func Parse(app *kingpin.Application, args []string) (*Config, error) {
+	c := Default()
+	p := &c.Provider
+
+	var logSampleClassLimits, requestHeaders []string
+
+	app.Flag("log-level", "Set the level of logging. (default: info, options: panic, debug, info, warning, error, fatal)").Default(c.LogLevel).Envar("GO_LOG").StringVar(&c.LogLevel)
+	app.Flag("listen-address", "The address this plugin listens on").Default(c.ListenAddress).Envar("LISTEN_ADDRESS").StringVar(&c.ListenAddress)
+	app.Flag("metrics-listen-address", "The address this plugin provides metrics on").Default(c.MetricsListenAddress).Envar("METRICS_LISTEN_ADDRESS").StringVar(&c.MetricsListenAddress)
+	app.Flag("tls-config", "Path to TLS config file.").Envar("TLS_CONFIG").Default(c.TLSConfig).StringVar(&c.TLSConfig)
+	app.Flag("single-listener", "Serve the metrics, the landing page and the admin endpoints on the webhook listen address under --admin-path-prefix instead of on --metrics-listen-address").Default(strconv.FormatBool(c.SingleListener)).Envar("SINGLE_LISTENER").BoolVar(&c.SingleListener)
+	app.Flag("admin-path-prefix", "Path prefix of the metrics, the landing page and the admin endpoints with --single-listener").Default(c.AdminPathPrefix).Envar("ADMIN_PATH_PREFIX").StringVar(&c.AdminPathPrefix)
+	app.Flag("admin-username", "Basic auth username required for the metrics, the landing page and the admin endpoints, the admin endpoints calling Porkbun are only served if set").Default(c.AdminUsername).Envar("ADMIN_USERNAME").StringVar(&c.AdminUsername)
+	app.Flag("admin-password", "Basic auth password required for the metrics, the landing page and the admin endpoints").Default(c.AdminPassword).Envar("ADMIN_PASSWORD").StringVar(&c.AdminPassword)
+	app.Flag("landing-page", "Serve the landing page showing the version and build details next to the metrics; --no-landing-page disables it").Default(strconv.FormatBool(c.LandingPage)).Envar("LANDING_PAGE").BoolVar(&c.LandingPage)
+	app.Flag("metrics-only", "Serve only /metrics next to the webhook, without the landing page and the admin endpoints").Default(strconv.FormatBool(c.MetricsOnly)).Envar("METRICS_ONLY").BoolVar(&c.MetricsOnly)
+
+	app.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains").Envar("DOMAIN_FILTER").StringsVar(&p.DomainFilter)
+	app.Flag("exclude-domains", "Exclude a domain below the domain filter, e.g. internal.example.com of example.com; its records are neither listed nor changed. Specify multiple times for multiple domains").Envar("EXCLUDE_DOMAINS").StringsVar(&p.ExcludeDomains)
+	app.Flag("dry-run", "Run without connecting to Porkbun's API").Default(strconv.FormatBool(p.DryRun)).Envar("DRY_RUN").BoolVar(&p.DryRun)
+	app.Flag("api-key", "The api key to connect to Porkbun's API").Envar("API_KEY").StringVar(&p.APIKey)
+	app.Flag("api-secret", "The api password to connect to Porkbun's API").Envar("API_SECRET").StringVar(&p.APISecret)
+	app.Flag("api-url", "Base URL of the Porkbun API, e.g. of a caching proxy; specify multiple times to fail over to the next URL while the earlier ones fail (default: https://api.porkbun.com/api/json/v3/)").Envar("API_URLS").StringsVar(&p.BaseURLs)
+	app.Flag("credentials-url", "HTTPS endpoint of a token exchange answering with short-lived Porkbun API keys, replacing --api-key and --api-secret").Default(p.CredentialsURL).Envar("CREDENTIALS_URL").StringVar(&p.CredentialsURL)
+	app.Flag("credentials-token-file", "File holding a JWT sent as bearer token to the token exchange, e.g. a projected service account token; read again for every exchange").Default(p.CredentialsTokenFile).Envar("CREDENTIALS_TOKEN_FILE").StringVar(&p.CredentialsTokenFile)
+	app.Flag("credentials-cert", "PEM file of the client certificate presented to the token exchange").Default(p.CredentialsCert).Envar("CREDENTIALS_CERT").StringVar(&p.CredentialsCert)
+	app.Flag("credentials-key", "PEM file of the key of the client certificate presented to the token exchange").Default(p.CredentialsKey).Envar("CREDENTIALS_KEY").StringVar(&p.CredentialsKey)
+	app.Flag("credentials-ca", "PEM file of the certificate authorities trusted for the token exchange; the system pool if unset").Default(p.CredentialsCA).Envar("CREDENTIALS_CA").StringVar(&p.CredentialsCA)
+	app.Flag("credentials-refresh", "Interval in which the API keys are exchanged again, earlier if they expire before").Default(p.CredentialsRefresh.String()).Envar("CREDENTIALS_REFRESH").DurationVar(&p.CredentialsRefresh)
+	app.Flag("warmup-timeout", "Maximum time to answer /records with 503 after startup until the initial zone fetch completed; 0 disables the warm-up gate").Default(p.WarmupTimeout.String()).Envar("WARMUP_TIMEOUT").DurationVar(&p.WarmupTimeout)
+	app.Flag("api-calls-warn-per-hour", "Log a warning when the API calls for a single zone within the last hour reach this number; 0 disables the warning").Default(strconv.Itoa(p.APICallsWarnPerHour)).Envar("API_CALLS_WARN_PER_HOUR").IntVar(&p.APICallsWarnPerHour)
+	app.Flag("conversion-plugin", "Path to a Go plugin exporting a ConversionHook that customizes the endpoint to record conversion; specify multiple times for multiple plugins").Envar("CONVERSION_PLUGINS").StringsVar(&p.ConversionPlugins)
+	app.Flag("records-max-page-size", "Maximum number of records per page for clients that request paginated record listings with the limit query parameter; 0 disables pagination").Default(strconv.Itoa(p.RecordsMaxPageSize)).Envar("RECORDS_MAX_PAGE_SIZE").IntVar(&p.RecordsMaxPageSize)
+	app.Flag("clock-skew-tolerance", "How far timestamps written by other replicas may lie in the future before a clock skew warning is logged").Default(p.ClockSkewTolerance.String()).Envar("CLOCK_SKEW_TOLERANCE").DurationVar(&p.ClockSkewTolerance)
+	app.Flag("log-sample-limit", "Number of high-frequency debug lines (endpoints, planning, ignored) logged per sync before the rest is only counted; 0 logs all lines").Default(strconv.Itoa(p.LogSampleLimit)).Envar("LOG_SAMPLE_LIMIT").IntVar(&p.LogSampleLimit)
+	app.Flag("log-sample-class-limit", "Per class override of --log-sample-limit given as class=limit, e.g. planning=50; specify multiple times for multiple classes").Envar("LOG_SAMPLE_CLASS_LIMITS").StringsVar(&logSampleClassLimits)
+	app.Flag("cluster-id", "Identifier of the cluster added to the User-Agent of all Porkbun API calls").Default(p.ClusterID).Envar("CLUSTER_ID").StringVar(&p.ClusterID)
+	app.Flag("request-header", "Extra header sent with all Porkbun API calls given as 'Name: value'; specify multiple times for multiple headers").Envar("REQUEST_HEADERS").StringsVar(&requestHeaders)
+	app.Flag("stale-after", "Age after which a managed record is reported as stale on the staleness report").Default(p.StaleAfter.String()).Envar("STALE_AFTER").DurationVar(&p.StaleAfter)
+	app.Flag("cname-target-check", "How CNAME and ALIAS targets outside the managed zones or pointing at missing names are handled (options: off, warn, block)").Default(p.CNAMETargetCheck).Envar("CNAME_TARGET_CHECK").EnumVar(&p.CNAMETargetCheck, porkbun.TargetCheckOff, porkbun.TargetCheckWarn, porkbun.TargetCheckBlock)
+	app.Flag("empty-targets", "How changes of endpoints without targets, e.g. of a Service that lost its LoadBalancer IP, are handled (options: skip, delete); delete removes all records of the name and type").Default(p.EmptyTargets).Envar("EMPTY_TARGETS").EnumVar(&p.EmptyTargets, porkbun.EmptyTargetsSkip, porkbun.EmptyTargetsDelete)
+	app.Flag("record-type-order", "Record type in the order records are created within a zone, records are deleted in reverse order; specify multiple times, e.g. TXT then A to create registry records first").Envar("RECORD_TYPE_ORDER").StringsVar(&p.RecordTypeOrder)
+	app.Flag("managed-record-types", "Record type listed to external-dns and accepted from it, records and endpoints of other types are left alone; specify multiple times, e.g. A, AAAA, CNAME and TXT (default: all types)").Envar("MANAGED_RECORD_TYPES").StringsVar(&p.ManagedRecordTypes)
+	app.Flag("excluded-record-types", "Record type neither listed to external-dns nor accepted from it, e.g. MX records maintained in the Porkbun console; specify multiple times for multiple types").Envar("EXCLUDED_RECORD_TYPES").StringsVar(&p.ExcludedRecordTypes)
+	app.Flag("cache-max-records", "Maximum number of records cached over all zones, the least recently synced zones are evicted beyond it; 0 caches all zones").Default(strconv.Itoa(p.CacheMaxRecords)).Envar("CACHE_MAX_RECORDS").IntVar(&p.CacheMaxRecords)
+	app.Flag("max-response-bytes", "Maximum size of a response of the Porkbun record API, larger zone listings fail instead of exhausting the memory; 0 allows any size").Default(strconv.FormatInt(p.MaxResponseBytes, 10)).Envar("MAX_RESPONSE_BYTES").Int64Var(&p.MaxResponseBytes)
+	app.Flag("max-creates-per-sync", "Maximum number of records created per sync, the rest is created by the next syncs, e.g. to migrate a zone with hundreds of records; 0 creates all records at once").Default(strconv.Itoa(p.MaxCreatesPerSync)).Envar("MAX_CREATES_PER_SYNC").IntVar(&p.MaxCreatesPerSync)
+	app.Flag("max-deletes-per-sync", "Maximum number of records a sync may delete, syncs deleting more fail without changing anything, e.g. after a misconfigured source lost all its endpoints; 0 allows any number").Default(strconv.Itoa(p.MaxDeletesPerSync)).Envar("MAX_DELETES_PER_SYNC").IntVar(&p.MaxDeletesPerSync)
+	app.Flag("apply-concurrency", "Number of record names per zone whose changes are applied in parallel, the changes to one name are always applied in order; 0 or 1 applies all changes in sequence").Default(strconv.Itoa(p.ApplyConcurrency)).Envar("APPLY_CONCURRENCY").IntVar(&p.ApplyConcurrency)
+	app.Flag("change-order", "Order in which the changes to a zone are applied (options: delete-before-create, create-before-delete); create-before-delete deletes replaced records last, so renamed records keep resolving").Default(p.ChangeOrder).Envar("CHANGE_ORDER").EnumVar(&p.ChangeOrder, porkbun.ChangeOrderDeleteFirst, porkbun.ChangeOrderCreateFirst)
+	app.Flag("apex-alias", "Create ALIAS records for CNAME endpoints at a zone apex, where Porkbun does not allow a CNAME; requires ALIAS in --managed-record-types of external-dns").Default(strconv.FormatBool(p.ApexAlias)).Envar("APEX_ALIAS").BoolVar(&p.ApexAlias)
+	app.Flag("manage-ns-records", "Manage the NS records delegating subzones; otherwise NS records are not listed and NS endpoints are rejected. The NS records of a zone apex are never managed").Default(strconv.FormatBool(p.ManageNSRecords)).Envar("MANAGE_NS_RECORDS").BoolVar(&p.ManageNSRecords)
+	app.Flag("ownership-guard", "Refuse updates and deletes of records without a registry TXT record of external-dns in their zone, of the owner ID of the sync if it has one; set --txt-prefix, --txt-suffix and --txt-wildcard-replacement like external-dns").Default(strconv.FormatBool(p.OwnershipGuard)).Envar("OWNERSHIP_GUARD").BoolVar(&p.OwnershipGuard)
+	app.Flag("deep-health-timeout", "Timeout of the live Porkbun ping served at /healthz/deep").Default(p.DeepHealthTimeout.String()).Envar("DEEP_HEALTH_TIMEOUT").DurationVar(&p.DeepHealthTimeout)
+	app.Flag("deep-health-interval", "Interval within which the result of /healthz/deep is reused instead of pinging Porkbun again; 0 pings for every request").Default(p.DeepHealthInterval.String()).Envar("DEEP_HEALTH_INTERVAL").DurationVar(&p.DeepHealthInterval)
+	app.Flag("zone-credentials-file", "Path to a JSON file with domain-scoped API keys per zone, used instead of --api-key and --api-secret for their zone").Default(p.ZoneCredentialsFile).Envar("ZONE_CREDENTIALS_FILE").StringVar(&p.ZoneCredentialsFile)
+	app.Flag("retry-policy-file", "Path to a JSON file with the retry policies of failed Porkbun API calls per kind of call (read, create, update, delete); without it no call is retried").Default(p.RetryPolicyFile).Envar("RETRY_POLICY_FILE").StringVar(&p.RetryPolicyFile)
+	app.Flag("rollback-on-failure", "Undo the changes applied to a zone when a later change of the zone fails in the same sync: delete created records, create deleted records again and restore edited records").Default(strconv.FormatBool(p.RollbackOnFailure)).Envar("ROLLBACK_ON_FAILURE").BoolVar(&p.RollbackOnFailure)
+	app.Flag("porkbun-request-timeout", "Timeout of a single Porkbun API call, every retry gets its own; 0 disables the timeout").Default(p.RequestTimeout.String()).Envar("PORKBUN_REQUEST_TIMEOUT").DurationVar(&p.RequestTimeout)
+	app.Flag("records-max-age", "Freshness lifetime announced in the Cache-Control header of /records responses; 0 announces no-cache. Stretched while the API usage is at --api-calls-warn-per-hour").Default(p.RecordsMaxAge.String()).Envar("RECORDS_MAX_AGE").DurationVar(&p.RecordsMaxAge)
+	app.Flag("min-ttl", "Minimum TTL in seconds, lower TTLs desired by external-dns are raised to it; at least the Porkbun minimum of 600").Default(strconv.FormatInt(p.MinTTL, 10)).Envar("MIN_TTL").Int64Var(&p.MinTTL)
+	app.Flag("default-ttl", "TTL in seconds of records whose endpoints have no TTL, so reads and writes converge on it; 0 leaves the TTL to Porkbun").Default(strconv.FormatInt(p.DefaultTTL, 10)).Envar("DEFAULT_TTL").Int64Var(&p.DefaultTTL)
+	app.Flag("cache-snapshot", "Location of a snapshot of the zone cache, written after record listings and restored at startup instead of fetching every zone: configmap://[namespace/]name or s3://bucket/key; empty disables snapshots").Default(p.CacheSnapshot).Envar("CACHE_SNAPSHOT").StringVar(&p.CacheSnapshot)
+	app.Flag("cache-snapshot-max-age", "Maximum age of the zones restored from the cache snapshot, older zones are fetched from Porkbun").Default(p.CacheSnapshotMaxAge.String()).Envar("CACHE_SNAPSHOT_MAX_AGE").DurationVar(&p.CacheSnapshotMaxAge)
+	app.Flag("cache-snapshot-interval", "Minimum time between two writes of the cache snapshot").Default(p.CacheSnapshotInterval.String()).Envar("CACHE_SNAPSHOT_INTERVAL").DurationVar(&p.CacheSnapshotInterval)
+	app.Flag("txt-prefix", "The --txt-prefix external-dns runs with, to find the registry TXT records for ownership transfers, the ownership guard, the TXT garbage collection and the labels of listed endpoints").Default(p.TXTPrefix).Envar("TXT_PREFIX").StringVar(&p.TXTPrefix)
+	app.Flag("txt-suffix", "The --txt-suffix external-dns runs with, to find the registry TXT records for ownership transfers, the ownership guard, the TXT garbage collection and the labels of listed endpoints").Default(p.TXTSuffix).Envar("TXT_SUFFIX").StringVar(&p.TXTSuffix)
+	app.Flag("txt-wildcard-replacement", "The --txt-wildcard-replacement external-dns runs with, to find the registry TXT records for ownership transfers, the ownership guard, the TXT garbage collection and the labels of listed endpoints").Default(p.TXTWildcardReplacement).Envar("TXT_WILDCARD_REPLACEMENT").StringVar(&p.TXTWildcardReplacement)
+	app.Flag("txt-gc-interval", "Interval of the garbage collection deleting registry TXT records of external-dns whose records are gone, an orphan is deleted when two passes found it; 0 disables it").Default(p.TXTGCInterval.String()).Envar("TXT_GC_INTERVAL").DurationVar(&p.TXTGCInterval)
+	app.Flag("txt-gc-owner-id", "The --txt-owner-id of the external-dns whose registry TXT records the TXT garbage collection may delete, records of other owners are kept; specify multiple times for multiple owners, required with --txt-gc-interval").Envar("TXT_GC_OWNER_IDS").StringsVar(&p.TXTGCOwnerIDs)
+	app.Flag("cutover-config", "Path to a JSON file defining groups of records that the admin API switches together between blue and green targets").Default(p.CutoverConfig).Envar("CUTOVER_CONFIG").StringVar(&p.CutoverConfig)
+	app.Flag("change-log-file", "File the applied changes are appended to as nsupdate (RFC 2136) scripts, e.g. /dev/stdout; empty disables the change log").Default(p.ChangeLogFile).Envar("CHANGE_LOG_FILE").StringVar(&p.ChangeLogFile)
+	app.Flag("encryption-key-file", "File holding a base64 encoded 32 byte key, e.g. from openssl rand -base64 32, the change log and the cache snapshots are encrypted with (AES-256-GCM); empty writes them unencrypted").Default(p.EncryptionKeyFile).Envar("ENCRYPTION_KEY_FILE").StringVar(&p.EncryptionKeyFile)
+	app.Flag("zone-lock-record", "Name of a TXT record relative to the zone, e.g. _dns-lock, that locks the zone: changes are skipped while another writer holds it, and the webhook holds it while applying changes; empty disables the lock").Default(p.ZoneLockRecord).Envar("ZONE_LOCK_RECORD").StringVar(&p.ZoneLockRecord)
+	app.Flag("zone-lock-ttl", "Time after which a zone lock written by the webhook expires if it is not released").Default(p.ZoneLockTTL.String()).Envar("ZONE_LOCK_TTL").DurationVar(&p.ZoneLockTTL)
+	app.Flag("sync-record", "Name of a TXT record relative to the zone, e.g. _extdns-sync, the time of the last changes to the zone is written to; empty disables the record").Default(p.SyncRecord).Envar("SYNC_RECORD").StringVar(&p.SyncRecord)
+	app.Flag("verify-resolver", "Resolver checked for the propagation of written records: system, porkbun (the Porkbun nameservers) or host:port, optionally with =timeout, e.g. 1.1.1.1:53=2s; specify multiple times for multiple resolvers, none disables the check").Envar("VERIFY_RESOLVERS").StringsVar(&p.VerifyResolvers)
+	app.Flag("verify-consensus", "Share of the verify resolvers that must answer with a written record for it to count as propagated").Default(strconv.FormatFloat(p.VerifyConsensus, 'g', -1, 64)).Envar("VERIFY_CONSENSUS").Float64Var(&p.VerifyConsensus)
+	app.Flag("verify-window", "Time after a write within which the record must propagate before a warning is logged").Default(p.VerifyWindow.String()).Envar("VERIFY_WINDOW").DurationVar(&p.VerifyWindow)
+
+	app.Command(CommandServe, "Serve the webhook.").Default()
+	app.Command(CommandReplay, "Apply a captured ApplyChanges payload once and exit, e.g. for disaster recovery or to reproduce a bug report.").
+		Arg("file", "File holding the JSON payload external-dns posted to /records, use /dev/stdin to read it from stdin.").Required().StringVar(&c.ReplayFile)
+	app.Command(CommandDecrypt, "Decrypt a change log or a downloaded cache snapshot encrypted with --encryption-key-file to stdout and exit.").
+		Arg("file", "Encrypted file, use /dev/stdin to read it from stdin.").Required().StringVar(&c.DecryptFile)
+	var templateVars []string
+	templates := porkbun.RecordTemplates()
+	templateNames := make([]string, 0, len(templates))
+	for name := range templates {
+		templateNames = append(templateNames, name)
+	}
+	slices.Sort(templateNames)
+	applyTemplate := app.Command(CommandApplyTemplate, "Apply a bundle of records for a common setup to a zone once and exit, e.g. the mail records of a mail provider (templates: "+strings.Join(templateNames, ", ")+").")
+	applyTemplate.Arg("template", "Name of the record template.").Required().EnumVar(&c.TemplateName, templateNames...)
+	applyTemplate.Arg("zone", "Zone the records are applied to, it must be in the domain filter.").Required().StringVar(&c.TemplateZone)
+	applyTemplate.Flag("var", "Variable of the template given as name=value, e.g. user=octocat for github-pages; specify multiple times for multiple variables").StringsVar(&templateVars)
+	applyTemplate.Flag("preview", "Print the changes without applying them").BoolVar(&c.TemplatePreview)
+
+	command, err := app.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	c.Command = command
+
+	var errs []error
+	if limits, err := porkbun.ParseLogSampleLimits(logSampleClassLimits); err != nil {
+		errs = append(errs, fmt.Errorf("--log-sample-class-limit: %v", err))
+	} else {
+		p.LogSampleClassLimits = limits
+	}
+	if headers, err := porkbun.ParseRequestHeaders(requestHeaders); err != nil {
+		errs = append(errs, fmt.Errorf("--request-header: %v", err))
+	} else {
+		p.RequestHeaders = headers
+	}
+	if vars, err := porkbun.ParseTemplateVars(templateVars); err != nil {
+		errs = append(errs, fmt.Errorf("--var: %v", err))
+	} else {
+		c.TemplateVars = vars
+	}
+
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return c, errors.Join(errs...)
+}
+
+// Validate checks the server settings and the provider settings.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := promslog.NewLevel().Set(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("--log-level: invalid log level %q", c.LogLevel))
+	}
+	if c.SingleListener {
+		if !strings.HasPrefix(c.AdminPathPrefix, "/") || strings.HasSuffix(c.AdminPathPrefix, "/") || slices.Contains(webhookPaths, c.AdminPathPrefix) {
+			errs = append(errs, fmt.Errorf("--admin-path-prefix: must start but not end with / and must not be a webhook path, got %q", c.AdminPathPrefix))
+		}
+	} else if c.ListenAddress == c.MetricsListenAddress {
+		errs = append(errs, fmt.Errorf("--listen-address and --metrics-listen-address must differ, both are %q", c.ListenAddress))
+	}
+	if (c.AdminUsername == "") != (c.AdminPassword == "") {
+		errs = append(errs, errors.New("--admin-username and --admin-password must be set together"))
+	}
+	if c.Provider.CutoverConfig != "" && c.AdminUsername == "" {
+		errs = append(errs, errors.New("--cutover-config: requires --admin-username and --admin-password, /cutover is only served behind basic auth"))
+	}
+	if err := validateBuild(c); err != nil {
+		errs = append(errs, err)
+	}
+	// Decrypting does not talk to Porkbun, it only needs the key
+	if c.Command == CommandDecrypt {
+		if c.Provider.EncryptionKeyFile == "" {
+			errs = append(errs, errors.New("--encryption-key-file: required to decrypt"))
+		}
+	} else if err := c.Provider.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// FormatErrors renders joined configuration errors as a list.
+func FormatErrors(err error) string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:\n")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(&b, "  - %s\n", line)
+	}
+	return b.String()
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(kingpin.New("test", ""), []string{
+		"--domain-filter=example.com",
+		"--api-key=key",
+		"--api-secret=secret",
+		"--log-sample-class-limit=planning=10",
+		"--request-header=X-Team: dns",
+		"--no-landing-page",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, CommandServe, cfg.Command)
+	assert.Equal(t, ":8888", cfg.ListenAddress)
+	assert.Equal(t, []string{"example.com"}, cfg.Provider.DomainFilter)
+	assert.Equal(t, porkbun.DefaultConfig().WarmupTimeout, cfg.Provider.WarmupTimeout)
+	assert.Equal(t, porkbun.DefaultConfig().StaleAfter, cfg.Provider.StaleAfter)
+	assert.Equal(t, map[string]int{"planning": 10}, cfg.Provider.LogSampleClassLimits)
+	assert.Equal(t, http.Header{"X-Team": {"dns"}}, cfg.Provider.RequestHeaders)
+	assert.False(t, cfg.LandingPage)
+	assert.False(t, cfg.MetricsOnly)
+
+	_, err = Parse(kingpin.New("test", ""), []string{
+		"--log-level=loud",
+		"--domain-filter=not a domain",
+		"--log-sample-class-limit=unknown=10",
+		"--request-header=no colon",
+	})
+	assert.ErrorContains(t, err, "--log-level")
+	assert.ErrorContains(t, err, "--domain-filter")
+	assert.ErrorContains(t, err, "--api-key")
+	assert.ErrorContains(t, err, "--api-secret")
+	assert.ErrorContains(t, err, "--log-sample-class-limit")
+	assert.ErrorContains(t, err, "--request-header")
+}
+
+func TestParseReplay(t *testing.T) {
+	cfg, err := Parse(kingpin.New("test", ""), []string{
+		"replay", "changes.json",
+		"--domain-filter=example.com",
+		"--api-key=key",
+		"--api-secret=secret",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, CommandReplay, cfg.Command)
+	assert.Equal(t, "changes.json", cfg.ReplayFile)
+
+	_, err = Parse(kingpin.New("test", ""), []string{"replay", "--domain-filter=example.com"})
+	assert.Error(t, err)
+}
+
+func TestParseApplyTemplate(t *testing.T) {
+	cfg, err := Parse(kingpin.New("test", ""), []string{
+		"apply-template", "github-pages", "example.com", "--var=user=octocat", "--preview",
+		"--domain-filter=example.com",
+		"--api-key=key",
+		"--api-secret=secret",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, CommandApplyTemplate, cfg.Command)
+	assert.Equal(t, "github-pages", cfg.TemplateName)
+	assert.Equal(t, map[string]string{"user": "octocat"}, cfg.TemplateVars)
+	assert.True(t, cfg.TemplatePreview)
+
+	_, err = Parse(kingpin.New("test", ""), []string{"apply-template", "unknown", "example.com", "--domain-filter=example.com", "--api-key=key", "--api-secret=secret"})
+	assert.Error(t, err)
+	_, err = Parse(kingpin.New("test", ""), []string{"apply-template", "github-pages", "example.com", "--var=octocat", "--domain-filter=example.com", "--api-key=key", "--api-secret=secret"})
+	assert.ErrorContains(t, err, "--var")
+}
+
+func TestParseDecrypt(t *testing.T) {
+	// decrypting needs neither a domain filter nor API keys
+	cfg, err := Parse(kingpin.New("test", ""), []string{"decrypt", "changes.log", "--encryption-key-file=key"})
+	assert.NoError(t, err)
+	assert.Equal(t, CommandDecrypt, cfg.Command)
+	assert.Equal(t, "changes.log", cfg.DecryptFile)
+
+	_, err = Parse(kingpin.New("test", ""), []string{"decrypt", "changes.log"})
+	assert.ErrorContains(t, err, "--encryption-key-file")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Provider.DomainFilter = []string{"example.com.", "Bücher.example", "xn--bcher-kva.example"}
+	cfg.Provider.APIKey = "key"
+	cfg.Provider.APISecret = "secret"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MetricsListenAddress = cfg.ListenAddress
+	cfg.Provider.StaleAfter = 0
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "--metrics-listen-address")
+	assert.ErrorContains(t, err, "--stale-after")
+
+	// a token exchange replaces the API keys
+	cfg = Default()
+	cfg.Provider.DomainFilter = []string{"example.com"}
+	cfg.Provider.CredentialsURL = "https://vault.internal/porkbun"
+	assert.NoError(t, cfg.Validate())
+	cfg.Provider.CredentialsURL = "http://vault.internal/porkbun"
+	cfg.Provider.CredentialsCert = "client.pem"
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, "--credentials-url")
+	assert.ErrorContains(t, err, "--credentials-key")
+	assert.NotContains(t, err.Error(), "--api-key")
+
+	// so do domain-scoped keys per zone
+	cfg = Default()
+	cfg.Provider.DomainFilter = []string{"example.com"}
+	cfg.Provider.ZoneCredentialsFile = "zones.json"
+	assert.NoError(t, cfg.Validate())
+
+	cfg = Default()
+	cfg.Provider.DomainFilter = []string{"example.com"}
+	cfg.Provider.APIKey = "key"
+	cfg.Provider.APISecret = "secret"
+	cfg.Provider.BaseURLs = []string{"https://porkbun-proxy.internal/api/json/v3/", "api.porkbun.com"}
+	assert.ErrorContains(t, cfg.Validate(), `--api-url: "api.porkbun.com"`)
+
+	cfg.Provider.BaseURLs = nil
+	cfg.Provider.DeepHealthTimeout = 0
+	cfg.Provider.DeepHealthInterval = -time.Second
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, "--deep-health-timeout")
+	assert.ErrorContains(t, err, "--deep-health-interval")
+
+	cfg.Provider.DeepHealthTimeout = time.Second
+	cfg.Provider.DeepHealthInterval = 0
+	cfg.Provider.TXTPrefix = "reg-"
+	cfg.Provider.TXTSuffix = "-reg"
+	assert.ErrorContains(t, cfg.Validate(), "--txt-prefix and --txt-suffix are mutually exclusive")
+
+	cfg.Provider.TXTSuffix = ""
+	cfg.Provider.RecordsMaxAge = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "--records-max-age")
+
+	cfg.Provider.RecordsMaxAge = 0
+	cfg.Provider.MinTTL = 300
+	assert.ErrorContains(t, cfg.Validate(), "--min-ttl")
+
+	cfg.Provider.MinTTL = 600
+	cfg.Provider.DefaultTTL = 300
+	assert.ErrorContains(t, cfg.Validate(), "--default-ttl")
+	cfg.Provider.DefaultTTL = 3600
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Provider.CacheSnapshot = "configmap://dns/porkbun-cache"
+	assert.NoError(t, cfg.Validate())
+	cfg.Provider.CacheSnapshot = "gs://bucket/snapshot"
+	cfg.Provider.CacheSnapshotMaxAge = 0
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, "--cache-snapshot:")
+	assert.ErrorContains(t, err, "--cache-snapshot-max-age")
+
+	cfg.Provider.CacheSnapshot = ""
+	cfg.Provider.CacheSnapshotMaxAge = time.Minute
+	cfg.Provider.ManagedRecordTypes = []string{"a", "cname", "PTR"}
+	assert.ErrorContains(t, cfg.Validate(), `--managed-record-types: unsupported or duplicate record type "PTR"`)
+	cfg.Provider.ManagedRecordTypes = []string{"a", "cname", "txt"}
+	assert.NoError(t, cfg.Validate())
+	cfg.Provider.ExcludedRecordTypes = []string{"MX", "TXT"}
+	assert.ErrorContains(t, cfg.Validate(), `--excluded-record-types: record type "TXT" is also in --managed-record-types`)
+	cfg.Provider.ManagedRecordTypes = nil
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Provider.MaxDeletesPerSync = -1
+	assert.ErrorContains(t, cfg.Validate(), "--max-deletes-per-sync")
+	cfg.Provider.MaxDeletesPerSync = 0
+
+	cfg.Provider.RequestTimeout = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "--porkbun-request-timeout")
+	cfg.Provider.RequestTimeout = 0
+
+	cfg.Provider.TXTGCInterval = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "--txt-gc-interval")
+	cfg.Provider.TXTGCInterval = time.Hour
+	assert.ErrorContains(t, cfg.Validate(), "--txt-gc-owner-id: required with --txt-gc-interval")
+	cfg.Provider.TXTGCOwnerIDs = []string{"default"}
+	assert.NoError(t, cfg.Validate())
+	cfg.Provider.TXTGCInterval = 0
+
+	cfg.Provider.ChangeOrder = "create-first"
+	assert.ErrorContains(t, cfg.Validate(), "--change-order")
+	cfg.Provider.ChangeOrder = porkbun.ChangeOrderCreateFirst
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Provider.CutoverConfig = "cutover.json"
+	assert.ErrorContains(t, cfg.Validate(), "--cutover-config: requires --admin-username")
+	cfg.AdminUsername = "ops"
+	cfg.AdminPassword = "secret"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Provider.CacheMaxRecords = 1000
+	assert.NoError(t, cfg.Validate())
+	cfg.Provider.RecordsMaxPageSize = 100
+	assert.ErrorContains(t, cfg.Validate(), "--cache-max-records and --records-max-page-size are mutually exclusive")
+	cfg.Provider.CacheMaxRecords = 0
+	assert.NoError(t, cfg.Validate())
+}
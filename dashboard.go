@@ -0,0 +1,74 @@
+//go:build !lite
+
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+)
+
+// dashboardTemplate renders the status of the provider as a minimal HTML page for small installations without Grafana.
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+	"timestamp": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>external-dns-porkbun-webhook dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.degraded { background: #fdd; }
+.gone { color: #888; }
+</style>
+</head>
+<body>
+<h1>external-dns-porkbun-webhook</h1>
+<h2>Zones</h2>
+<table>
+<tr><th>Zone</th><th>Records</th><th>Last sync</th><th>State</th></tr>
+{{- range .Zones}}
+<tr{{if .Degraded}} class="degraded"{{else if .Gone}} class="gone"{{end}}>
+<td>{{.Zone}}</td><td>{{.Records}}</td><td title="{{if not .LastSync.IsZero}}{{timestamp .LastSync}}{{end}}">{{ago .LastSync}}</td>
+<td>{{if .Degraded}}degraded: {{.Degraded}}{{else if .Gone}}not in the Porkbun account{{else}}ok{{end}}</td>
+</tr>
+{{- end}}
+</table>
+<h2>Recent changes</h2>
+{{- if .RecentChanges}}
+<table>
+<tr><th>Time</th><th>Owner</th><th>Action</th><th>Name</th><th>Type</th><th>Content</th></tr>
+{{- range .RecentChanges}}
+<tr><td title="{{timestamp .Time}}">{{ago .Time}}</td><td>{{.Owner}}</td><td>{{.Action}}</td><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Content}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No changes since the start of the webhook.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// dashboardHandler serves the zones and the latest record changes of the provider as HTML.
+func dashboardHandler(pbProvider *porkbun.PorkbunProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, pbProvider.Status()); err != nil {
+			logger.Error("unable to render dashboard", "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+)
+
+// decrypt writes the file encrypted with the key in keyFile decrypted to out.
+func decrypt(path string, keyFile string, out io.Writer) error {
+	outputCipher, err := porkbun.LoadOutputCipher(keyFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return outputCipher.Decrypt(f, out)
+}
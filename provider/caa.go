@@ -0,0 +1,51 @@
+package porkbun
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// recordTypeCAA is the CAA record type, which external-dns has no constant for.
+const recordTypeCAA = "CAA"
+
+// caaTagRegexp matches the property tag of a CAA record, e.g. issue, issuewild or iodef.
+var caaTagRegexp = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// parseCAA parses a CAA value in zone file format, e.g. `0 issue "letsencrypt.org"`. The value may be quoted or not,
+// quoted values may contain spaces and escaped quotes.
+// returns false if the value is not a valid CAA value
+func parseCAA(target string) (flags uint8, tag string, value string, ok bool) {
+	fields := strings.SplitN(strings.TrimSpace(target), " ", 2)
+	if len(fields) != 2 {
+		return 0, "", "", false
+	}
+	parsed, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return 0, "", "", false
+	}
+	tag, value, _ = strings.Cut(strings.TrimSpace(fields[1]), " ")
+	if !caaTagRegexp.MatchString(tag) {
+		return 0, "", "", false
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+	return uint8(parsed), strings.ToLower(tag), value, true
+}
+
+// formatCAA formats a CAA value in zone file format with a lower case tag and the value in quotes.
+func formatCAA(flags uint8, tag string, value string) string {
+	return strconv.Itoa(int(flags)) + " " + tag + ` "` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// normalizeCAA returns a CAA value in the form formatCAA writes, so the targets of endpoints and records compare
+// equal however the value was quoted. Values that can't be parsed are returned unchanged.
+func normalizeCAA(target string) string {
+	flags, tag, value, ok := parseCAA(target)
+	if !ok {
+		return target
+	}
+	return formatCAA(flags, tag, value)
+}
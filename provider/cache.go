@@ -0,0 +1,162 @@
+package porkbun
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unsafe"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// zoneCache keeps the records last retrieved from Porkbun for every zone.
+type zoneCache struct {
+	mu    sync.RWMutex
+	zones map[string]cachedZone
+	// generation is increased with every change of the cached records
+	generation uint64
+	// maxRecords limits the records cached over all zones, 0 means unlimited
+	maxRecords int
+	// evicted holds the zones dropped to stay within maxRecords, they count as fetched
+	evicted map[string]bool
+}
+
+// cachedZone is a snapshot of the records of one zone.
+type cachedZone struct {
+	records   []pb.Record
+	fetchedAt time.Time
+}
+
+func newZoneCache() *zoneCache {
+	return &zoneCache{zones: map[string]cachedZone{}, evicted: map[string]bool{}}
+}
+
+// set replaces the cached records of a zone.
+// If the cache holds more than maxRecords records afterwards, the least recently synced other zones are evicted.
+// The zone just set is always kept, even if it exceeds the limit on its own.
+// returns the evicted zones
+func (c *zoneCache) set(zone string, records []pb.Record, fetchedAt time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.zones[zone] = cachedZone{records: records, fetchedAt: fetchedAt}
+	delete(c.evicted, zone)
+	c.generation++
+
+	var evicted []string
+	for c.maxRecords > 0 && c.recordCount() > c.maxRecords {
+		oldest := ""
+		for name, z := range c.zones {
+			if name != zone && (oldest == "" || z.fetchedAt.Before(c.zones[oldest].fetchedAt)) {
+				oldest = name
+			}
+		}
+		if oldest == "" {
+			break
+		}
+		delete(c.zones, oldest)
+		c.evicted[oldest] = true
+		evicted = append(evicted, oldest)
+	}
+	cacheEvictionsTotal.Add(float64(len(evicted)))
+	c.updateMetrics()
+	return evicted
+}
+
+// recordCount returns the number of cached records over all zones, the caller must hold the lock.
+func (c *zoneCache) recordCount() int {
+	count := 0
+	for _, z := range c.zones {
+		count += len(z.records)
+	}
+	return count
+}
+
+// updateMetrics reports the size of the cache, the caller must hold the lock.
+func (c *zoneCache) updateMetrics() {
+	size := 0
+	for _, z := range c.zones {
+		for _, rec := range z.records {
+			size += recordSize(rec)
+		}
+	}
+	cacheRecords.Set(float64(c.recordCount()))
+	cacheBytes.Set(float64(size))
+}
+
+// recordSize estimates the memory held by a cached record.
+func recordSize(rec pb.Record) int {
+	return int(unsafe.Sizeof(rec)) + len(rec.ID) + len(rec.Name) + len(rec.Type) + len(rec.Content) + len(rec.TTL) + len(rec.Prio) + len(rec.Notes)
+}
+
+// get returns the cached records of a zone.
+// returns false if the zone has not been fetched yet
+func (c *zoneCache) get(zone string) (cachedZone, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	z, ok := c.zones[zone]
+	return z, ok
+}
+
+// complete reports whether every given zone has been fetched at least once.
+func (c *zoneCache) complete(zones []string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, zone := range zones {
+		if _, ok := c.zones[zone]; !ok && !c.evicted[zone] {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns the cached records of the zones together with the generation of the cache they were read from.
+// returns false if a zone has not been fetched yet or was evicted
+func (c *zoneCache) snapshot(zones []string) (map[string]cachedZone, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := make(map[string]cachedZone, len(zones))
+	for _, zone := range zones {
+		z, ok := c.zones[zone]
+		if !ok {
+			return nil, 0, false
+		}
+		snapshot[zone] = z
+	}
+	return snapshot, c.generation, true
+}
+
+// oldest returns when the least recently fetched of the zones was fetched.
+// returns false if a zone has not been fetched yet or was evicted
+func (c *zoneCache) oldest(zones []string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var oldest time.Time
+	for _, zone := range zones {
+		z, ok := c.zones[zone]
+		if !ok {
+			return time.Time{}, false
+		}
+		if oldest.IsZero() || z.fetchedAt.Before(oldest) {
+			oldest = z.fetchedAt
+		}
+	}
+	return oldest, len(zones) > 0
+}
+
+// ages returns for every cached zone how long ago it was fetched.
+func (c *zoneCache) ages(now time.Time) map[string]time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ages := make(map[string]time.Duration, len(c.zones))
+	for zone, z := range c.zones {
+		ages[zone] = now.Sub(z.fetchedAt)
+	}
+	return ages
+}
+
+// cacheZone caches the records of a zone and logs the zones evicted to make room for them.
+func (p *PorkbunProvider) cacheZone(ctx context.Context, zone string, records []pb.Record) {
+	for _, evicted := range p.cache.set(zone, records, p.clock.Now()) {
+		p.logger.WarnContext(ctx, "cached records limit reached - evicted least recently synced zone", "zone", evicted, "limit", p.cache.maxRecords)
+	}
+}
@@ -0,0 +1,77 @@
+package porkbun
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// backPressureFactor stretches the announced freshness lifetime of record listings while the API usage of a
+	// zone is at the warning threshold, so clients that honour it list less often.
+	backPressureFactor = 4
+	// backPressureMaxAge is the least freshness lifetime announced while the API usage is at the warning threshold.
+	backPressureMaxAge = time.Minute
+)
+
+// RecordsCacheHeaders wraps the records handler and answers record listings with the Age header, the seconds since
+// the least recently fetched zone of the served view was listed from Porkbun, and a Cache-Control header with the
+// freshness lifetime configured by WithRecordsMaxAge. While the API calls of a zone are at the warning threshold,
+// a longer lifetime is announced. Listings that could not be served from complete zones are marked no-store.
+func (p *PorkbunProvider) RecordsCacheHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+		next(&cacheHeaderWriter{ResponseWriter: w, p: p}, r)
+	}
+}
+
+// cacheHeaderWriter sets the cache headers right before the status is written, once the listing refreshed the cache.
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	p           *PorkbunProvider
+	wroteHeader bool
+}
+
+func (w *cacheHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK {
+			w.p.setCacheHeaders(w.Header())
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// setCacheHeaders sets the Age and Cache-Control headers of a record listing from the freshness of the zone cache.
+func (p *PorkbunProvider) setCacheHeaders(header http.Header) {
+	fetchedAt, ok := p.cache.oldest(p.activeZones(p.domainFilter.Zones()))
+	if !ok || p.dryRun {
+		header.Set("Cache-Control", "no-store")
+		return
+	}
+	age := max(p.clock.Now().Sub(fetchedAt), 0)
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+
+	maxAge := p.recordsMaxAge
+	if p.usage.atThreshold() {
+		maxAge = max(maxAge*backPressureFactor, backPressureMaxAge)
+	}
+	if maxAge < time.Second {
+		header.Set("Cache-Control", "no-cache")
+		return
+	}
+	header.Set("Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds())))
+}
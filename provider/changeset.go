@@ -0,0 +1,21 @@
+package porkbun
+
+import (
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// observeChangeSet records the shape of the changes of a sync: the number of endpoints to create, update and delete
+// and the number of zones they touch, so rate limits and batch thresholds can be sized from the real change patterns.
+func observeChangeSet(changes *plan.Changes, perZoneChanges map[string]*plan.Changes) {
+	changeSetSize.WithLabelValues("create").Observe(float64(len(changes.Create)))
+	changeSetSize.WithLabelValues("update").Observe(float64(len(changes.UpdateNew)))
+	changeSetSize.WithLabelValues("delete").Observe(float64(len(changes.Delete)))
+
+	touched := 0
+	for _, c := range perZoneChanges {
+		if c.HasChanges() {
+			touched++
+		}
+	}
+	zonesPerSync.Observe(float64(touched))
+}
@@ -0,0 +1,57 @@
+package porkbun
+
+import (
+	"context"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// porkbunClient is the part of the Porkbun API client used by the provider.
+type porkbunClient interface {
+	Ping(ctx context.Context) (string, error)
+	CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error)
+	EditRecord(ctx context.Context, domain string, id int, record pb.Record) error
+	DeleteRecord(ctx context.Context, domain string, id int) error
+	RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error)
+}
+
+// meteredClient counts every API call per zone before handing it to the wrapped client.
+type meteredClient struct {
+	client porkbunClient
+	usage  *apiUsage
+}
+
+func (c *meteredClient) Ping(ctx context.Context) (string, error) {
+	c.usage.record(ctx, accountZone, "ping")
+	return c.client.Ping(ctx)
+}
+
+func (c *meteredClient) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	c.usage.record(ctx, domain, "create")
+	return c.client.CreateRecord(ctx, domain, record)
+}
+
+func (c *meteredClient) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	c.usage.record(ctx, domain, "edit")
+	return c.client.EditRecord(ctx, domain, id, record)
+}
+
+func (c *meteredClient) DeleteRecord(ctx context.Context, domain string, id int) error {
+	c.usage.record(ctx, domain, "delete")
+	return c.client.DeleteRecord(ctx, domain, id)
+}
+
+func (c *meteredClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	c.usage.record(ctx, domain, "retrieve")
+	return c.client.RetrieveRecords(ctx, domain)
+}
+
+// retrieveRecords retrieves the records of the zone with their names fully qualified and normalized with listedName,
+// so the rest of the provider compares names in one form however Porkbun returned them.
+func (p *PorkbunProvider) retrieveRecords(ctx context.Context, zone string) ([]pb.Record, error) {
+	recs, err := p.client.RetrieveRecords(ctx, zone)
+	for i := range recs {
+		recs[i].Name = listedName(recs[i].Name, zone)
+	}
+	return recs, err
+}
@@ -0,0 +1,32 @@
+package porkbun
+
+import "time"
+
+// Clock provides the current time to all time based features, tests replace it to control time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock backed by the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// defaultClockSkewTolerance is how far timestamps written by other replicas may lie in the future without a warning.
+const defaultClockSkewTolerance = time.Minute
+
+// since returns the time elapsed since t.
+// Timestamps in the future are the result of clock skew between replicas or hosts; they count as just written,
+// and a warning is logged if they lie beyond the tolerated skew.
+func (p *PorkbunProvider) since(t time.Time) time.Duration {
+	elapsed := p.clock.Now().Sub(t)
+	if elapsed >= 0 {
+		return elapsed
+	}
+	if -elapsed > p.clockSkewTolerance {
+		p.logger.Warn("timestamp lies in the future, check the clock synchronization", "timestamp", t, "skew", -elapsed)
+	}
+	return 0
+}
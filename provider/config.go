@@ -0,0 +1,431 @@
+package porkbun
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// domainRegexp matches a domain name made of valid DNS labels.
+var domainRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9-]{2,63}$`)
+
+// Config holds all settings of the provider. It can be built programmatically,
+// the command line is parsed into it by the config package.
+type Config struct {
+	DomainFilter           []string
+	ExcludeDomains         []string
+	DryRun                 bool
+	APIKey                 string
+	APISecret              string
+	WarmupTimeout          time.Duration
+	APICallsWarnPerHour    int
+	ConversionPlugins      []string
+	RecordsMaxPageSize     int
+	ClockSkewTolerance     time.Duration
+	LogSampleLimit         int
+	LogSampleClassLimits   map[string]int
+	ClusterID              string
+	RequestHeaders         http.Header
+	StaleAfter             time.Duration
+	CNAMETargetCheck       string
+	RecordTypeOrder        []string
+	CacheMaxRecords        int
+	MaxResponseBytes       int64
+	MaxCreatesPerSync      int
+	MaxDeletesPerSync      int
+	VerifyResolvers        []string
+	VerifyConsensus        float64
+	VerifyWindow           time.Duration
+	CredentialsURL         string
+	CredentialsTokenFile   string
+	CredentialsCert        string
+	CredentialsKey         string
+	CredentialsCA          string
+	CredentialsRefresh     time.Duration
+	ApplyConcurrency       int
+	ChangeOrder            string
+	BaseURLs               []string
+	ZoneLockRecord         string
+	ZoneLockTTL            time.Duration
+	SyncRecord             string
+	EmptyTargets           string
+	EncryptionKeyFile      string
+	ApexAlias              bool
+	CutoverConfig          string
+	ChangeLogFile          string
+	ManageNSRecords        bool
+	OwnershipGuard         bool
+	DeepHealthTimeout      time.Duration
+	DeepHealthInterval     time.Duration
+	TXTPrefix              string
+	TXTSuffix              string
+	TXTWildcardReplacement string
+	ZoneCredentialsFile    string
+	RecordsMaxAge          time.Duration
+	MinTTL                 int64
+	DefaultTTL             int64
+	CacheSnapshot          string
+	CacheSnapshotMaxAge    time.Duration
+	CacheSnapshotInterval  time.Duration
+	ManagedRecordTypes     []string
+	ExcludedRecordTypes    []string
+	RetryPolicyFile        string
+	RequestTimeout         time.Duration
+	RollbackOnFailure      bool
+	TXTGCInterval          time.Duration
+	TXTGCOwnerIDs          []string
+}
+
+// DefaultConfig returns the provider settings with all defaults applied.
+// DomainFilter, APIKey and APISecret have no defaults and must be set,
+// the API keys only if no CredentialsURL is set.
+func DefaultConfig() Config {
+	return Config{
+		WarmupTimeout:         defaultWarmupTimeout,
+		APICallsWarnPerHour:   defaultAPICallWarningThreshold,
+		ClockSkewTolerance:    defaultClockSkewTolerance,
+		LogSampleClassLimits:  map[string]int{},
+		RequestHeaders:        http.Header{},
+		StaleAfter:            defaultStaleAfter,
+		CNAMETargetCheck:      TargetCheckOff,
+		EmptyTargets:          EmptyTargetsSkip,
+		ChangeOrder:           ChangeOrderDeleteFirst,
+		VerifyConsensus:       1,
+		VerifyWindow:          defaultVerifyWindow,
+		CredentialsRefresh:    defaultCredentialsRefresh,
+		ZoneLockTTL:           defaultZoneLockTTL,
+		DeepHealthTimeout:     defaultDeepHealthTimeout,
+		DeepHealthInterval:    defaultDeepHealthInterval,
+		MinTTL:                porkbunMinTTL,
+		CacheSnapshotMaxAge:   defaultSnapshotMaxAge,
+		CacheSnapshotInterval: defaultSnapshotInterval,
+		RequestTimeout:        defaultRequestTimeout,
+	}
+}
+
+// Validate checks the settings as a whole, so that every problem is reported at once
+// instead of failing on the first invalid or missing setting.
+// Errors name the command line flag of the setting.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if len(c.DomainFilter) == 0 {
+		errs = append(errs, errors.New("--domain-filter: at least one domain is required"))
+	}
+	for _, domain := range c.DomainFilter {
+		if !domainRegexp.MatchString(normalizeName(domain)) {
+			errs = append(errs, fmt.Errorf("--domain-filter: %q is not a valid domain name", domain))
+		}
+	}
+	for _, domain := range c.ExcludeDomains {
+		if !domainRegexp.MatchString(normalizeName(strings.TrimPrefix(domain, "."))) {
+			errs = append(errs, fmt.Errorf("--exclude-domains: %q is not a valid domain name", domain))
+		}
+	}
+
+	if c.CredentialsURL == "" && c.ZoneCredentialsFile == "" {
+		if c.APIKey == "" {
+			errs = append(errs, errors.New("--api-key: an API key is required unless --credentials-url or --zone-credentials-file is set"))
+		}
+		if c.APISecret == "" {
+			errs = append(errs, errors.New("--api-secret: an API secret is required unless --credentials-url or --zone-credentials-file is set"))
+		}
+	} else if c.CredentialsURL != "" && !strings.HasPrefix(c.CredentialsURL, "https://") {
+		errs = append(errs, fmt.Errorf("--credentials-url: must be an https:// URL, got %q", c.CredentialsURL))
+	}
+	if (c.CredentialsCert == "") != (c.CredentialsKey == "") {
+		errs = append(errs, errors.New("--credentials-cert and --credentials-key must be set together"))
+	}
+	if c.CredentialsRefresh <= 0 {
+		errs = append(errs, fmt.Errorf("--credentials-refresh: must be positive, got %s", c.CredentialsRefresh))
+	}
+
+	if c.WarmupTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--warmup-timeout: must not be negative, got %s", c.WarmupTimeout))
+	}
+	if c.APICallsWarnPerHour < 0 {
+		errs = append(errs, fmt.Errorf("--api-calls-warn-per-hour: must not be negative, got %d", c.APICallsWarnPerHour))
+	}
+	if c.RecordsMaxPageSize < 0 {
+		errs = append(errs, fmt.Errorf("--records-max-page-size: must not be negative, got %d", c.RecordsMaxPageSize))
+	}
+	if c.ClockSkewTolerance < 0 {
+		errs = append(errs, fmt.Errorf("--clock-skew-tolerance: must not be negative, got %s", c.ClockSkewTolerance))
+	}
+	if c.LogSampleLimit < 0 {
+		errs = append(errs, fmt.Errorf("--log-sample-limit: must not be negative, got %d", c.LogSampleLimit))
+	}
+	for class, limit := range c.LogSampleClassLimits {
+		if !isLogClass(class) {
+			errs = append(errs, fmt.Errorf("--log-sample-class-limit: unknown log class '%s'", class))
+		}
+		if limit < 0 {
+			errs = append(errs, fmt.Errorf("--log-sample-class-limit: limit of %s must not be negative, got %d", class, limit))
+		}
+	}
+	if c.StaleAfter <= 0 {
+		errs = append(errs, fmt.Errorf("--stale-after: must be positive, got %s", c.StaleAfter))
+	}
+	switch c.CNAMETargetCheck {
+	case TargetCheckOff, TargetCheckWarn, TargetCheckBlock:
+	default:
+		errs = append(errs, fmt.Errorf("--cname-target-check: must be one of %s, %s, %s, got %q", TargetCheckOff, TargetCheckWarn, TargetCheckBlock, c.CNAMETargetCheck))
+	}
+	switch c.EmptyTargets {
+	case EmptyTargetsSkip, EmptyTargetsDelete:
+	default:
+		errs = append(errs, fmt.Errorf("--empty-targets: must be one of %s, %s, got %q", EmptyTargetsSkip, EmptyTargetsDelete, c.EmptyTargets))
+	}
+	switch c.ChangeOrder {
+	case ChangeOrderDeleteFirst, ChangeOrderCreateFirst:
+	default:
+		errs = append(errs, fmt.Errorf("--change-order: must be one of %s, %s, got %q", ChangeOrderDeleteFirst, ChangeOrderCreateFirst, c.ChangeOrder))
+	}
+	if c.CacheMaxRecords < 0 {
+		errs = append(errs, fmt.Errorf("--cache-max-records: must not be negative, got %d", c.CacheMaxRecords))
+	}
+	// Paginated listings are served from the cache, which never holds all zones once zones are evicted
+	if c.CacheMaxRecords > 0 && c.RecordsMaxPageSize > 0 {
+		errs = append(errs, errors.New("--cache-max-records and --records-max-page-size are mutually exclusive, paginated listings need all zones cached"))
+	}
+	if c.MaxResponseBytes < 0 {
+		errs = append(errs, fmt.Errorf("--max-response-bytes: must not be negative, got %d", c.MaxResponseBytes))
+	}
+	for _, baseURL := range c.BaseURLs {
+		if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("--api-url: %q is not an http(s) URL", baseURL))
+		}
+	}
+	if c.ZoneLockTTL <= 0 {
+		errs = append(errs, fmt.Errorf("--zone-lock-ttl: must be positive, got %s", c.ZoneLockTTL))
+	}
+	if c.DeepHealthTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--deep-health-timeout: must be positive, got %s", c.DeepHealthTimeout))
+	}
+	if c.DeepHealthInterval < 0 {
+		errs = append(errs, fmt.Errorf("--deep-health-interval: must not be negative, got %s", c.DeepHealthInterval))
+	}
+	if c.TXTGCInterval < 0 {
+		errs = append(errs, fmt.Errorf("--txt-gc-interval: must not be negative, got %s", c.TXTGCInterval))
+	}
+	if len(c.ConversionPlugins) > 0 && !pluginsSupported {
+		errs = append(errs, fmt.Errorf("--conversion-plugin: %w", errPluginsUnsupported))
+	}
+	if c.TXTGCInterval > 0 && len(c.TXTGCOwnerIDs) == 0 {
+		errs = append(errs, errors.New("--txt-gc-owner-id: required with --txt-gc-interval, only registry TXT records of these owners are collected"))
+	}
+	if c.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--porkbun-request-timeout: must not be negative, got %s", c.RequestTimeout))
+	}
+	if c.RecordsMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("--records-max-age: must not be negative, got %s", c.RecordsMaxAge))
+	}
+	if c.MinTTL < porkbunMinTTL {
+		errs = append(errs, fmt.Errorf("--min-ttl: must be at least the Porkbun minimum of %d, got %d", porkbunMinTTL, c.MinTTL))
+	}
+	if c.DefaultTTL != 0 && c.DefaultTTL < c.MinTTL {
+		errs = append(errs, fmt.Errorf("--default-ttl: must be 0 or at least --min-ttl of %d, got %d", c.MinTTL, c.DefaultTTL))
+	}
+	if c.CacheSnapshot != "" {
+		if _, err := parseSnapshotLocation(c.CacheSnapshot); err != nil {
+			errs = append(errs, fmt.Errorf("--cache-snapshot: %v", err))
+		}
+	}
+	if c.CacheSnapshotMaxAge <= 0 {
+		errs = append(errs, fmt.Errorf("--cache-snapshot-max-age: must be positive, got %s", c.CacheSnapshotMaxAge))
+	}
+	if c.CacheSnapshotInterval < 0 {
+		errs = append(errs, fmt.Errorf("--cache-snapshot-interval: must not be negative, got %s", c.CacheSnapshotInterval))
+	}
+	if c.TXTPrefix != "" && c.TXTSuffix != "" {
+		errs = append(errs, errors.New("--txt-prefix and --txt-suffix are mutually exclusive"))
+	}
+	if c.ApplyConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("--apply-concurrency: must not be negative, got %d", c.ApplyConcurrency))
+	}
+	if c.MaxCreatesPerSync < 0 {
+		errs = append(errs, fmt.Errorf("--max-creates-per-sync: must not be negative, got %d", c.MaxCreatesPerSync))
+	}
+	if c.MaxDeletesPerSync < 0 {
+		errs = append(errs, fmt.Errorf("--max-deletes-per-sync: must not be negative, got %d", c.MaxDeletesPerSync))
+	}
+	for _, spec := range c.VerifyResolvers {
+		if _, err := ParseResolvers(spec); err != nil {
+			errs = append(errs, fmt.Errorf("--verify-resolver: %v", err))
+		}
+	}
+	if c.VerifyConsensus <= 0 || c.VerifyConsensus > 1 {
+		errs = append(errs, fmt.Errorf("--verify-consensus: must be greater than 0 and at most 1, got %g", c.VerifyConsensus))
+	}
+	if c.VerifyWindow <= 0 {
+		errs = append(errs, fmt.Errorf("--verify-window: must be positive, got %s", c.VerifyWindow))
+	}
+	managed := map[string]bool{}
+	for _, recordType := range c.ManagedRecordTypes {
+		recordType = strings.ToUpper(strings.TrimSpace(recordType))
+		if !slices.Contains(porkbunRecordTypes, recordType) || managed[recordType] {
+			errs = append(errs, fmt.Errorf("--managed-record-types: unsupported or duplicate record type %q, must be one of %s", recordType, strings.Join(porkbunRecordTypes, ", ")))
+		}
+		managed[recordType] = true
+	}
+	excluded := map[string]bool{}
+	for _, recordType := range c.ExcludedRecordTypes {
+		recordType = strings.ToUpper(strings.TrimSpace(recordType))
+		if !slices.Contains(porkbunRecordTypes, recordType) || excluded[recordType] {
+			errs = append(errs, fmt.Errorf("--excluded-record-types: unsupported or duplicate record type %q, must be one of %s", recordType, strings.Join(porkbunRecordTypes, ", ")))
+		}
+		if managed[recordType] {
+			errs = append(errs, fmt.Errorf("--excluded-record-types: record type %q is also in --managed-record-types", recordType))
+		}
+		excluded[recordType] = true
+	}
+	seen := map[string]bool{}
+	for _, recordType := range c.RecordTypeOrder {
+		recordType = strings.ToUpper(strings.TrimSpace(recordType))
+		if recordType == "" || seen[recordType] {
+			errs = append(errs, fmt.Errorf("--record-type-order: empty or duplicate record type %q", recordType))
+		}
+		seen[recordType] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewPorkbunProviderFromConfig creates a new provider from validated settings,
+// including the conversion hooks loaded from the configured plugins.
+func NewPorkbunProviderFromConfig(cfg Config, logger *slog.Logger) (*PorkbunProvider, error) {
+	var hooks []ConversionHook
+	for _, path := range cfg.ConversionPlugins {
+		hook, err := LoadConversionHookPlugin(path)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded conversion plugin", "path", path)
+		hooks = append(hooks, hook)
+	}
+
+	var resolvers []Resolver
+	for _, spec := range cfg.VerifyResolvers {
+		parsed, err := ParseResolvers(spec)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, parsed...)
+	}
+
+	var cutoverGroups []CutoverGroup
+	if cfg.CutoverConfig != "" {
+		groups, err := LoadCutoverGroups(cfg.CutoverConfig)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded cutover groups", "path", cfg.CutoverConfig, "groups", len(groups))
+		cutoverGroups = groups
+	}
+
+	var zoneCredentials []ZoneCredentials
+	if cfg.ZoneCredentialsFile != "" {
+		credentials, err := LoadZoneCredentials(cfg.ZoneCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded zone credentials", "path", cfg.ZoneCredentialsFile, "zones", len(credentials))
+		zoneCredentials = credentials
+	}
+
+	var retryPolicies RetryPolicies
+	if cfg.RetryPolicyFile != "" {
+		policies, err := LoadRetryPolicies(cfg.RetryPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded retry policies", "path", cfg.RetryPolicyFile)
+		retryPolicies = policies
+	}
+
+	opts := []Option{
+		WithStaleAfter(cfg.StaleAfter),
+		WithAPICallWarningThreshold(cfg.APICallsWarnPerHour),
+		WithConversionHooks(hooks...),
+		WithMaxPageSize(cfg.RecordsMaxPageSize),
+		WithClockSkewTolerance(cfg.ClockSkewTolerance),
+		WithLogSampling(cfg.LogSampleLimit, cfg.LogSampleClassLimits),
+		WithClusterID(cfg.ClusterID),
+		WithRequestHeaders(cfg.RequestHeaders),
+		WithCNAMETargetCheck(cfg.CNAMETargetCheck),
+		WithEmptyTargets(cfg.EmptyTargets),
+		WithTypeOrder(cfg.RecordTypeOrder...),
+		WithManagedTypes(cfg.ManagedRecordTypes...),
+		WithExcludedTypes(cfg.ExcludedRecordTypes...),
+		WithCacheLimit(cfg.CacheMaxRecords),
+		WithMaxResponseSize(cfg.MaxResponseBytes),
+		WithMaxCreatesPerSync(cfg.MaxCreatesPerSync),
+		WithMaxDeletesPerSync(cfg.MaxDeletesPerSync),
+		WithApplyConcurrency(cfg.ApplyConcurrency),
+		WithChangeOrder(cfg.ChangeOrder),
+		WithBaseURLs(cfg.BaseURLs...),
+		WithZoneLock(cfg.ZoneLockRecord, cfg.ZoneLockTTL),
+		WithSyncRecord(cfg.SyncRecord),
+		WithApexAlias(cfg.ApexAlias),
+		WithCutoverGroups(cutoverGroups...),
+		WithManageNS(cfg.ManageNSRecords),
+		WithOwnershipGuard(cfg.OwnershipGuard),
+		WithDeepHealthCheck(cfg.DeepHealthTimeout, cfg.DeepHealthInterval),
+		WithExcludeDomains(cfg.ExcludeDomains...),
+		WithTXTRegistry(cfg.TXTPrefix, cfg.TXTSuffix, cfg.TXTWildcardReplacement),
+		WithTXTGC(cfg.TXTGCInterval, cfg.TXTGCOwnerIDs...),
+		WithZoneCredentials(zoneCredentials...),
+		WithRetryPolicies(retryPolicies),
+		WithRequestTimeout(cfg.RequestTimeout),
+		WithRollbackOnFailure(cfg.RollbackOnFailure),
+		WithRecordsMaxAge(cfg.RecordsMaxAge),
+		WithMinTTL(cfg.MinTTL),
+		WithDefaultTTL(cfg.DefaultTTL),
+		WithPropagationCheck(cfg.VerifyConsensus, cfg.VerifyWindow, resolvers...),
+	}
+	if cfg.EncryptionKeyFile != "" {
+		outputCipher, err := LoadOutputCipher(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded output encryption key", "path", cfg.EncryptionKeyFile)
+		opts = append(opts, WithOutputEncryption(outputCipher))
+	}
+	if cfg.CacheSnapshot != "" {
+		store, err := NewSnapshotStore(cfg.CacheSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithCacheSnapshots(store, cfg.CacheSnapshotMaxAge, cfg.CacheSnapshotInterval))
+	}
+	if cfg.ChangeLogFile != "" {
+		file, err := os.OpenFile(cfg.ChangeLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open change log file: %v", err)
+		}
+		opts = append(opts, WithChangeLog(file))
+	}
+	if cfg.CredentialsURL != "" {
+		source, err := NewTokenExchange(TokenExchangeConfig{
+			URL:        cfg.CredentialsURL,
+			TokenFile:  cfg.CredentialsTokenFile,
+			ClientCert: cfg.CredentialsCert,
+			ClientKey:  cfg.CredentialsKey,
+			CA:         cfg.CredentialsCA,
+			Refresh:    cfg.CredentialsRefresh,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithCredentialsSource(source))
+	}
+
+	return NewPorkbunProvider(&cfg.DomainFilter, cfg.APIKey, cfg.APISecret, cfg.DryRun, logger, opts...)
+}
@@ -0,0 +1,51 @@
+package porkbun
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// exclusiveType reports whether a record of the type can't share its name with records of other types. Porkbun
+// flattens ALIAS records, so unlike a CNAME an ALIAS at the apex lives next to the NS, MX and TXT records there.
+func exclusiveType(recordType string) bool {
+	return recordType == endpoint.RecordTypeCNAME
+}
+
+// aliasType reports whether a record of the type points its name at another name, as CNAME and ALIAS records do.
+// Their changes are ordered together with the changes at their name and at their target.
+func aliasType(recordType string) bool {
+	return recordType == endpoint.RecordTypeCNAME || recordType == recordTypeALIAS
+}
+
+// findConflicts checks the records to create against the existing records of the zone, so a create blocked by
+// another record at the same name (e.g. a CNAME when creating an A record) fails with an error naming the blocking
+// record instead of Porkbun's generic failure. Existing records deleted or updated by the same changes are ignored.
+// returns nil if no record is blocked
+func findConflicts(zone string, creates []pb.Record, recs []pb.Record, removed ...[]pb.Record) error {
+	ignored := map[string]bool{}
+	for _, records := range removed {
+		for _, rec := range records {
+			if rec.ID != "" {
+				ignored[rec.ID] = true
+			}
+		}
+	}
+
+	var errs []error
+	for _, record := range creates {
+		fqdn := recordFQDN(record.Name, zone)
+		for _, rec := range recs {
+			if ignored[rec.ID] || normalizeName(rec.Name) != fqdn || rec.Type == record.Type {
+				continue
+			}
+			if exclusiveType(record.Type) || exclusiveType(rec.Type) {
+				errs = append(errs, fmt.Errorf("cannot create %s %s in zone '%s': %w: %s %s -> %s (ID %s)",
+					record.Type, fqdn, zone, errRecordConflict, rec.Type, fqdn, rec.Content, rec.ID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
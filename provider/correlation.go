@@ -0,0 +1,93 @@
+package porkbun
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// CorrelationHeader carries the ID correlating a sync across external-dns, this webhook and Porkbun.
+const CorrelationHeader = "X-Correlation-ID"
+
+// correlationIDRegexp matches correlation IDs accepted from clients.
+var correlationIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type correlationIDKey struct{}
+
+// withCorrelationID returns a context carrying the correlation ID.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// correlationID returns the correlation ID carried by the context.
+// returns empty string if there is none
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// newCorrelationID generates a random correlation ID.
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// correlationHandler adds the correlation ID and the external-dns owner ID of the context to every log line.
+type correlationHandler struct {
+	slog.Handler
+}
+
+func (h correlationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := correlationID(ctx); id != "" {
+		r.AddAttrs(slog.String("correlationID", id))
+	}
+	if owner := ownerID(ctx); owner != "" {
+		r.AddAttrs(slog.String("owner", owner))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return correlationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h correlationHandler) WithGroup(name string) slog.Handler {
+	return correlationHandler{h.Handler.WithGroup(name)}
+}
+
+// correlatedProvider runs every call of the provider with the correlation ID of a single webhook request,
+// since the external-dns webhook handlers call the provider without the request context.
+type correlatedProvider struct {
+	*PorkbunProvider
+	id string
+}
+
+func (c correlatedProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return c.PorkbunProvider.Records(withCorrelationID(ctx, c.id))
+}
+
+func (c correlatedProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	return c.PorkbunProvider.ApplyChanges(withCorrelationID(ctx, c.id), changes)
+}
+
+// Correlate assigns every request a correlation ID, taken from the X-Correlation-ID header or generated,
+// and answers with it in the same header. handler builds the handler serving the request from a provider
+// that carries the ID into all logs, metric exemplars and Porkbun API calls of the request.
+func (p *PorkbunProvider) Correlate(handler func(provider.Provider) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationHeader)
+		if !correlationIDRegexp.MatchString(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(CorrelationHeader, id)
+		handler(correlatedProvider{PorkbunProvider: p, id: id})(w, r.WithContext(withCorrelationID(r.Context(), id)))
+	}
+}
@@ -0,0 +1,222 @@
+package porkbun
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCredentialsRefresh = 15 * time.Minute
+	// credentialsExpiryMargin is how long before their expiry short-lived credentials are refreshed.
+	credentialsExpiryMargin = time.Minute
+)
+
+// CredentialsSource supplies the Porkbun API keys for every API call, e.g. short-lived keys issued by a secrets proxy.
+type CredentialsSource interface {
+	Credentials(ctx context.Context) (apiKey string, apiSecret string, err error)
+}
+
+// credentialsResponse is the answer of a token exchange endpoint.
+type credentialsResponse struct {
+	APIKey    string    `json:"apiKey"`
+	APISecret string    `json:"secretApiKey"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// tokenExchange obtains the API keys from an HTTPS endpoint, authenticated with a client certificate and/or
+// a JWT read from a file, and refreshes them on schedule. If a refresh fails, the previous keys are used
+// until they expire.
+type tokenExchange struct {
+	url        string
+	tokenFile  string
+	refresh    time.Duration
+	httpClient *http.Client
+	clock      Clock
+	logger     *slog.Logger
+
+	mu        sync.Mutex
+	apiKey    string
+	apiSecret string
+	refreshAt time.Time
+	expiresAt time.Time
+}
+
+// TokenExchangeConfig configures the token exchange obtaining the Porkbun API keys.
+type TokenExchangeConfig struct {
+	// URL of the HTTPS endpoint answering with apiKey, secretApiKey and optionally expiresAt as JSON.
+	URL string
+	// TokenFile holds a JWT sent as bearer token, it is read again for every exchange so rotated tokens are picked up.
+	TokenFile string
+	// ClientCert and ClientKey are the PEM files of the client certificate for mTLS.
+	ClientCert string
+	ClientKey  string
+	// CA is the PEM file of the certificate authorities trusted for the endpoint, the system pool if empty.
+	CA string
+	// Refresh is the interval in which the keys are exchanged again, earlier if they expire before.
+	Refresh time.Duration
+}
+
+// NewTokenExchange creates a credentials source obtaining the API keys from a token exchange endpoint.
+func NewTokenExchange(cfg TokenExchangeConfig, logger *slog.Logger) (CredentialsSource, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CA != "" {
+		pem, err := os.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", cfg.CA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	refresh := cfg.Refresh
+	if refresh <= 0 {
+		refresh = defaultCredentialsRefresh
+	}
+	return &tokenExchange{
+		url:       cfg.URL,
+		tokenFile: cfg.TokenFile,
+		refresh:   refresh,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		clock:  systemClock{},
+		logger: logger,
+	}, nil
+}
+
+func (t *tokenExchange) Credentials(ctx context.Context) (string, string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	if t.apiKey != "" && now.Before(t.refreshAt) {
+		return t.apiKey, t.apiSecret, nil
+	}
+
+	resp, err := t.exchange(ctx)
+	if err != nil {
+		if t.apiKey != "" && (t.expiresAt.IsZero() || now.Before(t.expiresAt)) {
+			t.logger.WarnContext(ctx, "unable to refresh Porkbun API keys, using the previous keys", "error", err.Error())
+			return t.apiKey, t.apiSecret, nil
+		}
+		return "", "", fmt.Errorf("unable to obtain Porkbun API keys: %v", err)
+	}
+
+	t.apiKey, t.apiSecret, t.expiresAt = resp.APIKey, resp.APISecret, resp.ExpiresAt
+	t.refreshAt = now.Add(t.refresh)
+	if !t.expiresAt.IsZero() && t.expiresAt.Add(-credentialsExpiryMargin).Before(t.refreshAt) {
+		t.refreshAt = t.expiresAt.Add(-credentialsExpiryMargin)
+	}
+	t.logger.InfoContext(ctx, "obtained Porkbun API keys", "expiresAt", t.expiresAt, "refreshAt", t.refreshAt)
+	return t.apiKey, t.apiSecret, nil
+}
+
+// exchange calls the token exchange endpoint.
+func (t *tokenExchange) exchange(ctx context.Context) (*credentialsResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if t.tokenFile != "" {
+		token, err := os.ReadFile(t.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read token file: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call token exchange: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token exchange response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var result credentialsResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unable to decode token exchange response: %v", err)
+	}
+	if result.APIKey == "" || result.APISecret == "" {
+		return nil, fmt.Errorf("token exchange response lacks apiKey or secretApiKey")
+	}
+	return &result, nil
+}
+
+// credentialsTransport puts the API keys of the credentials source into the JSON body of every request sent
+// to Porkbun, replacing the keys the clients were created with.
+type credentialsTransport struct {
+	base   http.RoundTripper
+	source CredentialsSource
+}
+
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err == nil {
+		if _, ok := fields["apikey"]; ok {
+			apiKey, apiSecret, err := t.source.Credentials(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			fields["apikey"], _ = json.Marshal(apiKey)
+			fields["secretapikey"], _ = json.Marshal(apiSecret)
+			if body, err = json.Marshal(fields); err != nil {
+				return nil, fmt.Errorf("unable to marshal request body: %v", err)
+			}
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withCredentials wraps the transport of the HTTP client so every request carries the keys of the source.
+func withCredentials(client *http.Client, source CredentialsSource) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &credentialsTransport{base: base, source: source}
+}
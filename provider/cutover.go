@@ -0,0 +1,366 @@
+package porkbun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Colors of the target sets of a cutover group.
+const (
+	CutoverBlue  = "blue"
+	CutoverGreen = "green"
+)
+
+// errUnknownCutoverGroup is returned when a cutover names a group that is not configured.
+var errUnknownCutoverGroup = errors.New("unknown cutover group")
+
+// CutoverRecord names a record switched by a cutover group.
+type CutoverRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// CutoverGroup is a set of records switched together between the blue and the green targets.
+type CutoverGroup struct {
+	Name    string          `json:"name"`
+	Records []CutoverRecord `json:"records"`
+	Blue    []string        `json:"blue"`
+	Green   []string        `json:"green"`
+}
+
+// CutoverStatus describes a cutover group and its active color, empty while the records of the group have the targets
+// of neither color.
+type CutoverStatus struct {
+	CutoverGroup
+	Active string `json:"active"`
+}
+
+// targets returns the targets of the color.
+func (g *CutoverGroup) targets(color string) []string {
+	if color == CutoverGreen {
+		return g.Green
+	}
+	return g.Blue
+}
+
+// LoadCutoverGroups reads the cutover groups from a JSON file holding a list of groups.
+func LoadCutoverGroups(path string) ([]CutoverGroup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cutover config: %v", err)
+	}
+	var groups []CutoverGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("unable to parse cutover config '%s': %v", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, g := range groups {
+		switch {
+		case g.Name == "" || seen[g.Name]:
+			return nil, fmt.Errorf("cutover config '%s': empty or duplicate group name %q", path, g.Name)
+		case len(g.Records) == 0:
+			return nil, fmt.Errorf("cutover config '%s': group %s has no records", path, g.Name)
+		case len(g.Blue) == 0 || len(g.Green) == 0:
+			return nil, fmt.Errorf("cutover config '%s': group %s needs blue and green targets", path, g.Name)
+		}
+		seen[g.Name] = true
+		for j, rec := range g.Records {
+			if rec.Name == "" || rec.Type == "" {
+				return nil, fmt.Errorf("cutover config '%s': record of group %s lacks name or type", path, g.Name)
+			}
+			groups[i].Records[j] = CutoverRecord{Name: normalizeName(rec.Name), Type: strings.ToUpper(rec.Type)}
+		}
+	}
+	return groups, nil
+}
+
+// cutoverState holds the configured cutover groups and the active color of each group.
+type cutoverState struct {
+	groups []CutoverGroup
+	// run serializes cutovers and the derivation of the active colors
+	run sync.Mutex
+	// mu guards active
+	mu     sync.Mutex
+	active map[string]string
+}
+
+// activeTargets returns the targets the record is switched to by a cutover, nil if it is in no switched group.
+func (s *cutoverState) activeTargets(name string, recordType string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name = normalizeName(name)
+	for i := range s.groups {
+		color, ok := s.active[s.groups[i].Name]
+		if !ok {
+			continue
+		}
+		if slices.Contains(s.groups[i].Records, CutoverRecord{Name: name, Type: recordType}) {
+			return s.groups[i].targets(color)
+		}
+	}
+	return nil
+}
+
+// setActive sets the active color of the group, an empty color clears it.
+// returns the color the group had before
+func (s *cutoverState) setActive(group string, color string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.active[group]
+	if color == "" {
+		delete(s.active, group)
+	} else {
+		s.active[group] = color
+	}
+	return previous
+}
+
+// status returns all groups with their active color.
+func (s *cutoverState) status() []CutoverStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := make([]CutoverStatus, 0, len(s.groups))
+	for _, g := range s.groups {
+		status = append(status, CutoverStatus{CutoverGroup: g, Active: s.active[g.Name]})
+	}
+	return status
+}
+
+// deriveCutoverColors sets the active color of every group whose records all have the targets of one color in the
+// listed endpoints. The color is not stored anywhere else, so this is how a restarted webhook or another replica learns
+// the color a cutover switched the group to. Groups with missing records or other targets keep their color, and nothing
+// is derived while a cutover runs, since its records are between the colors then.
+func (p *PorkbunProvider) deriveCutoverColors(ctx context.Context, endpoints []*endpoint.Endpoint) {
+	if len(p.cutover.groups) == 0 || !p.cutover.run.TryLock() {
+		return
+	}
+	defer p.cutover.run.Unlock()
+
+	listed := make(map[CutoverRecord][]string, len(endpoints))
+	for _, ep := range endpoints {
+		listed[CutoverRecord{Name: ep.DNSName, Type: ep.RecordType}] = ep.Targets
+	}
+	hasTargets := func(g *CutoverGroup, targets []string) bool {
+		for _, rec := range g.Records {
+			current, ok := listed[rec]
+			if !ok || !sameTargets(current, targets) {
+				return false
+			}
+		}
+		return true
+	}
+	for i := range p.cutover.groups {
+		g := &p.cutover.groups[i]
+		var color string
+		switch {
+		case hasTargets(g, g.Blue):
+			color = CutoverBlue
+		case hasTargets(g, g.Green):
+			color = CutoverGreen
+		default:
+			continue
+		}
+		if previous := p.cutover.setActive(g.Name, color); previous != color {
+			p.logger.InfoContext(ctx, "derived active color of cutover group from its records", "group", g.Name, "color", color, "previous", previous)
+		}
+	}
+}
+
+// overrideCutoverTargets replaces the targets of desired endpoints switched by a cutover with the active targets,
+// so the next sync does not revert the cutover to the targets of the sources.
+func (p *PorkbunProvider) overrideCutoverTargets(ep *endpoint.Endpoint) {
+	targets := p.cutover.activeTargets(ep.DNSName, ep.RecordType)
+	if targets == nil || sameTargets(ep.Targets, targets) {
+		return
+	}
+	p.logger.Debug("replacing targets of endpoint switched by a cutover", "endpoint", ep.DNSName, "type", ep.RecordType, "targets", targets)
+	ep.Targets = append(endpoint.Targets(nil), targets...)
+}
+
+// Cutover switches all records of the group to the targets of the color within one set of changes, then reads
+// the records back to verify them. The color is active from the start, so syncs running meanwhile desire the new
+// targets instead of reverting them. If applying or verifying fails, the records are switched back to the targets
+// they had before and the group gets its previous color back. All records of the group must exist.
+func (p *PorkbunProvider) Cutover(ctx context.Context, group string, color string) error {
+	if color != CutoverBlue && color != CutoverGreen {
+		return fmt.Errorf("invalid cutover color %q, must be %s or %s", color, CutoverBlue, CutoverGreen)
+	}
+	i := slices.IndexFunc(p.cutover.groups, func(g CutoverGroup) bool { return g.Name == group })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", errUnknownCutoverGroup, group)
+	}
+	g := &p.cutover.groups[i]
+	if p.dryRun {
+		return errors.New("cutovers are not available in dry run")
+	}
+
+	p.cutover.run.Lock()
+	defer p.cutover.run.Unlock()
+
+	before, err := p.cutoverEndpoints(ctx, g)
+	if err != nil {
+		cutoversTotal.WithLabelValues(group, "failed").Inc()
+		return err
+	}
+	targets := g.targets(color)
+	p.logger.InfoContext(ctx, "starting cutover", "group", group, "color", color, "targets", targets)
+	previousColor := p.cutover.setActive(group, color)
+
+	err = p.switchTargets(ctx, before, func(*endpoint.Endpoint) []string { return targets })
+	if err == nil {
+		err = p.verifyCutover(ctx, g, targets)
+	}
+	if err != nil {
+		p.logger.ErrorContext(ctx, "cutover failed, rolling back", "group", group, "color", color, "error", err.Error())
+		p.cutover.setActive(group, previousColor)
+		previous := make(map[string][]string, len(before))
+		for _, ep := range before {
+			previous[ep.DNSName+"\x00"+ep.RecordType] = ep.Targets
+		}
+		if rollbackErr := p.rollbackCutover(ctx, g, previous); rollbackErr != nil {
+			cutoversTotal.WithLabelValues(group, "rollback_failed").Inc()
+			return fmt.Errorf("cutover of group '%s' to %s failed: %v, rollback failed: %v", group, color, err, rollbackErr)
+		}
+		cutoversTotal.WithLabelValues(group, "rolled_back").Inc()
+		return fmt.Errorf("cutover of group '%s' to %s failed and was rolled back: %v", group, color, err)
+	}
+
+	cutoversTotal.WithLabelValues(group, "succeeded").Inc()
+	p.logger.InfoContext(ctx, "cutover completed", "group", group, "color", color)
+	return nil
+}
+
+// cutoverEndpoints reads the current endpoints of the records of the group from Porkbun, in the order of the group.
+func (p *PorkbunProvider) cutoverEndpoints(ctx context.Context, g *CutoverGroup) ([]*endpoint.Endpoint, error) {
+	zones := p.domainFilter.Zones()
+	byZone := map[string][]*endpoint.Endpoint{}
+	endpoints := make([]*endpoint.Endpoint, 0, len(g.Records))
+	for _, rec := range g.Records {
+		zone := endpointZoneName(&endpoint.Endpoint{DNSName: rec.Name}, zones)
+		if zone == "" {
+			return nil, fmt.Errorf("record '%s' of cutover group '%s' is in no managed zone", rec.Name, g.Name)
+		}
+		current, ok := byZone[zone]
+		if !ok {
+			records, err := p.retrieveRecords(ctx, zone)
+			if err != nil {
+				return nil, fmt.Errorf("unable to query DNS zone records for domain '%v': %w", zone, err)
+			}
+			current = p.recordsToEndpoints(ctx, zone, records, nil)
+			byZone[zone] = current
+		}
+		i := slices.IndexFunc(current, func(ep *endpoint.Endpoint) bool {
+			return ep.DNSName == rec.Name && ep.RecordType == rec.Type
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%s record '%s' of cutover group '%s' not found", rec.Type, rec.Name, g.Name)
+		}
+		endpoints = append(endpoints, current[i])
+	}
+	return endpoints, nil
+}
+
+// switchTargets updates the endpoints whose targets differ from the targets returned for them, in one set of changes.
+func (p *PorkbunProvider) switchTargets(ctx context.Context, current []*endpoint.Endpoint, targetsOf func(*endpoint.Endpoint) []string) error {
+	changes := &plan.Changes{}
+	for _, ep := range current {
+		targets := targetsOf(ep)
+		if sameTargets(ep.Targets, targets) {
+			continue
+		}
+		desired := ep.DeepCopy()
+		desired.Targets = append(endpoint.Targets(nil), targets...)
+		changes.UpdateOld = append(changes.UpdateOld, ep)
+		changes.UpdateNew = append(changes.UpdateNew, desired)
+	}
+	return p.ApplyChanges(ctx, changes)
+}
+
+// verifyCutover reads the records of the group back and checks that all of them have the targets.
+func (p *PorkbunProvider) verifyCutover(ctx context.Context, g *CutoverGroup, targets []string) error {
+	current, err := p.cutoverEndpoints(ctx, g)
+	if err != nil {
+		return err
+	}
+	for _, ep := range current {
+		if !sameTargets(ep.Targets, targets) {
+			return fmt.Errorf("%s record '%s' has targets %v instead of %v", ep.RecordType, ep.DNSName, ep.Targets, targets)
+		}
+	}
+	return nil
+}
+
+// rollbackCutover switches the records of the group back to the targets they had before the cutover.
+func (p *PorkbunProvider) rollbackCutover(ctx context.Context, g *CutoverGroup, previous map[string][]string) error {
+	current, err := p.cutoverEndpoints(ctx, g)
+	if err != nil {
+		return err
+	}
+	return p.switchTargets(ctx, current, func(ep *endpoint.Endpoint) []string {
+		return previous[ep.DNSName+"\x00"+ep.RecordType]
+	})
+}
+
+// sameTargets reports whether both lists hold the same targets, in any order.
+func sameTargets(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	trimmed := func(targets []string) []string {
+		sorted := make([]string, len(targets))
+		for i, target := range targets {
+			sorted[i] = strings.Trim(target, "\"")
+		}
+		slices.Sort(sorted)
+		return sorted
+	}
+	return slices.Equal(trimmed(a), trimmed(b))
+}
+
+// CutoverHandler serves the cutover groups with their active color as JSON. A POST with the group and to query
+// parameters, e.g. ?group=web&to=green, switches the group and answers with its new status.
+func (p *PorkbunProvider) CutoverHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, p.cutover.status(), p.logger)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	group, color := r.URL.Query().Get("group"), r.URL.Query().Get("to")
+	if group == "" || color == "" {
+		http.Error(w, "missing group or to query parameter", http.StatusBadRequest)
+		return
+	}
+	if err := p.Cutover(r.Context(), group, color); err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, errUnknownCutoverGroup):
+			status = http.StatusNotFound
+		case color != CutoverBlue && color != CutoverGreen:
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	for _, s := range p.cutover.status() {
+		if s.Name == group {
+			writeJSON(w, s, p.logger)
+		}
+	}
+}
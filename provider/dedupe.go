@@ -0,0 +1,26 @@
+package porkbun
+
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// dedupeEndpoints drops endpoints of the same name, type and target as an earlier one, e.g. the same endpoint
+// produced by several sources, so its record is not created or deleted twice. The endpoints must hold one target each,
+// as split by splitTargets; the first of the duplicates is kept.
+// returns the remaining endpoints and the number of dropped duplicates
+func dedupeEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, int) {
+	deduped := make([]*endpoint.Endpoint, 0, len(endpoints))
+	seen := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		key := normalizeName(ep.DNSName) + "\x00" + ep.RecordType
+		if len(ep.Targets) > 0 {
+			key += "\x00" + normalizeTarget(ep.RecordType, ep.Targets[0])
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, ep)
+	}
+	return deduped, len(endpoints) - len(deduped)
+}
@@ -0,0 +1,67 @@
+package porkbun
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDeepHealthTimeout  = 5 * time.Second
+	defaultDeepHealthInterval = 30 * time.Second
+)
+
+// DeepHealth is the result of a live check of the Porkbun API.
+type DeepHealth struct {
+	Healthy   bool      `json:"healthy"`
+	Detail    string    `json:"detail,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// deepHealthCheck keeps the latest live check, so frequent probes don't use up the API quota.
+type deepHealthCheck struct {
+	mu   sync.Mutex
+	last *DeepHealth
+}
+
+// DeepHealth pings the Porkbun API with the deep health timeout. A result younger than the deep health interval
+// is returned again instead, and concurrent callers share one ping.
+func (p *PorkbunProvider) DeepHealth(ctx context.Context) DeepHealth {
+	p.deepHealth.mu.Lock()
+	defer p.deepHealth.mu.Unlock()
+
+	now := p.clock.Now()
+	if last := p.deepHealth.last; last != nil && now.Sub(last.CheckedAt) < p.deepHealthInterval {
+		return *last
+	}
+	if p.dryRun {
+		return DeepHealth{Healthy: true, Detail: "dry run", Latency: "0s", CheckedAt: now}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.deepHealthTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := p.client.Ping(ctx)
+	p.health.setLogin(err)
+
+	result := DeepHealth{Healthy: err == nil, Latency: time.Since(start).Round(time.Millisecond).String(), CheckedAt: now}
+	if err != nil {
+		result.Detail = err.Error()
+		p.logger.WarnContext(ctx, "deep health check failed", "error", err.Error())
+	}
+	p.deepHealth.last = &result
+	return result
+}
+
+// DeepHealthzHandler serves the live check of the Porkbun API as JSON, with status 503 Service Unavailable if it failed.
+// It is meant for humans and monitoring, liveness probes should use the /healthz endpoint, which never calls Porkbun.
+func (p *PorkbunProvider) DeepHealthzHandler(w http.ResponseWriter, r *http.Request) {
+	health := p.DeepHealth(r.Context())
+	if !health.Healthy {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, health, p.logger)
+}
@@ -0,0 +1,78 @@
+package porkbun
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// delegations returns the delegation points of the zone: the names below the apex with NS records, which hand the
+// names below them to other nameservers. Records below a delegation point are never answered from the zone.
+func delegations(zone string, recs []pb.Record) []string {
+	var points []string
+	for _, rec := range recs {
+		if rec.Type != endpoint.RecordTypeNS {
+			continue
+		}
+		if name := normalizeName(rec.Name); name != zone {
+			points = append(points, name)
+		}
+	}
+	return points
+}
+
+// delegated reports whether the name lies below one of the delegation points. The delegation points themselves are
+// not delegated, their NS records belong to the zone.
+func delegated(name string, points []string) bool {
+	name = normalizeName(name)
+	for _, point := range points {
+		if strings.HasSuffix(name, "."+point) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDelegated drops the changes of endpoints below the delegation points of the zone and counts them in skipped,
+// since the nameservers of the subzone answer for them.
+func (p *PorkbunProvider) dropDelegated(ctx context.Context, zone string, c *plan.Changes, recs []pb.Record, skipped skipSummary) {
+	points := delegations(zone, recs)
+	if len(points) == 0 {
+		return
+	}
+	keep := func(kind string, ep *endpoint.Endpoint) bool {
+		if !delegated(ep.DNSName, points) {
+			return true
+		}
+		p.logger.WarnContext(ctx, "skipping change of endpoint in a delegated subzone", "type", kind, "zone", zone, "endpoint", ep.String())
+		skipped.skip(skipReasonDelegated, 1)
+		return false
+	}
+
+	creates := make([]*endpoint.Endpoint, 0, len(c.Create))
+	for _, ep := range c.Create {
+		if keep("create", ep) {
+			creates = append(creates, ep)
+		}
+	}
+	var updateOld, updateNew []*endpoint.Endpoint
+	for i, ep := range c.UpdateOld {
+		if !keep("update", ep) {
+			continue
+		}
+		updateOld = append(updateOld, ep)
+		if i < len(c.UpdateNew) {
+			updateNew = append(updateNew, c.UpdateNew[i])
+		}
+	}
+	deletes := make([]*endpoint.Endpoint, 0, len(c.Delete))
+	for _, ep := range c.Delete {
+		if keep("delete", ep) {
+			deletes = append(deletes, ep)
+		}
+	}
+	c.Create, c.UpdateOld, c.UpdateNew, c.Delete = creates, updateOld, updateNew, deletes
+}
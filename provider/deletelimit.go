@@ -0,0 +1,27 @@
+package porkbun
+
+import (
+	"errors"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// errTooManyDeletes is returned instead of applying changes that delete more records than allowed per sync.
+var errTooManyDeletes = errors.New("too many deletes")
+
+// plannedDeletes returns the number of records the changes delete over all zones: the targets of the deletes and of
+// updates that change the record type, and the targets updates drop. Deletes without targets count the cached
+// records of their name and type, duplicates count once. Changes the ownership guard drops later are counted as well.
+func (p *PorkbunProvider) plannedDeletes(perZoneChanges map[string]*plan.Changes) int {
+	deletes := 0
+	for zone, c := range perZoneChanges {
+		oldSide, newSide, _, typeDeletes := splitTypeChanges(c.UpdateOld, c.UpdateNew)
+		_, _, _, updateDeletes := splitUpdates(oldSide, newSide)
+		cached, _ := p.cache.get(zone)
+		zoneDeletes := append(append([]*endpoint.Endpoint{}, c.Delete...), typeDeletes...)
+		deduped, _ := dedupeEndpoints(append(splitTargets(expandEmptyDeletes(zoneDeletes, cached.records)), updateDeletes...))
+		deletes += len(deduped)
+	}
+	return deletes
+}
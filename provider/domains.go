@@ -0,0 +1,179 @@
+package porkbun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// porkbunBaseURL is the base URL of the Porkbun API for the calls the porkbun client library does not cover.
+const porkbunBaseURL = "https://api.porkbun.com/api/json/v3/"
+
+// DomainPrice contains the prices Porkbun charges for a TLD.
+type DomainPrice struct {
+	Registration string `json:"registration"`
+	Renewal      string `json:"renewal"`
+	Transfer     string `json:"transfer"`
+}
+
+// DomainAvailability is the result of a domain availability check.
+type DomainAvailability struct {
+	Domain       string `json:"domain"`
+	Available    bool   `json:"available"`
+	Price        string `json:"price,omitempty"`
+	RegularPrice string `json:"regularPrice,omitempty"`
+	Premium      bool   `json:"premium"`
+}
+
+type pricingResponse struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Pricing map[string]DomainPrice `json:"pricing"`
+}
+
+type checkDomainResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Response struct {
+		Avail        string `json:"avail"`
+		Price        string `json:"price"`
+		RegularPrice string `json:"regularPrice"`
+		Premium      string `json:"premium"`
+	} `json:"response"`
+}
+
+// domainAPI calls the Porkbun domain endpoints.
+type domainAPI struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	apiSecret  string
+}
+
+func newDomainAPI(apiKey string, apiSecret string) *domainAPI {
+	return &domainAPI{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:    porkbunBaseURL,
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+	}
+}
+
+// post sends an authenticated request to the given API path and decodes the response into result.
+func (a *domainAPI) post(ctx context.Context, path string, result interface{}) error {
+	body, err := json.Marshal(map[string]string{
+		"apikey":       a.apiKey,
+		"secretapikey": a.apiSecret,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call Porkbun API: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("porkbun API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// Pricing returns the Porkbun prices for all TLDs.
+func (a *domainAPI) Pricing(ctx context.Context) (map[string]DomainPrice, error) {
+	var resp pricingResponse
+	if err := a.post(ctx, "pricing/get", &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "SUCCESS" {
+		return nil, fmt.Errorf("unable to get pricing: %s", resp.Message)
+	}
+	return resp.Pricing, nil
+}
+
+// CheckDomain checks whether a domain can be registered.
+func (a *domainAPI) CheckDomain(ctx context.Context, domain string) (*DomainAvailability, error) {
+	var resp checkDomainResponse
+	if err := a.post(ctx, "domain/checkDomain/"+url.PathEscape(domain), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "SUCCESS" {
+		return nil, fmt.Errorf("unable to check domain '%s': %s", domain, resp.Message)
+	}
+	return &DomainAvailability{
+		Domain:       domain,
+		Available:    resp.Response.Avail == "yes",
+		Price:        resp.Response.Price,
+		RegularPrice: resp.Response.RegularPrice,
+		Premium:      resp.Response.Premium == "yes",
+	}, nil
+}
+
+// DomainCheckHandler serves the availability of the domain given in the domain query parameter as JSON.
+func (p *PorkbunProvider) DomainCheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	if domain == "" {
+		http.Error(w, "missing domain query parameter", http.StatusBadRequest)
+		return
+	}
+
+	p.usage.record(r.Context(), accountZone, "checkDomain")
+	availability, err := p.domains.CheckDomain(r.Context(), domain)
+	if err != nil {
+		p.logger.Error("unable to check domain availability", "domain", domain, "error", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, availability, p.logger)
+}
+
+// DomainPricingHandler serves the Porkbun prices as JSON, limited to the TLDs given in the tld query parameter if present.
+func (p *PorkbunProvider) DomainPricingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p.usage.record(r.Context(), accountZone, "pricing")
+	pricing, err := p.domains.Pricing(r.Context())
+	if err != nil {
+		p.logger.Error("unable to get domain pricing", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if tlds := r.URL.Query()["tld"]; len(tlds) > 0 {
+		filtered := make(map[string]DomainPrice, len(tlds))
+		for _, tld := range tlds {
+			tld = strings.TrimPrefix(strings.ToLower(tld), ".")
+			if price, ok := pricing[tld]; ok {
+				filtered[tld] = price
+			}
+		}
+		pricing = filtered
+	}
+	writeJSON(w, pricing, p.logger)
+}
@@ -0,0 +1,86 @@
+package porkbun
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// recordingClient stands in for the Porkbun API in dry run. It keeps the zones in memory, starting empty, and logs
+// every write instead of sending it to Porkbun, so a dry run goes through the conversion, ID resolution, ordering
+// and validation of the changes like a real sync, and the change log shows the resulting diff.
+type recordingClient struct {
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	zones  map[string][]pb.Record
+	nextID int
+}
+
+func newRecordingClient(logger *slog.Logger) *recordingClient {
+	return &recordingClient{logger: logger, zones: map[string][]pb.Record{}}
+}
+
+func (c *recordingClient) Ping(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (c *recordingClient) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	record.ID = strconv.Itoa(c.nextID)
+	record.Name = recordFQDN(record.Name, domain)
+	if record.TTL == "" {
+		record.TTL = pb.DefaultTTL
+	}
+	c.zones[domain] = append(c.zones[domain], record)
+	c.logger.InfoContext(ctx, "dry run - would create record", "zone", domain, "name", record.Name, "type", record.Type, "content", record.Content, "prio", record.Prio, "ttl", record.TTL)
+	return c.nextID, nil
+}
+
+func (c *recordingClient) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, ok := c.find(domain, id)
+	if !ok {
+		return pb.Status{Status: "ERROR", Message: "Invalid record ID."}
+	}
+	record.ID = strconv.Itoa(id)
+	record.Name = recordFQDN(record.Name, domain)
+	c.logger.InfoContext(ctx, "dry run - would edit record", "zone", domain, "id", id, "name", record.Name, "type", record.Type, "from", c.zones[domain][i].Content, "content", record.Content, "prio", record.Prio, "ttl", record.TTL)
+	c.zones[domain][i] = record
+	return nil
+}
+
+func (c *recordingClient) DeleteRecord(ctx context.Context, domain string, id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, ok := c.find(domain, id)
+	if !ok {
+		return pb.Status{Status: "ERROR", Message: "Invalid record ID."}
+	}
+	record := c.zones[domain][i]
+	c.logger.InfoContext(ctx, "dry run - would delete record", "zone", domain, "id", id, "name", record.Name, "type", record.Type, "content", record.Content)
+	c.zones[domain] = append(c.zones[domain][:i], c.zones[domain][i+1:]...)
+	return nil
+}
+
+func (c *recordingClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]pb.Record{}, c.zones[domain]...), nil
+}
+
+// find returns the index of the record with the ID in the zone, the caller must hold mu.
+func (c *recordingClient) find(domain string, id int) (int, bool) {
+	for i, rec := range c.zones[domain] {
+		if rec.ID == strconv.Itoa(id) {
+			return i, true
+		}
+	}
+	return 0, false
+}
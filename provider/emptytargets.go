@@ -0,0 +1,96 @@
+package porkbun
+
+import (
+	"context"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Policies for endpoints without targets, e.g. of a Service that briefly lost its LoadBalancer IP.
+const (
+	// EmptyTargetsSkip skips the changes of endpoints without targets and reports them.
+	EmptyTargetsSkip = "skip"
+	// EmptyTargetsDelete deletes all records of the name and type of endpoints without targets.
+	EmptyTargetsDelete = "delete"
+)
+
+// handleEmptyTargets applies the empty targets policy to the changes. A create without targets has nothing to write
+// and is always skipped. With EmptyTargetsSkip, updates and deletes without targets are skipped as well; with
+// EmptyTargetsDelete, they become deletes without targets, which expandEmptyDeletes resolves to all records of the
+// name and type.
+func (p *PorkbunProvider) handleEmptyTargets(ctx context.Context, changes *plan.Changes, skipped skipSummary) *plan.Changes {
+	handled := &plan.Changes{}
+	for _, ep := range changes.Create {
+		if len(ep.Targets) == 0 {
+			p.logger.WarnContext(ctx, "skipping create of endpoint without targets", "endpoint", ep.String())
+			skipped.skip(skipReasonEmptyTargets, 1)
+			continue
+		}
+		handled.Create = append(handled.Create, ep)
+	}
+
+	for i, ep := range changes.UpdateNew {
+		if len(ep.Targets) > 0 || i >= len(changes.UpdateOld) {
+			handled.UpdateNew = append(handled.UpdateNew, ep)
+			if i < len(changes.UpdateOld) {
+				handled.UpdateOld = append(handled.UpdateOld, changes.UpdateOld[i])
+			}
+			continue
+		}
+		if p.emptyTargets == EmptyTargetsDelete {
+			p.logger.WarnContext(ctx, "deleting records of endpoint updated to no targets", "endpoint", ep.String())
+			handled.Delete = append(handled.Delete, emptyEndpoint(ep))
+			continue
+		}
+		p.logger.WarnContext(ctx, "skipping update of endpoint to no targets", "endpoint", ep.String())
+		skipped.skip(skipReasonEmptyTargets, 1)
+	}
+	if len(changes.UpdateOld) > len(changes.UpdateNew) {
+		handled.UpdateOld = append(handled.UpdateOld, changes.UpdateOld[len(changes.UpdateNew):]...)
+	}
+
+	for _, ep := range changes.Delete {
+		if len(ep.Targets) == 0 && p.emptyTargets != EmptyTargetsDelete {
+			p.logger.WarnContext(ctx, "skipping delete of endpoint without targets", "endpoint", ep.String())
+			skipped.skip(skipReasonEmptyTargets, 1)
+			continue
+		}
+		handled.Delete = append(handled.Delete, ep)
+	}
+	return handled
+}
+
+// emptyEndpoint returns an endpoint of the name and type of ep without targets.
+func emptyEndpoint(ep *endpoint.Endpoint) *endpoint.Endpoint {
+	empty := endpoint.NewEndpoint(ep.DNSName, ep.RecordType).WithSetIdentifier(ep.SetIdentifier)
+	for key, value := range ep.Labels {
+		if key != RecordIDLabelKey {
+			empty.Labels[key] = value
+		}
+	}
+	return empty
+}
+
+// expandEmptyDeletes replaces the deletes without targets by deletes of all records of their name and type in the zone.
+func expandEmptyDeletes(deletes []*endpoint.Endpoint, recs []pb.Record) []*endpoint.Endpoint {
+	expanded := make([]*endpoint.Endpoint, 0, len(deletes))
+	for _, ep := range deletes {
+		if len(ep.Targets) > 0 {
+			expanded = append(expanded, ep)
+			continue
+		}
+		name := normalizeName(ep.DNSName)
+		for _, rec := range recs {
+			if rec.Type != ep.RecordType || normalizeName(rec.Name) != name {
+				continue
+			}
+			single := ep.DeepCopy()
+			single.Targets = endpoint.Targets{recordTarget(rec)}
+			single.Labels[RecordIDLabelKey] = rec.ID
+			expanded = append(expanded, single)
+		}
+	}
+	return expanded
+}
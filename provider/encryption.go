@@ -0,0 +1,115 @@
+package porkbun
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks data sealed by an OutputCipher, followed by the base64 encoded nonce and ciphertext.
+const encryptedPrefix = "pbenc1:"
+
+// OutputCipher encrypts outputs that hold full record contents, like the change log and the cache snapshots,
+// since TXT records may carry tokens. It uses AES-256-GCM with a key shared by all replicas, so a snapshot written
+// by one replica can be restored by another.
+type OutputCipher struct {
+	aead cipher.AEAD
+}
+
+// NewOutputCipher returns a cipher using the 32 byte key.
+func NewOutputCipher(key []byte) (*OutputCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputCipher{aead: aead}, nil
+}
+
+// LoadOutputCipher reads the base64 encoded 32 byte key from the file, e.g. created with `openssl rand -base64 32`.
+func LoadOutputCipher(path string) (*OutputCipher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key '%s' is not base64 encoded: %v", path, err)
+	}
+	c, err := NewOutputCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key '%s': %v", path, err)
+	}
+	return c, nil
+}
+
+// seal encrypts the data into a single line of text.
+func (c *OutputCipher) seal(data []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to create nonce: %v", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, data, nil)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// open decrypts data sealed by seal.
+func (c *OutputCipher) open(data []byte) ([]byte, error) {
+	encoded, found := bytes.CutPrefix(bytes.TrimSpace(data), []byte(encryptedPrefix))
+	if !found {
+		return nil, fmt.Errorf("data is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %v", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted data: too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	data, err = c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt, wrong key or modified data: %v", err)
+	}
+	return data, nil
+}
+
+// Decrypt copies r to w, decrypting the encrypted lines, e.g. the blocks of an encrypted change log or a downloaded
+// cache snapshot. Lines that are not encrypted are copied as they are.
+func (c *OutputCipher) Decrypt(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if bytes.HasPrefix(line, []byte(encryptedPrefix)) {
+				data, err := c.open(line)
+				if err != nil {
+					return err
+				}
+				line = data
+			}
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// recordNotFoundMessages are fragments of the messages Porkbun answers with when a record ID does not exist (anymore).
+// Generic failures like "Unable to edit record" are also returned for invalid content and must not match.
+var recordNotFoundMessages = []string{
+	"invalid record id",
+	"record not found",
+}
+
+// errRecordGone is returned when a record is not found in its zone any more after a refresh.
+var errRecordGone = errors.New("record not found after refresh")
+
+// errRecordConflict is returned when a record can't be created since another record at the same name excludes it.
+var errRecordConflict = errors.New("conflicting record exists")
+
+// errZoneLocked is returned when the lock record of a zone is held by another writer.
+var errZoneLocked = errors.New("zone is locked")
+
+// isRecordNotFound reports whether the error means that the record ID used in an edit or delete no longer exists.
+func isRecordNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var message string
+	var status pb.Status
+	var serverErr *pb.ServerError
+	switch {
+	case errors.As(err, &status):
+		message = status.Message
+	case errors.As(err, &serverErr):
+		if serverErr.StatusCode == http.StatusNotFound {
+			return true
+		}
+		message = serverErr.Message
+	default:
+		return false
+	}
+
+	message = strings.ToLower(message)
+	for _, fragment := range recordNotFoundMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransient reports whether the error is a failure of the Porkbun API that passes by itself: rate limits, server
+// errors, network failures and maintenances. Joined errors are transient if all of them are.
+func isTransient(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if !isTransient(err) {
+				return false
+			}
+		}
+		return len(joined.Unwrap()) > 0
+	}
+
+	var serverErr *pb.ServerError
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case isMaintenance(err):
+		return true
+	case errors.As(err, &serverErr):
+		return serverErr.StatusCode == http.StatusTooManyRequests || serverErr.StatusCode >= http.StatusInternalServerError
+	case errors.As(err, &netErr):
+		return true
+	}
+	return false
+}
+
+// softError marks transient errors as soft errors, which external-dns retries with the next sync instead of treating
+// them as fatal. Other errors are returned as they are.
+func softError(err error) error {
+	if isTransient(err) {
+		return provider.NewSoftError(err)
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// Orders in which the operations of a zone are applied.
+const (
+	// ChangeOrderDeleteFirst deletes records before creating and updating records, so a replaced record never exists
+	// next to its replacement.
+	ChangeOrderDeleteFirst = "delete-before-create"
+	// ChangeOrderCreateFirst creates and updates records before deleting the records they replace, so a renamed
+	// record keeps resolving under one of its names.
+	ChangeOrderCreateFirst = "create-before-delete"
+)
+
+// nameBatch holds the record operations of the names that must be applied one after another.
+type nameBatch struct {
+	deletes []pb.Record
+	creates []pb.Record
+	updates []pb.Record
+}
+
+// batchByName splits the changes of a zone into batches of the same record name, keeping the order of the operations
+// within a name. A CNAME or ALIAS joins the batch of its target, so the target is still created first.
+// returns the batches in the order of their first operation
+func batchByName(zone string, change *PorkbunChange) []*nameBatch {
+	// parent links names whose operations must not run concurrently
+	parent := map[string]string{}
+	var find func(name string) string
+	find = func(name string) string {
+		if p, ok := parent[name]; ok && p != name {
+			root := find(p)
+			parent[name] = root
+			return root
+		}
+		return name
+	}
+	var order []string
+	for _, recs := range []*[]pb.Record{change.Delete, change.Create, change.UpdateNew} {
+		for _, rec := range *recs {
+			name := normalizeName(recordFQDN(rec.Name, zone))
+			if _, ok := parent[name]; !ok {
+				parent[name] = name
+				order = append(order, name)
+			}
+		}
+	}
+	for _, recs := range []*[]pb.Record{change.Create, change.UpdateNew} {
+		for _, rec := range *recs {
+			if !aliasType(rec.Type) {
+				continue
+			}
+			target := normalizeName(rec.Content)
+			if _, ok := parent[target]; ok {
+				parent[find(target)] = find(normalizeName(recordFQDN(rec.Name, zone)))
+			}
+		}
+	}
+
+	byRoot := map[string]*nameBatch{}
+	batches := make([]*nameBatch, 0, len(order))
+	batchOf := func(rec pb.Record) *nameBatch {
+		root := find(normalizeName(recordFQDN(rec.Name, zone)))
+		b, ok := byRoot[root]
+		if !ok {
+			b = &nameBatch{}
+			byRoot[root] = b
+			batches = append(batches, b)
+		}
+		return b
+	}
+	for _, rec := range *change.Delete {
+		b := batchOf(rec)
+		b.deletes = append(b.deletes, rec)
+	}
+	for _, rec := range *change.Create {
+		b := batchOf(rec)
+		b.creates = append(b.creates, rec)
+	}
+	for _, rec := range *change.UpdateNew {
+		b := batchOf(rec)
+		b.updates = append(b.updates, rec)
+	}
+	return batches
+}
+
+// applyRecords executes the changes of a zone in the change order. With ChangeOrderDeleteFirst the deletes, creates
+// and updates are applied in one phase. With ChangeOrderCreateFirst, only the deletes blocking a create or update
+// are applied with them; the other deletes follow once all creates and updates succeeded.
+func (p *PorkbunProvider) applyRecords(ctx context.Context, zone string, change *PorkbunChange) error {
+	if p.changeOrder != ChangeOrderCreateFirst {
+		return p.applyPhase(ctx, zone, change)
+	}
+	blocking, deferred := splitBlockingDeletes(zone, *change.Delete, *change.Create, *change.UpdateNew)
+	first := &PorkbunChange{Create: change.Create, UpdateNew: change.UpdateNew, UpdateOld: change.UpdateOld, Delete: &blocking}
+	if err := p.applyPhase(ctx, zone, first); err != nil {
+		return err
+	}
+	return p.applyPhase(ctx, zone, &PorkbunChange{Create: &[]pb.Record{}, UpdateNew: &[]pb.Record{}, Delete: &deferred})
+}
+
+// splitBlockingDeletes splits the deletes of a zone into the ones that must precede the creates and updates, since
+// Porkbun refuses a record next to them: deletes at the name of a written record where either is a CNAME or ALIAS,
+// and deletes of a record with the same type and content.
+// returns the blocking and the remaining deletes, in their original order
+func splitBlockingDeletes(zone string, deletes []pb.Record, writes ...[]pb.Record) ([]pb.Record, []pb.Record) {
+	blocking := make([]pb.Record, 0, len(deletes))
+	deferred := make([]pb.Record, 0, len(deletes))
+	for _, del := range deletes {
+		name := normalizeName(recordFQDN(del.Name, zone))
+		blocks := false
+		for _, recs := range writes {
+			for _, rec := range recs {
+				if normalizeName(recordFQDN(rec.Name, zone)) != name {
+					continue
+				}
+				sameRecord := del.Type == rec.Type && normalizeTarget(del.Type, del.Content) == normalizeTarget(rec.Type, rec.Content)
+				if aliasType(del.Type) || aliasType(rec.Type) || sameRecord {
+					blocks = true
+				}
+			}
+		}
+		if blocks {
+			blocking = append(blocking, del)
+		} else {
+			deferred = append(deferred, del)
+		}
+	}
+	return blocking, deferred
+}
+
+// applyPhase executes changes of a zone. Without apply concurrency, all deletes are applied first,
+// then all creates and all updates. Otherwise the operations on one record name are applied one after another
+// in that order, so e.g. an A record is deleted before the new one is created, while up to applyConcurrency
+// unrelated names are applied in parallel. All errors are returned.
+func (p *PorkbunProvider) applyPhase(ctx context.Context, zone string, change *PorkbunChange) error {
+	if p.applyConcurrency <= 1 {
+		return p.applyBatch(ctx, zone, &nameBatch{deletes: *change.Delete, creates: *change.Create, updates: *change.UpdateNew})
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	slots := make(chan struct{}, p.applyConcurrency)
+	for _, batch := range batchByName(zone, change) {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := p.applyBatch(ctx, zone, batch); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// applyBatch deletes, creates and updates the records of the batch in this order, stopping at the first error.
+func (p *PorkbunProvider) applyBatch(ctx context.Context, zone string, batch *nameBatch) error {
+	if _, err := p.DeleteDnsRecords(ctx, zone, &batch.deletes); err != nil {
+		return err
+	}
+	if _, err := p.CreateDnsRecords(ctx, zone, &batch.creates); err != nil {
+		return err
+	}
+	if _, err := p.UpdateDnsRecords(ctx, zone, &batch.updates); err != nil {
+		return err
+	}
+	return nil
+}
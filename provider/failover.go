@@ -0,0 +1,142 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointRetryAfter is how long a failed API base URL is passed over before it is tried first again.
+const endpointRetryAfter = 30 * time.Second
+
+// apiEndpoints tracks the health of the Porkbun API base URLs. Requests go to the first healthy base URL
+// in the configured order, a failed base URL is passed over until endpointRetryAfter has passed.
+type apiEndpoints struct {
+	mu        sync.Mutex
+	urls      []string
+	downUntil []time.Time
+	clock     Clock
+	logger    *slog.Logger
+}
+
+func newAPIEndpoints(urls []string, clock Clock, logger *slog.Logger) *apiEndpoints {
+	for _, u := range urls {
+		apiEndpointUp.WithLabelValues(u).Set(1)
+	}
+	return &apiEndpoints{urls: urls, downUntil: make([]time.Time, len(urls)), clock: clock, logger: logger}
+}
+
+// candidates returns the base URLs in the order they are tried: the healthy ones in the configured order,
+// followed by the failed ones, which are still tried as a last resort.
+func (e *apiEndpoints) candidates() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.clock.Now()
+	healthy := make([]int, 0, len(e.urls))
+	var down []int
+	for i := range e.urls {
+		if now.Before(e.downUntil[i]) {
+			down = append(down, i)
+			continue
+		}
+		healthy = append(healthy, i)
+	}
+	return append(healthy, down...)
+}
+
+// markUp records a successful request to the base URL.
+func (e *apiEndpoints) markUp(i int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.downUntil[i].IsZero() {
+		e.logger.Info("Porkbun API endpoint recovered", "url", e.urls[i])
+		e.downUntil[i] = time.Time{}
+	}
+	apiEndpointUp.WithLabelValues(e.urls[i]).Set(1)
+}
+
+// markDown records a failed request to the base URL.
+func (e *apiEndpoints) markDown(ctx context.Context, i int, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.logger.WarnContext(ctx, "Porkbun API endpoint failed", "url", e.urls[i], "error", reason)
+	e.downUntil[i] = e.clock.Now().Add(endpointRetryAfter)
+	apiEndpointUp.WithLabelValues(e.urls[i]).Set(0)
+}
+
+// failoverTransport sends requests addressed to the first base URL to the healthiest base URL and retries them
+// against the next one if it can't be reached or answers with a server error.
+type failoverTransport struct {
+	base      http.RoundTripper
+	endpoints *apiEndpoints
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := strings.CutPrefix(req.URL.String(), t.endpoints.urls[0])
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	candidates := t.endpoints.candidates()
+	for n, i := range candidates {
+		attempt := req.Clone(req.Context())
+		if n > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("unable to replay request body: %v", err)
+			}
+			attempt.Body = body
+		}
+		target, err := url.Parse(t.endpoints.urls[i] + path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build request URL: %v", err)
+		}
+		attempt.URL = target
+		attempt.Host = ""
+
+		resp, err := t.base.RoundTrip(attempt)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			t.endpoints.markUp(i)
+			return resp, nil
+		}
+		// a canceled sync says nothing about the health of the base URL
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		t.endpoints.markDown(req.Context(), i, reason)
+
+		last := n == len(candidates)-1 || (req.Body != nil && req.GetBody == nil)
+		if last {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}
+	return nil, fmt.Errorf("no Porkbun API endpoint configured")
+}
+
+// withFailover wraps the transport of the HTTP client so requests fail over between the base URLs.
+func withFailover(client *http.Client, endpoints *apiEndpoints) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &failoverTransport{base: base, endpoints: endpoints}
+}
@@ -0,0 +1,101 @@
+package porkbun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// domainFilterHolder holds the domain filter of the provider.
+// The filter is never modified in place; updates swap in a new filter (copy-on-write),
+// so Records(), ApplyChanges() and the negotiation always work on a consistent snapshot.
+type domainFilterHolder struct {
+	current atomic.Pointer[domainFilterSnapshot]
+}
+
+// domainFilterSnapshot is a domain filter together with its zones and excluded domains in the form Porkbun uses.
+type domainFilterSnapshot struct {
+	filter   *endpoint.DomainFilter
+	zones    []string
+	exclude  []string
+	excluded []string
+}
+
+func newDomainFilterHolder(filter *endpoint.DomainFilter) *domainFilterHolder {
+	h := &domainFilterHolder{}
+	h.Store(filter, nil)
+	return h
+}
+
+// Load returns the current snapshot of the domain filter, it must not be modified.
+func (h *domainFilterHolder) Load() *endpoint.DomainFilter {
+	return h.current.Load().filter
+}
+
+// Zones returns the zones of the current domain filter normalized with normalizeName. The domain filter keeps
+// internationalized zones in Unicode, while Porkbun and the record names use their ASCII form.
+func (h *domainFilterHolder) Zones() []string {
+	return h.current.Load().zones
+}
+
+// Exclusions returns the excluded domains of the current domain filter as they were configured.
+func (h *domainFilterHolder) Exclusions() []string {
+	return h.current.Load().exclude
+}
+
+// Excluded reports whether the name is one of the excluded domains or lies below one.
+func (h *domainFilterHolder) Excluded(name string) bool {
+	name = normalizeName(name)
+	for _, domain := range h.current.Load().excluded {
+		if inZone(name, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store replaces the domain filter and the domains excluded by it, which the filter must have been created with.
+// The filters are sorted up front because DomainFilter.MarshalJSON sorts them in place,
+// on a sorted snapshot that is a read-only operation and safe for concurrent use.
+func (h *domainFilterHolder) Store(filter *endpoint.DomainFilter, exclude []string) {
+	sort.Strings(filter.Filters)
+	zones := make([]string, 0, len(filter.Filters))
+	for _, zone := range filter.Filters {
+		zones = append(zones, normalizeName(zone))
+	}
+	excluded := make([]string, 0, len(exclude))
+	for _, domain := range exclude {
+		excluded = append(excluded, normalizeName(strings.TrimPrefix(domain, ".")))
+	}
+	h.current.Store(&domainFilterSnapshot{filter: filter, zones: zones, exclude: exclude, excluded: excluded})
+}
+
+// GetDomainFilter returns the domain filter that is sent to external-dns during the negotiation.
+func (p *PorkbunProvider) GetDomainFilter() endpoint.DomainFilterInterface {
+	return p.domainFilter.Load()
+}
+
+// SetDomainFilter replaces the zones managed by the provider at runtime, the excluded domains are kept.
+func (p *PorkbunProvider) SetDomainFilter(domains []string) error {
+	exclude := p.domainFilter.Exclusions()
+	filter := endpoint.NewDomainFilterWithExclusions(domains, exclude)
+	if !filter.IsConfigured() {
+		return fmt.Errorf("porkbun provider requires at least one configured domain in the domainFilter")
+	}
+	p.domainFilter.Store(filter, exclude)
+	p.gone.reset()
+	p.logger.Info("domain filter updated", "domains", filter.Filters)
+	return nil
+}
+
+// endpointZone returns the managed zone of the endpoint like endpointZoneName.
+// returns empty string if the endpoint is outside all zones or in an excluded domain
+func (p *PorkbunProvider) endpointZone(ep *endpoint.Endpoint, zones []string) string {
+	if p.domainFilter.Excluded(ep.DNSName) {
+		return ""
+	}
+	return endpointZoneName(ep, zones)
+}
@@ -0,0 +1,136 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// goneZoneRecheckInterval is the pause between checks whether a gone zone reappeared in the account.
+const goneZoneRecheckInterval = 15 * time.Minute
+
+// zoneGoneMessages are fragments of the messages Porkbun answers with when a domain is not in the account,
+// e.g. after it was transferred away or expired.
+var zoneGoneMessages = []string{
+	"invalid domain",
+	"domain not found",
+	"not in your account",
+}
+
+// isZoneGone reports whether the error means that the domain is not in the Porkbun account any more.
+func isZoneGone(err error) bool {
+	var status pb.Status
+	if !errors.As(err, &status) {
+		return false
+	}
+	message := strings.ToLower(status.Message)
+	for _, fragment := range zoneGoneMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// goneZones keeps the zones that left the Porkbun account, they are excluded from syncs
+// until they reappear or the domain filter changes.
+type goneZones struct {
+	mu    sync.Mutex
+	zones map[string]goneZone
+}
+
+type goneZone struct {
+	since       time.Time
+	lastChecked time.Time
+}
+
+func newGoneZones() *goneZones {
+	return &goneZones{zones: map[string]goneZone{}}
+}
+
+// mark records that the zone was found gone at now.
+func (g *goneZones) mark(zone string, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	z, ok := g.zones[zone]
+	if !ok {
+		z.since = now
+	}
+	z.lastChecked = now
+	g.zones[zone] = z
+	zoneGone.WithLabelValues(zone).Set(1)
+}
+
+// clear forgets that the zone was gone.
+// returns true if the zone was gone
+func (g *goneZones) clear(zone string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.zones[zone]; !ok {
+		return false
+	}
+	delete(g.zones, zone)
+	zoneGone.DeleteLabelValues(zone)
+	return true
+}
+
+// reset forgets all gone zones.
+func (g *goneZones) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for zone := range g.zones {
+		zoneGone.DeleteLabelValues(zone)
+	}
+	g.zones = map[string]goneZone{}
+}
+
+// isGone reports whether the zone is gone.
+func (g *goneZones) isGone(zone string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.zones[zone]
+	return ok
+}
+
+// due reports whether the zone should be fetched: it is not gone or it is time to check whether it reappeared.
+func (g *goneZones) due(zone string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	z, ok := g.zones[zone]
+	return !ok || now.Sub(z.lastChecked) >= goneZoneRecheckInterval
+}
+
+// list returns the gone zones and since when they are gone.
+func (g *goneZones) list() map[string]time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	list := make(map[string]time.Time, len(g.zones))
+	for zone, z := range g.zones {
+		list[zone] = z.since
+	}
+	return list
+}
+
+// activeZones returns the zones that are not gone.
+func (p *PorkbunProvider) activeZones(zones []string) []string {
+	active := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		if !p.gone.isGone(zone) {
+			active = append(active, zone)
+		}
+	}
+	return active
+}
+
+// markZoneGone excludes a zone that left the Porkbun account from the syncs.
+func (p *PorkbunProvider) markZoneGone(ctx context.Context, zone string, err error) {
+	if !p.gone.isGone(zone) {
+		p.logger.WarnContext(ctx, "zone is not in the Porkbun account any more, excluding it from syncs", "zone", zone, "error", err.Error())
+	}
+	p.gone.mark(zone, p.clock.Now())
+	p.health.setZone(zone, nil)
+}
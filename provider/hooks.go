@@ -0,0 +1,102 @@
+package porkbun
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+	"sync"
+
+	pb "github.com/nrdcg/porkbun"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ConversionHook customizes the translation between external-dns endpoints and Porkbun records,
+// e.g. to rewrite names or targets or to apply a custom TXT encoding.
+type ConversionHook interface {
+	// ToRecord is called for every record built from an endpoint, before it is sent to Porkbun.
+	ToRecord(ep *endpoint.Endpoint, record *pb.Record)
+	// FromRecord is called for every endpoint built from a Porkbun record, before it is returned to external-dns.
+	FromRecord(record pb.Record, ep *endpoint.Endpoint)
+}
+
+// conversionHookSymbol is the symbol a Go plugin has to export to provide a ConversionHook.
+const conversionHookSymbol = "ConversionHook"
+
+// errPluginsUnsupported is returned for conversion hook plugins by builds that can't open Go plugins, like the release
+// image, which is built without cgo.
+var errPluginsUnsupported = errors.New("this build can't load conversion hook plugins, they need a custom build of the webhook with CGO_ENABLED=1 and the Go toolchain and dependency versions the plugins are built with")
+
+var (
+	registeredHooksMu sync.Mutex
+	registeredHooks   []ConversionHook
+)
+
+// RegisterConversionHook adds a hook compiled into the binary, it is meant to be called from init functions.
+// Registered hooks are used by every provider created afterwards.
+func RegisterConversionHook(hook ConversionHook) {
+	registeredHooksMu.Lock()
+	defer registeredHooksMu.Unlock()
+	registeredHooks = append(registeredHooks, hook)
+}
+
+// compiledInHooks returns a copy of the registered hooks.
+func compiledInHooks() []ConversionHook {
+	registeredHooksMu.Lock()
+	defer registeredHooksMu.Unlock()
+	return append([]ConversionHook(nil), registeredHooks...)
+}
+
+// LoadConversionHookPlugin opens a Go plugin and returns the ConversionHook it exports.
+// The plugin has to export a variable named ConversionHook that implements the interface, and it has to be built with
+// the same Go toolchain and dependency versions as a webhook binary built with cgo.
+func LoadConversionHookPlugin(path string) (ConversionHook, error) {
+	if !pluginsSupported {
+		return nil, errPluginsUnsupported
+	}
+	plug, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open conversion hook plugin '%s', it must be built with the Go toolchain and dependency versions of the webhook: %v", path, err)
+	}
+	sym, err := plug.Lookup(conversionHookSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("conversion hook plugin '%s' does not export %s: %v", path, conversionHookSymbol, err)
+	}
+
+	// Lookup returns a pointer for exported variables
+	switch hook := sym.(type) {
+	case *ConversionHook:
+		return *hook, nil
+	case ConversionHook:
+		return hook, nil
+	default:
+		return nil, fmt.Errorf("%s exported by conversion hook plugin '%s' has unexpected type %T", conversionHookSymbol, path, sym)
+	}
+}
+
+// applyToRecordHooks runs the hooks on records converted from endpoints, records[i] has to belong to endpoints[i].
+// Hooks may rewrite name and content, so the record IDs not taken from the record ID label
+// are resolved again against the existing records recs of the zone.
+func (p *PorkbunProvider) applyToRecordHooks(endpoints []*endpoint.Endpoint, records *[]pb.Record, recs *[]pb.Record, zone string) {
+	if len(p.hooks) == 0 {
+		return
+	}
+	for _, hook := range p.hooks {
+		for i := range *records {
+			hook.ToRecord(endpoints[i], &(*records)[i])
+		}
+	}
+	for i, record := range *records {
+		if endpoints[i].Labels[RecordIDLabelKey] != "" {
+			continue
+		}
+		(*records)[i].ID = getIDforRecord(recordFQDN(record.Name, zone), recordTarget(record), record.Type, recs)
+	}
+}
+
+// applyFromRecordHooks runs the hooks on an endpoint converted from a record.
+func (p *PorkbunProvider) applyFromRecordHooks(record pb.Record, ep *endpoint.Endpoint) {
+	for _, hook := range p.hooks {
+		hook.FromRecord(record, ep)
+	}
+}
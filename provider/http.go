@@ -0,0 +1,15 @@
+package porkbun
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// writeJSON writes v as JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}, logger *slog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("unable to write response", "error", err.Error())
+	}
+}
@@ -0,0 +1,53 @@
+package porkbun
+
+import (
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// listedLabelKeys maps the endpoint labels restored on listed endpoints, the ones the planner needs to tell whose
+// records they are, to their keys in the metadata of the notes.
+var listedLabelKeys = map[string]string{
+	endpoint.OwnerLabelKey:    notesKeyOwner,
+	endpoint.ResourceLabelKey: notesKeyResource,
+}
+
+// registryLabels returns the labels of the registry TXT records of a zone by normalized name, as read from their
+// heritage=external-dns content.
+func registryLabels(recs []pb.Record) map[string]endpoint.Labels {
+	registry := map[string]endpoint.Labels{}
+	for _, rec := range recs {
+		if rec.Type != endpoint.RecordTypeTXT {
+			continue
+		}
+		if labels, err := endpoint.NewLabelsFromStringPlain(parseTXT(rec.Content)); err == nil {
+			registry[normalizeName(rec.Name)] = labels
+		}
+	}
+	return registry
+}
+
+// setListedLabels restores the owner and resource labels of an endpoint listed from the record: from the metadata
+// this provider wrote into its notes, overridden by the registry TXT record of the endpoint named like txtRecordName
+// or, as written by older external-dns versions, like the record itself. Registry TXT records carry their labels in
+// their content and are left alone.
+func (p *PorkbunProvider) setListedLabels(ep *endpoint.Endpoint, rec pb.Record, registry map[string]endpoint.Labels) {
+	if rec.Type == endpoint.RecordTypeTXT {
+		if _, err := endpoint.NewLabelsFromStringPlain(parseTXT(rec.Content)); err == nil {
+			return
+		}
+	}
+	name := normalizeName(ep.DNSName)
+	labels := registry[p.txtRecordName(name, rec.Type)]
+	if labels == nil {
+		labels = registry[name]
+	}
+	meta := parseNotes(rec.Notes)
+	for key, notesKey := range listedLabelKeys {
+		if value := labels[key]; value != "" {
+			ep.Labels[key] = value
+		} else if value := meta[notesKey]; value != "" {
+			ep.Labels[key] = value
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package porkbun
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// syncRecordContent is the content of the sync record: "last-write=<RFC 3339 time>; writer=<holder>".
+func syncRecordContent(now time.Time) string {
+	return "last-write=" + now.UTC().Format(time.RFC3339) + "; writer=" + lockHolder()
+}
+
+// isSyncRecord reports whether the record is the sync record of the zone, which is not listed to external-dns.
+func (p *PorkbunProvider) isSyncRecord(rec pb.Record, zone string) bool {
+	return p.syncRecordName != "" && rec.Type == endpoint.RecordTypeTXT && normalizeName(rec.Name) == recordFQDN(p.syncRecordName, zone)
+}
+
+// recordZoneWrite records that changes were written to the zone: it exports the time as metric and, if a sync record
+// is configured, writes the time into it, editing the existing sync record in recs if there is one.
+// Failing to write the sync record is logged, the changes were applied anyway.
+func (p *PorkbunProvider) recordZoneWrite(ctx context.Context, zone string, recs []pb.Record) {
+	now := p.clock.Now()
+	zoneLastWrite.WithLabelValues(zone).Set(float64(now.Unix()))
+	if p.syncRecordName == "" {
+		return
+	}
+
+	records := []pb.Record{{Name: p.syncRecordName, Type: endpoint.RecordTypeTXT, Content: syncRecordContent(now)}}
+	stampLastModified(&records, now)
+	record := records[0]
+
+	id, found := 0, false
+	for _, rec := range recs {
+		if p.isSyncRecord(rec, zone) {
+			id, found = parseRecordID(rec.ID)
+			break
+		}
+	}
+	var err error
+	if found {
+		err = p.client.EditRecord(ctx, zone, id, record)
+	}
+	if !found || isRecordNotFound(err) {
+		_, err = p.client.CreateRecord(ctx, zone, record)
+	}
+	if err != nil {
+		p.logger.WarnContext(ctx, "unable to write sync record", "zone", zone, "name", p.syncRecordName, "error", err.Error())
+	}
+}
@@ -0,0 +1,130 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// defaultZoneLockTTL is how long a zone lock written by the webhook is valid if it is not released,
+// e.g. because the webhook crashed while applying changes.
+const defaultZoneLockTTL = 5 * time.Minute
+
+// zoneLock is the content of a lock record: "holder=<name>; expires=<RFC 3339 time>".
+// A lock without expiry is held until its record is removed.
+type zoneLock struct {
+	holder  string
+	expires time.Time
+}
+
+// parseZoneLock reads the content of a lock record. Content in another format is taken as the holder of a lock
+// without expiry, so a lock written by hand is respected as well.
+func parseZoneLock(content string) zoneLock {
+	content = strings.Trim(content, "\"")
+	lock := zoneLock{holder: content}
+	for _, field := range strings.Split(content, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "holder":
+			lock.holder = value
+		case "expires":
+			if expires, err := time.Parse(time.RFC3339, value); err == nil {
+				lock.expires = expires
+			}
+		}
+	}
+	return lock
+}
+
+func (l zoneLock) String() string {
+	return "holder=" + l.holder + "; expires=" + l.expires.UTC().Format(time.RFC3339)
+}
+
+// active reports whether the lock is still held at now.
+func (l zoneLock) active(now time.Time) bool {
+	return l.expires.IsZero() || now.Before(l.expires)
+}
+
+// lockHolder identifies this webhook instance in the zone locks it writes.
+func lockHolder() string {
+	host, _ := os.Hostname()
+	return "external-dns-porkbun-webhook/" + host
+}
+
+// zoneLocks splits the lock records in the records of the zone into the locks of other holders still held at now
+// and the lock records to clean up: expired ones and those left behind by this webhook.
+func (p *PorkbunProvider) zoneLocks(zone string, recs []pb.Record, now time.Time) (held []zoneLock, stale []pb.Record) {
+	fqdn := recordFQDN(p.zoneLockName, zone)
+	for _, rec := range recs {
+		if rec.Type != endpoint.RecordTypeTXT || normalizeName(rec.Name) != fqdn {
+			continue
+		}
+		lock := parseZoneLock(rec.Content)
+		if lock.holder == lockHolder() || !lock.active(now) {
+			stale = append(stale, rec)
+			continue
+		}
+		held = append(held, lock)
+	}
+	return held, stale
+}
+
+// lockZone takes the lock of the zone before changes are applied, so other automation respecting the lock record
+// does not write to the zone at the same time. If another holder has the lock, errZoneLocked is returned.
+// The lock is written and the zone read back to detect a holder that took the lock at the same time, in which case
+// both back off until the next sync.
+// returns the function releasing the lock, which also removes stale lock records
+func (p *PorkbunProvider) lockZone(ctx context.Context, zone string, recs []pb.Record) (func(), error) {
+	if p.zoneLockName == "" {
+		return func() {}, nil
+	}
+
+	now := p.clock.Now()
+	held, stale := p.zoneLocks(zone, recs, now)
+	if len(held) > 0 {
+		return nil, fmt.Errorf("zone '%s' is locked by %s: %w", zone, held[0].holder, errZoneLocked)
+	}
+
+	lock := zoneLock{holder: lockHolder(), expires: now.Add(p.zoneLockTTL)}
+	id, err := p.client.CreateRecord(ctx, zone, pb.Record{Name: p.zoneLockName, Type: endpoint.RecordTypeTXT, Content: lock.String()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock zone '%s': %w", zone, err)
+	}
+
+	release := func() {
+		ids := []string{strconv.Itoa(id)}
+		for _, rec := range stale {
+			ids = append(ids, rec.ID)
+		}
+		for _, recordID := range ids {
+			n, ok := parseRecordID(recordID)
+			err := fmt.Errorf("invalid record ID '%s'", recordID)
+			if ok {
+				err = p.client.DeleteRecord(ctx, zone, n)
+			}
+			if err != nil && !isRecordNotFound(err) {
+				p.logger.WarnContext(ctx, "unable to remove zone lock record, it expires by itself", "zone", zone, "id", recordID, "error", err.Error())
+			}
+		}
+	}
+
+	current, err := p.retrieveRecords(ctx, zone)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("unable to verify lock of zone '%s': %w", zone, err)
+	}
+	if held, _ := p.zoneLocks(zone, current, p.clock.Now()); len(held) > 0 {
+		release()
+		return nil, fmt.Errorf("zone '%s' was locked by %s at the same time: %w", zone, held[0].holder, errZoneLocked)
+	}
+	return release, nil
+}
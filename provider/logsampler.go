@@ -0,0 +1,102 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Classes of high-frequency debug log lines that can be sampled.
+const (
+	logClassEndpoints = "endpoints"
+	logClassPlanning  = "planning"
+	logClassIgnored   = "ignored"
+)
+
+// logSampler emits only the first lines of a class of debug log lines per sync and counts the rest.
+type logSampler struct {
+	mu           sync.Mutex
+	logger       *slog.Logger
+	defaultLimit int
+	limits       map[string]int
+	counts       map[string]int
+}
+
+func newLogSampler(logger *slog.Logger) *logSampler {
+	return &logSampler{
+		logger: logger,
+		limits: map[string]int{},
+		counts: map[string]int{},
+	}
+}
+
+// limit returns the number of lines of the class logged per sync, 0 means all lines are logged.
+func (s *logSampler) limit(class string) int {
+	if limit, ok := s.limits[class]; ok {
+		return limit
+	}
+	return s.defaultLimit
+}
+
+// debug logs a debug line of the class unless the limit of the class is already reached in this sync.
+func (s *logSampler) debug(ctx context.Context, class string, msg string, args ...any) {
+	if !s.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	s.mu.Lock()
+	s.counts[class]++
+	count := s.counts[class]
+	limit := s.limit(class)
+	s.mu.Unlock()
+
+	if limit > 0 && count > limit {
+		return
+	}
+	s.logger.DebugContext(ctx, msg, args...)
+}
+
+// flush logs how many lines per class were suppressed and starts a new sync.
+func (s *logSampler) flush(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for class, count := range s.counts {
+		if limit := s.limit(class); limit > 0 && count > limit {
+			s.logger.DebugContext(ctx, "suppressed debug log lines", "class", class, "logged", limit, "suppressed", count-limit)
+		}
+	}
+	s.counts = map[string]int{}
+}
+
+// isLogClass reports whether class is one of the sampled log classes.
+func isLogClass(class string) bool {
+	switch class {
+	case logClassEndpoints, logClassPlanning, logClassIgnored:
+		return true
+	}
+	return false
+}
+
+// ParseLogSampleLimits parses per class limits given as class=limit.
+func ParseLogSampleLimits(values []string) (map[string]int, error) {
+	limits := make(map[string]int, len(values))
+	for _, value := range values {
+		class, limit, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid log sample limit '%s', expected class=limit", value)
+		}
+		if !isLogClass(class) {
+			return nil, fmt.Errorf("unknown log class '%s', expected one of %s, %s, %s", class, logClassEndpoints, logClassPlanning, logClassIgnored)
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid log sample limit '%s', expected a non-negative number", value)
+		}
+		limits[class] = n
+	}
+	return limits, nil
+}
@@ -0,0 +1,93 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// maintenanceRecheckInterval is the pause between pings checking whether the Porkbun API is back from maintenance.
+const maintenanceRecheckInterval = 30 * time.Second
+
+// errMaintenance is returned instead of calling the Porkbun API while it is in maintenance.
+var errMaintenance = errors.New("porkbun API is in maintenance")
+
+// isMaintenance reports whether the error is a maintenance answer of the Porkbun API.
+func isMaintenance(err error) bool {
+	var status pb.Status
+	var serverErr *pb.ServerError
+	switch {
+	case errors.Is(err, errMaintenance):
+		return true
+	case errors.As(err, &status):
+		return strings.Contains(strings.ToLower(status.Message), "maintenance")
+	case errors.As(err, &serverErr):
+		return strings.Contains(strings.ToLower(serverErr.Message), "maintenance")
+	}
+	return false
+}
+
+// maintenanceState tracks a maintenance of the Porkbun API. While it lasts, changes are paused and records are
+// listed from the cache, the API is only pinged once per recheck interval to find out whether it is back.
+type maintenanceState struct {
+	mu          sync.Mutex
+	since       time.Time
+	lastChecked time.Time
+}
+
+// paused reports whether a maintenance is ongoing and the API is not due to be checked again at now.
+func (m *maintenanceState) paused(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.since.IsZero() || now.Sub(m.lastChecked) >= maintenanceRecheckInterval {
+		return false
+	}
+	return true
+}
+
+// pauseForMaintenance pauses the changes after a maintenance answer of the API.
+func (p *PorkbunProvider) pauseForMaintenance(ctx context.Context, err error) {
+	m := &p.maintenance
+	now := p.clock.Now()
+	m.mu.Lock()
+	started := m.since.IsZero()
+	if started {
+		m.since = now
+	}
+	m.lastChecked = now
+	m.mu.Unlock()
+	if started {
+		apiMaintenance.Set(1)
+		p.logger.WarnContext(ctx, "Porkbun API is in maintenance, pausing changes and listing records from the cache", "error", err.Error())
+	}
+}
+
+// resumeAfterMaintenance resumes the changes once the API answered again.
+func (p *PorkbunProvider) resumeAfterMaintenance(ctx context.Context) {
+	m := &p.maintenance
+	m.mu.Lock()
+	since := m.since
+	m.since = time.Time{}
+	m.mu.Unlock()
+	if !since.IsZero() {
+		apiMaintenance.Set(0)
+		p.logger.InfoContext(ctx, "Porkbun API is back from maintenance, resuming changes", "duration", p.clock.Now().Sub(since).String())
+	}
+}
+
+// maintenanceEndpoints lists the records of all active zones from the cache while the API is in maintenance.
+// returns an error if a zone has not been cached yet
+func (p *PorkbunProvider) maintenanceEndpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	endpoints, _, err := p.cachedEndpoints(ctx)
+	if err != nil {
+		return nil, softError(fmt.Errorf("%w, unable to list records from the cache: %v", errMaintenance, err))
+	}
+	p.logger.DebugContext(ctx, "Porkbun API is in maintenance, listed records from the cache", "endpoints", len(endpoints))
+	return endpoints, nil
+}
@@ -0,0 +1,300 @@
+package porkbun
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const metricsNamespace = "external_dns_porkbun"
+
+// metricUnits are the OpenMetrics units of the metrics measured in a unit, their names end with the unit.
+var metricUnits = map[string]string{
+	prometheus.BuildFQName(metricsNamespace, "", "cache_bytes"):                    "bytes",
+	prometheus.BuildFQName(metricsNamespace, "", "cache_age_seconds"):              "seconds",
+	prometheus.BuildFQName(metricsNamespace, "", "verify_lookup_duration_seconds"): "seconds",
+	prometheus.BuildFQName(metricsNamespace, "", "verify_propagation_seconds"):     "seconds",
+}
+
+var (
+	apiCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_calls_total",
+		Help:      "Number of calls made to the Porkbun API.",
+	}, []string{"zone", "operation"})
+
+	zoneGone = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "zone_gone",
+		Help:      "Set to 1 for managed zones that are not in the Porkbun account any more and are excluded from syncs.",
+	}, []string{"zone"})
+
+	cacheRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_records",
+		Help:      "Number of records held in the zone cache.",
+	})
+
+	cacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_bytes",
+		Help:      "Estimated memory in bytes held by the records in the zone cache.",
+	})
+
+	cacheEvictionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_evictions_total",
+		Help:      "Number of zones evicted from the zone cache to stay within the cached records limit.",
+	})
+
+	pendingCreates = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_creates",
+		Help:      "Number of creates deferred to later syncs by --max-creates-per-sync.",
+	})
+
+	verifyLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "verify_lookups_total",
+		Help:      "Number of propagation lookups by resolver and result (propagated, pending, error).",
+	}, []string{"resolver", "result"})
+
+	verifyLookupSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "verify_lookup_duration_seconds",
+		Help:      "Duration of propagation lookups in seconds by resolver.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"resolver"})
+
+	verifyPropagationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "verify_propagations_total",
+		Help:      "Number of written records by propagation result (propagated once the resolver consensus is reached, timed_out).",
+	}, []string{"result"})
+
+	verifyPropagationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "verify_propagation_seconds",
+		Help:      "Time in seconds from writing a record until the resolver consensus reported it as propagated.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300},
+	})
+
+	skippedEndpointsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "skipped_endpoints_total",
+		Help:      "Number of endpoints skipped by reason (no_zone, unsupported_type, filtered, zone_gone, zone_locked, unmanaged_type, maintenance, empty_targets, not_owned, protected, delegated, duplicate, invalid_target).",
+	}, []string{"reason"})
+
+	apiEndpointUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_endpoint_up",
+		Help:      "Set to 0 while a Porkbun API base URL is passed over after a failed request, 1 otherwise.",
+	}, []string{"url"})
+
+	cutoversTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cutovers_total",
+		Help:      "Number of blue/green cutovers by group and result (succeeded, rolled_back, rollback_failed, failed).",
+	}, []string{"group", "result"})
+
+	rollbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rollbacks_total",
+		Help:      "Number of rollbacks of the changes applied to a zone by a failed sync, by zone and result (rolled_back, rollback_failed).",
+	}, []string{"zone", "result"})
+
+	syncsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "syncs_total",
+		Help:      "Number of syncs with changes by external-dns owner ID and result (succeeded, failed).",
+	}, []string{"owner", "result"})
+
+	recordChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "record_changes_total",
+		Help:      "Number of records written to Porkbun by external-dns owner ID, zone and action (create, update, delete).",
+	}, []string{"owner", "zone", "action"})
+
+	cacheSnapshotWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_snapshot_writes_total",
+		Help:      "Number of cache snapshots written for other replicas to restore by result (succeeded, failed).",
+	}, []string{"result"})
+
+	changeSetSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "change_set_size",
+		Help:      "Number of endpoints per sync with changes by action (create, update, delete).",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{"action"})
+
+	zonesPerSync = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "zones_per_sync",
+		Help:      "Number of zones touched by a sync with changes.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50},
+	})
+
+	apiMaintenance = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_maintenance",
+		Help:      "Set to 1 while the Porkbun API is in maintenance and changes are paused.",
+	})
+
+	apiRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_retries_total",
+		Help:      "Number of retried Porkbun API calls by operation and failure class (network, rate_limit, server_error).",
+	}, []string{"operation", "class"})
+
+	orphanedTXTDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orphaned_txt_records_deleted_total",
+		Help:      "Number of orphaned registry TXT records deleted by the TXT garbage collection, by zone.",
+	}, []string{"zone"})
+
+	zoneLastWrite = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "zone_last_write_timestamp_seconds",
+		Help:      "Unix time of the last sync that wrote changes to the zone.",
+	}, []string{"zone"})
+
+	unchangedUpdatesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "unchanged_updates_total",
+		Help:      "Number of updates skipped because the Porkbun record already matched the desired state.",
+	})
+
+	deleteLimitExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "delete_limit_exceeded_total",
+		Help:      "Number of syncs refused because they would delete more records than --max-deletes-per-sync.",
+	})
+
+	apiCallsLastHour = &usageCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "api_calls_last_hour"),
+			"Number of calls made to the Porkbun API within the last hour.",
+			[]string{"zone"}, nil,
+		),
+	}
+
+	cacheAge = &cacheAgeCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "cache_age_seconds"),
+			"Seconds since the cached records of the zone were fetched from Porkbun, the staleness of the records served.",
+			[]string{"zone"}, nil,
+		),
+	}
+)
+
+// usageCollector reports the API calls within the rolling hour of the API usage of the most recently created provider.
+// The counts are computed at scrape time, so zones without calls drop to zero as the hour rolls over.
+type usageCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.Mutex
+	usage *apiUsage
+}
+
+// observe makes the collector report the API usage.
+func (c *usageCollector) observe(usage *apiUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage = usage
+}
+
+func (c *usageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *usageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	usage := c.usage
+	c.mu.Unlock()
+	if usage == nil {
+		return
+	}
+	for zone, calls := range usage.allLastHour() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(calls), zone)
+	}
+}
+
+// cacheAgeCollector reports the age of the zones in the cache of the most recently created provider.
+// The ages are computed at scrape time, so they keep growing while no zone is fetched.
+type cacheAgeCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.Mutex
+	cache *zoneCache
+	clock Clock
+}
+
+// observe makes the collector report the ages of the zones in the cache.
+func (c *cacheAgeCollector) observe(cache *zoneCache, clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = cache
+	c.clock = clock
+}
+
+func (c *cacheAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *cacheAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	cache, clock := c.cache, c.clock
+	c.mu.Unlock()
+	if cache == nil {
+		return
+	}
+	for zone, age := range cache.ages(clock.Now()) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, age.Seconds(), zone)
+	}
+}
+
+// UnitGatherer sets the OpenMetrics unit of the provider metrics gathered by gatherer,
+// so an encoder writing units exposes them as UNIT metadata.
+func UnitGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		for _, family := range families {
+			if unit, ok := metricUnits[family.GetName()]; ok {
+				family.Unit = &unit
+			}
+		}
+		return families, err
+	})
+}
+
+func init() {
+	prometheus.MustRegister(
+		apiCallsTotal,
+		apiCallsLastHour,
+		cacheAge,
+		zoneGone,
+		cacheRecords,
+		cacheBytes,
+		cacheEvictionsTotal,
+		pendingCreates,
+		verifyLookupsTotal,
+		verifyLookupSeconds,
+		verifyPropagationsTotal,
+		verifyPropagationSeconds,
+		skippedEndpointsTotal,
+		apiEndpointUp,
+		cutoversTotal,
+		rollbacksTotal,
+		syncsTotal,
+		recordChangesTotal,
+		cacheSnapshotWritesTotal,
+		changeSetSize,
+		zonesPerSync,
+		apiRetriesTotal,
+		apiMaintenance,
+		zoneLastWrite,
+		orphanedTXTDeletedTotal,
+		unchangedUpdatesTotal,
+		deleteLimitExceededTotal,
+	)
+}
@@ -0,0 +1,33 @@
+package porkbun
+
+import (
+	"context"
+	"sort"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// chunkCreates limits the creates of a zone to the remaining budget of the sync, so onboarding a zone with
+// hundreds of records is spread over several sync intervals instead of running into the external-dns timeout.
+// external-dns plans the deferred creates again with the next sync. Records created by an earlier chunk are skipped,
+// so a chunk that failed half-way continues where it stopped. TXT records are created first, so a record never
+// exists without the registry record marking it as owned by external-dns.
+// returns the creates to apply now and the number of deferred creates
+func (p *PorkbunProvider) chunkCreates(ctx context.Context, zone string, creates []*endpoint.Endpoint, recs []pb.Record, budget *int) ([]*endpoint.Endpoint, int) {
+	pending := make([]*endpoint.Endpoint, 0, len(creates))
+	for _, ep := range creates {
+		if (*convertToPorkbunRecord(&recs, []*endpoint.Endpoint{ep}, zone, false))[0].ID != "" {
+			p.logger.DebugContext(ctx, "record already created by an earlier sync - skipping", "zone", zone, "endpoint", ep)
+			continue
+		}
+		pending = append(pending, ep)
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].RecordType == endpoint.RecordTypeTXT && pending[j].RecordType != endpoint.RecordTypeTXT
+	})
+
+	take := min(len(pending), *budget)
+	*budget -= take
+	return pending[:take], len(pending) - take
+}
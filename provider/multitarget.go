@@ -0,0 +1,120 @@
+package porkbun
+
+import (
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Porkbun keeps one record per target, while external-dns expects one endpoint per name and type holding all targets.
+// Records of the same name and type are therefore merged into one endpoint when listed, and endpoints are split
+// into one endpoint per target before they are converted into records.
+
+// recordIDSeparator separates the IDs in the record ID label of an endpoint listed from several records,
+// the IDs are in the order of the targets.
+const recordIDSeparator = ","
+
+// mergeTargets merges endpoints of the same name, type and set identifier into one endpoint holding all targets,
+// in the order they were listed. The TTL and labels of the first endpoint are kept, the record IDs are joined.
+// TXT endpoints are not merged, since the external-dns TXT registry reads the ownership only from the first target.
+func mergeTargets(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	merged := make([]*endpoint.Endpoint, 0, len(endpoints))
+	byKey := map[string]*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			merged = append(merged, ep)
+			continue
+		}
+		key := ep.DNSName + "\x00" + ep.RecordType + "\x00" + ep.SetIdentifier
+		first, ok := byKey[key]
+		if !ok {
+			byKey[key] = ep
+			merged = append(merged, ep)
+			continue
+		}
+		first.Targets = append(first.Targets, ep.Targets...)
+		first.Labels[RecordIDLabelKey] += recordIDSeparator + ep.Labels[RecordIDLabelKey]
+	}
+	return merged
+}
+
+// splitTargets splits endpoints with several targets into one endpoint per target, each with the ID of its record.
+// If the record IDs don't line up with the targets, the IDs are dropped and resolved by content instead.
+// Endpoints without targets have no record to write and are dropped.
+func splitTargets(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	split := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if len(ep.Targets) == 0 {
+			continue
+		}
+		if len(ep.Targets) == 1 {
+			split = append(split, ep)
+			continue
+		}
+		ids := strings.Split(ep.Labels[RecordIDLabelKey], recordIDSeparator)
+		for i, target := range ep.Targets {
+			single := ep.DeepCopy()
+			single.Targets = endpoint.Targets{target}
+			if len(ids) == len(ep.Targets) && ids[i] != "" {
+				single.Labels[RecordIDLabelKey] = ids[i]
+			} else {
+				delete(single.Labels, RecordIDLabelKey)
+			}
+			split = append(split, single)
+		}
+	}
+	return split
+}
+
+// splitUpdates splits the updates into updates of single targets, external-dns lists both sides of an update
+// in the same order. A target kept by an update is edited in place, a changed target replaces one of the removed
+// targets in place, targets beyond that are created or deleted.
+// returns both sides of the single target updates in the same order, and the creates and deletes
+func splitUpdates(updateOld []*endpoint.Endpoint, updateNew []*endpoint.Endpoint) (oldSide []*endpoint.Endpoint, newSide []*endpoint.Endpoint, creates []*endpoint.Endpoint, deletes []*endpoint.Endpoint) {
+	pairs := min(len(updateOld), len(updateNew))
+	for i := 0; i < pairs; i++ {
+		olds := splitTargets(updateOld[i : i+1])
+		news := splitTargets(updateNew[i : i+1])
+
+		used := make([]bool, len(olds))
+		var unmatched []*endpoint.Endpoint
+		for _, n := range news {
+			match := -1
+			for j, o := range olds {
+				if !used[j] && sameTarget(o.Targets[0], n.Targets[0]) {
+					match = j
+					break
+				}
+			}
+			if match < 0 {
+				unmatched = append(unmatched, n)
+				continue
+			}
+			used[match] = true
+			oldSide = append(oldSide, olds[match])
+			newSide = append(newSide, n)
+		}
+		for j, o := range olds {
+			if used[j] {
+				continue
+			}
+			if len(unmatched) > 0 {
+				oldSide = append(oldSide, o)
+				newSide = append(newSide, unmatched[0])
+				unmatched = unmatched[1:]
+				continue
+			}
+			deletes = append(deletes, o)
+		}
+		creates = append(creates, unmatched...)
+	}
+	// unpaired updates keep their place behind the pairs
+	oldSide = append(oldSide, splitTargets(updateOld[pairs:])...)
+	newSide = append(newSide, splitTargets(updateNew[pairs:])...)
+	return oldSide, newSide, creates, deletes
+}
+
+// sameTarget reports whether two targets write the same record content.
+func sameTarget(a string, b string) bool {
+	return strings.Trim(a, "\"") == strings.Trim(b, "\"")
+}
@@ -0,0 +1,106 @@
+package porkbun
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// normalizeName returns a DNS name without trailing dot in lower case, with internationalized labels in the ASCII
+// form Porkbun uses, e.g. bücher.example as xn--bcher-kva.example.
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if isASCII(name) {
+		return name
+	}
+	if ascii, err := idna.Punycode.ToASCII(name); err == nil {
+		return ascii
+	}
+	return name
+}
+
+// inZone reports whether the normalized name is the zone or lies below it. Names are compared on label boundaries,
+// so notexample.com is not in example.com.
+func inZone(name string, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// Porkbun names records in two forms: records are written with the name relative to the zone, empty or @ for the
+// apex, and listed with the fully qualified name, which for the apex may be @ or @ followed by the zone.
+// external-dns names endpoints fully qualified. listedName and relativeName convert between the forms; names are
+// never split on dots, so e.g. a.b.example.com keeps all its labels whichever zone it is in.
+
+// listedName returns the normalized name of a record listed from the zone. Porkbun denotes the zone apex as @,
+// alone or followed by the zone, or leaves it empty. Other names are taken as they are, including labels starting
+// with an underscore like _acme-challenge or _matrix._tcp, and an @ label anywhere else does not denote the apex.
+func listedName(name string, zone string) string {
+	name = normalizeName(name)
+	if name == "" || name == "@" || name == "@."+zone {
+		return zone
+	}
+	return name
+}
+
+// relativeName returns the name of a record in the zone as written to Porkbun: the fully qualified, normalized name
+// without the zone, or empty string for the apex.
+func relativeName(name string, zone string) string {
+	if name == zone {
+		return ""
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// recordFQDN returns the fully qualified name of a record name relative to the zone, the reverse of relativeName.
+// An empty name and @ denote the apex.
+func recordFQDN(name string, zone string) string {
+	if name == "" || name == "@" {
+		return zone
+	}
+	return name + "." + zone
+}
+
+// normalizeTarget returns a target in the form it is compared in: the host name of host record types normalized like
+// names with normalizeName, e.g. "10 Mail.Example.com." as "10 mail.example.com". Other targets are returned as they are.
+func normalizeTarget(recordType string, target string) string {
+	if !hostTarget(recordType) {
+		return target
+	}
+	i := strings.LastIndexByte(target, ' ')
+	return target[:i+1] + normalizeName(target[i+1:])
+}
+
+// hostTarget reports whether the targets of the record type are host names, or end with one like MX and SRV targets.
+func hostTarget(recordType string) bool {
+	switch recordType {
+	case endpoint.RecordTypeCNAME, recordTypeALIAS, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypeSRV:
+		return true
+	}
+	return false
+}
+
+// isASCII reports whether s holds ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiTarget converts an internationalized host name in a target of a host record type to the ASCII form Porkbun
+// stores, e.g. "10 mail.bücher.example" to "10 mail.xn--bcher-kva.example", so desired and listed targets compare
+// equal. Other targets, and host names that are no valid IDN, are returned as they are.
+func asciiTarget(recordType string, target string) string {
+	if !hostTarget(recordType) || isASCII(target) {
+		return target
+	}
+	i := strings.LastIndexByte(target, ' ')
+	ascii, err := idna.Punycode.ToASCII(strings.ToLower(target[i+1:]))
+	if err != nil {
+		return target
+	}
+	return target[:i+1] + ascii
+}
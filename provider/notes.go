@@ -0,0 +1,141 @@
+package porkbun
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// notesPrefix marks the Porkbun notes field as written by this provider.
+const notesPrefix = "external-dns:"
+
+const (
+	notesKeyLastModified = "last-modified"
+	notesKeyOwner        = "owner"
+	notesKeyResource     = "resource"
+)
+
+// parseNotes extracts the metadata this provider keeps in the notes field of a Porkbun record.
+// The metadata follows any text an operator wrote into the notes.
+// returns nil if the notes were not written by this provider
+func parseNotes(notes string) map[string]string {
+	i := strings.Index(notes, notesPrefix)
+	if i < 0 {
+		return nil
+	}
+
+	meta := map[string]string{}
+	for _, field := range strings.Split(notes[i+len(notesPrefix):], ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found || key == "" {
+			continue
+		}
+		meta[key] = value
+	}
+	return meta
+}
+
+// formatNotes serializes metadata into the notes field of a Porkbun record.
+// Keys are sorted so that unchanged metadata always produces the same notes.
+func formatNotes(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, key+"="+meta[key])
+	}
+	return notesPrefix + " " + strings.Join(fields, "; ")
+}
+
+// operatorNotes returns the part of the notes that was not written by this provider.
+func operatorNotes(notes string) string {
+	if i := strings.Index(notes, notesPrefix); i >= 0 {
+		notes = notes[:i]
+	}
+	return strings.TrimSpace(notes)
+}
+
+// existingNotes returns the notes of the existing record with the ID.
+// returns false if the record has no ID or does not exist
+func existingNotes(id string, recs []pb.Record) (string, bool) {
+	if id == "" {
+		return "", false
+	}
+	for _, rec := range recs {
+		if rec.ID == id {
+			return rec.Notes, true
+		}
+	}
+	return "", false
+}
+
+// keepOperatorNotes puts the text an operator wrote into the notes of the existing records
+// in front of the metadata of the records written to the same IDs, unless the endpoint brought its own notes.
+func keepOperatorNotes(records *[]pb.Record, recs []pb.Record) {
+	for i := range *records {
+		notes, ok := existingNotes((*records)[i].ID, recs)
+		if !ok || operatorNotes((*records)[i].Notes) != "" {
+			continue
+		}
+		if operator := operatorNotes(notes); operator != "" {
+			(*records)[i].Notes = strings.TrimSpace(operator + " " + (*records)[i].Notes)
+		}
+	}
+}
+
+// endpointNotes builds the notes for a record created from the endpoint: the notes of its notes property, followed by
+// the owner ID and the originating Kubernetes resource (e.g. ingress/default/web) taken from the endpoint's labels
+// so the owner of a record is visible in the Porkbun console and listed again, and the protection of the record.
+// returns empty string if the endpoint carries nothing worth noting
+func endpointNotes(ep *endpoint.Endpoint) string {
+	notes, _ := ep.GetProviderSpecificProperty(ProviderSpecificNotes)
+	notes = cleanNotes(notes)
+	meta := map[string]string{}
+	if owner := ep.Labels[endpoint.OwnerLabelKey]; owner != "" {
+		meta[notesKeyOwner] = owner
+	}
+	if resource := ep.Labels[endpoint.ResourceLabelKey]; resource != "" {
+		meta[notesKeyResource] = resource
+	}
+	if endpointProtected(ep) {
+		meta[notesKeyProtected] = "true"
+	}
+	if len(meta) > 0 {
+		notes = strings.TrimSpace(notes + " " + formatNotes(meta))
+	}
+	return notes
+}
+
+// stampLastModified records the time of the write in the notes of every record.
+func stampLastModified(records *[]pb.Record, now time.Time) {
+	for i := range *records {
+		meta := parseNotes((*records)[i].Notes)
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta[notesKeyLastModified] = now.UTC().Format(time.RFC3339)
+		(*records)[i].Notes = strings.TrimSpace(operatorNotes((*records)[i].Notes) + " " + formatNotes(meta))
+	}
+}
+
+// lastModified returns the last write time stamped into the notes of a record.
+// returns false if the record carries no valid stamp
+func lastModified(rec pb.Record) (time.Time, bool) {
+	value, ok := parseNotes(rec.Notes)[notesKeyLastModified]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,28 @@
+package porkbun
+
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// managesNS reports whether the NS records of the name in the zone are managed. NS records are only managed with
+// --manage-ns-records and only below the apex, so the delegation of the zone itself is never changed.
+func (p *PorkbunProvider) managesNS(name string, zone string) bool {
+	return p.manageNS && name != zone
+}
+
+// rejectUnmanagedNS drops the desired NS endpoints that are not managed, so external-dns neither creates nor
+// deletes delegations by accident.
+func (p *PorkbunProvider) rejectUnmanagedNS(endpoints []*endpoint.Endpoint, zones []string) []*endpoint.Endpoint {
+	managed := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.RecordType == endpoint.RecordTypeNS {
+			name := normalizeName(ep.DNSName)
+			if !p.managesNS(name, endpointZoneName(ep, zones)) {
+				p.logger.Warn("rejecting NS endpoint, NS records are only managed for subzones with --manage-ns-records", "endpoint", ep.DNSName)
+				continue
+			}
+		}
+		managed = append(managed, ep)
+	}
+	return managed
+}
@@ -0,0 +1,152 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// The applied changes can be written as nsupdate scripts (RFC 2136 dynamic updates), one block per zone and sync:
+//
+//	; 2026-01-02T15:04:05Z external-dns-porkbun-webhook correlation=3f2a9c1d owner=cluster-a
+//	zone example.com.
+//	update delete www.example.com. IN A 192.0.2.1
+//	update add www.example.com. 600 IN A 192.0.2.2
+//	send
+//
+// An edited record is written as the delete of its previous content and the add of the new one.
+
+// changeScriptWriter writes the applied changes to a sink, blocks of concurrent syncs are not interleaved.
+type changeScriptWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	logger *slog.Logger
+	// cipher encrypts every block into one line if set
+	cipher *OutputCipher
+}
+
+// write writes the script of a zone, scripts without changes are not written.
+func (s *changeScriptWriter) write(ctx context.Context, now time.Time, script *changeScript) {
+	if s == nil || script == nil {
+		return
+	}
+	script.mu.Lock()
+	lines := script.lines
+	script.mu.Unlock()
+	if len(lines) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "; %s external-dns-porkbun-webhook", now.UTC().Format(time.RFC3339))
+	if id := correlationID(ctx); id != "" {
+		fmt.Fprintf(&b, " correlation=%s", id)
+	}
+	if owner := ownerID(ctx); owner != "" {
+		fmt.Fprintf(&b, " owner=%s", owner)
+	}
+	fmt.Fprintf(&b, "\nzone %s.\n", script.zone)
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteString("send\n")
+	block := b.String()
+	if s.cipher != nil {
+		sealed, err := s.cipher.seal([]byte(block))
+		if err != nil {
+			s.logger.ErrorContext(ctx, "unable to encrypt change log", "zone", script.zone, "error", err.Error())
+			return
+		}
+		block = string(sealed) + "\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.w, block); err != nil {
+		s.logger.ErrorContext(ctx, "unable to write change log", "zone", script.zone, "error", err.Error())
+	}
+}
+
+// changeScript collects the nsupdate lines of the changes applied to a zone.
+type changeScript struct {
+	zone string
+	// previous are the records of the zone before the changes by ID, to write the delete of edited records
+	previous map[string]pb.Record
+
+	mu    sync.Mutex
+	lines []string
+}
+
+type changeScriptKey struct{}
+
+// withChangeScript returns a context collecting the changes applied to the zone into a new script.
+func withChangeScript(ctx context.Context, zone string, recs []pb.Record) (context.Context, *changeScript) {
+	script := &changeScript{zone: zone, previous: make(map[string]pb.Record, len(recs))}
+	for _, rec := range recs {
+		script.previous[rec.ID] = rec
+	}
+	return context.WithValue(ctx, changeScriptKey{}, script), script
+}
+
+// recordChangeScript adds an applied change to the script carried by the context, if any.
+func recordChangeScript(ctx context.Context, action string, record pb.Record) {
+	script, _ := ctx.Value(changeScriptKey{}).(*changeScript)
+	if script == nil {
+		return
+	}
+	var lines []string
+	name := recordFQDN(record.Name, script.zone)
+	switch action {
+	case "create":
+		lines = []string{"update add " + rrLine(name, record, true)}
+	case "delete":
+		lines = []string{"update delete " + rrLine(name, record, false)}
+	case "update":
+		if previous, ok := script.previous[record.ID]; ok {
+			lines = append(lines, "update delete "+rrLine(normalizeName(previous.Name), previous, false))
+		} else {
+			lines = append(lines, fmt.Sprintf("; previous content of record %s unknown", record.ID))
+		}
+		lines = append(lines, "update add "+rrLine(name, record, true))
+	}
+
+	script.mu.Lock()
+	defer script.mu.Unlock()
+	script.lines = append(script.lines, lines...)
+}
+
+// rrLine formats a record with the fully qualified name as resource record in zone file format, with the TTL
+// only if withTTL is set since deletes match any TTL.
+func rrLine(name string, record pb.Record, withTTL bool) string {
+	name += "."
+	if withTTL {
+		ttl := record.TTL
+		if ttl == "" {
+			ttl = pb.DefaultTTL
+		}
+		name += " " + ttl
+	}
+	return fmt.Sprintf("%s IN %s %s", name, record.Type, rrData(record))
+}
+
+// rrData returns the data of a record in zone file format: TXT values quoted, host names fully qualified.
+func rrData(record pb.Record) string {
+	data := recordTarget(record)
+	switch record.Type {
+	case endpoint.RecordTypeTXT:
+		data = quoteTXT(data)
+	case endpoint.RecordTypeCNAME, recordTypeALIAS, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypeSRV:
+		if !strings.HasSuffix(data, ".") {
+			data += "."
+		}
+	}
+	return data
+}
@@ -0,0 +1,44 @@
+package porkbun
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxExactFloat is the largest integer a float64 holds exactly, larger values in scientific notation are rejected.
+const maxExactFloat = 1 << 53
+
+// parseNumber parses a numeric field of a Porkbun record, which the API returns as string. Besides plain integers,
+// surrounding whitespace, a zero fraction like "600.0" and scientific notation like "6e2" are accepted, the API
+// returned them for records saved by the Porkbun console. Empty, negative, fractional and other values are not.
+func parseNumber(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, n >= 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > maxExactFloat || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// normalizeNumber returns a numeric field of a Porkbun record as plain integer, e.g. "600" for "6e2".
+// returns empty string if the value is not a valid number
+func normalizeNumber(value string) string {
+	n, ok := parseNumber(value)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// parseRecordID parses the ID of a Porkbun record for the edit and delete calls, IDs start at 1.
+func parseRecordID(value string) (int, bool) {
+	n, ok := parseNumber(value)
+	return int(n), ok && n > 0
+}
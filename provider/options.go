@@ -0,0 +1,382 @@
+package porkbun
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures optional behaviour of the PorkbunProvider.
+type Option func(*PorkbunProvider)
+
+// WithStaleAfter sets the age after which a managed record is reported as stale.
+func WithStaleAfter(staleAfter time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.staleAfter = staleAfter
+	}
+}
+
+// WithAPICallWarningThreshold sets the number of API calls per zone and hour above which a warning is logged.
+// A threshold of 0 disables the warning.
+func WithAPICallWarningThreshold(callsPerHour int) Option {
+	return func(p *PorkbunProvider) {
+		p.usage.warnPerHour = callsPerHour
+	}
+}
+
+// WithConversionHooks adds hooks that customize the translation between endpoints and records.
+// They run after the hooks registered with RegisterConversionHook.
+func WithConversionHooks(hooks ...ConversionHook) Option {
+	return func(p *PorkbunProvider) {
+		p.hooks = append(p.hooks, hooks...)
+	}
+}
+
+// WithMaxPageSize enables pagination of record listings for clients that request it, limited to pageSize records per page.
+// A page size of 0 always serves the complete listing.
+func WithMaxPageSize(pageSize int) Option {
+	return func(p *PorkbunProvider) {
+		p.maxPageSize = pageSize
+	}
+}
+
+// WithClock replaces the system clock, e.g. to control time in tests.
+func WithClock(clock Clock) Option {
+	return func(p *PorkbunProvider) {
+		p.clock = clock
+		p.usage.clock = clock
+	}
+}
+
+// WithClockSkewTolerance sets how far timestamps may lie in the future before a clock skew warning is logged.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.clockSkewTolerance = tolerance
+	}
+}
+
+// WithLogSampling limits the high-frequency debug log lines to the first lines per sync.
+// defaultLimit applies to all classes without an entry in limits, a limit of 0 logs all lines.
+func WithLogSampling(defaultLimit int, limits map[string]int) Option {
+	return func(p *PorkbunProvider) {
+		p.sampler.defaultLimit = defaultLimit
+		for class, limit := range limits {
+			p.sampler.limits[class] = limit
+		}
+	}
+}
+
+// WithClusterID adds an identifier of the cluster to the User-Agent sent to Porkbun.
+func WithClusterID(clusterID string) Option {
+	return func(p *PorkbunProvider) {
+		p.clusterID = clusterID
+	}
+}
+
+// WithRequestHeaders adds static headers to every request sent to Porkbun.
+// A User-Agent given here replaces the default one.
+func WithRequestHeaders(headers http.Header) Option {
+	return func(p *PorkbunProvider) {
+		p.headers = headers
+	}
+}
+
+// WithCNAMETargetCheck sets how CNAME and ALIAS targets outside the managed zones or pointing at missing names are handled:
+// TargetCheckOff skips the check, TargetCheckWarn logs them and TargetCheckBlock skips the changes writing them.
+func WithCNAMETargetCheck(mode string) Option {
+	return func(p *PorkbunProvider) {
+		p.targetCheck = mode
+	}
+}
+
+// WithEmptyTargets sets how changes of endpoints without targets are handled: EmptyTargetsSkip skips and reports
+// them, EmptyTargetsDelete deletes all records of their name and type.
+func WithEmptyTargets(policy string) Option {
+	return func(p *PorkbunProvider) {
+		p.emptyTargets = policy
+	}
+}
+
+// WithTypeOrder sets the order of record types in which the records of a zone are created, e.g. TXT before A
+// so registry records land before the records they own. Records are deleted in reverse order.
+// CNAME and ALIAS targets are still created before the records pointing at them.
+func WithTypeOrder(types ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.typeOrder = types
+	}
+}
+
+// WithCacheLimit limits the number of records cached over all zones. When the limit is exceeded,
+// the least recently synced zones are evicted. A limit of 0 caches all zones.
+func WithCacheLimit(maxRecords int) Option {
+	return func(p *PorkbunProvider) {
+		p.cache.maxRecords = maxRecords
+	}
+}
+
+// WithMaxResponseSize fails record API calls whose response is larger than maxBytes,
+// so a single giant zone can't exhaust the memory. A size of 0 allows responses of any size.
+func WithMaxResponseSize(maxBytes int64) Option {
+	return func(p *PorkbunProvider) {
+		p.maxResponseBytes = maxBytes
+	}
+}
+
+// WithMaxCreatesPerSync limits the records created per sync, deferring the rest to the next syncs,
+// e.g. to migrate a zone with hundreds of records. A limit of 0 creates all records at once.
+func WithMaxCreatesPerSync(maxCreates int) Option {
+	return func(p *PorkbunProvider) {
+		p.maxCreatesPerSync = maxCreates
+	}
+}
+
+// WithMaxDeletesPerSync refuses to apply the changes of a sync that delete more records, e.g. after a misconfigured
+// source lost all its endpoints. A limit of 0 allows any number of deletes.
+func WithMaxDeletesPerSync(maxDeletes int) Option {
+	return func(p *PorkbunProvider) {
+		p.maxDeletesPerSync = maxDeletes
+	}
+}
+
+// WithPropagationCheck checks every written record against the resolvers until at least the consensus share of them
+// (between 0 and 1) answers with it, and reports records that did not propagate within the window.
+// The check runs in the background and does not delay or fail the sync.
+func WithPropagationCheck(consensus float64, window time.Duration, resolvers ...Resolver) Option {
+	return func(p *PorkbunProvider) {
+		p.verifyConsensus = consensus
+		p.verifyWindow = window
+		p.resolvers = resolvers
+	}
+}
+
+// WithCredentialsSource obtains the API keys for every API call from the source instead of
+// the keys the provider was created with, which may be empty then.
+func WithCredentialsSource(source CredentialsSource) Option {
+	return func(p *PorkbunProvider) {
+		p.credentials = source
+	}
+}
+
+// WithApplyConcurrency applies the changes to up to concurrency record names of a zone in parallel.
+// The changes to one name are still applied one after another. A concurrency of 0 or 1 applies all changes in sequence.
+func WithApplyConcurrency(concurrency int) Option {
+	return func(p *PorkbunProvider) {
+		p.applyConcurrency = concurrency
+	}
+}
+
+// WithChangeOrder sets the order in which the operations of a zone are applied: ChangeOrderDeleteFirst deletes
+// records before creating and updating records, ChangeOrderCreateFirst deletes them last unless they block a create.
+func WithChangeOrder(order string) Option {
+	return func(p *PorkbunProvider) {
+		p.changeOrder = order
+	}
+}
+
+// WithBaseURLs sends the API calls to the first of the Porkbun API base URLs, e.g. a caching proxy, instead of
+// api.porkbun.com. Further base URLs are failed over to while the earlier ones can't be reached or answer with
+// server errors.
+func WithBaseURLs(urls ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.baseURLs = make([]string, 0, len(urls))
+		for _, u := range urls {
+			if !strings.HasSuffix(u, "/") {
+				u += "/"
+			}
+			p.baseURLs = append(p.baseURLs, u)
+		}
+	}
+}
+
+// WithZoneLock makes the provider respect the TXT record name (relative to the zone) as lock of every zone, and hold it
+// while applying changes, so other automation like Terraform or scripts can coordinate with the webhook.
+// Locks written by the webhook expire after ttl if they are not released. An empty name disables the lock.
+func WithZoneLock(name string, ttl time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.zoneLockName = normalizeName(name)
+		p.zoneLockTTL = ttl
+	}
+}
+
+// WithSyncRecord makes the provider write the time of its last changes to a zone into the TXT record name (relative
+// to the zone), so the sync can be audited from the zone itself. An empty name disables the record.
+func WithSyncRecord(name string) Option {
+	return func(p *PorkbunProvider) {
+		p.syncRecordName = normalizeName(name)
+	}
+}
+
+// WithApexAlias turns CNAME endpoints at a zone apex into ALIAS records, which Porkbun resolves like a CNAME
+// but allows next to the other records of the apex.
+func WithApexAlias(enabled bool) Option {
+	return func(p *PorkbunProvider) {
+		p.apexAlias = enabled
+	}
+}
+
+// WithCutoverGroups configures the groups of records the admin API can switch between blue and green targets.
+func WithCutoverGroups(groups ...CutoverGroup) Option {
+	return func(p *PorkbunProvider) {
+		p.cutover.groups = groups
+	}
+}
+
+// WithChangeLog writes every change applied to Porkbun to w as nsupdate script, so changes can be reviewed
+// in the familiar RFC 2136 form and replayed against other DNS servers.
+func WithChangeLog(w io.Writer) Option {
+	return func(p *PorkbunProvider) {
+		if w != nil {
+			p.changeScripts = &changeScriptWriter{w: w, logger: p.logger}
+		}
+	}
+}
+
+// WithOutputEncryption encrypts the outputs holding full record contents with the cipher: the change log and the
+// cache snapshots. Snapshots that can't be decrypted are not restored.
+func WithOutputEncryption(c *OutputCipher) Option {
+	return func(p *PorkbunProvider) {
+		p.outputCipher = c
+	}
+}
+
+// WithManageNS lets external-dns create and delete the NS records delegating subzones, which are ignored otherwise.
+// The NS records of the zone apex are never managed.
+func WithManageNS(enabled bool) Option {
+	return func(p *PorkbunProvider) {
+		p.manageNS = enabled
+	}
+}
+
+// WithTXTRegistry sets the --txt-prefix, --txt-suffix and --txt-wildcard-replacement external-dns runs with,
+// so the registry TXT records of an endpoint can be found for ownership transfers.
+func WithTXTRegistry(prefix string, suffix string, wildcardReplacement string) Option {
+	return func(p *PorkbunProvider) {
+		p.txtPrefix = prefix
+		p.txtSuffix = suffix
+		p.txtWildcardReplacement = wildcardReplacement
+	}
+}
+
+// WithTXTGC deletes registry TXT records of external-dns whose records are gone every interval, 0 disables it.
+// Only registry TXT records owned by one of the owner IDs are deleted. RunTXTGC runs the garbage collection.
+func WithTXTGC(interval time.Duration, owners ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.txtGCInterval = interval
+		p.txtGCOwners = owners
+	}
+}
+
+// WithOwnershipGuard refuses updates and deletes of records without a registry TXT record of external-dns in their
+// zone, so records created in the Porkbun console are never changed by external-dns.
+func WithOwnershipGuard(enabled bool) Option {
+	return func(p *PorkbunProvider) {
+		p.ownershipGuard = enabled
+	}
+}
+
+// WithExcludeDomains excludes domains below the zones of the domain filter, e.g. internal.example.com of the zone
+// example.com. Records of the excluded domains and below are neither listed nor changed, and the domain filter sent
+// to external-dns excludes them.
+func WithExcludeDomains(domains ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.excludeDomains = domains
+	}
+}
+
+// WithZoneCredentials configures domain-scoped API keys, used instead of the account keys for all calls concerning
+// their zone. The account keys are not required if every zone of the domain filter has its own keys.
+func WithZoneCredentials(credentials ...ZoneCredentials) Option {
+	return func(p *PorkbunProvider) {
+		p.zoneCredentials = credentials
+	}
+}
+
+// WithRecordsMaxAge sets the freshness lifetime announced in the Cache-Control header of record listings,
+// 0 announces no-cache. A longer lifetime is announced while the API usage is at the warning threshold.
+func WithRecordsMaxAge(maxAge time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.recordsMaxAge = maxAge
+	}
+}
+
+// WithMinTTL sets the minimum TTL desired TTLs are raised to, e.g. to keep records cached longer than Porkbun requires.
+// TTLs below the Porkbun minimum of 600 seconds are raised to the Porkbun minimum.
+func WithMinTTL(ttl int64) Option {
+	return func(p *PorkbunProvider) {
+		p.minTTL = max(ttl, porkbunMinTTL)
+	}
+}
+
+// WithDefaultTTL sets the TTL of endpoints without TTL, so their records converge on it instead of whatever TTL
+// Porkbun or the console gave them. 0 leaves the TTL of these records to Porkbun.
+func WithDefaultTTL(ttl int64) Option {
+	return func(p *PorkbunProvider) {
+		p.defaultTTL = ttl
+	}
+}
+
+// WithDeepHealthCheck sets the timeout of the live Porkbun ping of the deep health check, and the interval
+// within which its result is reused. An interval of 0 pings Porkbun for every check.
+func WithDeepHealthCheck(timeout time.Duration, interval time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		if timeout > 0 {
+			p.deepHealthTimeout = timeout
+		}
+		p.deepHealthInterval = interval
+	}
+}
+
+// WithCacheSnapshots restores the zone cache from the snapshot in store at startup, using the zones fetched within
+// maxAge, and writes the cache to the store after record listings, at most once per interval.
+func WithCacheSnapshots(store SnapshotStore, maxAge time.Duration, interval time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.snapshots.store = store
+		p.snapshots.maxAge = maxAge
+		p.snapshots.interval = interval
+	}
+}
+
+// WithManagedTypes restricts the record types that are listed to external-dns and accepted from it.
+// Without types all record types are managed.
+func WithManagedTypes(recordTypes ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.managedTypes = nil
+		for _, recordType := range recordTypes {
+			p.managedTypes = append(p.managedTypes, strings.ToUpper(strings.TrimSpace(recordType)))
+		}
+	}
+}
+
+// WithExcludedTypes excludes record types from being listed to external-dns and accepted from it,
+// e.g. MX records maintained in the Porkbun console.
+func WithExcludedTypes(recordTypes ...string) Option {
+	return func(p *PorkbunProvider) {
+		p.excludedTypes = nil
+		for _, recordType := range recordTypes {
+			p.excludedTypes = append(p.excludedTypes, strings.ToUpper(strings.TrimSpace(recordType)))
+		}
+	}
+}
+
+// WithRequestTimeout bounds every Porkbun API call, 0 disables the timeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(p *PorkbunProvider) {
+		p.requestTimeout = timeout
+	}
+}
+
+// WithRollbackOnFailure undoes the changes applied to a zone when a later change of the zone fails in the same sync,
+// so the zone is not left half-changed.
+func WithRollbackOnFailure(enabled bool) Option {
+	return func(p *PorkbunProvider) {
+		p.rollbackOnFailure = enabled
+	}
+}
+
+// WithRetryPolicies retries failed API calls per kind of call, e.g. reads aggressively while deletes are never retried.
+func WithRetryPolicies(policies RetryPolicies) Option {
+	return func(p *PorkbunProvider) {
+		p.retryPolicies = policies
+	}
+}
@@ -0,0 +1,164 @@
+package porkbun
+
+import (
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// orderByDependencies sorts endpoints so that the target of a CNAME or ALIAS is placed before the record pointing at it,
+// so that records created in one sync never dangle. Otherwise the original order is kept.
+// Endpoints taking part in a loop keep their original order.
+// Only endpoints of one zone are ordered, orderZones takes care of records pointing into other zones.
+func orderByDependencies(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	names := map[string][]int{}
+	for i, ep := range endpoints {
+		name := normalizeName(ep.DNSName)
+		names[name] = append(names[name], i)
+	}
+
+	// dependents[i] lists the endpoints waiting for endpoint i to be placed
+	pending := make([]int, len(endpoints))
+	dependents := make([][]int, len(endpoints))
+	for i, ep := range endpoints {
+		if !aliasType(ep.RecordType) {
+			continue
+		}
+		for _, target := range ep.Targets {
+			for _, j := range names[normalizeName(target)] {
+				if j == i {
+					continue
+				}
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	ordered := make([]*endpoint.Endpoint, 0, len(endpoints))
+	placed := make([]bool, len(endpoints))
+	var place func(i int)
+	place = func(i int) {
+		placed[i] = true
+		ordered = append(ordered, endpoints[i])
+		for _, d := range dependents[i] {
+			pending[d]--
+			if pending[d] == 0 && !placed[d] {
+				place(d)
+			}
+		}
+	}
+	for i := range endpoints {
+		if pending[i] == 0 && !placed[i] {
+			place(i)
+		}
+	}
+	// whatever is left is part of a loop
+	for i := range endpoints {
+		if !placed[i] {
+			ordered = append(ordered, endpoints[i])
+		}
+	}
+	return ordered
+}
+
+// orderByType sorts endpoints stably by the position of their record type in order.
+// Endpoints of types missing in order follow the others in their original order.
+func orderByType(endpoints []*endpoint.Endpoint, order []string) []*endpoint.Endpoint {
+	if len(order) == 0 {
+		return endpoints
+	}
+	rank := make(map[string]int, len(order))
+	for i, recordType := range order {
+		rank[strings.ToUpper(recordType)] = i
+	}
+	rankOf := func(ep *endpoint.Endpoint) int {
+		if r, ok := rank[ep.RecordType]; ok {
+			return r
+		}
+		return len(order)
+	}
+
+	ordered := append([]*endpoint.Endpoint(nil), endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rankOf(ordered[i]) < rankOf(ordered[j])
+	})
+	return ordered
+}
+
+// orderZones sorts the zones so that a zone creating the target of a CNAME or ALIAS created in another zone is applied
+// first.
+// Otherwise the original order is kept, zones depending on each other keep their original order.
+func orderZones(zones []string, changes map[string]*plan.Changes) []string {
+	created := map[string]string{}
+	for _, zone := range zones {
+		for _, ep := range changes[zone].Create {
+			created[normalizeName(ep.DNSName)] = zone
+		}
+	}
+
+	// dependsOn[zone] lists the zones creating targets of CNAME and ALIAS records created in the zone
+	dependsOn := map[string]map[string]bool{}
+	for _, zone := range zones {
+		for _, ep := range changes[zone].Create {
+			if !aliasType(ep.RecordType) {
+				continue
+			}
+			for _, target := range ep.Targets {
+				if other, ok := created[normalizeName(target)]; ok && other != zone {
+					if dependsOn[zone] == nil {
+						dependsOn[zone] = map[string]bool{}
+					}
+					dependsOn[zone][other] = true
+				}
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(zones))
+	placed := map[string]bool{}
+	for {
+		next := ""
+		for _, zone := range zones {
+			if placed[zone] {
+				continue
+			}
+			ready := true
+			for other := range dependsOn[zone] {
+				if !placed[other] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = zone
+				break
+			}
+		}
+		// the remaining zones depend on each other
+		if next == "" {
+			for _, zone := range zones {
+				if !placed[zone] {
+					next = zone
+					break
+				}
+			}
+		}
+		if next == "" {
+			return ordered
+		}
+		placed[next] = true
+		ordered = append(ordered, next)
+	}
+}
+
+// reverseEndpoints returns the endpoints in reverse order.
+func reverseEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	reversed := make([]*endpoint.Endpoint, len(endpoints))
+	for i, ep := range endpoints {
+		reversed[len(endpoints)-1-i] = ep
+	}
+	return reversed
+}
@@ -0,0 +1,68 @@
+package porkbun
+
+import (
+	"context"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// guardOwnership drops the updates and deletes of records external-dns does not own in the zone, so records created
+// in the Porkbun console are never changed or removed by a planner that mistakes them for its own. A record is owned
+// if the zone holds its registry TXT record with heritage=external-dns, named like txtRecordName or, as written by
+// older external-dns versions, like the record itself; if the sync has an owner ID, the TXT record must name it.
+// Registry TXT records are owned by their own content. Creates are left alone, they never touch existing records.
+func (p *PorkbunProvider) guardOwnership(ctx context.Context, zone string, c *plan.Changes, recs []pb.Record, skipped skipSummary) {
+	owner := ownerID(ctx)
+	registry := map[string][]string{}
+	for _, rec := range recs {
+		if rec.Type != endpoint.RecordTypeTXT {
+			continue
+		}
+		if labels, err := endpoint.NewLabelsFromStringPlain(parseTXT(rec.Content)); err == nil {
+			name := normalizeName(rec.Name)
+			registry[name] = append(registry[name], labels[endpoint.OwnerLabelKey])
+		}
+	}
+	owned := func(ep *endpoint.Endpoint) bool {
+		if ep.RecordType == endpoint.RecordTypeTXT && len(ep.Targets) > 0 {
+			if labels, err := endpoint.NewLabelsFromStringPlain(parseTXT(ep.Targets[0])); err == nil {
+				return owner == "" || labels[endpoint.OwnerLabelKey] == owner
+			}
+		}
+		for _, name := range []string{p.txtRecordName(ep.DNSName, ep.RecordType), normalizeName(ep.DNSName)} {
+			for _, o := range registry[name] {
+				if owner == "" || o == owner {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var updateOld, updateNew []*endpoint.Endpoint
+	for i, ep := range c.UpdateOld {
+		if !owned(ep) {
+			p.logger.WarnContext(ctx, "skipping update of record not owned by external-dns", "zone", zone, "endpoint", ep.String())
+			skipped.skip(skipReasonNotOwned, 1)
+			continue
+		}
+		updateOld = append(updateOld, ep)
+		if i < len(c.UpdateNew) {
+			updateNew = append(updateNew, c.UpdateNew[i])
+		}
+	}
+	c.UpdateOld, c.UpdateNew = updateOld, updateNew
+
+	deletes := make([]*endpoint.Endpoint, 0, len(c.Delete))
+	for _, ep := range c.Delete {
+		if !owned(ep) {
+			p.logger.WarnContext(ctx, "skipping delete of record not owned by external-dns", "zone", zone, "endpoint", ep.String())
+			skipped.skip(skipReasonNotOwned, 1)
+			continue
+		}
+		deletes = append(deletes, ep)
+	}
+	c.Delete = deletes
+}
@@ -0,0 +1,67 @@
+package porkbun
+
+import (
+	"context"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Several external-dns instances with different --txt-owner-id can share one webhook. The owner ID of a sync is taken
+// from the changes external-dns sends: the owner label the TXT registry sets on the endpoints, or the owner in the
+// heritage TXT records written along with them. Sync metrics, the recent changes, the change log and the log lines
+// of a sync carry the owner ID, so every team can follow its own syncs. Syncs of an external-dns without the TXT
+// registry have no owner ID.
+
+type ownerIDKey struct{}
+
+// withOwnerID returns a context carrying the external-dns owner ID of a sync.
+func withOwnerID(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerIDKey{}, owner)
+}
+
+// ownerID returns the external-dns owner ID carried by the context.
+// returns empty string if there is none
+func ownerID(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerIDKey{}).(string)
+	return owner
+}
+
+// changesOwner returns the owner ID of the external-dns instance that sent the changes.
+// returns empty string if no endpoint names an owner
+func changesOwner(changes *plan.Changes) string {
+	for _, endpoints := range [][]*endpoint.Endpoint{changes.Create, changes.UpdateNew, changes.UpdateOld, changes.Delete} {
+		for _, ep := range endpoints {
+			if owner := endpointOwner(ep); owner != "" {
+				return owner
+			}
+		}
+	}
+	return ""
+}
+
+// endpointOwner returns the owner ID of an endpoint from its owner label, or for registry TXT records from the
+// owner in their heritage content.
+func endpointOwner(ep *endpoint.Endpoint) string {
+	if owner := ep.Labels[endpoint.OwnerLabelKey]; owner != "" {
+		return owner
+	}
+	if ep.RecordType != endpoint.RecordTypeTXT {
+		return ""
+	}
+	for _, target := range ep.Targets {
+		if labels, err := endpoint.NewLabelsFromStringPlain(parseTXT(target)); err == nil && labels[endpoint.OwnerLabelKey] != "" {
+			return labels[endpoint.OwnerLabelKey]
+		}
+	}
+	return ""
+}
+
+// observeSync counts a sync with changes of the owner by its result.
+func observeSync(owner string, err error) {
+	result := "succeeded"
+	if err != nil {
+		result = "failed"
+	}
+	syncsTotal.WithLabelValues(owner, result).Inc()
+}
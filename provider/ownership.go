@@ -0,0 +1,163 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// txtRecordTypeTemplate is replaced with the record type in the TXT prefix and suffix of external-dns.
+const txtRecordTypeTemplate = "%{record_type}"
+
+// errNotOwned is returned when a name to transfer has no records owned by the previous owner.
+var errNotOwned = errors.New("no records owned")
+
+// OwnershipTransfer describes a record whose registry TXT record was moved to another owner ID.
+type OwnershipTransfer struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TXT  string `json:"txt"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// txtRecordName returns the name of the registry TXT record external-dns writes for an endpoint, following the
+// naming of its TXT registry: the record type is added to the first label unless the prefix or suffix holds the
+// %{record_type} template, then the prefix and suffix are added to the first label.
+func (p *PorkbunProvider) txtRecordName(dnsName string, recordType string) string {
+	labels := strings.SplitN(normalizeName(dnsName), ".", 2)
+	recordType = strings.ToLower(recordType)
+	prefix := strings.ReplaceAll(strings.ToLower(p.txtPrefix), txtRecordTypeTemplate, recordType)
+	suffix := strings.ReplaceAll(strings.ToLower(p.txtSuffix), txtRecordTypeTemplate, recordType)
+
+	if p.txtWildcardReplacement != "" && labels[0] == "*" {
+		labels[0] = strings.ToLower(p.txtWildcardReplacement)
+	}
+	if !strings.Contains(p.txtPrefix+p.txtSuffix, txtRecordTypeTemplate) {
+		labels[0] = recordType + "-" + labels[0]
+	}
+	labels[0] = prefix + labels[0] + suffix
+	return strings.Join(labels, ".")
+}
+
+// ownershipChanges returns the updates of the registry TXT records of the names owned by from to the owner ID to,
+// and the records they describe. Only the owner label of the TXT records is rewritten, the other labels are kept.
+func (p *PorkbunProvider) ownershipChanges(current []*endpoint.Endpoint, from string, to string, names []string) (*plan.Changes, []OwnershipTransfer) {
+	txtRecords := map[string]*endpoint.Endpoint{}
+	for _, ep := range current {
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			txtRecords[normalizeName(ep.DNSName)] = ep
+		}
+	}
+
+	changes := &plan.Changes{}
+	transfers := make([]OwnershipTransfer, 0)
+	for _, ep := range current {
+		if ep.RecordType == endpoint.RecordTypeTXT || !slices.Contains(names, normalizeName(ep.DNSName)) {
+			continue
+		}
+		txtName := p.txtRecordName(ep.DNSName, ep.RecordType)
+		txt, ok := txtRecords[txtName]
+		if !ok {
+			continue
+		}
+		for _, target := range txt.Targets {
+			labels, err := endpoint.NewLabelsFromStringPlain(target)
+			if err != nil || labels[endpoint.OwnerLabelKey] != from {
+				continue
+			}
+			labels[endpoint.OwnerLabelKey] = to
+			content := labels.SerializePlain(strings.HasPrefix(target, `"`))
+			changes.UpdateOld = append(changes.UpdateOld, endpoint.NewEndpoint(txt.DNSName, endpoint.RecordTypeTXT, target))
+			changes.UpdateNew = append(changes.UpdateNew, endpoint.NewEndpoint(txt.DNSName, endpoint.RecordTypeTXT, content))
+			transfers = append(transfers, OwnershipTransfer{Name: ep.DNSName, Type: ep.RecordType, TXT: txtName, From: from, To: to})
+		}
+	}
+	return changes, transfers
+}
+
+// TransferOwnership moves the records of the names from the external-dns owner ID from to the owner ID to, e.g.
+// when a Service moves to another cluster. Only the owner label in the registry TXT records is rewritten, the records
+// themselves are kept. Every name must have records owned by from, otherwise nothing is changed. The TXT records
+// are read back afterwards to verify the transfer. TXT records in the encrypted format are not supported.
+func (p *PorkbunProvider) TransferOwnership(ctx context.Context, from string, to string, names []string) ([]OwnershipTransfer, error) {
+	if from == "" || to == "" || from == to {
+		return nil, fmt.Errorf("invalid ownership transfer from %q to %q", from, to)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no names to transfer")
+	}
+	if p.dryRun {
+		return nil, errors.New("ownership transfers are not available in dry run")
+	}
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, normalizeName(name))
+	}
+
+	current, err := p.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, transfers := p.ownershipChanges(current, from, to, normalized)
+	for _, name := range normalized {
+		if !slices.ContainsFunc(transfers, func(t OwnershipTransfer) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("%w by '%s' for '%s'", errNotOwned, from, name)
+		}
+	}
+
+	p.logger.InfoContext(ctx, "transferring ownership", "from", from, "to", to, "names", normalized, "txtRecords", len(transfers))
+	if err := p.ApplyChanges(ctx, changes); err != nil {
+		return nil, fmt.Errorf("unable to transfer ownership from '%s' to '%s': %v", from, to, err)
+	}
+
+	// Read back that the TXT records name the new owner
+	current, err = p.Records(ctx)
+	if err != nil {
+		return transfers, fmt.Errorf("unable to verify ownership transfer: %v", err)
+	}
+	_, verified := p.ownershipChanges(current, to, from, normalized)
+	for _, t := range transfers {
+		if !slices.ContainsFunc(verified, func(v OwnershipTransfer) bool { return v.Name == t.Name && v.Type == t.Type }) {
+			return transfers, fmt.Errorf("%s record '%s' is not owned by '%s' after the transfer", t.Type, t.Name, to)
+		}
+	}
+	p.logger.InfoContext(ctx, "ownership transferred", "from", from, "to", to, "names", normalized)
+	return transfers, nil
+}
+
+// OwnershipHandler transfers the records of the names given by name query parameters from one external-dns
+// owner ID to another, e.g. POST ?from=cluster-a&to=cluster-b&name=www.example.com, and answers with the
+// transferred records as JSON.
+func (p *PorkbunProvider) OwnershipHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	from, to, names := query.Get("from"), query.Get("to"), query["name"]
+	if from == "" || to == "" || len(names) == 0 {
+		http.Error(w, "missing from, to or name query parameter", http.StatusBadRequest)
+		return
+	}
+	transfers, err := p.TransferOwnership(r.Context(), from, to, names)
+	if err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, errNotOwned):
+			status = http.StatusNotFound
+		case from == to:
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, transfers, p.logger)
+}
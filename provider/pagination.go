@@ -0,0 +1,138 @@
+package porkbun
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
+)
+
+// ContinueHeader carries the token to request the next page of records.
+const ContinueHeader = "X-Continue"
+
+// PaginateRecords wraps the records handler and paginates record listings for clients that ask for it
+// with the limit query parameter. The first page is listed from Porkbun, following pages (requested with
+// the continue query parameter set to the token of the previous page) are served from the zone cache.
+// The token carries the generation of the cache, once the cache changed between pages the token is stale and
+// answered with 410 Gone, so all pages belong to the same listing and clients have to start over.
+// Requests without limit, and all requests in dry-run mode, get the single monolithic response.
+func (p *PorkbunProvider) PaginateRecords(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.maxPageSize <= 0 || p.dryRun || r.Method != http.MethodGet || !r.URL.Query().Has("limit") {
+			next(w, r)
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, p.maxPageSize)
+
+		var after string
+		var generation uint64
+		token := r.URL.Query().Get("continue")
+		if token != "" {
+			generation, after, err = parseContinueToken(token)
+			if err != nil {
+				http.Error(w, "invalid continue token", http.StatusBadRequest)
+				return
+			}
+		} else if _, err := p.Records(r.Context()); err != nil {
+			p.logger.ErrorContext(r.Context(), "unable to list records", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		endpoints, current, err := p.cachedEndpoints(r.Context())
+		if err != nil {
+			p.logger.ErrorContext(r.Context(), "unable to list records", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if token != "" && current != generation {
+			http.Error(w, "records changed since the listing started, start over without continue", http.StatusGone)
+			return
+		}
+
+		page, nextKey := paginate(endpoints, after, limit)
+		if nextKey != "" {
+			w.Header().Set(ContinueHeader, continueToken(current, nextKey))
+		}
+		w.Header().Set(webhook.ContentTypeHeader, webhook.MediaTypeFormatAndVersion)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			p.logger.ErrorContext(r.Context(), "unable to encode records", "error", err.Error())
+		}
+	}
+}
+
+// continueToken encodes the cache generation and the key of the last endpoint of a page.
+func continueToken(generation uint64, after string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(generation, 10) + ":" + after))
+}
+
+// parseContinueToken decodes a token built by continueToken.
+func parseContinueToken(token string) (uint64, string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, "", err
+	}
+	value, after, found := strings.Cut(string(decoded), ":")
+	if !found || after == "" {
+		return 0, "", fmt.Errorf("malformed continue token")
+	}
+	generation, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return generation, after, nil
+}
+
+// cachedEndpoints converts the cached records of all zones into endpoints.
+// returns the generation of the cache the endpoints were read from
+func (p *PorkbunProvider) cachedEndpoints(ctx context.Context) ([]*endpoint.Endpoint, uint64, error) {
+	zones := p.activeZones(p.domainFilter.Zones())
+	snapshot, generation, ok := p.cache.snapshot(zones)
+	if !ok {
+		return nil, 0, fmt.Errorf("not all zones have been fetched yet")
+	}
+	endpoints := make([]*endpoint.Endpoint, 0)
+	for _, zone := range zones {
+		endpoints = append(endpoints, p.recordsToEndpoints(ctx, zone, snapshot[zone].records, nil)...)
+	}
+	return endpoints, generation, nil
+}
+
+// paginationKey orders endpoints deterministically across pages.
+func paginationKey(ep *endpoint.Endpoint) string {
+	return strings.Join([]string{ep.DNSName, ep.RecordType, ep.SetIdentifier, strings.Join(ep.Targets, ",")}, "\x00")
+}
+
+// paginate returns up to limit endpoints following the endpoint with the key after.
+// returns the key to continue with, or empty string on the last page
+func paginate(endpoints []*endpoint.Endpoint, after string, limit int) ([]*endpoint.Endpoint, string) {
+	sorted := append([]*endpoint.Endpoint(nil), endpoints...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return paginationKey(sorted[i]) < paginationKey(sorted[j])
+	})
+
+	start := sort.Search(len(sorted), func(i int) bool {
+		return paginationKey(sorted[i]) > after
+	})
+	end := min(start+limit, len(sorted))
+
+	page := sorted[start:end]
+	if end == len(sorted) {
+		return page, ""
+	}
+	return page, paginationKey(page[len(page)-1])
+}
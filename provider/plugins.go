@@ -0,0 +1,6 @@
+//go:build cgo && (linux || darwin || freebsd)
+
+package porkbun
+
+// pluginsSupported reports whether the binary can open Go plugins, which needs cgo on Linux, macOS or FreeBSD.
+const pluginsSupported = true
@@ -0,0 +1,7 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+
+package porkbun
+
+// pluginsSupported reports whether the binary can open Go plugins, this build can't: it was built without cgo or for
+// a platform without plugin support.
+const pluginsSupported = false
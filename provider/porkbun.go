@@ -2,10 +2,15 @@ package porkbun
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	pb "github.com/nrdcg/porkbun"
 
@@ -17,13 +22,81 @@ import (
 // PorkbunProvider is an implementation of Provider for porkbun DNS.
 type PorkbunProvider struct {
 	provider.BaseProvider
-	client       *pb.Client
-	domainFilter endpoint.DomainFilter
+	client       porkbunClient
+	domainFilter *domainFilterHolder
 	dryRun       bool
 	logger       *slog.Logger
+	staleAfter   time.Duration
+	cache        *zoneCache
+	warmedUp     atomic.Bool
+	usage        *apiUsage
+	domains      *domainAPI
+	hooks        []ConversionHook
+	health       *providerHealth
+	maxPageSize  int
+	clock        Clock
+	sampler      *logSampler
+	clusterID    string
+	headers      http.Header
+
+	clockSkewTolerance time.Duration
+	targetCheck        string
+	typeOrder          []string
+	gone               *goneZones
+	maxResponseBytes   int64
+	maxCreatesPerSync  int
+	maxDeletesPerSync  int
+	credentials        CredentialsSource
+	applyConcurrency   int
+	changeOrder        string
+	baseURLs           []string
+	zoneLockName       string
+	zoneLockTTL        time.Duration
+	syncRecordName     string
+	emptyTargets       string
+	outputCipher       *OutputCipher
+	changes            changeLog
+	apexAlias          bool
+	cutover            cutoverState
+	changeScripts      *changeScriptWriter
+	manageNS           bool
+	ownershipGuard     bool
+	deepHealth         deepHealthCheck
+	deepHealthTimeout  time.Duration
+	deepHealthInterval time.Duration
+
+	txtPrefix              string
+	txtSuffix              string
+	txtWildcardReplacement string
+	zoneCredentials        []ZoneCredentials
+	excludeDomains         []string
+	recordsMaxAge          time.Duration
+	minTTL                 int64
+	defaultTTL             int64
+	snapshots              snapshotState
+	managedTypes           []string
+	excludedTypes          []string
+	retryPolicies          RetryPolicies
+	requestTimeout         time.Duration
+	rollbackOnFailure      bool
+	txtGC                  txtGCState
+	txtGCInterval          time.Duration
+	txtGCOwners            []string
+	maintenance            maintenanceState
+
+	resolvers           []Resolver
+	verifyConsensus     float64
+	verifyWindow        time.Duration
+	verifyRetryInterval time.Duration
 }
 
-// PorkbunChange includes the changesets that need to be applied to the porkbun API
+// RecordIDLabelKey is the endpoint label carrying the ID of the Porkbun record an endpoint was listed from.
+// external-dns hands it back on the endpoints to update or delete, so their records are addressed by ID
+// even if their content changed in the meantime.
+const RecordIDLabelKey = "porkbun-record-id"
+
+// PorkbunChange includes the changesets that need to be applied to the porkbun API.
+// Each update is a single edit of UpdateNew; UpdateOld only locates the records to edit and is never written.
 type PorkbunChange struct {
 	Create    *[]pb.Record
 	UpdateNew *[]pb.Record
@@ -32,188 +105,538 @@ type PorkbunChange struct {
 }
 
 // NewPorkbunProvider creates a new provider including the porkbun API client
-func NewPorkbunProvider(domainFilterList *[]string, apiKey string, apiSecret string, dryRun bool, logger *slog.Logger) (*PorkbunProvider, error) {
+func NewPorkbunProvider(domainFilterList *[]string, apiKey string, apiSecret string, dryRun bool, logger *slog.Logger, opts ...Option) (*PorkbunProvider, error) {
 	domainFilter := endpoint.NewDomainFilter(*domainFilterList)
 
 	if !domainFilter.IsConfigured() {
 		return nil, fmt.Errorf("porkbun provider requires at least one configured domain in the domainFilter")
 	}
 
-	if apiKey == "" {
+	logger.Debug("creating porkbun provider", "api-key", apiKey, "api-secret", apiSecret)
+	logger = slog.New(correlationHandler{logger.Handler()})
+
+	clock := systemClock{}
+	usage := newAPIUsage(defaultAPICallWarningThreshold, clock, logger)
+	pbClient := pb.New(apiSecret, apiKey)
+	client := &meteredClient{client: pbClient, usage: usage}
+
+	p := &PorkbunProvider{
+		client:       client,
+		domainFilter: newDomainFilterHolder(domainFilter),
+		dryRun:       dryRun,
+		logger:       logger,
+		staleAfter:   defaultStaleAfter,
+		cache:        newZoneCache(),
+		usage:        usage,
+		domains:      newDomainAPI(apiKey, apiSecret),
+		hooks:        compiledInHooks(),
+		health:       newProviderHealth(),
+		clock:        clock,
+		sampler:      newLogSampler(logger),
+
+		clockSkewTolerance: defaultClockSkewTolerance,
+		gone:               newGoneZones(),
+		zoneLockTTL:        defaultZoneLockTTL,
+		deepHealthTimeout:  defaultDeepHealthTimeout,
+		deepHealthInterval: defaultDeepHealthInterval,
+		minTTL:             porkbunMinTTL,
+		requestTimeout:     defaultRequestTimeout,
+		snapshots:          snapshotState{maxAge: defaultSnapshotMaxAge, interval: defaultSnapshotInterval},
+
+		verifyConsensus:     1,
+		verifyWindow:        defaultVerifyWindow,
+		verifyRetryInterval: verifyInterval,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.cutover.active = map[string]string{}
+	if len(p.excludeDomains) > 0 {
+		p.domainFilter.Store(endpoint.NewDomainFilterWithExclusions(domainFilter.Filters, p.excludeDomains), p.excludeDomains)
+	}
+	if p.changeScripts != nil {
+		p.changeScripts.cipher = p.outputCipher
+	}
+
+	// API keys are only optional if a credentials source supplies them, or every zone has its own keys
+	accountKeys := p.credentials != nil || !coversZones(p.zoneCredentials, p.domainFilter.Zones())
+	if accountKeys && p.credentials == nil && apiKey == "" {
 		return nil, fmt.Errorf("porkbun provider requires an API Key")
 	}
 
-	if apiSecret == "" {
+	if accountKeys && p.credentials == nil && apiSecret == "" {
 		return nil, fmt.Errorf("porkbun provider requires an API Password")
 	}
 
-	logger.Debug("creating porkbun provider", "api-key", apiKey, "api-secret", apiSecret)
+	// Clients with the keys of single zones share the transport settings of the account client
+	pbClients := []*pb.Client{pbClient}
+	if len(p.zoneCredentials) > 0 {
+		zones := make(map[string]porkbunClient, len(p.zoneCredentials))
+		for _, c := range p.zoneCredentials {
+			zoneClient := pb.New(c.APISecret, c.APIKey)
+			zones[c.Zone] = zoneClient
+			pbClients = append(pbClients, zoneClient)
+		}
+		var account porkbunClient
+		if accountKeys || apiKey != "" {
+			account = pbClient
+		}
+		client.client = newZoneClients(account, zones)
+	}
+	// In dry run all calls go to an in-memory stand-in of the API, which logs the writes instead of sending them
+	if dryRun {
+		client.client = newRecordingClient(logger)
+	}
+	// Timeouts sit below the retries, so every attempt gets its own deadline
+	if p.requestTimeout > 0 {
+		client.client = &timeoutClient{client: client.client, timeout: p.requestTimeout}
+	}
+	// Retries sit above the metering, so every attempt is counted as an API call
+	if p.retryPolicies.retries() {
+		p.client = newRetryingClient(client, p.retryPolicies, logger)
+	}
 
-	client := pb.New(apiSecret, apiKey)
+	apiCallsLastHour.observe(usage)
+	cacheAge.observe(p.cache, p.clock)
 
-	return &PorkbunProvider{
-		client:       client,
-		domainFilter: *domainFilter,
-		dryRun:       dryRun,
-		logger:       logger,
-	}, nil
+	if len(p.baseURLs) > 0 {
+		baseURL, err := url.Parse(p.baseURLs[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Porkbun API URL '%s': %v", p.baseURLs[0], err)
+		}
+		for _, c := range pbClients {
+			c.BaseURL = baseURL
+		}
+		p.domains.baseURL = p.baseURLs[0]
+	}
+	if len(p.baseURLs) > 1 {
+		endpoints := newAPIEndpoints(p.baseURLs, p.clock, logger)
+		for _, c := range pbClients {
+			withFailover(c.HTTPClient, endpoints)
+		}
+		withFailover(p.domains.httpClient, endpoints)
+	}
+
+	headers := p.headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if headers.Get("User-Agent") == "" {
+		headers.Set("User-Agent", userAgent(p.clusterID))
+	}
+	for _, c := range pbClients {
+		withHeaders(c.HTTPClient, headers)
+	}
+	withHeaders(p.domains.httpClient, headers)
+	if p.credentials != nil {
+		withCredentials(pbClient.HTTPClient, p.credentials)
+		withCredentials(p.domains.httpClient, p.credentials)
+	}
+	if p.maxResponseBytes > 0 {
+		for _, c := range pbClients {
+			withResponseLimit(c.HTTPClient, p.maxResponseBytes)
+		}
+	}
+
+	return p, nil
 }
 
 func (p *PorkbunProvider) CreateDnsRecords(ctx context.Context, zone string, records *[]pb.Record) (string, error) {
 	for _, record := range *records {
-		_, err := p.client.CreateRecord(ctx, zone, record)
+		start := p.clock.Now()
+		id, err := p.client.CreateRecord(ctx, zone, record)
 		if err != nil {
-			return "", fmt.Errorf("unable to create record: %v", err)
+			p.reportChange(ctx, zone, "create", record, start, err)
+			return "", fmt.Errorf("unable to create record: %w", err)
 		}
+		record.ID = strconv.Itoa(id)
+		p.reportChange(ctx, zone, "create", record, start, nil)
+		p.recordChange(ctx, zone, "create", record)
 	}
 	return "", nil
 }
 
 func (p *PorkbunProvider) DeleteDnsRecords(ctx context.Context, zone string, records *[]pb.Record) (string, error) {
 	for _, record := range *records {
-		id, err := strconv.Atoi(record.ID)
-		if err != nil {
-			return "", fmt.Errorf("unable to parse record ID '%s': %v. Full record: %+v", record.ID, err, record)
+		// A record with an invalid ID is resolved again by its content, like a record whose ID no longer exists
+		start := p.clock.Now()
+		var err error
+		id, ok := parseRecordID(record.ID)
+		if ok {
+			err = p.client.DeleteRecord(ctx, zone, id)
+		} else if record.ID != "" {
+			p.logger.WarnContext(ctx, "invalid record ID", "zone", zone, "name", record.Name, "type", record.Type, "id", record.ID)
 		}
-		err = p.client.DeleteRecord(ctx, zone, id)
+		if !ok || isRecordNotFound(err) {
+			err = p.retryWithFreshID(ctx, zone, record, false, func(id int) error {
+				return p.client.DeleteRecord(ctx, zone, id)
+			})
+			if errors.Is(err, errRecordGone) {
+				p.logger.InfoContext(ctx, "record to delete is already gone", "zone", zone, "name", record.Name, "type", record.Type)
+				err = nil
+			}
+		}
+		p.reportChange(ctx, zone, "delete", record, start, err)
 		if err != nil {
-			return "", fmt.Errorf("unable to delete record: %v", err)
+			return "", fmt.Errorf("unable to delete record: %w", err)
 		}
+		p.recordChange(ctx, zone, "delete", record)
 	}
 	return "", nil
 }
 
 func (p *PorkbunProvider) UpdateDnsRecords(ctx context.Context, zone string, records *[]pb.Record) (string, error) {
 	for _, record := range *records {
-		id, err := strconv.Atoi(record.ID)
-		if err != nil {
-			return "", fmt.Errorf("unable to parse record ID '%s': %v. Full record: %+v", record.ID, err, record)
+		start := p.clock.Now()
+		var err error
+		id, ok := parseRecordID(record.ID)
+		if ok {
+			err = p.client.EditRecord(ctx, zone, id, record)
+		} else if record.ID != "" {
+			p.logger.WarnContext(ctx, "invalid record ID", "zone", zone, "name", record.Name, "type", record.Type, "id", record.ID)
 		}
-		err = p.client.EditRecord(ctx, zone, id, record)
+		if !ok || isRecordNotFound(err) {
+			err = p.retryWithFreshID(ctx, zone, record, true, func(id int) error {
+				return p.client.EditRecord(ctx, zone, id, record)
+			})
+		}
+		// A record removed out-of-band is created again, so the update does not fail every sync
+		if errors.Is(err, errRecordGone) {
+			p.logger.InfoContext(ctx, "record to update is gone, creating it", "zone", zone, "name", record.Name, "type", record.Type)
+			record.ID = ""
+			id, err := p.client.CreateRecord(ctx, zone, record)
+			if err != nil {
+				p.reportChange(ctx, zone, "create", record, start, err)
+				return "", fmt.Errorf("unable to create record: %w", err)
+			}
+			record.ID = strconv.Itoa(id)
+			p.reportChange(ctx, zone, "create", record, start, nil)
+			p.recordChange(ctx, zone, "create", record)
+			continue
+		}
+		p.reportChange(ctx, zone, "update", record, start, err)
 		if err != nil {
-			return "", fmt.Errorf("unable to update record: %v", err)
+			return "", fmt.Errorf("unable to update record: %w", err)
 		}
+		p.recordChange(ctx, zone, "update", record)
 	}
 	return "", nil
 }
 
+// recordChange records a successful write of the record in the recent changes, the change log and the metrics
+// of the owner of the sync.
+func (p *PorkbunProvider) recordChange(ctx context.Context, zone string, action string, record pb.Record) {
+	owner := ownerID(ctx)
+	p.changes.add(p.clock.Now(), owner, zone, action, record)
+	recordChangeScript(ctx, action, record)
+	recordRollbackJournal(ctx, action, record)
+	recordChangesTotal.WithLabelValues(owner, zone, action).Inc()
+}
+
+// retryWithFreshID handles a record that was changed out-of-band: it re-fetches the zone, resolves the record ID again
+// and retries the operation once. If matchByName is set and no record matches the content any more,
+// the only record with the same name and type is used.
+func (p *PorkbunProvider) retryWithFreshID(ctx context.Context, zone string, record pb.Record, matchByName bool, op func(id int) error) error {
+	p.logger.InfoContext(ctx, "record ID no longer exists, refreshing zone", "zone", zone, "name", record.Name, "type", record.Type, "id", record.ID)
+
+	recs, err := p.retrieveRecords(ctx, zone)
+	if err != nil {
+		return fmt.Errorf("unable to refresh DNS records for domain '%v': %w", zone, err)
+	}
+	p.cacheZone(ctx, zone, recs)
+
+	fqdn := recordFQDN(record.Name, zone)
+	freshID := getIDforRecord(fqdn, recordTarget(record), record.Type, &recs)
+	if freshID == "" && matchByName {
+		freshID = getOnlyIDforName(fqdn, record.Type, recs)
+	}
+	if freshID == "" {
+		return fmt.Errorf("%s %s in zone '%s': %w", fqdn, record.Type, zone, errRecordGone)
+	}
+
+	id, ok := parseRecordID(freshID)
+	if !ok {
+		return fmt.Errorf("unable to parse record ID '%s' of %s %s in zone '%s'", freshID, fqdn, record.Type, zone)
+	}
+	return op(id)
+}
+
 // Records delivers the list of Endpoint records for all zones.
 func (p *PorkbunProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	endpoints := make([]*endpoint.Endpoint, 0)
+	skipped := skipSummary{}
 
-	if p.dryRun {
-		p.logger.Debug("dry run - skipping login")
-	} else {
-		err := p.ensureLogin(ctx)
-		if err != nil {
-			return nil, err
+	err := p.ensureLogin(ctx)
+	if isMaintenance(err) {
+		return p.maintenanceEndpoints(ctx)
+	}
+	if err != nil {
+		return nil, softError(err)
+	}
+
+	for _, domain := range p.domainFilter.Zones() {
+		if !p.gone.due(domain, p.clock.Now()) {
+			continue
 		}
 
-		for _, domain := range p.domainFilter.Filters {
+		if records, ok := p.restoredRecords(domain); ok {
+			p.logger.InfoContext(ctx, "got DNS records for domain from cache snapshot", "domain", domain)
+			endpoints = append(endpoints, p.recordsToEndpoints(ctx, domain, records, skipped)...)
+			continue
+		}
 
-			records, err := p.client.RetrieveRecords(ctx, domain)
-			if err != nil {
-				return nil, fmt.Errorf("unable to query DNS zone records for domain '%v': %v", domain, err)
-			}
-			p.logger.Info("got DNS records for domain", "domain", domain)
-			for _, rec := range records {
-				name := rec.Name
-				nameStart := strings.Split(rec.Name, ".")[0]
-				if nameStart == "@" {
-					name = domain
-				}
-				ttl, err := strconv.Atoi(rec.TTL)
-				if err != nil {
-					return nil, fmt.Errorf("unable to parse TTL value: %v", err)
-				}
-				ep := endpoint.NewEndpointWithTTL(name, rec.Type, endpoint.TTL(ttl), rec.Content)
-				endpoints = append(endpoints, ep)
-			}
+		records, err := p.retrieveRecords(ctx, domain)
+		if isMaintenance(err) {
+			p.pauseForMaintenance(ctx, err)
+			return p.maintenanceEndpoints(ctx)
+		}
+		if isZoneGone(err) {
+			p.markZoneGone(ctx, domain, err)
+			continue
 		}
+		p.health.setZone(domain, err)
+		if err != nil {
+			return nil, softError(fmt.Errorf("unable to query DNS zone records for domain '%v': %w", domain, err))
+		}
+		if p.gone.clear(domain) {
+			p.logger.InfoContext(ctx, "zone is back in the Porkbun account", "zone", domain)
+		}
+		p.logger.InfoContext(ctx, "got DNS records for domain", "domain", domain)
+		p.cacheZone(ctx, domain, records)
+		endpoints = append(endpoints, p.recordsToEndpoints(ctx, domain, records, skipped)...)
+	}
+	p.deriveCutoverColors(ctx, endpoints)
+	if p.snapshots.store != nil {
+		go p.saveSnapshot(context.WithoutCancel(ctx))
 	}
 	for _, endpointItem := range endpoints {
-		p.logger.Debug("endpoints collected", "endpoints", endpointItem.String())
+		p.sampler.debug(ctx, logClassEndpoints, "endpoints collected", "endpoints", endpointItem.String())
 	}
+	p.sampler.flush(ctx)
+	skipped.report(ctx, p.logger, "records")
 	return endpoints, nil
 }
 
+// recordsToEndpoints converts the Porkbun records of a zone into endpoints, merging records of the same name and type.
+// Records of unmanaged types, NS records that are not managed and records in delegated subzones are left out.
+// Anomalies in single records are logged and do not fail the whole zone: records without type or outside
+// the zone are skipped and counted in skipped, an unparseable TTL is treated as not configured and an unparseable
+// priority is left out of the target.
+func (p *PorkbunProvider) recordsToEndpoints(ctx context.Context, domain string, records []pb.Record, skipped skipSummary) []*endpoint.Endpoint {
+	endpoints := make([]*endpoint.Endpoint, 0, len(records))
+	points := delegations(domain, records)
+	registry := registryLabels(records)
+	for _, rec := range records {
+		name := listedName(rec.Name, domain)
+		if rec.Type == "" || !inZone(name, domain) {
+			p.logger.WarnContext(ctx, "skipping unexpected record", "zone", domain, "id", rec.ID, "name", rec.Name, "type", rec.Type)
+			if rec.Type == "" {
+				skipped.skip(skipReasonUnsupportedType, 1)
+			} else {
+				skipped.skip(skipReasonFiltered, 1)
+			}
+			continue
+		}
+		if p.isSyncRecord(rec, domain) {
+			continue
+		}
+		if delegated(name, points) {
+			p.sampler.debug(ctx, logClassIgnored, "ignoring record in a delegated subzone", "zone", domain, "id", rec.ID, "name", rec.Name, "type", rec.Type)
+			continue
+		}
+		if p.domainFilter.Excluded(name) {
+			p.sampler.debug(ctx, logClassIgnored, "ignoring record of excluded domain", "zone", domain, "id", rec.ID, "name", rec.Name, "type", rec.Type)
+			continue
+		}
+		if !p.managesType(rec.Type) {
+			p.sampler.debug(ctx, logClassIgnored, "ignoring record of unmanaged type", "zone", domain, "id", rec.ID, "name", rec.Name, "type", rec.Type)
+			continue
+		}
+		if rec.Type == endpoint.RecordTypeNS && !p.managesNS(name, domain) {
+			p.sampler.debug(ctx, logClassIgnored, "ignoring unmanaged NS record", "zone", domain, "id", rec.ID, "name", rec.Name)
+			continue
+		}
+		ttl, ok := parseNumber(rec.TTL)
+		if !ok {
+			p.logger.WarnContext(ctx, "ignoring invalid TTL of record", "zone", domain, "id", rec.ID, "name", rec.Name, "ttl", rec.TTL)
+		}
+		if rec.Prio != "" && hasPriority(rec.Type) && normalizeNumber(rec.Prio) == "" {
+			p.logger.WarnContext(ctx, "ignoring invalid priority of record", "zone", domain, "id", rec.ID, "name", rec.Name, "prio", rec.Prio)
+		}
+		ep := endpoint.NewEndpointWithTTL(name, rec.Type, endpoint.TTL(ttl), recordTarget(rec))
+		if rec.ID != "" {
+			ep.Labels[RecordIDLabelKey] = rec.ID
+		}
+		p.setListedLabels(ep, rec, registry)
+		setNotesProperty(ep, rec.Notes)
+		setProtectedProperty(ep, rec)
+		p.applyFromRecordHooks(rec, ep)
+		endpoints = append(endpoints, ep)
+	}
+	return mergeTargets(endpoints)
+}
+
 // ApplyChanges applies a given set of changes in a given zone.
-func (p *PorkbunProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+func (p *PorkbunProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) (err error) {
 	if !changes.HasChanges() {
-		p.logger.Debug("no changes detected - nothing to do")
+		p.logger.DebugContext(ctx, "no changes detected - nothing to do")
 		return nil
 	}
-
-	if p.dryRun {
-		p.logger.Debug("dry run - skipping login")
-	} else {
-		err := p.ensureLogin(ctx)
-		if err != nil {
-			return err
+	owner := changesOwner(changes)
+	ctx = withOwnerID(ctx, owner)
+	defer func() {
+		if isMaintenance(err) {
+			p.pauseForMaintenance(ctx, err)
 		}
+		observeSync(owner, err)
+	}()
+
+	if err := p.ensureLogin(ctx); isMaintenance(err) {
+		// The changes are planned again by the next sync, failing every sync of the maintenance would only add noise
+		p.logger.InfoContext(ctx, "Porkbun API is in maintenance, pausing changes", "create", len(changes.Create), "updateNew", len(changes.UpdateNew), "delete", len(changes.Delete))
+		skippedEndpointsTotal.WithLabelValues(skipReasonMaintenance).Add(float64(len(changes.Create) + len(changes.UpdateNew) + len(changes.Delete)))
+		return nil
+	} else if err != nil {
+		return softError(err)
 	}
+	zones := p.domainFilter.Zones()
 	perZoneChanges := map[string]*plan.Changes{}
+	skipped := skipSummary{}
+	defer skipped.report(ctx, p.logger, "apply")
+	ctx, results := withChangeResults(ctx)
+	changes = p.rejectUnmanagedChanges(changes, skipped)
+	changes = p.handleEmptyTargets(ctx, changes, skipped)
+	changes = p.checkCNAMETargets(ctx, zones, changes, skipped)
 
-	for _, zoneName := range p.domainFilter.Filters {
-		p.logger.Debug("zone detected", "zone", zoneName)
+	for _, zoneName := range zones {
+		p.logger.DebugContext(ctx, "zone detected", "zone", zoneName)
 
 		perZoneChanges[zoneName] = &plan.Changes{}
 	}
 
 	for _, ep := range changes.Create {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := p.endpointZone(ep, zones)
 		if zoneName == "" {
-			p.logger.Debug("ignoring change since it did not match any zone", "type", "create", "endpoint", ep)
+			p.sampler.debug(ctx, logClassIgnored, "ignoring change since it did not match any zone", "type", "create", "endpoint", ep)
+			skipped.skip(skipReasonNoZone, 1)
 			continue
 		}
-		p.logger.Debug("planning", "type", "create", "endpoint", ep, "zone", zoneName)
+		p.sampler.debug(ctx, logClassPlanning, "planning", "type", "create", "endpoint", ep, "zone", zoneName)
 
 		perZoneChanges[zoneName].Create = append(perZoneChanges[zoneName].Create, ep)
 	}
 
 	for _, ep := range changes.UpdateOld {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := p.endpointZone(ep, zones)
 		if zoneName == "" {
-			p.logger.Debug("ignoring change since it did not match any zone", "type", "updateOld", "endpoint", ep)
+			p.sampler.debug(ctx, logClassIgnored, "ignoring change since it did not match any zone", "type", "updateOld", "endpoint", ep)
 			continue
 		}
-		p.logger.Debug("planning", "type", "updateOld", "endpoint", ep, "zone", zoneName)
+		p.sampler.debug(ctx, logClassPlanning, "planning", "type", "updateOld", "endpoint", ep, "zone", zoneName)
 
 		perZoneChanges[zoneName].UpdateOld = append(perZoneChanges[zoneName].UpdateOld, ep)
 	}
 
 	for _, ep := range changes.UpdateNew {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := p.endpointZone(ep, zones)
 		if zoneName == "" {
-			p.logger.Debug("ignoring change since it did not match any zone", "type", "updateNew", "endpoint", ep)
+			p.sampler.debug(ctx, logClassIgnored, "ignoring change since it did not match any zone", "type", "updateNew", "endpoint", ep)
+			skipped.skip(skipReasonNoZone, 1)
 			continue
 		}
-		p.logger.Debug("planning", "type", "updateNew", "endpoint", ep, "zone", zoneName)
+		p.sampler.debug(ctx, logClassPlanning, "planning", "type", "updateNew", "endpoint", ep, "zone", zoneName)
 		perZoneChanges[zoneName].UpdateNew = append(perZoneChanges[zoneName].UpdateNew, ep)
 	}
 
 	for _, ep := range changes.Delete {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := p.endpointZone(ep, zones)
 		if zoneName == "" {
-			p.logger.Debug("ignoring change since it did not match any zone", "type", "delete", "endpoint", ep)
+			p.sampler.debug(ctx, logClassIgnored, "ignoring change since it did not match any zone", "type", "delete", "endpoint", ep)
+			skipped.skip(skipReasonNoZone, 1)
 			continue
 		}
-		p.logger.Debug("planning", "type", "delete", "endpoint", ep, "zone", zoneName)
+		p.sampler.debug(ctx, logClassPlanning, "planning", "type", "delete", "endpoint", ep, "zone", zoneName)
 		perZoneChanges[zoneName].Delete = append(perZoneChanges[zoneName].Delete, ep)
 	}
 
-	if p.dryRun {
-		p.logger.Info("dry run - not applying changes")
-		return nil
+	p.sampler.flush(ctx)
+	observeChangeSet(changes, perZoneChanges)
+
+	if p.maxDeletesPerSync > 0 {
+		if deletes := p.plannedDeletes(perZoneChanges); deletes > p.maxDeletesPerSync {
+			deleteLimitExceededTotal.Inc()
+			p.logger.ErrorContext(ctx, "refusing to apply changes deleting more records than allowed", "deletes", deletes, "maxDeletesPerSync", p.maxDeletesPerSync)
+			return fmt.Errorf("%w: the changes delete %d records, at most %d are allowed per sync", errTooManyDeletes, deletes, p.maxDeletesPerSync)
+		}
 	}
 
+	createBudget := p.maxCreatesPerSync
+	deferredCreates := 0
+	written := make([]*endpoint.Endpoint, 0)
+	// Zones are applied independently, a failing zone does not keep the others from being changed
+	var zoneErrs []error
+	var succeeded, failed []string
+
 	// Assemble changes per zone and prepare it for the porkbun API client
-	for zoneName, c := range perZoneChanges {
+	for _, zoneName := range orderZones(zones, perZoneChanges) {
+		if len(zoneErrs) > 0 && (ctx.Err() != nil || isMaintenance(zoneErrs[len(zoneErrs)-1])) {
+			// The remaining zones would fail the same way
+			break
+		}
+		c := perZoneChanges[zoneName]
+		if p.gone.isGone(zoneName) {
+			if c.HasChanges() {
+				p.logger.WarnContext(ctx, "skipping changes for zone that is not in the Porkbun account", "zone", zoneName)
+				skipped.skip(skipReasonZoneGone, len(c.Create)+len(c.UpdateNew)+len(c.Delete))
+			}
+			continue
+		}
 		// Gather records from API to extract the record ID which is necessary for updating/deleting the record
-		recs, err := p.client.RetrieveRecords(ctx, zoneName)
+		recs, err := p.retrieveRecords(ctx, zoneName)
+		if isZoneGone(err) {
+			p.markZoneGone(ctx, zoneName, err)
+			skipped.skip(skipReasonZoneGone, len(c.Create)+len(c.UpdateNew)+len(c.Delete))
+			continue
+		}
+		p.health.setZone(zoneName, err)
 		if err != nil {
-			p.logger.Error("unable to get DNS records for domain", "zone", zoneName, "error", err.Error())
+			// Without the records, IDs, conflicts and guards can't be resolved
+			p.logger.ErrorContext(ctx, "unable to get DNS records for domain, skipping its changes", "zone", zoneName, "error", err.Error())
+			zoneErrs = append(zoneErrs, fmt.Errorf("zone '%s': unable to get DNS records: %w", zoneName, err))
+			failed = append(failed, zoneName)
+			continue
 		}
+		if p.ownershipGuard {
+			p.guardOwnership(ctx, zoneName, c, recs, skipped)
+		}
+		p.protectRecords(ctx, zoneName, c, recs, skipped)
+		p.dropDelegated(ctx, zoneName, c, recs, skipped)
+
+		// Type changes are replaced, one Porkbun record is written per target
+		var typeCreates, typeDeletes, updateCreates, updateDeletes []*endpoint.Endpoint
+		c.UpdateOld, c.UpdateNew, typeCreates, typeDeletes = splitTypeChanges(c.UpdateOld, c.UpdateNew)
+		c.UpdateOld, c.UpdateNew, updateCreates, updateDeletes = splitUpdates(c.UpdateOld, c.UpdateNew)
+		c.Create = append(splitTargets(append(c.Create, typeCreates...)), updateCreates...)
+		c.Delete = append(splitTargets(expandEmptyDeletes(append(c.Delete, typeDeletes...), recs)), updateDeletes...)
+
+		// The same endpoint of several sources is written once
+		var createDuplicates, deleteDuplicates int
+		c.Create, createDuplicates = dedupeEndpoints(c.Create)
+		c.Delete, deleteDuplicates = dedupeEndpoints(c.Delete)
+		if duplicates := createDuplicates + deleteDuplicates; duplicates > 0 {
+			p.logger.DebugContext(ctx, "skipping duplicate changes", "zone", zoneName, "creates", createDuplicates, "deletes", deleteDuplicates)
+			skipped.skip(skipReasonDuplicate, duplicates)
+		}
+
+		if p.maxCreatesPerSync > 0 {
+			var deferred int
+			c.Create, deferred = p.chunkCreates(ctx, zoneName, c.Create, recs, &createBudget)
+			deferredCreates += deferred
+		}
+
+		// Create in the configured type order and CNAME targets before the CNAMEs, delete in reverse
+		c.Create = orderByDependencies(orderByType(c.Create, p.typeOrder))
+		c.Delete = reverseEndpoints(orderByDependencies(orderByType(c.Delete, p.typeOrder)))
 
 		change := &PorkbunChange{
 			Create:    convertToPorkbunRecord(&recs, c.Create, zoneName, false),
@@ -222,26 +645,85 @@ func (p *PorkbunProvider) ApplyChanges(ctx context.Context, changes *plan.Change
 			Delete:    convertToPorkbunRecord(&recs, c.Delete, zoneName, true),
 		}
 
-		// If not in dry run, apply changes
-		_, err = p.UpdateDnsRecords(ctx, zoneName, change.UpdateOld)
-		if err != nil {
-			return err
+		p.applyToRecordHooks(c.Create, change.Create, &recs, zoneName)
+		p.applyToRecordHooks(c.UpdateNew, change.UpdateNew, &recs, zoneName)
+		p.applyToRecordHooks(c.UpdateOld, change.UpdateOld, &recs, zoneName)
+		p.applyToRecordHooks(c.Delete, change.Delete, &recs, zoneName)
+
+		// An update edits the record of the old endpoint in place, also if its content changes
+		inheritIDs(change.UpdateNew, change.UpdateOld)
+		keepTTLs(change.UpdateNew, recs)
+		if unchanged := dropUnchanged(zoneName, change.UpdateNew, change.UpdateOld, recs); unchanged > 0 {
+			p.logger.DebugContext(ctx, "skipping updates of records that are already up to date", "zone", zoneName, "updates", unchanged)
+			unchangedUpdatesTotal.Add(float64(unchanged))
 		}
-		_, err = p.DeleteDnsRecords(ctx, zoneName, change.Delete)
-		if err != nil {
-			return err
+
+		if err := findConflicts(zoneName, *change.Create, recs, *change.Delete, *change.UpdateOld); err != nil {
+			zoneErrs = append(zoneErrs, fmt.Errorf("zone '%s': %w", zoneName, err))
+			failed = append(failed, zoneName)
+			continue
+		}
+
+		// Stamp written records so stale ones can be found later, keeping notes written in the console
+		now := p.clock.Now()
+		stampLastModified(change.Create, now)
+		stampLastModified(change.UpdateNew, now)
+		keepOperatorNotes(change.Create, recs)
+		keepOperatorNotes(change.UpdateNew, recs)
+
+		release, err := p.lockZone(ctx, zoneName, recs)
+		if errors.Is(err, errZoneLocked) {
+			p.logger.WarnContext(ctx, "skipping changes for locked zone", "zone", zoneName, "error", err.Error())
+			skipped.skip(skipReasonZoneLocked, len(c.Create)+len(c.UpdateNew)+len(c.Delete))
+			continue
 		}
-		_, err = p.CreateDnsRecords(ctx, zoneName, change.Create)
 		if err != nil {
-			return err
+			zoneErrs = append(zoneErrs, fmt.Errorf("zone '%s': %w", zoneName, err))
+			failed = append(failed, zoneName)
+			continue
+		}
+
+		applyCtx, script := withChangeScript(ctx, zoneName, recs)
+		var journal *rollbackJournal
+		if p.rollbackOnFailure {
+			applyCtx, journal = withRollbackJournal(applyCtx, zoneName, recs)
+		}
+		err = p.applyRecords(applyCtx, zoneName, change)
+		if err == nil && len(*change.Create)+len(*change.UpdateNew)+len(*change.Delete) > 0 {
+			p.recordZoneWrite(ctx, zoneName, recs)
+		}
+		if err != nil && journal != nil {
+			err = p.rollbackZone(applyCtx, journal, err)
 		}
-		_, err = p.UpdateDnsRecords(ctx, zoneName, change.UpdateNew)
+		p.changeScripts.write(ctx, p.clock.Now(), script)
+		release()
 		if err != nil {
-			return err
+			zoneErrs = append(zoneErrs, fmt.Errorf("zone '%s': %w", zoneName, err))
+			failed = append(failed, zoneName)
+			continue
+		}
+		succeeded = append(succeeded, zoneName)
+		written = append(append(written, c.Create...), c.UpdateNew...)
+	}
+
+	if p.maxCreatesPerSync > 0 {
+		pendingCreates.Set(float64(deferredCreates))
+		if deferredCreates > 0 {
+			p.logger.InfoContext(ctx, "migration in progress - deferred creates to the next sync", "created", p.maxCreatesPerSync-createBudget, "deferred", deferredCreates)
 		}
 	}
 
-	p.logger.Debug("update completed")
+	// Records written in dry run are not published, there is nothing to verify
+	if len(p.resolvers) > 0 && len(written) > 0 && !p.dryRun {
+		go p.verifyPropagation(context.WithoutCancel(ctx), written)
+	}
+
+	if len(zoneErrs) > 0 {
+		p.logger.ErrorContext(ctx, "changes failed for some zones", append([]any{"failedZones", failed, "succeededZones", succeeded}, results.attrs()...)...)
+		return softError(errors.Join(zoneErrs...))
+	}
+
+	p.logger.InfoContext(ctx, "update completed", append([]any{"zones", succeeded}, results.attrs()...)...)
 
 	return nil
 }
@@ -252,30 +734,70 @@ func convertToPorkbunRecord(recs *[]pb.Record, endpoints []*endpoint.Endpoint, z
 	records := make([]pb.Record, len(endpoints))
 
 	for i, ep := range endpoints {
-		recordName := strings.TrimSuffix(ep.DNSName, "."+zoneName)
-		if recordName == zoneName {
-			recordName = ""
+		dnsName := normalizeName(ep.DNSName)
+		recordName := relativeName(dnsName, zoneName)
+		target := asciiTarget(ep.RecordType, ep.Targets[0])
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			target = parseTXT(target)
+		}
+		if ep.RecordType == recordTypeCAA {
+			target = normalizeCAA(target)
 		}
-		target := ep.Targets[0]
-		if ep.RecordType == endpoint.RecordTypeTXT && strings.HasPrefix(target, "\"heritage=") {
-			target = strings.Trim(ep.Targets[0], "\"")
+
+		ttl := ""
+		if ep.RecordTTL.IsConfigured() {
+			ttl = strconv.FormatInt(int64(ep.RecordTTL), 10)
+		}
+
+		content, prio := splitPriority(ep.RecordType, target)
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			content = formatTXT(content)
 		}
 
 		records[i] = pb.Record{
 			Type:    ep.RecordType,
 			Name:    recordName,
-			Content: target,
-			ID:      getIDforRecord(ep.DNSName, target, ep.RecordType, recs),
+			Content: content,
+			TTL:     ttl,
+			Prio:    prio,
+			ID:      recordID(ep, dnsName, target, recs),
+			Notes:   endpointNotes(ep),
 		}
 	}
 	return &records
 }
 
+// recordID returns the ID of the record an endpoint was listed from, taken from its record ID label.
+// Endpoints without the label, e.g. from payloads captured before the label existed, are resolved by their content.
+// returns empty string if no match found
+func recordID(ep *endpoint.Endpoint, dnsName string, target string, recs *[]pb.Record) string {
+	if id := ep.Labels[RecordIDLabelKey]; id != "" {
+		return id
+	}
+	return getIDforRecord(dnsName, target, ep.RecordType, recs)
+}
+
+// inheritIDs gives the new side of updates the record IDs of the old side. external-dns lists both sides in the same order.
+// The new side is not resolved by its own content, which could match another record of the zone; if the old side
+// could not be resolved, the edit resolves the record again when it is applied.
+func inheritIDs(updateNew *[]pb.Record, updateOld *[]pb.Record) {
+	for i := range *updateNew {
+		if i < len(*updateOld) {
+			(*updateNew)[i].ID = (*updateOld)[i].ID
+		}
+	}
+}
+
 // getIDforRecord compares the endpoint with existing records to get the ID from Porkbun to ensure it can be safely removed.
+// Names and host names in targets are compared normalized, since Porkbun may return them in mixed case and
+// external-dns may send them with a trailing dot. The target is compared in the form external-dns uses, including
+// the priority of MX and SRV records.
 // returns empty string if no match found
 func getIDforRecord(recordName string, target string, recordType string, recs *[]pb.Record) string {
+	recordName = normalizeName(recordName)
+	target = normalizeTarget(recordType, target)
 	for _, rec := range *recs {
-		if recordType == rec.Type && target == rec.Content && rec.Name == recordName {
+		if recordType == rec.Type && target == normalizeTarget(rec.Type, recordTarget(rec)) && normalizeName(rec.Name) == recordName {
 			return rec.ID
 		}
 	}
@@ -283,25 +805,46 @@ func getIDforRecord(recordName string, target string, recordType string, recs *[
 	return ""
 }
 
-// endpointZoneName determines zoneName for endpoint by taking longest suffix zoneName match in endpoint DNSName
-// returns empty string if no match found
-func endpointZoneName(endpoint *endpoint.Endpoint, zones []string) (zone string) {
-	var matchZoneName = ""
-	for _, zoneName := range zones {
-		if strings.HasSuffix(endpoint.DNSName, zoneName) && len(zoneName) > len(matchZoneName) {
-			matchZoneName = zoneName
+// getOnlyIDforName returns the ID of the record with the given name and type if there is exactly one.
+// returns empty string otherwise
+func getOnlyIDforName(recordName string, recordType string, recs []pb.Record) string {
+	recordName = normalizeName(recordName)
+	id := ""
+	for _, rec := range recs {
+		if rec.Type != recordType || normalizeName(rec.Name) != recordName {
+			continue
+		}
+		if id != "" {
+			return ""
 		}
+		id = rec.ID
 	}
-	return matchZoneName
+	return id
+}
+
+// endpointZoneName determines zoneName for endpoint by taking longest suffix zoneName match in endpoint DNSName,
+// matching whole labels only so that e.g. notexample.com is not in example.com
+// returns empty string if no match found
+func endpointZoneName(endpoint *endpoint.Endpoint, zones []string) (zone string) {
+	return nameZone(normalizeName(endpoint.DNSName), zones)
 }
 
 // ensureLogin makes sure that we are logged in to Porkbun API.
 func (p *PorkbunProvider) ensureLogin(ctx context.Context) error {
-	p.logger.Debug("performing login to Porkbun API")
+	if p.maintenance.paused(p.clock.Now()) {
+		return errMaintenance
+	}
+	p.logger.DebugContext(ctx, "performing login to Porkbun API")
 	_, err := p.client.Ping(ctx)
+	if isMaintenance(err) {
+		p.pauseForMaintenance(ctx, err)
+		return fmt.Errorf("%w: %v", errMaintenance, err)
+	}
+	p.health.setLogin(err)
 	if err != nil {
 		return err
 	}
-	p.logger.Debug("successfully logged in to Porkbun API")
+	p.resumeAfterMaintenance(ctx)
+	p.logger.DebugContext(ctx, "successfully logged in to Porkbun API")
 	return nil
 }
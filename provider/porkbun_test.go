@@ -15,16 +15,41 @@ limitations under the License.
 package porkbun
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	pb "github.com/nrdcg/porkbun"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/promslog"
 
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
 )
 
 func TestPorkbunProvider(t *testing.T) {
@@ -34,6 +59,322 @@ func TestPorkbunProvider(t *testing.T) {
 	t.Run("NewPorkbunProvider", testNewPorkbunProvider)
 	t.Run("ApplyChanges", testApplyChanges)
 	t.Run("Records", testRecords)
+	t.Run("Notes", testNotes)
+	t.Run("WarmupGate", testWarmupGate)
+	t.Run("APIUsage", testAPIUsage)
+	t.Run("DomainCheck", testDomainCheck)
+	t.Run("DomainFilter", testDomainFilter)
+	t.Run("ConversionHooks", testConversionHooks)
+	t.Run("Readiness", testReadiness)
+	t.Run("Paginate", testPaginate)
+	t.Run("Since", testSince)
+	t.Run("LogSampler", testLogSampler)
+	t.Run("StaleIDRefresh", testStaleIDRefresh)
+	t.Run("OrderByDependencies", testOrderByDependencies)
+	t.Run("RequestHeaders", testRequestHeaders)
+	t.Run("Config", testConfig)
+	t.Run("RecordAnomalies", testRecordAnomalies)
+	t.Run("Correlate", testCorrelate)
+	t.Run("CNAMETargetCheck", testCNAMETargetCheck)
+	t.Run("OrderByType", testOrderByType)
+	t.Run("ReadChanges", testReadChanges)
+	t.Run("GoneZones", testGoneZones)
+	t.Run("MemoryGuardrails", testMemoryGuardrails)
+	t.Run("MixedCaseNames", testMixedCaseNames)
+	t.Run("MigrationChunks", testMigrationChunks)
+	t.Run("TTLOverride", testTTLOverride)
+	t.Run("RecordConflicts", testRecordConflicts)
+	t.Run("PropagationCheck", testPropagationCheck)
+	t.Run("RecordIDLabels", testRecordIDLabels)
+	t.Run("TokenExchange", testTokenExchange)
+	t.Run("ApplyConcurrency", testApplyConcurrency)
+	t.Run("SkippedEndpoints", testSkippedEndpoints)
+	t.Run("BaseURLFailover", testBaseURLFailover)
+	t.Run("MultipleTargets", testMultipleTargets)
+	t.Run("TTLFallback", testTTLFallback)
+	t.Run("ZoneLock", testZoneLock)
+	t.Run("Status", testStatus)
+	t.Run("Priority", testPriority)
+	t.Run("ApexAlias", testApexAlias)
+	t.Run("Cutover", testCutover)
+	t.Run("CAA", testCAA)
+	t.Run("MetricsMetadata", testMetricsMetadata)
+	t.Run("NameEdgeCases", testNameEdgeCases)
+	t.Run("ChangeLog", testChangeLog)
+	t.Run("ManageNS", testManageNS)
+	t.Run("DeepHealth", testDeepHealth)
+	t.Run("OwnershipTransfer", testOwnershipTransfer)
+	t.Run("ZoneCredentials", testZoneCredentials)
+	t.Run("LongTXT", testLongTXT)
+	t.Run("RecordsCacheHeaders", testRecordsCacheHeaders)
+	t.Run("TXTQuoting", testTXTQuoting)
+	t.Run("GroupedRecords", testGroupedRecords)
+	t.Run("OwnerPartitioning", testOwnerPartitioning)
+	t.Run("MinTTL", testMinTTL)
+	t.Run("NumericFields", testNumericFields)
+	t.Run("DefaultTTL", testDefaultTTL)
+	t.Run("CacheSnapshots", testCacheSnapshots)
+	t.Run("ManagedRecordTypes", testManagedRecordTypes)
+	t.Run("ChangeSetMetrics", testChangeSetMetrics)
+	t.Run("ExcludedRecordTypes", testExcludedRecordTypes)
+	t.Run("RetryPolicies", testRetryPolicies)
+	t.Run("AdjustEndpoints", testAdjustEndpoints)
+	t.Run("RecordTemplates", testRecordTemplates)
+	t.Run("Maintenance", testMaintenance)
+	t.Run("UnderscoreLabels", testUnderscoreLabels)
+	t.Run("DryRun", testDryRun)
+	t.Run("SyncRecord", testSyncRecord)
+	t.Run("UnchangedUpdates", testUnchangedUpdates)
+	t.Run("EmptyTargets", testEmptyTargets)
+	t.Run("SingleEditUpdates", testSingleEditUpdates)
+	t.Run("TypeChanges", testTypeChanges)
+	t.Run("OutputEncryption", testOutputEncryption)
+	t.Run("NotesRoundTrip", testNotesRoundTrip)
+	t.Run("OwnershipGuard", testOwnershipGuard)
+	t.Run("MaxDeletesPerSync", testMaxDeletesPerSync)
+	t.Run("RecordProtection", testRecordProtection)
+	t.Run("ExcludeDomains", testExcludeDomains)
+	t.Run("DelegatedSubzones", testDelegatedSubzones)
+	t.Run("NameForms", testNameForms)
+	t.Run("ZoneFailureIsolation", testZoneFailureIsolation)
+	t.Run("SoftErrors", testSoftErrors)
+	t.Run("RequestTimeout", testRequestTimeout)
+	t.Run("RollbackOnFailure", testRollbackOnFailure)
+	t.Run("DuplicateChanges", testDuplicateChanges)
+	t.Run("TargetValidation", testTargetValidation)
+	t.Run("TXTGC", testTXTGC)
+	t.Run("ChangeResults", testChangeResults)
+	t.Run("EndpointLabels", testEndpointLabels)
+	t.Run("ChangeOrder", testChangeOrder)
+	t.Run("SRVRecords", testSRVRecords)
+	t.Run("TTLProperty", testTTLProperty)
+}
+
+func testMemoryGuardrails(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	record := pb.Record{ID: "1", Name: "foo.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}
+
+	// the least recently synced zones are evicted, but still count as fetched
+	cache := newZoneCache()
+	cache.maxRecords = 3
+	assert.Empty(t, cache.set("a.com", []pb.Record{record, record}, now))
+	assert.Empty(t, cache.set("b.com", []pb.Record{record}, now.Add(time.Minute)))
+	assert.Equal(t, []string{"a.com"}, cache.set("c.com", []pb.Record{record}, now.Add(2*time.Minute)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(cacheRecords))
+	assert.Equal(t, float64(2*recordSize(record)), testutil.ToFloat64(cacheBytes))
+	assert.True(t, cache.complete([]string{"a.com", "b.com", "c.com"}))
+	_, _, ok := cache.snapshot([]string{"a.com", "b.com", "c.com"})
+	assert.False(t, ok)
+
+	// a zone exceeding the limit on its own is kept and evicts all others
+	assert.Equal(t, []string{"b.com", "c.com"}, cache.set("d.com", []pb.Record{record, record, record, record}, now.Add(3*time.Minute)))
+	_, ok = cache.get("d.com")
+	assert.True(t, ok)
+
+	// a zone that is synced again is not evicted any more
+	assert.Equal(t, []string{"d.com"}, cache.set("a.com", []pb.Record{record}, now.Add(4*time.Minute)))
+	_, _, ok = cache.snapshot([]string{"a.com"})
+	assert.True(t, ok)
+
+	// responses larger than the limit fail instead of being read into memory
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"SUCCESS","records":[{"id":"1","name":"foo.example.com","type":"A","content":"5.5.5.5"}]}`))
+	}))
+	defer server.Close()
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxResponseSize(64))
+	p.client.(*meteredClient).client.(*pb.Client).BaseURL, _ = url.Parse(server.URL + "/")
+	_, err := p.client.RetrieveRecords(context.TODO(), "example.com")
+	assert.ErrorContains(t, err, "response exceeds size limit")
+
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxResponseSize(1024))
+	p.client.(*meteredClient).client.(*pb.Client).BaseURL, _ = url.Parse(server.URL + "/")
+	records, err := p.client.RetrieveRecords(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	// a body of exactly the limit is read completely
+	body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("12345")), remaining: 5, limit: 5}
+	content, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", string(content))
+	body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("123456")), remaining: 5, limit: 5}
+	_, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, errResponseTooLarge)
+}
+
+func testMixedCaseNames(t *testing.T) {
+	recs := []pb.Record{
+		{ID: "1", Name: "WWW.Example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		{ID: "2", Name: "Example.COM", Type: "TXT", Content: "heritage=external-dns", TTL: "600"},
+	}
+	assert.Equal(t, "1", getIDforRecord("www.example.com", "5.5.5.5", "A", &recs))
+	assert.Equal(t, "1", getIDforRecord("WWW.EXAMPLE.COM.", "5.5.5.5", "A", &recs))
+	assert.Equal(t, "2", getOnlyIDforName("example.com", "TXT", recs))
+	assert.Equal(t, "example.com", endpointZoneName(endpoint.NewEndpoint("WWW.Example.Com", "A", "5.5.5.5"), []string{"example.com"}))
+
+	converted := convertToPorkbunRecord(&recs, []*endpoint.Endpoint{endpoint.NewEndpoint("WWW.EXAMPLE.COM.", "A", "5.5.5.5")}, "example.com", false)
+	assert.Equal(t, "www", (*converted)[0].Name)
+	assert.Equal(t, "1", (*converted)[0].ID)
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": recs})
+	p.client = client
+
+	// names returned in mixed case are listed in lower case
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, "www.example.com", endpoints[0].DNSName)
+	assert.Equal(t, "example.com", endpoints[1].DNSName)
+
+	// changes sent in lower case resolve the IDs of the mixed-case records instead of creating duplicates
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", "5.5.5.5")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1"}, client.calls)
+}
+
+func testMigrationChunks(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxCreatesPerSync(3))
+	client := newFakeClient(map[string][]pb.Record{})
+	p.client = client
+
+	changes := &plan.Changes{}
+	for _, name := range []string{"a", "b", "c"} {
+		changes.Create = append(changes.Create,
+			endpoint.NewEndpoint(name+".example.com", endpoint.RecordTypeA, "5.5.5.5"),
+			endpoint.NewEndpoint("a-"+name+".example.com", endpoint.RecordTypeTXT, "heritage=external-dns"),
+		)
+	}
+	changes.Create = append(changes.Create, endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "6.6.6.6"))
+
+	countTypes := func(zone string) map[string]int {
+		counts := map[string]int{}
+		for _, rec := range client.zones[zone] {
+			counts[rec.Type]++
+		}
+		return counts
+	}
+
+	// the registry records are created first, the rest is deferred
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, map[string]int{"TXT": 3}, countTypes("example.com"))
+	assert.Empty(t, client.zones["example.org"])
+	assert.Equal(t, float64(4), testutil.ToFloat64(pendingCreates))
+
+	// records created by the earlier sync are skipped when external-dns plans them again
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, map[string]int{"TXT": 3, "A": 3}, countTypes("example.com"))
+	assert.Empty(t, client.zones["example.org"])
+	assert.Equal(t, float64(1), testutil.ToFloat64(pendingCreates))
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, map[string]int{"TXT": 3, "A": 3}, countTypes("example.com"))
+	assert.Equal(t, map[string]int{"A": 1}, countTypes("example.org"))
+	assert.Equal(t, float64(0), testutil.ToFloat64(pendingCreates))
+}
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeClient is an in-memory Porkbun API.
+type fakeClient struct {
+	zones  map[string][]pb.Record
+	nextID int
+	// fail is consulted before every call and can inject errors
+	fail  func(op string, zone string, id int) error
+	calls []string
+	// mu guards zones, nextID and calls, fail is called without holding it
+	mu sync.Mutex
+}
+
+func newFakeClient(zones map[string][]pb.Record) *fakeClient {
+	return &fakeClient{zones: zones, nextID: 1000}
+}
+
+func (c *fakeClient) failure(op string, zone string, id int) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, fmt.Sprintf("%s %s %d", op, zone, id))
+	c.mu.Unlock()
+	if c.fail == nil {
+		return nil
+	}
+	return c.fail(op, zone, id)
+}
+
+func (c *fakeClient) Ping(ctx context.Context) (string, error) {
+	return "127.0.0.1", c.failure("ping", "", 0)
+}
+
+func (c *fakeClient) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	if err := c.failure("create", domain, 0); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	record.ID = strconv.Itoa(c.nextID)
+	record.Name = recordFQDN(record.Name, domain)
+	if record.TTL == "" {
+		record.TTL = pb.DefaultTTL
+	}
+	c.zones[domain] = append(c.zones[domain], record)
+	return c.nextID, nil
+}
+
+func (c *fakeClient) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	if err := c.failure("edit", domain, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, rec := range c.zones[domain] {
+		if rec.ID == strconv.Itoa(id) {
+			record.ID = rec.ID
+			record.Name = recordFQDN(record.Name, domain)
+			c.zones[domain][i] = record
+			return nil
+		}
+	}
+	return pb.Status{Status: "ERROR", Message: "Invalid record ID."}
+}
+
+func (c *fakeClient) DeleteRecord(ctx context.Context, domain string, id int) error {
+	if err := c.failure("delete", domain, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, rec := range c.zones[domain] {
+		if rec.ID == strconv.Itoa(id) {
+			c.zones[domain] = append(c.zones[domain][:i], c.zones[domain][i+1:]...)
+			return nil
+		}
+	}
+	return pb.Status{Status: "ERROR", Message: "Invalid record ID."}
+}
+
+func (c *fakeClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	if err := c.failure("retrieve", domain, 0); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]pb.Record(nil), c.zones[domain]...), nil
 }
 
 func testEndpointZoneName(t *testing.T) {
@@ -63,6 +404,22 @@ func testEndpointZoneName(t *testing.T) {
 	assert.Equal(t, endpointZoneName(&ep1, zoneList), "bar.org")
 	assert.Equal(t, endpointZoneName(&ep2, zoneList), "")
 	assert.Equal(t, endpointZoneName(&ep3, zoneList), "baz.org")
+
+	// zones match on label boundaries only, the longest zone wins
+	for name, zone := range map[string]string{
+		"notbar.org":         "",
+		"foo.notbar.org":     "",
+		"bar.org.evil.com":   "",
+		"Foo.Bar.org.":       "bar.org",
+		"sub.bar.org":        "sub.bar.org",
+		"foo.sub.bar.org":    "sub.bar.org",
+		"foo.notsub.bar.org": "bar.org",
+	} {
+		ep := endpoint.NewEndpoint(name, endpoint.RecordTypeA, "5.5.5.5")
+		assert.Equal(t, zone, endpointZoneName(ep, []string{"bar.org", "sub.bar.org"}), name)
+	}
+	assert.False(t, inZone("notbar.org", "bar.org"))
+	assert.True(t, inZone("bar.org", "bar.org"))
 }
 
 func testGetIDforRecord(t *testing.T) {
@@ -269,3 +626,4284 @@ func testRecords(t *testing.T) {
 	assert.Equal(t, []*endpoint.Endpoint{}, ep)
 	assert.NoError(t, err)
 }
+
+func testNotes(t *testing.T) {
+	// notes not written by the provider are ignored
+	assert.Nil(t, parseNotes("added by hand"))
+	_, ok := lastModified(pb.Record{Notes: "added by hand"})
+	assert.False(t, ok)
+
+	meta := parseNotes("external-dns: last-modified=2024-01-02T03:04:05Z; foo=bar")
+	assert.Equal(t, map[string]string{"last-modified": "2024-01-02T03:04:05Z", "foo": "bar"}, meta)
+	assert.Equal(t, "external-dns: foo=bar; last-modified=2024-01-02T03:04:05Z", formatNotes(meta))
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := []pb.Record{
+		{Name: "foo", Type: "A", Content: "5.5.5.5"},
+		{Name: "bar", Type: "A", Content: "5.5.5.5", Notes: "external-dns: foo=bar"},
+	}
+	stampLastModified(&records, now)
+
+	for _, rec := range records {
+		modified, ok := lastModified(rec)
+		assert.True(t, ok)
+		assert.Equal(t, now, modified)
+	}
+	assert.Equal(t, "bar", parseNotes(records[1].Notes)["foo"])
+
+	// the originating resource is taken from the endpoint labels
+	ep := &endpoint.Endpoint{
+		DNSName:    "foo.bar.org",
+		Targets:    endpoint.Targets{"5.5.5.5"},
+		RecordType: endpoint.RecordTypeA,
+		Labels:     endpoint.Labels{endpoint.ResourceLabelKey: "ingress/default/web"},
+	}
+	assert.Equal(t, "external-dns: resource=ingress/default/web", endpointNotes(ep))
+	assert.Equal(t, "", endpointNotes(&endpoint.Endpoint{DNSName: "foo.bar.org"}))
+
+	converted := convertToPorkbunRecord(&[]pb.Record{}, []*endpoint.Endpoint{ep}, "bar.org", false)
+	assert.Equal(t, "ingress/default/web", parseNotes((*converted)[0].Notes)[notesKeyResource])
+
+	// notes written in the console are kept in front of the metadata
+	existing := []pb.Record{{ID: "1", Notes: "ask the web team external-dns: last-modified=2024-01-02T03:04:05Z"}}
+	written := []pb.Record{{ID: "1", Notes: "external-dns: resource=ingress/default/web"}}
+	stampLastModified(&written, now)
+	keepOperatorNotes(&written, existing)
+	assert.Equal(t, "ask the web team external-dns: last-modified=2025-06-01T12:00:00Z; resource=ingress/default/web", written[0].Notes)
+	modified, ok := lastModified(written[0])
+	assert.True(t, ok)
+	assert.Equal(t, now, modified)
+
+	// the staleness report is served from the cache
+	domainFilter := []string{"bar.org"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: now}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock))
+	p.client = newFakeClient(map[string][]pb.Record{})
+	assert.Empty(t, p.StaleRecords(time.Hour))
+	p.cache.set("bar.org", append(existing, pb.Record{ID: "2", Notes: "added by hand"}), now)
+	stale := p.StaleRecords(time.Hour)
+	assert.Len(t, stale, 1)
+	assert.Empty(t, p.client.(*fakeClient).calls)
+}
+
+func testWarmupGate(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger)
+	handler := p.WarmupGate(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// listing records is rejected until the warm-up has finished
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	// applying changes is never gated
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/records", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	p.Warmup(context.TODO(), time.Minute)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func testAPIUsage(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	usage := newAPIUsage(3, clock, logger)
+
+	usage.record(context.TODO(), "example.com", "retrieve")
+	usage.record(context.TODO(), "example.com", "create")
+	usage.record(context.TODO(), "example.org", "retrieve")
+	assert.Equal(t, 2, usage.lastHour("example.com"))
+	assert.Equal(t, 1, usage.lastHour("example.org"))
+
+	usage.record(context.TODO(), "example.com", "edit")
+	assert.True(t, usage.warned["example.com"])
+
+	collector := &usageCollector{desc: apiCallsLastHour.desc}
+	collector.observe(usage)
+	assert.Equal(t, 2, testutil.CollectAndCount(collector))
+
+	// calls older than an hour drop out of the window, also for zones without new calls
+	clock.now = clock.now.Add(61 * time.Minute)
+	assert.Equal(t, map[string]int{"example.com": 0, "example.org": 0}, usage.allLastHour())
+	assert.Equal(t, 0, usage.lastHour("example.com"))
+	usage.record(context.TODO(), "example.com", "retrieve")
+	assert.False(t, usage.warned["example.com"])
+}
+
+func testDomainCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/domain/checkDomain/example.com":
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","response":{"avail":"yes","price":"9.68","regularPrice":"10.37","premium":"no"}}`))
+		case "/pricing/get":
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","pricing":{"com":{"registration":"9.68","renewal":"10.37","transfer":"10.37"},"org":{"registration":"6.88","renewal":"10.74","transfer":"10.74"}}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"ERROR","message":"Invalid domain."}`))
+		}
+	}))
+	defer server.Close()
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger)
+	p.domains.baseURL = server.URL + "/"
+
+	rec := httptest.NewRecorder()
+	p.DomainCheckHandler(rec, httptest.NewRequest(http.MethodGet, "/domains/check?domain=example.com", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"domain":"example.com","available":true,"price":"9.68","regularPrice":"10.37","premium":false}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	p.DomainCheckHandler(rec, httptest.NewRequest(http.MethodGet, "/domains/check?domain=invalid", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+
+	rec = httptest.NewRecorder()
+	p.DomainCheckHandler(rec, httptest.NewRequest(http.MethodGet, "/domains/check", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	p.DomainPricingHandler(rec, httptest.NewRequest(http.MethodGet, "/domains/pricing?tld=.org", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"org":{"registration":"6.88","renewal":"10.74","transfer":"10.74"}}`, rec.Body.String())
+
+	// both only answer GET requests
+	rec = httptest.NewRecorder()
+	p.DomainCheckHandler(rec, httptest.NewRequest(http.MethodPost, "/domains/check?domain=example.com", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	rec = httptest.NewRecorder()
+	p.DomainPricingHandler(rec, httptest.NewRequest(http.MethodDelete, "/domains/pricing", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Allow"))
+}
+
+func testDomainFilter(t *testing.T) {
+	domainFilter := []string{"example.org", "example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger)
+
+	snapshot := p.domainFilter.Load()
+	assert.Equal(t, []string{"example.com", "example.org"}, snapshot.Filters)
+	assert.True(t, p.GetDomainFilter().Match("foo.example.org"))
+
+	assert.Error(t, p.SetDomainFilter([]string{}))
+	assert.NoError(t, p.SetDomainFilter([]string{"example.net"}))
+
+	// earlier snapshots are not modified by updates
+	assert.Equal(t, []string{"example.com", "example.org"}, snapshot.Filters)
+	assert.True(t, p.GetDomainFilter().Match("foo.example.net"))
+	assert.False(t, p.GetDomainFilter().Match("foo.example.org"))
+}
+
+type prefixHook struct{}
+
+func (prefixHook) ToRecord(ep *endpoint.Endpoint, record *pb.Record) {
+	record.Name = "prefix-" + record.Name
+}
+
+func (prefixHook) FromRecord(record pb.Record, ep *endpoint.Endpoint) {
+	ep.DNSName = strings.TrimPrefix(ep.DNSName, "prefix-")
+}
+
+func testConversionHooks(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger, WithConversionHooks(prefixHook{}))
+
+	ep := &endpoint.Endpoint{
+		DNSName:    "foo.example.com",
+		Targets:    endpoint.Targets{"5.5.5.5"},
+		RecordType: endpoint.RecordTypeA,
+	}
+	existing := []pb.Record{{ID: "7", Name: "prefix-foo.example.com", Type: "A", Content: "5.5.5.5"}}
+	records := convertToPorkbunRecord(&existing, []*endpoint.Endpoint{ep}, "example.com", false)
+	p.applyToRecordHooks([]*endpoint.Endpoint{ep}, records, &existing, "example.com")
+	assert.Equal(t, "prefix-foo", (*records)[0].Name)
+	assert.Equal(t, "7", (*records)[0].ID)
+
+	read := endpoint.NewEndpoint("prefix-foo.example.com", endpoint.RecordTypeA, "5.5.5.5")
+	p.applyFromRecordHooks((*records)[0], read)
+	assert.Equal(t, "foo.example.com", read.DNSName)
+
+	_, err := LoadConversionHookPlugin("does-not-exist.so")
+	assert.Error(t, err)
+
+	// builds that can't open plugins, like the release image built without cgo, refuse them at startup
+	cfg := DefaultConfig()
+	cfg.DomainFilter = []string{"example.com"}
+	cfg.APIKey = "key"
+	cfg.APISecret = "secret"
+	cfg.ConversionPlugins = []string{"does-not-exist.so"}
+	if pluginsSupported {
+		assert.NoError(t, cfg.Validate())
+	} else {
+		assert.ErrorIs(t, cfg.Validate(), errPluginsUnsupported)
+	}
+
+	// rewritten records written by ApplyChanges are found again for updates and deletes
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithConversionHooks(prefixHook{}))
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}})
+	p.client = client
+
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{ep}})
+	assert.NoError(t, err)
+	assert.Equal(t, "prefix-foo.example.com", client.zones["example.com"][0].Name)
+
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.example.com", endpoints[0].DNSName)
+
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Delete: endpoints})
+	assert.NoError(t, err)
+	assert.Empty(t, client.zones["example.com"])
+}
+
+func testReadiness(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger)
+
+	// not ready before the warm-up finished
+	rec := httptest.NewRecorder()
+	p.ReadyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.False(t, p.Readiness().Components["cache"].Ready)
+
+	p.Warmup(context.TODO(), time.Minute)
+	rec = httptest.NewRecorder()
+	p.ReadyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// a failing zone is listed as degraded until it recovers
+	p.health.setZone("example.com", errors.New("boom"))
+	readiness := p.Readiness()
+	assert.False(t, readiness.Ready)
+	assert.Equal(t, map[string]string{"example.com": "boom"}, readiness.DegradedZones)
+
+	p.health.setZone("example.com", nil)
+	assert.True(t, p.Readiness().Ready)
+}
+
+func testPaginate(t *testing.T) {
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("c.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+		endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+		endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+		endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeTXT, "heritage=external-dns"),
+	}
+
+	page, next := paginate(endpoints, "", 3)
+	assert.Len(t, page, 3)
+	assert.Equal(t, "a.example.com", page[0].DNSName)
+	assert.Equal(t, endpoint.RecordTypeTXT, page[1].RecordType)
+	assert.Equal(t, "b.example.com", page[2].DNSName)
+	assert.NotEmpty(t, next)
+
+	page, next = paginate(endpoints, next, 3)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "c.example.com", page[0].DNSName)
+	assert.Empty(t, next)
+
+	// without a limit the wrapped handler serves the complete listing
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger, WithMaxPageSize(2))
+	called := false
+	handler := p.PaginateRecords(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.True(t, called)
+
+	// dry-run mode has no records to page through
+	called = false
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/records?limit=1", nil))
+	assert.True(t, called)
+
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxPageSize(2))
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "a.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "2", Name: "b.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "3", Name: "c.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+	handler = p.PaginateRecords(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token := rec.Header().Get(ContinueHeader)
+	assert.NotEmpty(t, token)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=2&continue="+token, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(ContinueHeader))
+
+	// tokens of a listing are stale once the cache changed
+	cached, _ := p.cache.get("example.com")
+	p.cache.set("example.com", cached.records, cached.fetchedAt)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=2&continue="+token, nil))
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func testSince(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger, WithClock(clock), WithClockSkewTolerance(time.Minute))
+
+	assert.Equal(t, time.Hour, p.since(clock.now.Add(-time.Hour)))
+	// timestamps from skewed clocks count as just written
+	assert.Equal(t, time.Duration(0), p.since(clock.now.Add(30*time.Second)))
+	assert.Equal(t, time.Duration(0), p.since(clock.now.Add(time.Hour)))
+}
+
+func testLogSampler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	sampler := newLogSampler(logger)
+	sampler.defaultLimit = 2
+	sampler.limits[logClassIgnored] = 0
+
+	for i := 0; i < 5; i++ {
+		sampler.debug(context.TODO(), logClassPlanning, "planning")
+		sampler.debug(context.TODO(), logClassIgnored, "ignoring")
+	}
+	sampler.flush(context.TODO())
+
+	assert.Equal(t, 2, strings.Count(buf.String(), "msg=planning"))
+	assert.Equal(t, 5, strings.Count(buf.String(), "msg=ignoring"))
+	assert.Contains(t, buf.String(), "class=planning logged=2 suppressed=3")
+
+	// counting starts over with every sync
+	buf.Reset()
+	sampler.debug(context.TODO(), logClassPlanning, "planning")
+	assert.Equal(t, 1, strings.Count(buf.String(), "msg=planning"))
+
+	limits, err := ParseLogSampleLimits([]string{"planning=10"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"planning": 10}, limits)
+	_, err = ParseLogSampleLimits([]string{"unknown=10"})
+	assert.Error(t, err)
+	_, err = ParseLogSampleLimits([]string{"planning"})
+	assert.Error(t, err)
+}
+
+func testStaleIDRefresh(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+
+	// the records were recreated out-of-band and got new IDs
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "2", Name: "foo.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "3", Name: "bar.example.com", Type: "A", Content: "6.6.6.6", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	_, err := p.DeleteDnsRecords(context.TODO(), "example.com", &[]pb.Record{{ID: "1", Name: "foo", Type: "A", Content: "5.5.5.5"}})
+	assert.NoError(t, err)
+	assert.Len(t, client.zones["example.com"], 1)
+
+	// edits fall back to the only record with the same name and type
+	_, err = p.UpdateDnsRecords(context.TODO(), "example.com", &[]pb.Record{{ID: "1", Name: "bar", Type: "A", Content: "7.7.7.7"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "7.7.7.7", client.zones["example.com"][0].Content)
+
+	// deleting records that are really gone succeeds, editing them creates them again
+	_, err = p.DeleteDnsRecords(context.TODO(), "example.com", &[]pb.Record{{ID: "1", Name: "baz", Type: "A", Content: "5.5.5.5"}})
+	assert.NoError(t, err)
+	_, err = p.UpdateDnsRecords(context.TODO(), "example.com", &[]pb.Record{{ID: "1", Name: "baz", Type: "A", Content: "5.5.5.5"}})
+	assert.NoError(t, err)
+	assert.Len(t, client.zones["example.com"], 2)
+	assert.Equal(t, "baz.example.com", client.zones["example.com"][1].Name)
+
+	// the same goes for records whose ID was never resolved
+	_, err = p.DeleteDnsRecords(context.TODO(), "example.com", &[]pb.Record{{Name: "qux", Type: "A", Content: "5.5.5.5"}})
+	assert.NoError(t, err)
+	_, err = p.UpdateDnsRecords(context.TODO(), "example.com", &[]pb.Record{{Name: "qux", Type: "A", Content: "8.8.8.8"}})
+	assert.NoError(t, err)
+	assert.Len(t, client.zones["example.com"], 3)
+	assert.Equal(t, "8.8.8.8", client.zones["example.com"][2].Content)
+
+	// other failures of the refresh are still returned
+	client.fail = func(op string, zone string, id int) error {
+		if op == "retrieve" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	_, err = p.UpdateDnsRecords(context.TODO(), "example.com", &[]pb.Record{{Name: "quux", Type: "A", Content: "8.8.8.8"}})
+	assert.Error(t, err)
+	client.fail = nil
+
+	assert.True(t, isRecordNotFound(pb.Status{Status: "ERROR", Message: "Invalid record ID."}))
+	assert.True(t, isRecordNotFound(&pb.ServerError{StatusCode: http.StatusNotFound}))
+	assert.False(t, isRecordNotFound(&pb.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "Service Unavailable"}))
+	assert.False(t, isRecordNotFound(errors.New("Invalid record ID.")))
+	assert.False(t, isRecordNotFound(pb.Status{Status: "ERROR", Message: "Unable to edit record: invalid content."}))
+}
+
+func testOrderByDependencies(t *testing.T) {
+	names := func(endpoints []*endpoint.Endpoint) []string {
+		result := make([]string, 0, len(endpoints))
+		for _, ep := range endpoints {
+			result = append(result, ep.DNSName)
+		}
+		return result
+	}
+
+	// c -> b -> a chain listed in the wrong order
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("c.example.com", endpoint.RecordTypeCNAME, "b.example.com"),
+		endpoint.NewEndpoint("other.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+		endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeCNAME, "a.example.com."),
+		endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+	}
+	assert.Equal(t, []string{"other.example.com", "a.example.com", "b.example.com", "c.example.com"}, names(orderByDependencies(endpoints)))
+	assert.Equal(t, []string{"c.example.com", "b.example.com", "a.example.com", "other.example.com"}, names(reverseEndpoints(orderByDependencies(endpoints))))
+
+	// loops keep their order
+	loop := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("x.example.com", endpoint.RecordTypeCNAME, "y.example.com"),
+		endpoint.NewEndpoint("y.example.com", endpoint.RecordTypeCNAME, "x.example.com"),
+	}
+	assert.Equal(t, []string{"x.example.com", "y.example.com"}, names(orderByDependencies(loop)))
+
+	// ALIAS records wait for their targets like CNAMEs
+	aliases := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", recordTypeALIAS, "lb.example.com"),
+		endpoint.NewEndpoint("lb.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+	}
+	assert.Equal(t, []string{"lb.example.com", "example.com"}, names(orderByDependencies(aliases)))
+
+	// zones creating CNAME targets are applied before the zones pointing at them
+	changes := map[string]*plan.Changes{
+		"a.org": {Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.a.org", endpoint.RecordTypeCNAME, "lb.b.org")}},
+		"b.org": {Create: []*endpoint.Endpoint{endpoint.NewEndpoint("lb.b.org", endpoint.RecordTypeA, "5.5.5.5")}},
+		"c.org": {},
+	}
+	assert.Equal(t, []string{"b.org", "a.org", "c.org"}, orderZones([]string{"a.org", "b.org", "c.org"}, changes))
+	changes["b.org"].Create = append(changes["b.org"].Create, endpoint.NewEndpoint("www.b.org", endpoint.RecordTypeCNAME, "www.a.org"))
+	assert.Equal(t, []string{"c.org", "a.org", "b.org"}, orderZones([]string{"a.org", "b.org", "c.org"}, changes))
+	changes = map[string]*plan.Changes{
+		"a.org": {Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.org", recordTypeALIAS, "lb.b.org")}},
+		"b.org": {Create: []*endpoint.Endpoint{endpoint.NewEndpoint("lb.b.org", endpoint.RecordTypeA, "5.5.5.5")}},
+	}
+	assert.Equal(t, []string{"b.org", "a.org"}, orderZones([]string{"a.org", "b.org"}, changes))
+}
+
+func testRequestHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		if r.URL.Path == "/ping" {
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"SUCCESS","pricing":{}}`))
+	}))
+	defer server.Close()
+
+	headers, err := ParseRequestHeaders([]string{"X-Team: dns", "X-Ticket:1234"})
+	assert.NoError(t, err)
+	_, err = ParseRequestHeaders([]string{"no colon"})
+	assert.Error(t, err)
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClusterID("prod-eu"), WithRequestHeaders(headers))
+	p.domains.baseURL = server.URL + "/"
+
+	_, err = p.domains.Pricing(context.TODO())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(received.Get("User-Agent"), "external-dns-porkbun-webhook/"))
+	assert.True(t, strings.HasSuffix(received.Get("User-Agent"), "(cluster=prod-eu)"))
+	assert.Equal(t, "dns", received.Get("X-Team"))
+	assert.Equal(t, "1234", received.Get("X-Ticket"))
+
+	// all DNS calls go through the Porkbun client
+	pbClient := p.client.(*meteredClient).client.(*pb.Client)
+	pbClient.BaseURL, _ = url.Parse(server.URL + "/")
+	received = nil
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "external-dns-porkbun-webhook/dev (cluster=prod-eu)", received.Get("User-Agent"))
+	assert.Equal(t, "dns", received.Get("X-Team"))
+
+	assert.Equal(t, "external-dns-porkbun-webhook/dev", userAgent(""))
+}
+
+func testConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DomainFilter = []string{"example.com"}
+	cfg.APIKey = "key"
+	cfg.APISecret = "secret"
+	assert.NoError(t, cfg.Validate())
+
+	p, err := NewPorkbunProviderFromConfig(cfg, promslog.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStaleAfter, p.staleAfter)
+	assert.Equal(t, defaultAPICallWarningThreshold, p.usage.warnPerHour)
+
+	cfg.DomainFilter = []string{"not a domain"}
+	cfg.APIKey = ""
+	cfg.WarmupTimeout = -time.Second
+	cfg.LogSampleClassLimits = map[string]int{"unknown": 1}
+	cfg.CNAMETargetCheck = "sometimes"
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, "--domain-filter")
+	assert.ErrorContains(t, err, "--api-key")
+	assert.ErrorContains(t, err, "--warmup-timeout")
+	assert.ErrorContains(t, err, "--log-sample-class-limit")
+	assert.ErrorContains(t, err, "--cname-target-check")
+}
+
+func testRecordAnomalies(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "a.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "2", Name: "b.example.com", Type: "A", Content: "5.5.5.5", TTL: "ten minutes"},
+			{ID: "3", Name: "c.example.org", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "", Name: "d.example.com", Type: "", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+
+	// a single odd record does not fail the whole listing
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, endpoint.TTL(600), endpoints[0].RecordTTL)
+	assert.Equal(t, "b.example.com", endpoints[1].DNSName)
+	assert.False(t, endpoints[1].RecordTTL.IsConfigured())
+}
+
+// FuzzRecordsToEndpoints feeds arbitrary record fields through the conversion into endpoints and the ID resolution.
+func FuzzRecordsToEndpoints(f *testing.F) {
+	f.Add("1", "foo.example.com", "A", "5.5.5.5", "600")
+	f.Add("", "@.example.com", "TXT", "\"heritage=external-dns\"", "")
+	f.Add("abc", "foo.example.org", "", "", "-1")
+	f.Add("2", "", "CNAME", "example.com.", "99999999999999999999")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger)
+
+	f.Fuzz(func(t *testing.T, id, name, recordType, content, ttl string) {
+		recs := []pb.Record{{ID: id, Name: name, Type: recordType, Content: content, TTL: ttl}}
+		for _, ep := range p.recordsToEndpoints(context.TODO(), "example.com", recs, nil) {
+			assert.True(t, ep.DNSName == "example.com" || strings.HasSuffix(ep.DNSName, ".example.com"))
+			assert.GreaterOrEqual(t, int64(ep.RecordTTL), int64(0))
+			if len(ep.Targets) == 0 {
+				continue
+			}
+			converted := convertToPorkbunRecord(&recs, []*endpoint.Endpoint{ep}, "example.com", true)
+			assert.Len(t, *converted, 1)
+		}
+		getIDforRecord(name, content, recordType, &recs)
+		getOnlyIDforName(name, recordType, recs)
+	})
+}
+
+// FuzzRetrieveRecordsResponse feeds arbitrary Porkbun responses through Records().
+func FuzzRetrieveRecordsResponse(f *testing.F) {
+	f.Add(`{"status":"SUCCESS","records":[{"id":"1","name":"foo.example.com","type":"A","content":"5.5.5.5","ttl":"600"}]}`)
+	f.Add(`{"status":"SUCCESS","records":[{"name":"foo.example.com","type":"A","ttl":600}]}`)
+	f.Add(`{"status":"SUCCESS","records":[{"id":"1","name":"other.org","type":"A","content":"5.5.5.5","ttl":"x"}]}`)
+	f.Add(`{"status":"SUCCESS","records":null}`)
+	f.Add(`{"status":"ERROR","message":"Invalid domain."}`)
+	f.Add(`not json`)
+
+	var mu sync.Mutex
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1"}`))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client.(*meteredClient).client.(*pb.Client).BaseURL, _ = url.Parse(server.URL + "/")
+
+	f.Fuzz(func(t *testing.T, response string) {
+		mu.Lock()
+		body = response
+		mu.Unlock()
+
+		endpoints, err := p.Records(context.TODO())
+		if err != nil {
+			return
+		}
+		for _, ep := range endpoints {
+			assert.True(t, ep.DNSName == "example.com" || strings.HasSuffix(ep.DNSName, ".example.com"))
+		}
+	})
+}
+
+func testCorrelate(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(CorrelationHeader))
+		if r.URL.Path == "/ping" {
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"SUCCESS","records":[{"id":"1","name":"foo.example.com","type":"A","content":"5.5.5.5","ttl":"600"}]}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	domainFilter := []string{"example.com"}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client.(*meteredClient).client.(*pb.Client).BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := p.Correlate(func(pr provider.Provider) http.HandlerFunc {
+		s := webhook.WebhookServer{Provider: pr}
+		return s.RecordsHandler
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/records", nil)
+	req.Header.Set(CorrelationHeader, "sync-1")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "sync-1", rec.Header().Get(CorrelationHeader))
+	assert.Equal(t, []string{"sync-1", "sync-1"}, received)
+	assert.Contains(t, buf.String(), "correlationID=sync-1")
+
+	// invalid IDs are replaced by a generated one
+	req = httptest.NewRequest(http.MethodGet, "/records", nil)
+	req.Header.Set(CorrelationHeader, "not valid!")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	assert.Regexp(t, "^[0-9a-f]{16}$", rec.Header().Get(CorrelationHeader))
+}
+
+func testCNAMETargetCheck(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithCNAMETargetCheck(TargetCheckBlock))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "lb.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}},
+	})
+	p.client = client
+	_, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// targets existing in the zone or created along with the CNAME are fine
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "lb.example.com"),
+		endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "api-lb.example.com"),
+		endpoint.NewEndpoint("api-lb.example.com", endpoint.RecordTypeA, "6.6.6.6"),
+	}})
+	assert.NoError(t, err)
+
+	// typos and targets outside the managed zones are skipped, the other changes are still applied
+	skippedTargets := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonCNAMETarget))
+	typo := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("shop.example.com", endpoint.RecordTypeCNAME, "lb.exmaple.com"),
+		endpoint.NewEndpoint("docs.example.com", endpoint.RecordTypeCNAME, "ld.example.com"),
+	}}
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    append([]*endpoint.Endpoint{endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeA, "7.7.7.7")}, typo.Create...),
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "lb.example.com")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "lb.example.org")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, skippedTargets+3, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonCNAMETarget)))
+	assert.Len(t, client.zones["example.com"], 5)
+	for _, rec := range client.zones["example.com"] {
+		if rec.Name == "www.example.com" {
+			assert.Equal(t, "lb.example.com", rec.Content)
+		}
+	}
+
+	// warn mode applies them anyway
+	p.targetCheck = TargetCheckWarn
+	assert.NoError(t, p.ApplyChanges(context.TODO(), typo))
+	assert.Len(t, client.zones["example.com"], 7)
+}
+
+func testOrderByType(t *testing.T) {
+	a := endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "5.5.5.5")
+	txt := endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, "heritage=external-dns")
+	mx := endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mail.example.com")
+	aaaa := endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeAAAA, "::1")
+	endpoints := []*endpoint.Endpoint{a, mx, txt, aaaa}
+
+	assert.Equal(t, endpoints, orderByType(endpoints, nil))
+	assert.Equal(t, []*endpoint.Endpoint{txt, a, mx, aaaa}, orderByType(endpoints, []string{"txt", "A"}))
+
+	// deletes run in reverse order
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithTypeOrder("TXT", "A"))
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}})
+	p.client = client
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{a, txt}}))
+	assert.Equal(t, "TXT", client.zones["example.com"][0].Type)
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	client.calls = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: endpoints}))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1002", "delete example.com 1001"}, client.calls)
+}
+
+func testReadChanges(t *testing.T) {
+	changes, err := ReadChanges(strings.NewReader(`{"create":[{"dnsName":"foo.example.com","targets":["5.5.5.5"],"recordType":"A"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.example.com", changes.Create[0].DNSName)
+
+	_, err = ReadChanges(strings.NewReader(`{}`))
+	assert.Error(t, err)
+	_, err = ReadChanges(strings.NewReader(`not json`))
+	assert.Error(t, err)
+}
+
+func testGoneZones(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "foo.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}},
+	})
+	transferred := true
+	client.fail = func(op string, zone string, id int) error {
+		if zone == "example.org" && transferred {
+			return pb.Status{Status: "ERROR", Message: "Invalid domain."}
+		}
+		return nil
+	}
+	p.client = client
+	p.warmedUp.Store(true)
+
+	// the gone zone does not fail the sync and is reported without making the provider unready
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	readiness := p.Readiness()
+	assert.True(t, readiness.Ready)
+	assert.Contains(t, readiness.GoneZones, "example.org")
+
+	// it is neither fetched nor changed until the recheck is due
+	client.calls = nil
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "5.5.5.5")}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "ping  0", "retrieve example.com 0"}, client.calls)
+
+	// it is synced again once it reappeared
+	transferred = false
+	clock.now = clock.now.Add(goneZoneRecheckInterval)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, p.Readiness().GoneZones)
+
+	assert.False(t, isZoneGone(pb.Status{Status: "ERROR", Message: "Invalid record ID."}))
+	assert.False(t, isZoneGone(nil))
+}
+
+func testTTLOverride(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}},
+	})
+	p.client = client
+
+	// TTLs below the Porkbun minimum are raised, missing TTLs are left to the Porkbun default
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 60, "5.5.5.5"),
+		endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 3600, "5.5.5.5"),
+		endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.TTL(porkbunMinTTL), adjusted[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(3600), adjusted[1].RecordTTL)
+	assert.False(t, adjusted[2].RecordTTL.IsConfigured())
+
+	// a TTL-only change edits the record exactly once
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 3600, "5.5.5.5")})
+	assert.NoError(t, err)
+	changes := (&plan.Plan{
+		Current:        current,
+		Desired:        desired,
+		ManagedRecords: []string{endpoint.RecordTypeA},
+	}).Calculate().Changes
+	assert.Len(t, changes.UpdateNew, 1)
+
+	client.calls = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "edit example.com 1"}, client.calls)
+	assert.Equal(t, "3600", client.zones["example.com"][0].TTL)
+
+	// the TTL read back matches the desired one, so the next plan is empty
+	current, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	changes = (&plan.Plan{
+		Current:        current,
+		Desired:        desired,
+		ManagedRecords: []string{endpoint.RecordTypeA},
+	}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+
+	// a content change edits the record of the old content in place
+	client.calls = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 3600, "5.5.5.5")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 3600, "6.6.6.6")},
+	}))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "edit example.com 1"}, client.calls)
+	assert.Equal(t, "6.6.6.6", client.zones["example.com"][0].Content)
+}
+
+func testRecordConflicts(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "CNAME", Content: "lb.example.net", TTL: "600"},
+			{ID: "2", Name: "mail.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// an A record can't be created next to a CNAME, the error names the blocking record
+	client.calls = nil
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5")},
+	})
+	assert.ErrorIs(t, err, errRecordConflict)
+	assert.ErrorContains(t, err, "CNAME www.example.com -> lb.example.net (ID 1)")
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0"}, client.calls)
+
+	// a CNAME can't be created next to any other record
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeCNAME, "mx.example.net")},
+	})
+	assert.ErrorIs(t, err, errRecordConflict)
+
+	// records of the same type and records removed by the same changes do not conflict
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+			endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeA, "6.6.6.6"),
+		},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "lb.example.net")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1", "create example.com 0", "create example.com 0"}, client.calls)
+}
+
+// fakeResolver answers lookups from a map of "name type" to targets.
+type fakeResolver struct {
+	name    string
+	mu      sync.Mutex
+	answers map[string][]string
+}
+
+func (r *fakeResolver) Name() string {
+	return r.name
+}
+
+func (r *fakeResolver) Lookup(ctx context.Context, name string, recordType string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	answer, ok := r.answers[name+" "+recordType]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return append([]string(nil), answer...), nil
+}
+
+func testPropagationCheck(t *testing.T) {
+	resolvers, err := ParseResolvers("porkbun=2s")
+	assert.NoError(t, err)
+	assert.Len(t, resolvers, len(porkbunNameservers))
+	resolvers, err = ParseResolvers("1.1.1.1:53")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.1.1:53", resolvers[0].Name())
+	for _, spec := range []string{"1.1.1.1", "system=soon", "porkbun=-1s"} {
+		_, err = ParseResolvers(spec)
+		assert.Error(t, err, spec)
+	}
+
+	assert.Equal(t, "www.example.com", canonicalTarget(endpoint.RecordTypeCNAME, "WWW.Example.com."))
+	assert.Equal(t, "heritage=external-dns", canonicalTarget(endpoint.RecordTypeTXT, `"heritage=external-dns"`))
+	assert.Equal(t, "10 mail.example.com", canonicalTarget(endpoint.RecordTypeMX, "10 mail.example.com."))
+
+	updated := &fakeResolver{name: "updated", answers: map[string][]string{"www.example.com A": {"5.5.5.5", "6.6.6.6"}}}
+	stale := &fakeResolver{name: "stale", answers: map[string][]string{"www.example.com A": {"5.5.5.5"}}}
+	failing := &fakeResolver{name: "failing"}
+	ep := endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5", "6.6.6.6")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	// the consensus share of the resolvers must answer with all targets
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithPropagationCheck(0.5, time.Minute, updated, stale))
+	assert.True(t, p.consensus(context.TODO(), ep))
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithPropagationCheck(1, time.Minute, updated, stale, failing))
+	assert.False(t, p.consensus(context.TODO(), ep))
+
+	// records that do not propagate within the window are reported, once propagated they are counted
+	p.verifyRetryInterval = 10 * time.Millisecond
+	p.verifyWindow = 50 * time.Millisecond
+	timedOut := testutil.ToFloat64(verifyPropagationsTotal.WithLabelValues("timed_out"))
+	p.verifyPropagation(context.TODO(), []*endpoint.Endpoint{ep, endpoint.NewEndpoint("example.com", "SRV", "0 5 443 www.example.com")})
+	assert.Equal(t, timedOut+1, testutil.ToFloat64(verifyPropagationsTotal.WithLabelValues("timed_out")))
+
+	stale.mu.Lock()
+	stale.answers["www.example.com A"] = []string{"6.6.6.6", "5.5.5.5"}
+	stale.mu.Unlock()
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithPropagationCheck(1, time.Minute, updated, stale))
+	propagatedBefore := testutil.ToFloat64(verifyPropagationsTotal.WithLabelValues("propagated"))
+	p.verifyPropagation(context.TODO(), []*endpoint.Endpoint{ep})
+	assert.Equal(t, propagatedBefore+1, testutil.ToFloat64(verifyPropagationsTotal.WithLabelValues("propagated")))
+	assert.Positive(t, testutil.ToFloat64(verifyLookupsTotal.WithLabelValues("failing", "error")))
+}
+
+func testRecordIDLabels(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// listed endpoints carry the ID of their record
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "1", endpoints[0].Labels[RecordIDLabelKey])
+	assert.Equal(t, "2", endpoints[1].Labels[RecordIDLabelKey])
+
+	// the records are addressed by the labelled ID, even though their content changed in the meantime
+	client.zones["example.com"][0].Content = "7.7.7.7"
+	client.zones["example.com"][1].Content = "7.7.7.7"
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete:    []*endpoint.Endpoint{endpoints[0]},
+		UpdateOld: []*endpoint.Endpoint{endpoints[1]},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "6.6.6.6")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1", "edit example.com 2"}, client.calls)
+	assert.Len(t, client.zones["example.com"], 1)
+	assert.Equal(t, "6.6.6.6", client.zones["example.com"][0].Content)
+
+	// endpoints without the label are still resolved by their content
+	recs := client.zones["example.com"]
+	converted := convertToPorkbunRecord(&recs, []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "6.6.6.6")}, "example.com", true)
+	assert.Equal(t, "2", (*converted)[0].ID)
+}
+
+func testTokenExchange(t *testing.T) {
+	var exchanges int
+	var fail bool
+	exchange := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer workload-token" || fail {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		exchanges++
+		_, _ = fmt.Fprintf(w, `{"apiKey":"pk%d","secretApiKey":"sk%d","expiresAt":"2025-06-01T12:30:00Z"}`, exchanges, exchanges)
+	}))
+	defer exchange.Close()
+
+	var received map[string]string
+	porkbun := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1"}`))
+	}))
+	defer porkbun.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("workload-token\n"), 0o600))
+
+	logger := promslog.New(&promslog.Config{})
+	source, err := NewTokenExchange(TokenExchangeConfig{URL: exchange.URL, TokenFile: tokenFile, Refresh: 15 * time.Minute}, logger)
+	assert.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	source.(*tokenExchange).httpClient = exchange.Client()
+	source.(*tokenExchange).clock = clock
+
+	// the provider needs no API keys of its own, every call carries the exchanged keys
+	domainFilter := []string{"example.com"}
+	p, err := NewPorkbunProvider(&domainFilter, "", "", false, logger, WithCredentialsSource(source))
+	assert.NoError(t, err)
+	p.client.(*meteredClient).client.(*pb.Client).BaseURL, _ = url.Parse(porkbun.URL + "/")
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"apikey": "pk1", "secretapikey": "sk1"}, received)
+
+	// the keys are reused until the refresh interval has passed
+	clock.now = clock.now.Add(10 * time.Minute)
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "pk1", received["apikey"])
+	clock.now = clock.now.Add(6 * time.Minute)
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "pk2", received["apikey"])
+
+	// a failed refresh falls back to the previous keys until they expire
+	fail = true
+	clock.now = clock.now.Add(13 * time.Minute)
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "pk2", received["apikey"])
+	clock.now = clock.now.Add(2 * time.Minute)
+	_, err = p.client.Ping(context.TODO())
+	assert.ErrorContains(t, err, "unable to obtain Porkbun API keys")
+
+	_, err = NewPorkbunProvider(&domainFilter, "", "", false, logger)
+	assert.Error(t, err)
+}
+
+func testApplyConcurrency(t *testing.T) {
+	change := &PorkbunChange{
+		Delete: &[]pb.Record{{ID: "1", Name: "www", Type: "A", Content: "5.5.5.5"}},
+		Create: &[]pb.Record{
+			{Name: "www", Type: "A", Content: "6.6.6.6"},
+			{Name: "target", Type: "A", Content: "7.7.7.7"},
+			{Name: "alias", Type: "CNAME", Content: "target.example.com."},
+		},
+		UpdateNew: &[]pb.Record{{ID: "2", Name: "api", Type: "A", Content: "8.8.8.8"}},
+	}
+
+	// the operations on one name and a CNAME with its target form a batch
+	batches := batchByName("example.com", change)
+	assert.Len(t, batches, 3)
+	assert.Equal(t, &nameBatch{deletes: (*change.Delete)[:1], creates: (*change.Create)[:1]}, batches[0])
+	assert.Equal(t, &nameBatch{creates: (*change.Create)[1:]}, batches[1])
+	assert.Equal(t, &nameBatch{updates: *change.UpdateNew}, batches[2])
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithApplyConcurrency(3))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// unrelated names are applied in parallel, the delete of www is done before its create starts
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	deleted := false
+	client.fail = func(op string, zone string, id int) error {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		if op == "delete" {
+			deleted = true
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+	assert.NoError(t, p.applyRecords(context.TODO(), "example.com", change))
+	assert.True(t, deleted)
+	assert.Equal(t, 3, maxInFlight)
+	names := map[string]string{}
+	for _, rec := range client.zones["example.com"] {
+		names[rec.Name] = rec.Content
+	}
+	assert.Equal(t, map[string]string{
+		"www.example.com":    "6.6.6.6",
+		"api.example.com":    "8.8.8.8",
+		"target.example.com": "7.7.7.7",
+		"alias.example.com":  "target.example.com.",
+	}, names)
+
+	// errors of all names are reported
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" {
+			return errors.New("create failed")
+		}
+		return nil
+	}
+	err := p.applyRecords(context.TODO(), "example.com", &PorkbunChange{
+		Delete:    &[]pb.Record{},
+		Create:    &[]pb.Record{{Name: "a", Type: "A", Content: "1.1.1.1"}, {Name: "b", Type: "A", Content: "1.1.1.1"}},
+		UpdateNew: &[]pb.Record{},
+	})
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+}
+
+func testSkippedEndpoints(t *testing.T) {
+	skippedCount := func(reason string) float64 {
+		return testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(reason))
+	}
+	noZone, unsupported, filtered := skippedCount(skipReasonNoZone), skippedCount(skipReasonUnsupportedType), skippedCount(skipReasonFiltered)
+
+	domainFilter := []string{"example.com"}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"},
+			{ID: "2", Name: "www.example.com", Type: "", Content: "5.5.5.5", TTL: "600"},
+			{ID: "3", Name: "www.example.org", Type: "A", Content: "5.5.5.5", TTL: "600"},
+		},
+	})
+
+	// records that can't be listed are counted and summarized once per sync
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, unsupported+1, skippedCount(skipReasonUnsupportedType))
+	assert.Equal(t, filtered+1, skippedCount(skipReasonFiltered))
+	assert.Contains(t, logs.String(), `msg="skipped endpoints" operation=records filtered=1 unsupported_type=1`)
+
+	// changes outside the managed zones are counted once per endpoint, not for both sides of an update
+	logs.Reset()
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "5.5.5.5")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeA, "5.5.5.5")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeA, "6.6.6.6")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, noZone+2, skippedCount(skipReasonNoZone))
+	assert.Contains(t, logs.String(), `msg="skipped endpoints" operation=apply no_zone=2`)
+
+	// nothing is reported for syncs without skipped endpoints
+	logs.Reset()
+	var summary skipSummary
+	summary.skip(skipReasonNoZone, 1)
+	summary.report(context.TODO(), logger, "apply")
+	assert.Empty(t, logs.String())
+}
+
+func testBaseURLFailover(t *testing.T) {
+	var mu sync.Mutex
+	var primaryDown bool
+	var hits []string
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, _ := io.ReadAll(r.Body)
+			hits = append(hits, name+" "+r.URL.Path+" "+strconv.FormatBool(strings.Contains(string(body), `"apikey":"KEY"`)))
+			if name == "primary" && primaryDown {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1","pricing":{}}`))
+		}
+	}
+	primary := httptest.NewServer(handler("primary"))
+	defer primary.Close()
+	secondary := httptest.NewServer(handler("secondary"))
+	defer secondary.Close()
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, err := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock),
+		WithBaseURLs(primary.URL+"/api/json/v3", secondary.URL+"/proxy/"))
+	assert.NoError(t, err)
+
+	// requests go to the first base URL while it is healthy
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"primary /api/json/v3/ping true"}, hits)
+
+	// a server error fails over to the next base URL, with the same body
+	primaryDown = true
+	hits = nil
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	_, err = p.domains.Pricing(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"primary /api/json/v3/ping true", "secondary /proxy/ping true", "secondary /proxy/pricing/get true"}, hits)
+	assert.Equal(t, float64(0), testutil.ToFloat64(apiEndpointUp.WithLabelValues(primary.URL+"/api/json/v3/")))
+
+	// the failed base URL is tried first again once the retry interval has passed
+	primaryDown = false
+	hits = nil
+	clock.now = clock.now.Add(endpointRetryAfter)
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"primary /api/json/v3/ping true"}, hits)
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiEndpointUp.WithLabelValues(primary.URL+"/api/json/v3/")))
+
+	// if all base URLs fail, the error of the last one is returned
+	secondary.Close()
+	primaryDown = true
+	clock.now = clock.now.Add(endpointRetryAfter)
+	_, err = p.client.Ping(context.TODO())
+	assert.Error(t, err)
+}
+
+func testMultipleTargets(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "www.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+			{ID: "3", Name: "www.example.com", Type: "TXT", Content: "heritage=external-dns", TTL: "600"},
+			{ID: "4", Name: "www.example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// records of the same name and type are listed as one endpoint, except TXT records
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 3)
+	assert.Equal(t, endpoint.Targets{"1.1.1.1", "2.2.2.2"}, endpoints[0].Targets)
+	assert.Equal(t, "1,2", endpoints[0].Labels[RecordIDLabelKey])
+
+	// a kept target is left alone, a changed target replaces a removed one
+	client.calls = nil
+	desired := endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "2.2.2.2", "3.3.3.3")
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: endpoints[:1], UpdateNew: []*endpoint.Endpoint{desired}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "edit example.com 1"}, client.calls)
+
+	// added targets are created, removed ones deleted
+	endpoints, _ = p.Records(context.TODO())
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: endpoints[:1],
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "3.3.3.3")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 2"}, client.calls)
+	endpoints, _ = p.Records(context.TODO())
+	assert.Equal(t, endpoint.Targets{"3.3.3.3"}, endpoints[0].Targets)
+
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: endpoints[:1],
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "3.3.3.3", "4.4.4.4")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "create example.com 0"}, client.calls)
+
+	// creates and deletes write one record per target
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeAAAA, "2001:db8::1", "2001:db8::2")},
+	})
+	assert.NoError(t, err)
+	endpoints, _ = p.Records(context.TODO())
+	assert.Len(t, endpoints, 4)
+	assert.Equal(t, endpoint.Targets{"2001:db8::1", "2001:db8::2"}, endpoints[3].Targets)
+
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Delete: endpoints[:1]})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1001", "delete example.com 1"}, client.calls)
+}
+
+func testTTLFallback(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "3600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "1.1.1.1", TTL: "3600"},
+		},
+	})
+	p.client = client
+
+	// the endpoint TTL is written, without one the record keeps its TTL and new records get the Porkbun default
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 3600, "1.1.1.1"),
+			endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 3600, "1.1.1.1"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "2.2.2.2"),
+			endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 1200, "2.2.2.2"),
+		},
+	})
+	assert.NoError(t, err)
+	ttls := map[string]string{}
+	for _, rec := range client.zones["example.com"] {
+		ttls[rec.Name] = rec.TTL
+	}
+	assert.Equal(t, map[string]string{"www.example.com": "3600", "api.example.com": "1200", "new.example.com": pb.DefaultTTL}, ttls)
+}
+
+func testZoneLock(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, zoneLock{holder: "terraform", expires: now}, parseZoneLock(`"holder=terraform; expires=2025-06-01T12:00:00Z"`))
+	assert.Equal(t, zoneLock{holder: "maintenance"}, parseZoneLock("maintenance"))
+	assert.True(t, parseZoneLock("maintenance").active(now))
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(&fakeClock{now: now}), WithZoneLock("_dns-lock", time.Minute))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "_dns-lock.example.com", Type: "TXT", Content: "holder=terraform; expires=2025-06-01T12:05:00Z", TTL: "600"},
+		},
+	})
+	p.client = client
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+
+	// changes are skipped while another writer holds the lock
+	locked := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonZoneLocked))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0"}, client.calls)
+	assert.Equal(t, locked+1, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonZoneLocked)))
+
+	// an expired lock is taken over, the webhook holds the lock while applying and removes the stale lock afterwards
+	client.zones["example.com"][0].Content = "holder=terraform; expires=2025-06-01T11:59:00Z"
+	client.calls = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "create example.com 0", "retrieve example.com 0", "create example.com 0", "delete example.com 1001", "delete example.com 1"}, client.calls)
+	assert.Len(t, client.zones["example.com"], 1)
+	assert.Equal(t, "www.example.com", client.zones["example.com"][0].Name)
+
+	// a lock taken by another writer at the same time makes the webhook back off
+	client.calls = nil
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" {
+			client.mu.Lock()
+			client.zones["example.com"] = append(client.zones["example.com"], pb.Record{ID: "9", Name: "_dns-lock.example.com", Type: "TXT", Content: "holder=script"})
+			client.mu.Unlock()
+		}
+		return nil
+	}
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "create example.com 0", "retrieve example.com 0", "delete example.com 1003"}, client.calls)
+}
+
+func testStatus(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(&fakeClock{now: now}))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"}},
+	})
+	client.fail = func(op string, zone string, id int) error {
+		if zone == "example.org" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	p.client = client
+
+	// zones report their cached records and health, changes are listed newest first
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")},
+	})
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.Error(t, err)
+
+	status := p.Status()
+	assert.Equal(t, []ZoneStatus{
+		{Zone: "example.com", Records: 1, LastSync: now},
+		{Zone: "example.org", Degraded: "connection refused"},
+	}, status.Zones)
+	assert.Equal(t, []RecordChange{
+		{Time: now, Zone: "example.com", Action: "create", Name: "api.example.com", Type: "A", Content: "2.2.2.2"},
+		{Time: now, Zone: "example.com", Action: "delete", Name: "www.example.com", Type: "A", Content: "1.1.1.1"},
+	}, status.RecentChanges)
+
+	// only the latest changes are kept
+	var log changeLog
+	for i := 0; i < recentChangesLimit+5; i++ {
+		log.add(now, "", "example.com", "create", pb.Record{Name: strconv.Itoa(i), Type: "A"})
+	}
+	changes := log.list()
+	assert.Len(t, changes, recentChangesLimit)
+	assert.Equal(t, strconv.Itoa(recentChangesLimit+4)+".example.com", changes[0].Name)
+}
+
+func testPriority(t *testing.T) {
+	content, prio := splitPriority(endpoint.RecordTypeMX, "10 mail.example.com")
+	assert.Equal(t, "mail.example.com", content)
+	assert.Equal(t, "10", prio)
+	content, prio = splitPriority(endpoint.RecordTypeSRV, "0 5 443 www.example.com")
+	assert.Equal(t, "5 443 www.example.com", content)
+	assert.Equal(t, "0", prio)
+	content, prio = splitPriority(endpoint.RecordTypeMX, "mail.example.com")
+	assert.Equal(t, "mail.example.com", content)
+	assert.Empty(t, prio)
+	content, prio = splitPriority(endpoint.RecordTypeA, "1.1.1.1")
+	assert.Equal(t, "1.1.1.1", content)
+	assert.Empty(t, prio)
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "MX", Content: "mx1.example.com", Prio: "10", TTL: "600"},
+			{ID: "2", Name: "example.com", Type: "MX", Content: "mx2.example.com", Prio: "20", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// the priority is listed in front of the target
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"10 mx1.example.com", "20 mx2.example.com"}, endpoints[0].Targets)
+
+	// and written to the prio field, records without ID label are resolved including the priority
+	delete(endpoints[0].Labels, RecordIDLabelKey)
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("_sip._tcp.example.com", endpoint.RecordTypeSRV, "0 5 5060 sip.example.com")},
+		UpdateOld: endpoints,
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mx1.example.com", "30 mx2.example.com")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []pb.Record{
+		{ID: "1", Name: "example.com", Type: "MX", Content: "mx1.example.com", Prio: "10", TTL: "600"},
+		{ID: "2", Name: "example.com", Type: "MX", Content: "mx2.example.com", Prio: "30", TTL: "600"},
+		{ID: "1001", Name: "_sip._tcp.example.com", Type: "SRV", Content: "5 5060 sip.example.com", Prio: "0", TTL: pb.DefaultTTL},
+	}, stripNotes(client.zones["example.com"]))
+}
+
+// stripNotes returns the records without their notes.
+func stripNotes(recs []pb.Record) []pb.Record {
+	stripped := make([]pb.Record, len(recs))
+	for i, rec := range recs {
+		rec.Notes = ""
+		stripped[i] = rec
+	}
+	return stripped
+}
+
+func testApexAlias(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{})
+	p.client = client
+
+	// without the option a CNAME stays a CNAME
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeCNAME, "lb.example.net")})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.RecordTypeCNAME, adjusted[0].RecordType)
+
+	// with it only CNAMEs at the apex become ALIAS records
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithApexAlias(true))
+	p.client = client
+	desired := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("Example.com.", endpoint.RecordTypeCNAME, "lb.example.net"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "lb.example.net"),
+	}
+	adjusted, err = p.AdjustEndpoints(desired)
+	assert.NoError(t, err)
+	assert.Equal(t, recordTypeALIAS, adjusted[0].RecordType)
+	assert.Equal(t, endpoint.RecordTypeCNAME, adjusted[1].RecordType)
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: adjusted}))
+	assert.Equal(t, "ALIAS", client.zones["example.com"][0].Type)
+	assert.Equal(t, "example.com", client.zones["example.com"][0].Name)
+
+	// the ALIAS record is listed as it is desired, so nothing is planned for the next sync
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	changes := (&plan.Plan{
+		Current:        current,
+		Desired:        adjusted,
+		ManagedRecords: []string{endpoint.RecordTypeCNAME, recordTypeALIAS},
+	}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+
+	// the ALIAS record does not conflict with the NS, MX and TXT records at the apex
+	client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "NS", Content: "curitiba.ns.porkbun.com", TTL: "86400"},
+			{ID: "2", Name: "example.com", Type: "MX", Content: "mail.example.com", Prio: "10", TTL: "600"},
+			{ID: "3", Name: "example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+		},
+	})
+	p.client = client
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: adjusted[:1]}))
+	if assert.Len(t, client.zones["example.com"], 4) {
+		assert.Equal(t, "ALIAS", client.zones["example.com"][3].Type)
+	}
+
+	// a CNAME at the same name still conflicts with it
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeCNAME, "lb.example.org")},
+	})
+	assert.ErrorIs(t, err, errRecordConflict)
+}
+
+func testCutover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cutover.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"name":"web","records":[{"name":"WWW.example.com.","type":"a"},{"name":"api.example.com","type":"A"}],"blue":["192.0.2.1"],"green":["192.0.2.2","192.0.2.3"]}]`), 0o600))
+	groups, err := LoadCutoverGroups(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []CutoverRecord{{Name: "www.example.com", Type: "A"}, {Name: "api.example.com", Type: "A"}}, groups[0].Records)
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"name":"web","records":[{"name":"www.example.com","type":"A"}],"blue":["192.0.2.1"]}]`), 0o600))
+	_, err = LoadCutoverGroups(path)
+	assert.ErrorContains(t, err, "needs blue and green targets")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithCutoverGroups(groups...))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+		},
+	})
+	p.client = client
+	targetsOf := func() map[string][]string {
+		current, err := p.Records(context.TODO())
+		assert.NoError(t, err)
+		targets := map[string][]string{}
+		for _, ep := range current {
+			targets[ep.DNSName] = ep.Targets
+		}
+		return targets
+	}
+
+	assert.ErrorIs(t, p.Cutover(context.TODO(), "db", CutoverGreen), errUnknownCutoverGroup)
+	assert.ErrorContains(t, p.Cutover(context.TODO(), "web", "red"), "invalid cutover color")
+
+	// all records of the group are switched, keeping their TTL
+	assert.NoError(t, p.Cutover(context.TODO(), "web", CutoverGreen))
+	assert.Equal(t, map[string][]string{
+		"www.example.com": {"192.0.2.2", "192.0.2.3"},
+		"api.example.com": {"192.0.2.2", "192.0.2.3"},
+	}, targetsOf())
+	assert.Equal(t, "600", client.zones["example.com"][0].TTL)
+	assert.Equal(t, CutoverGreen, p.cutover.status()[0].Active)
+
+	// the desired targets of the sources are replaced, so the next sync keeps the cutover
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("other.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"192.0.2.2", "192.0.2.3"}, adjusted[0].Targets)
+	assert.Equal(t, endpoint.Targets{"192.0.2.1"}, adjusted[1].Targets)
+
+	// a failure switches the records back to the targets they had before, syncs meanwhile desire the new targets
+	edits := 0
+	var during endpoint.Targets
+	client.fail = func(op string, zone string, id int) error {
+		if op == "edit" {
+			edits++
+			if edits == 2 {
+				adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.2")})
+				assert.NoError(t, err)
+				during = adjusted[0].Targets
+				return errors.New("boom")
+			}
+		}
+		return nil
+	}
+	err = p.Cutover(context.TODO(), "web", CutoverBlue)
+	assert.ErrorContains(t, err, "rolled back")
+	assert.Equal(t, endpoint.Targets{"192.0.2.1"}, during)
+	assert.Equal(t, map[string][]string{
+		"www.example.com": {"192.0.2.2", "192.0.2.3"},
+		"api.example.com": {"192.0.2.2", "192.0.2.3"},
+	}, targetsOf())
+	assert.Equal(t, CutoverGreen, p.cutover.status()[0].Active)
+	client.fail = nil
+
+	// a restarted webhook or another replica derives the active color from the records, so its syncs keep the cutover
+	restarted, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithCutoverGroups(groups...))
+	restarted.client = client
+	assert.Empty(t, restarted.cutover.status()[0].Active)
+	_, err = restarted.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, CutoverGreen, restarted.cutover.status()[0].Active)
+	adjusted, err = restarted.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1")})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"192.0.2.2", "192.0.2.3"}, adjusted[0].Targets)
+
+	// the admin API lists the groups and triggers cutovers
+	rec := httptest.NewRecorder()
+	p.CutoverHandler(rec, httptest.NewRequest(http.MethodPost, "/cutover?group=web&to=blue", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"active":"blue"`)
+	assert.Equal(t, map[string][]string{
+		"www.example.com": {"192.0.2.1"},
+		"api.example.com": {"192.0.2.1"},
+	}, targetsOf())
+
+	rec = httptest.NewRecorder()
+	p.CutoverHandler(rec, httptest.NewRequest(http.MethodGet, "/cutover", nil))
+	var status []CutoverStatus
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, CutoverBlue, status[0].Active)
+
+	rec = httptest.NewRecorder()
+	p.CutoverHandler(rec, httptest.NewRequest(http.MethodPost, "/cutover?group=db&to=blue", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func testCAA(t *testing.T) {
+	flags, tag, value, ok := parseCAA(`128 Issue "ca.example.net; account=230123"`)
+	assert.True(t, ok)
+	assert.Equal(t, uint8(128), flags)
+	assert.Equal(t, "issue", tag)
+	assert.Equal(t, "ca.example.net; account=230123", value)
+	assert.Equal(t, `0 iodef "mailto:security@example.com"`, normalizeCAA("0 iodef mailto:security@example.com"))
+	assert.Equal(t, `0 issue "a\"b"`, normalizeCAA(`0 issue "a\"b"`))
+	for _, invalid := range []string{"issue letsencrypt.org", "256 issue x", "0 is-sue x", "0"} {
+		_, _, _, ok = parseCAA(invalid)
+		assert.False(t, ok, invalid)
+		assert.Equal(t, invalid, normalizeCAA(invalid))
+	}
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "CAA", Content: "0 issue letsencrypt.org", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// records are listed in zone file format, whether Porkbun keeps the value quoted or not
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{`0 issue "letsencrypt.org"`}, endpoints[0].Targets)
+
+	// desired targets are normalized the same way, so nothing is planned for an unchanged record
+	calculate := func(desired []*endpoint.Endpoint) *plan.Changes {
+		return (&plan.Plan{
+			Current:        endpoints,
+			Desired:        desired,
+			ManagedRecords: []string{recordTypeCAA},
+		}).Calculate().Changes
+	}
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.com", recordTypeCAA, 600, "0 ISSUE letsencrypt.org")})
+	assert.NoError(t, err)
+	assert.False(t, calculate(desired).HasChanges())
+
+	desired, err = p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.com", recordTypeCAA, 600, "0 ISSUE letsencrypt.org", `128 iodef "mailto:security@example.com"`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{`0 issue "letsencrypt.org"`, `128 iodef "mailto:security@example.com"`}, desired[0].Targets)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), calculate(desired)))
+
+	// flags, tag and value survive the round trip
+	assert.Equal(t, `128 iodef "mailto:security@example.com"`, client.zones["example.com"][1].Content)
+	endpoints, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, desired[0].Targets, endpoints[0].Targets)
+}
+
+func testMetricsMetadata(t *testing.T) {
+	// vectors are only gathered with a child, which is removed again to keep the other tests unaffected
+	apiCallsTotal.WithLabelValues("lint", "lint")
+	defer apiCallsTotal.DeleteLabelValues("lint", "lint")
+	zoneGone.WithLabelValues("lint")
+	defer zoneGone.DeleteLabelValues("lint")
+	verifyLookupsTotal.WithLabelValues("lint", "lint")
+	defer verifyLookupsTotal.DeleteLabelValues("lint", "lint")
+	verifyLookupSeconds.WithLabelValues("lint")
+	defer verifyLookupSeconds.DeleteLabelValues("lint")
+	verifyPropagationsTotal.WithLabelValues("lint")
+	defer verifyPropagationsTotal.DeleteLabelValues("lint")
+	skippedEndpointsTotal.WithLabelValues("lint")
+	defer skippedEndpointsTotal.DeleteLabelValues("lint")
+	apiEndpointUp.WithLabelValues("lint")
+	defer apiEndpointUp.DeleteLabelValues("lint")
+	cutoversTotal.WithLabelValues("lint", "lint")
+	defer cutoversTotal.DeleteLabelValues("lint", "lint")
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(apiCallsTotal, zoneGone, cacheRecords, cacheBytes, cacheEvictionsTotal, pendingCreates,
+		verifyLookupsTotal, verifyLookupSeconds, verifyPropagationsTotal, verifyPropagationSeconds,
+		skippedEndpointsTotal, apiEndpointUp, cutoversTotal)
+	families, err := UnitGatherer(registry).Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 13)
+
+	// all metrics pass the Prometheus linter and the names of metrics with a unit end with it
+	problems, err := promlint.NewWithMetricFamilies(families).Lint()
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	for _, family := range families {
+		if family.Unit != nil {
+			assert.True(t, strings.HasSuffix(family.GetName(), "_"+family.GetUnit()), family.GetName())
+		}
+	}
+
+	// OpenMetrics carries the units and the _created series
+	var out bytes.Buffer
+	format := expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	encoder := expfmt.NewEncoder(&out, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+	for _, family := range families {
+		assert.NoError(t, encoder.Encode(family))
+	}
+	assert.Contains(t, out.String(), "# UNIT external_dns_porkbun_cache_bytes bytes\n")
+	assert.Contains(t, out.String(), "# UNIT external_dns_porkbun_verify_propagation_seconds seconds\n")
+	assert.Contains(t, out.String(), "external_dns_porkbun_cutovers_created{group=\"lint\",result=\"lint\"}")
+	assert.Contains(t, out.String(), "external_dns_porkbun_verify_propagation_seconds_created ")
+}
+
+func testNameEdgeCases(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	zones := []string{"example.com", "sub.example.com", "xn--bcher-kva.example"}
+
+	// the zone and Porkbun record name of endpoint names, and the name they are listed with again
+	names := []struct {
+		dnsName    string
+		zone       string
+		recordName string
+		listed     string
+	}{
+		{dnsName: "example.com", zone: "example.com", recordName: "", listed: "example.com"},
+		{dnsName: "example.com.", zone: "example.com", recordName: "", listed: "example.com"},
+		{dnsName: "Example.COM", zone: "example.com", recordName: "", listed: "example.com"},
+		{dnsName: "www.example.com", zone: "example.com", recordName: "www", listed: "www.example.com"},
+		{dnsName: "WWW.Example.Com.", zone: "example.com", recordName: "www", listed: "www.example.com"},
+		{dnsName: "*.example.com", zone: "example.com", recordName: "*", listed: "*.example.com"},
+		{dnsName: "*.apps.example.com", zone: "example.com", recordName: "*.apps", listed: "*.apps.example.com"},
+		{dnsName: "a.b.c.example.com", zone: "example.com", recordName: "a.b.c", listed: "a.b.c.example.com"},
+		{dnsName: "example.example.com", zone: "example.com", recordName: "example", listed: "example.example.com"},
+		{dnsName: "sub.example.com", zone: "sub.example.com", recordName: "", listed: "sub.example.com"},
+		{dnsName: "a.sub.example.com", zone: "sub.example.com", recordName: "a", listed: "a.sub.example.com"},
+		{dnsName: "a.notsub.example.com", zone: "example.com", recordName: "a.notsub", listed: "a.notsub.example.com"},
+		{dnsName: label63 + ".example.com", zone: "example.com", recordName: label63, listed: label63 + ".example.com"},
+		{dnsName: "xn--bcher-kva.example", zone: "xn--bcher-kva.example", recordName: "", listed: "xn--bcher-kva.example"},
+		{dnsName: "xn--80ak6aa92e.xn--bcher-kva.example", zone: "xn--bcher-kva.example", recordName: "xn--80ak6aa92e", listed: "xn--80ak6aa92e.xn--bcher-kva.example"},
+		{dnsName: "Bücher.example", zone: "xn--bcher-kva.example", recordName: "", listed: "xn--bcher-kva.example"},
+		{dnsName: "пример.bücher.example.", zone: "xn--bcher-kva.example", recordName: "xn--e1afmkfd", listed: "xn--e1afmkfd.xn--bcher-kva.example"},
+		{dnsName: "notexample.com", zone: ""},
+		{dnsName: "example.com.evil.net", zone: ""},
+		{dnsName: "com", zone: ""},
+	}
+	for _, n := range names {
+		ep := endpoint.NewEndpoint(n.dnsName, endpoint.RecordTypeA, "192.0.2.1")
+		assert.Equal(t, n.zone, endpointZoneName(ep, zones), n.dnsName)
+		if n.zone == "" {
+			continue
+		}
+		converted := convertToPorkbunRecord(&[]pb.Record{}, []*endpoint.Endpoint{ep}, n.zone, false)
+		assert.Equal(t, n.recordName, (*converted)[0].Name, n.dnsName)
+	}
+
+	// the same names written through ApplyChanges are listed by Records() with their normalized name,
+	// names outside all zones are skipped
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&zones, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{})
+	p.client = client
+	var desired []*endpoint.Endpoint
+	var listed []string
+	for i, n := range names {
+		desired = append(desired, endpoint.NewEndpoint(n.dnsName, endpoint.RecordTypeTXT, fmt.Sprintf("case-%d", i)))
+		if n.zone != "" {
+			listed = append(listed, n.listed)
+		}
+	}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: desired}))
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var got []string
+	for _, ep := range endpoints {
+		got = append(got, ep.DNSName)
+	}
+	assert.ElementsMatch(t, listed, got)
+
+	// zones configured in Unicode are managed in their ASCII form
+	unicode := []string{"bücher.example"}
+	p, _ = NewPorkbunProvider(&unicode, "KEY", "PASSWORD", false, logger)
+	assert.Equal(t, []string{"xn--bcher-kva.example"}, p.domainFilter.Zones())
+
+	// names as Porkbun returns them: the @ convention and mixed case denote the apex, records outside the zone
+	// or lookalike zones are skipped
+	records := []struct {
+		name   string
+		listed string
+	}{
+		{name: "@", listed: "example.com"},
+		{name: "@.example.com", listed: "example.com"},
+		{name: "example.com", listed: "example.com"},
+		{name: "EXAMPLE.com", listed: "example.com"},
+		{name: "www.example.com", listed: "www.example.com"},
+		{name: "*.example.com", listed: "*.example.com"},
+		{name: label63 + ".example.com", listed: label63 + ".example.com"},
+		{name: "notexample.com", listed: ""},
+		{name: "www.example.org", listed: ""},
+	}
+	for _, r := range records {
+		converted := p.recordsToEndpoints(context.TODO(), "example.com", []pb.Record{{ID: "1", Name: r.name, Type: "A", Content: "192.0.2.1", TTL: "600"}}, nil)
+		if r.listed == "" {
+			assert.Empty(t, converted, r.name)
+			continue
+		}
+		if assert.Len(t, converted, 1, r.name) {
+			assert.Equal(t, r.listed, converted[0].DNSName, r.name)
+		}
+	}
+
+	// records are found by names and host targets that differ only by case or a trailing dot, so deleting an endpoint
+	// without the record ID label deletes the listed record instead of missing it
+	recs := []pb.Record{
+		{ID: "1", Name: "Www.Example.com", Type: "CNAME", Content: "Lb.Example.net", TTL: "600"},
+		{ID: "2", Name: "example.com", Type: "MX", Content: "mail.example.com.", Prio: "10", TTL: "600"},
+		{ID: "3", Name: "example.com", Type: "TXT", Content: "Mixed Case.", TTL: "600"},
+	}
+	assert.Equal(t, "1", getIDforRecord("www.example.com.", "lb.example.net.", endpoint.RecordTypeCNAME, &recs))
+	assert.Equal(t, "2", getIDforRecord("EXAMPLE.COM", "10 Mail.Example.com", endpoint.RecordTypeMX, &recs))
+	assert.Equal(t, "", getIDforRecord("example.com", "mixed case", endpoint.RecordTypeTXT, &recs))
+	client = newFakeClient(map[string][]pb.Record{"example.com": append([]pb.Record(nil), recs...)})
+	p.client = client
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("WWW.example.com.", endpoint.RecordTypeCNAME, "LB.example.net.")},
+	}))
+	assert.Contains(t, client.calls, "delete example.com 1")
+}
+
+func testChangeLog(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithChangeLog(&out))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "900"},
+			{ID: "2", Name: "example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// edits are written as delete of the previous and add of the new content
+	err := p.ApplyChanges(withCorrelationID(context.TODO(), "3f2a9c1d"), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mx.example.com")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.2")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "v=spf1 -all")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `; 2025-06-01T12:00:00Z external-dns-porkbun-webhook correlation=3f2a9c1d
+zone example.com.
+update delete example.com. IN TXT "v=spf1 -all"
+update add example.com. 300 IN MX 10 mx.example.com.
+update delete www.example.com. IN A 192.0.2.1
+update add www.example.com. 900 IN A 192.0.2.2
+send
+`, out.String())
+
+	// only the changes applied before a failure are written, syncs without changes write nothing
+	out.Reset()
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "www.example.com")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mx.example.com")},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, `; 2025-06-01T12:00:00Z external-dns-porkbun-webhook
+zone example.com.
+update delete example.com. IN MX 10 mx.example.com.
+send
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{}))
+	assert.Empty(t, out.String())
+}
+
+func testManageNS(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	recs := map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "NS", Content: "curitiba.ns.porkbun.com", TTL: "86400"},
+			{ID: "2", Name: "dev.example.com", Type: "NS", Content: "ns1.dev-dns.net", TTL: "600"},
+			{ID: "3", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+		},
+	}
+	desired := func() []*endpoint.Endpoint {
+		return []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", endpoint.RecordTypeNS, "ns1.example.net"),
+			endpoint.NewEndpoint("dev.example.com", endpoint.RecordTypeNS, "ns1.dev-dns.net"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		}
+	}
+	names := func(endpoints []*endpoint.Endpoint) []string {
+		var names []string
+		for _, ep := range endpoints {
+			names = append(names, ep.RecordType+" "+ep.DNSName)
+		}
+		return names
+	}
+
+	// by default NS records are neither listed nor desired
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client = newFakeClient(recs)
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A www.example.com"}, names(endpoints))
+	adjusted, err := p.AdjustEndpoints(desired())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A www.example.com"}, names(adjusted))
+
+	// with --manage-ns-records the delegations of subzones are managed, the apex NS records never
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithManageNS(true))
+	p.client = newFakeClient(recs)
+	endpoints, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NS dev.example.com", "A www.example.com"}, names(endpoints))
+	adjusted, err = p.AdjustEndpoints(desired())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NS dev.example.com", "A www.example.com"}, names(adjusted))
+}
+
+func testDeepHealth(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithDeepHealthCheck(time.Second, time.Minute))
+	client := newFakeClient(map[string][]pb.Record{})
+	p.client = client
+
+	// Porkbun is pinged once, the result is reused within the interval
+	health := p.DeepHealth(context.TODO())
+	assert.True(t, health.Healthy)
+	assert.Equal(t, clock.now, health.CheckedAt)
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.True(t, p.DeepHealth(context.TODO()).Healthy)
+	assert.Equal(t, []string{"ping  0"}, client.calls)
+
+	// a failed ping is served with 503 and also shows in the readiness
+	client.fail = func(op string, zone string, id int) error {
+		return errors.New("connection refused")
+	}
+	clock.now = clock.now.Add(time.Minute)
+	rec := httptest.NewRecorder()
+	p.DeepHealthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz/deep", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var served DeepHealth
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.False(t, served.Healthy)
+	assert.Equal(t, "connection refused", served.Detail)
+	assert.Equal(t, []string{"ping  0", "ping  0"}, client.calls)
+	assert.False(t, p.Readiness().Components["credentials"].Ready)
+
+	// without an interval every check pings Porkbun
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithDeepHealthCheck(time.Second, 0))
+	client = newFakeClient(map[string][]pb.Record{})
+	p.client = client
+	p.DeepHealth(context.TODO())
+	p.DeepHealth(context.TODO())
+	assert.Len(t, client.calls, 2)
+}
+
+func testOwnershipTransfer(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "www.example.com", Type: "AAAA", Content: "2001:db8::1", TTL: "600"},
+			{ID: "3", Name: "a-www.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-a,external-dns/resource=service/default/web", TTL: "600"},
+			{ID: "4", Name: "aaaa-www.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-a,external-dns/resource=service/default/web", TTL: "600"},
+			{ID: "5", Name: "api.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+			{ID: "6", Name: "a-api.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-c,external-dns/resource=service/default/api", TTL: "600"},
+		},
+	})
+	p.client = client
+	contentOf := func(id string) string {
+		for _, rec := range client.zones["example.com"] {
+			if rec.ID == id {
+				return rec.Content
+			}
+		}
+		return ""
+	}
+
+	// nothing is changed if any name is not owned by the previous owner
+	_, err := p.TransferOwnership(context.TODO(), "cluster-a", "cluster-b", []string{"www.example.com", "api.example.com"})
+	assert.ErrorIs(t, err, errNotOwned)
+	_, err = p.TransferOwnership(context.TODO(), "cluster-a", "cluster-a", []string{"www.example.com"})
+	assert.ErrorContains(t, err, "invalid ownership transfer")
+	assert.Equal(t, "heritage=external-dns,external-dns/owner=cluster-a,external-dns/resource=service/default/web", contentOf("3"))
+
+	// only the owner label of the registry TXT records is rewritten
+	transfers, err := p.TransferOwnership(context.TODO(), "cluster-a", "cluster-b", []string{"WWW.example.com."})
+	assert.NoError(t, err)
+	assert.Equal(t, []OwnershipTransfer{
+		{Name: "www.example.com", Type: "A", TXT: "a-www.example.com", From: "cluster-a", To: "cluster-b"},
+		{Name: "www.example.com", Type: "AAAA", TXT: "aaaa-www.example.com", From: "cluster-a", To: "cluster-b"},
+	}, transfers)
+	assert.Equal(t, "heritage=external-dns,external-dns/owner=cluster-b,external-dns/resource=service/default/web", contentOf("3"))
+	assert.Equal(t, "heritage=external-dns,external-dns/owner=cluster-b,external-dns/resource=service/default/web", contentOf("4"))
+	assert.Equal(t, "192.0.2.1", contentOf("1"))
+	assert.Equal(t, "heritage=external-dns,external-dns/owner=cluster-c,external-dns/resource=service/default/api", contentOf("6"))
+
+	// the handler answers 404 for names the previous owner does not own
+	rec := httptest.NewRecorder()
+	p.OwnershipHandler(rec, httptest.NewRequest(http.MethodPost, "/ownership?from=cluster-a&to=cluster-b&name=www.example.com", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = httptest.NewRecorder()
+	p.OwnershipHandler(rec, httptest.NewRequest(http.MethodPost, "/ownership?from=cluster-c", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// TXT records are named like the registry of external-dns names them
+	assert.Equal(t, "a-www.example.com", p.txtRecordName("www.example.com", "A"))
+	WithTXTRegistry("reg-", "", "wildcard")(p)
+	assert.Equal(t, "reg-cname-wildcard.example.com", p.txtRecordName("*.example.com", "CNAME"))
+	WithTXTRegistry("", "-%{record_type}.reg", "")(p)
+	assert.Equal(t, "www-aaaa.reg.example.com", p.txtRecordName("www.example.com", "AAAA"))
+}
+
+func testZoneCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"zone":"Example.com.","apiKey":"pk1_com","secretApiKey":"sk1_com"},{"zone":"example.com","apiKey":"pk1_dup","secretApiKey":"sk1_dup"}]`), 0o600))
+	_, err := LoadZoneCredentials(path)
+	assert.ErrorContains(t, err, "duplicate zone")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"zone":"Example.com.","apiKey":"pk1_com","secretApiKey":"sk1_com"},{"zone":"example.net","apiKey":"pk1_net","secretApiKey":"sk1_net"}]`), 0o600))
+	credentials, err := LoadZoneCredentials(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", credentials[0].Zone)
+
+	// every call is sent with the keys of its zone, zones without keys use the account keys
+	var mu sync.Mutex
+	keys := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			APIKey string `json:"apikey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		keys[r.URL.Path] = body.APIKey
+		mu.Unlock()
+		if strings.Contains(r.URL.Path, "example.net") {
+			_, _ = w.Write([]byte(`{"status":"ERROR","message":"Domain is not opted in to API access."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"SUCCESS","yourIp":"127.0.0.1","records":[]}`))
+	}))
+	defer server.Close()
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithBaseURLs(server.URL+"/"), WithZoneCredentials(credentials...))
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/ping": "KEY", "/dns/retrieve/example.com": "pk1_com", "/dns/retrieve/example.org": "KEY"}, keys)
+
+	// the keys of zones in the domain filter are checked for access to their zone
+	domainFilter = []string{"example.com", "example.net"}
+	p, err = NewPorkbunProvider(&domainFilter, "", "", false, logger, WithBaseURLs(server.URL+"/"), WithZoneCredentials(credentials...))
+	assert.NoError(t, err)
+	err = p.CheckZoneCredentials(context.TODO())
+	assert.ErrorContains(t, err, "API key of zone 'example.net' has no access to the zone")
+	assert.NotContains(t, err.Error(), "example.com")
+	_, err = p.client.Ping(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "pk1_com", keys["/ping"])
+
+	// without account keys, every zone needs its own keys
+	domainFilter = []string{"example.com", "example.org"}
+	_, err = NewPorkbunProvider(&domainFilter, "", "", false, logger, WithZoneCredentials(credentials...))
+	assert.ErrorContains(t, err, "requires an API Key")
+	clients := newZoneClients(nil, map[string]porkbunClient{"example.com": newFakeClient(map[string][]pb.Record{})})
+	_, err = clients.RetrieveRecords(context.TODO(), "example.org")
+	assert.ErrorContains(t, err, "no API keys configured for zone 'example.org'")
+}
+
+func testLongTXT(t *testing.T) {
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA", 8)
+	assert.Equal(t, "v=spf1 -all", formatTXT("v=spf1 -all"))
+	split := formatTXT(dkim)
+	assert.Equal(t, `"`+dkim[:255]+`" "`+dkim[255:]+`"`, split)
+	assert.Equal(t, dkim, parseTXT(split))
+
+	// quotes are escaped, multi-byte characters are not cut in half
+	tricky := strings.Repeat("é", 128) + `"quoted" \ ` + strings.Repeat("ü", 100)
+	assert.Equal(t, tricky, parseTXT(formatTXT(tricky)))
+	for _, s := range strings.SplitAfter(formatTXT(tricky), `" "`) {
+		assert.True(t, utf8.ValidString(s))
+	}
+
+	// content that is not a list of strings is kept as it is
+	for _, content := range []string{`v=spf1 "a" "b"`, `"a" "b`, `"a""b"`, ""} {
+		assert.Equal(t, content, parseTXT(content))
+	}
+	assert.Equal(t, "ab", parseTXT(` "a"  "b" `))
+
+	// long targets are written as several strings and listed as one target
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}})
+	p.client = client
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("mail._domainkey.example.com", endpoint.RecordTypeTXT, dkim)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, split, client.zones["example.com"][0].Content)
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{dkim}, current[0].Targets)
+
+	// and found again by their target for deletion
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("mail._domainkey.example.com", endpoint.RecordTypeTXT, dkim)},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, client.zones["example.com"])
+}
+
+func testRecordsCacheHeaders(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithMaxPageSize(1),
+		WithRecordsMaxAge(30*time.Second), WithAPICallWarningThreshold(3))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+		},
+		"example.org": {},
+	})
+	p.client = client
+	handler := p.RecordsCacheHeaders(p.PaginateRecords(func(w http.ResponseWriter, r *http.Request) {
+		s := webhook.WebhookServer{Provider: p}
+		s.RecordsHandler(w, r)
+	}))
+
+	// a fresh listing has no age and announces the configured lifetime
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("Age"))
+	assert.Equal(t, "max-age=30", rec.Header().Get("Cache-Control"))
+
+	// following pages are served from the cache and age with it
+	clock.now = clock.now.Add(45 * time.Second)
+	token := rec.Header().Get(ContinueHeader)
+	assert.NotEmpty(t, token)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records?limit=1&continue="+url.QueryEscape(token), nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "45", rec.Header().Get("Age"))
+	ages := map[string]time.Duration{"example.com": 45 * time.Second, "example.org": 45 * time.Second}
+	assert.Equal(t, ages, p.cache.ages(clock.now))
+
+	// the lifetime is stretched while the API usage is at the warning threshold
+	for range 3 {
+		p.usage.record(context.TODO(), "example.com", "retrieve")
+	}
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, "max-age=120", rec.Header().Get("Cache-Control"))
+	WithRecordsMaxAge(0)(p)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, "max-age=60", rec.Header().Get("Cache-Control"))
+	clock.now = clock.now.Add(2 * time.Hour)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	// failed listings are not cached
+	client.fail = func(op string, zone string, id int) error {
+		return errors.New("boom")
+	}
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/records", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Empty(t, rec.Header().Get("Age"))
+}
+
+func testTXTQuoting(t *testing.T) {
+	// every value round-trips through the Porkbun content, quoted targets are read as their value
+	for _, tc := range []struct {
+		target  string
+		value   string
+		content string
+	}{
+		{target: "v=spf1 include:_spf.example.com -all", value: "v=spf1 include:_spf.example.com -all", content: "v=spf1 include:_spf.example.com -all"},
+		{target: `"v=spf1 -all"`, value: "v=spf1 -all", content: "v=spf1 -all"},
+		{target: `"heritage=external-dns,external-dns/owner=default"`, value: "heritage=external-dns,external-dns/owner=default", content: "heritage=external-dns,external-dns/owner=default"},
+		{target: `say "hi"; bye`, value: `say "hi"; bye`, content: `say "hi"; bye`},
+		{target: `"say \"hi\"; bye"`, value: `say "hi"; bye`, content: `say "hi"; bye`},
+		{target: `"a" "b"`, value: "ab", content: "ab"},
+		{target: `"\"a\""`, value: `"a"`, content: `"\"a\""`},
+		{target: `"back\\slash"`, value: `back\slash`, content: `back\slash`},
+		{target: `" padded "`, value: " padded ", content: " padded "},
+		{target: `""`, value: "", content: ""},
+	} {
+		value := parseTXT(tc.target)
+		assert.Equal(t, tc.value, value, tc.target)
+		assert.Equal(t, tc.content, formatTXT(value), tc.target)
+		assert.Equal(t, value, recordTarget(pb.Record{Type: endpoint.RecordTypeTXT, Content: formatTXT(value)}), tc.target)
+	}
+
+	// quoted desired targets match the records written for them, so no update is planned again
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": {
+		{ID: "1", Name: "example.com", Type: "TXT", Content: `"v=spf1 -all"`, TTL: "600"},
+	}})
+	p.client = client
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, `"v=spf1 -all"`),
+		endpoint.NewEndpoint("note.example.com", endpoint.RecordTypeTXT, `"say \"hi\"; bye"`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"v=spf1 -all"}, desired[0].Targets)
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"v=spf1 -all"}, current[0].Targets)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: desired[1:]}))
+	assert.Equal(t, `say "hi"; bye`, client.zones["example.com"][1].Content)
+
+	// registry TXT records are found by their quoted target
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, `"v=spf1 -all"`)},
+	}))
+	assert.Len(t, client.zones["example.com"], 1)
+}
+
+func testGroupedRecords(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "4.4.4.4", TTL: "600"},
+			{ID: "3", Name: "www.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+			{ID: "4", Name: "www.example.com", Type: "AAAA", Content: "2001:db8::1", TTL: "600"},
+			{ID: "5", Name: "www.example.com", Type: "A", Content: "3.3.3.3", TTL: "600"},
+		},
+		"example.org": {
+			{ID: "6", Name: "www.example.org", Type: "A", Content: "1.1.1.1", TTL: "600"},
+		},
+	})
+
+	// three A records of one name are one endpoint, other types, names and zones are not grouped with them
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 4)
+	var www []*endpoint.Endpoint
+	for _, ep := range endpoints {
+		if ep.DNSName == "www.example.com" && ep.RecordType == endpoint.RecordTypeA {
+			www = append(www, ep)
+		}
+	}
+	if assert.Len(t, www, 1) {
+		assert.Equal(t, endpoint.Targets{"1.1.1.1", "2.2.2.2", "3.3.3.3"}, www[0].Targets)
+		assert.Equal(t, "1,3,5", www[0].Labels[RecordIDLabelKey])
+		// the planner sees nothing to change when the same targets are desired in another order
+		assert.True(t, www[0].Targets.Same(endpoint.Targets{"3.3.3.3", "1.1.1.1", "2.2.2.2"}))
+	}
+}
+
+func testOwnerPartitioning(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithChangeLog(&out))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "old.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "a-old.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=team-b,external-dns/resource=service/default/old", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// the owner is taken from the owner label
+	ep := endpoint.NewEndpoint("team-a.example.com", endpoint.RecordTypeA, "192.0.2.2")
+	ep.Labels[endpoint.OwnerLabelKey] = "team-a"
+	created := testutil.ToFloat64(recordChangesTotal.WithLabelValues("team-a", "example.com", "create"))
+	synced := testutil.ToFloat64(syncsTotal.WithLabelValues("team-a", "succeeded"))
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{ep}})
+	assert.NoError(t, err)
+	assert.Equal(t, created+1, testutil.ToFloat64(recordChangesTotal.WithLabelValues("team-a", "example.com", "create")))
+	assert.Equal(t, synced+1, testutil.ToFloat64(syncsTotal.WithLabelValues("team-a", "succeeded")))
+	assert.Contains(t, out.String(), "external-dns-porkbun-webhook owner=team-a\n")
+	assert.Equal(t, "team-a", p.Status().RecentChanges[0].Owner)
+
+	// or from the heritage of registry TXT records
+	deleted := testutil.ToFloat64(recordChangesTotal.WithLabelValues("team-b", "example.com", "delete"))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("a-old.example.com", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=team-b,external-dns/resource=service/default/old"`),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, deleted+2, testutil.ToFloat64(recordChangesTotal.WithLabelValues("team-b", "example.com", "delete")))
+	assert.Equal(t, "team-b", p.Status().RecentChanges[0].Owner)
+
+	// failed syncs are counted by owner too
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	failed := testutil.ToFloat64(syncsTotal.WithLabelValues("team-a", "failed"))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{ep}})
+	assert.Error(t, err)
+	assert.Equal(t, failed+1, testutil.ToFloat64(syncsTotal.WithLabelValues("team-a", "failed")))
+
+	// changes without owner are not attributed
+	assert.Equal(t, "", changesOwner(&plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeTXT, "v=spf1 -all"),
+	}}))
+}
+
+func testMinTTL(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMinTTL(3600))
+
+	// TTLs below the configured minimum are raised to it, missing TTLs are left to the Porkbun default
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 300, "5.5.5.5"),
+		endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 7200, "5.5.5.5"),
+		endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.TTL(3600), adjusted[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(7200), adjusted[1].RecordTTL)
+	assert.False(t, adjusted[2].RecordTTL.IsConfigured())
+
+	// a minimum below the Porkbun minimum keeps the Porkbun minimum
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMinTTL(60))
+	adjusted, _ = p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 300, "5.5.5.5")})
+	assert.Equal(t, endpoint.TTL(porkbunMinTTL), adjusted[0].RecordTTL)
+}
+
+func testNumericFields(t *testing.T) {
+	for value, expected := range map[string]int64{"600": 600, " 600 ": 600, "600.0": 600, "6e2": 600, "0": 0} {
+		n, ok := parseNumber(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, n, value)
+	}
+	for _, value := range []string{"", "-1", "600.5", "6e-1", "ten", "NaN", "1e300"} {
+		_, ok := parseNumber(value)
+		assert.False(t, ok, value)
+	}
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "6e2"},
+			{ID: "2", Name: "example.com", Type: "MX", Content: "mail.example.com", TTL: " 3600 ", Prio: "10.0"},
+			{ID: "3", Name: "backup.example.com", Type: "MX", Content: "mail.example.com", TTL: "", Prio: "high"},
+		},
+	})
+	p.client = client
+
+	// odd numbers are read as plain integers, invalid ones are left out instead of failing the sync
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 3)
+	assert.Equal(t, endpoint.TTL(600), endpoints[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(3600), endpoints[1].RecordTTL)
+	assert.Equal(t, endpoint.Targets{"10 mail.example.com"}, endpoints[1].Targets)
+	assert.False(t, endpoints[2].RecordTTL.IsConfigured())
+	assert.Equal(t, endpoint.Targets{"mail.example.com"}, endpoints[2].Targets)
+
+	// records with an invalid ID are resolved again by their content
+	client.calls = nil
+	ep := endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")
+	ep.Labels[RecordIDLabelKey] = "1.5"
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{ep}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "retrieve example.com 0", "delete example.com 1"}, client.calls)
+}
+
+func testDefaultTTL(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithDefaultTTL(3600))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}},
+	})
+	p.client = client
+
+	// endpoints without TTL get the default TTL, configured TTLs are kept
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+		endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 900, "5.5.5.5"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.TTL(3600), adjusted[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(900), adjusted[1].RecordTTL)
+
+	// the record converges on the default TTL with one update, after which there is nothing to change
+	current, _ := p.Records(context.TODO())
+	changes := (&plan.Plan{Current: current, Desired: adjusted[:1], ManagedRecords: []string{endpoint.RecordTypeA}}).Calculate().Changes
+	assert.Len(t, changes.UpdateNew, 1)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, "3600", client.zones["example.com"][0].TTL)
+	current, _ = p.Records(context.TODO())
+	changes = (&plan.Plan{Current: current, Desired: adjusted[:1], ManagedRecords: []string{endpoint.RecordTypeA}}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+}
+
+// memorySnapshotStore keeps a snapshot in memory.
+type memorySnapshotStore struct {
+	mu       sync.Mutex
+	snapshot []byte
+	saves    int
+}
+
+func (s *memorySnapshotStore) Load(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot, nil
+}
+
+func (s *memorySnapshotStore) Save(ctx context.Context, snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = snapshot
+	s.saves++
+	return nil
+}
+
+func (s *memorySnapshotStore) saved() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func testCacheSnapshots(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	store := &memorySnapshotStore{}
+	zones := map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"}},
+		"example.org": {{ID: "2", Name: "www.example.org", Type: "A", Content: "192.0.2.2", TTL: "600"}},
+	}
+
+	// the leader writes the cache after a complete listing
+	leader, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithCacheSnapshots(store, 10*time.Minute, time.Minute))
+	leader.client = newFakeClient(zones)
+	_, err := leader.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return store.saved() == 1 }, time.Second, 10*time.Millisecond)
+
+	// writes are spaced by the interval
+	leader.saveSnapshot(context.TODO())
+	assert.Equal(t, 1, store.saved())
+
+	// a new replica restores every zone and only pings Porkbun during the warm-up
+	clock.now = clock.now.Add(5 * time.Minute)
+	replica, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithCacheSnapshots(store, 10*time.Minute, time.Minute))
+	client := newFakeClient(zones)
+	replica.client = client
+	replica.Warmup(context.TODO(), time.Minute)
+	assert.True(t, replica.Readiness().Ready)
+	assert.Equal(t, []string{"ping  0"}, client.calls)
+
+	// the first listing is served from the snapshot, keeping when the zones were fetched, the next one from Porkbun
+	endpoints, err := replica.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, []string{"ping  0", "ping  0"}, client.calls)
+	assert.Equal(t, map[string]time.Duration{"example.com": 5 * time.Minute, "example.org": 5 * time.Minute}, replica.cache.ages(clock.now))
+	_, err = replica.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "ping  0", "ping  0", "retrieve example.com 0", "retrieve example.org 0"}, client.calls)
+	assert.Eventually(t, func() bool { return store.saved() == 2 }, time.Second, 10*time.Millisecond)
+
+	// zones older than the maximum age are fetched again
+	clock.now = clock.now.Add(11 * time.Minute)
+	replica, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithCacheSnapshots(store, 10*time.Minute, time.Minute))
+	client = newFakeClient(zones)
+	replica.client = client
+	assert.False(t, replica.restoreSnapshot(context.TODO()))
+	_, err = replica.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Contains(t, client.calls, "retrieve example.com 0")
+
+	// the snapshot is kept in the binary data of a ConfigMap, created on the first write
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600))
+	var mu sync.Mutex
+	var stored []byte
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		switch {
+		case stored == nil && r.Method != http.MethodPost:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(configMap{BinaryData: map[string][]byte{snapshotConfigMapKey: stored}})
+		default:
+			var cm configMap
+			_ = json.NewDecoder(r.Body).Decode(&cm)
+			stored = cm.BinaryData[snapshotConfigMapKey]
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+	cmStore := newConfigMapStore(server.URL, "dns", "porkbun-cache", tokenFile, server.Client())
+	data, err := cmStore.Load(context.TODO())
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.NoError(t, cmStore.Save(context.TODO(), []byte("snapshot")))
+	assert.NoError(t, cmStore.Save(context.TODO(), []byte("snapshot 2")))
+	data, err = cmStore.Load(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "snapshot 2", string(data))
+	assert.Equal(t, []string{
+		"GET /api/v1/namespaces/dns/configmaps/porkbun-cache Bearer sa-token",
+		"PUT /api/v1/namespaces/dns/configmaps/porkbun-cache Bearer sa-token",
+		"POST /api/v1/namespaces/dns/configmaps Bearer sa-token",
+		"PUT /api/v1/namespaces/dns/configmaps/porkbun-cache Bearer sa-token",
+		"GET /api/v1/namespaces/dns/configmaps/porkbun-cache Bearer sa-token",
+	}, requests)
+
+	// or in an S3 object, signed with the AWS keys
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	var object []byte
+	var authorization string
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/dns-cache/porkbun/snapshot%2B1.json.gz", r.URL.EscapedPath())
+		authorization = r.Header.Get("Authorization")
+		if r.Method == http.MethodPut {
+			object, _ = io.ReadAll(r.Body)
+			return
+		}
+		if object == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(object)
+	}))
+	defer s3.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", s3.URL)
+	objectStore, err := NewSnapshotStore("s3://dns-cache/porkbun/snapshot+1.json.gz")
+	assert.NoError(t, err)
+	data, err = objectStore.Load(context.TODO())
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.NoError(t, objectStore.Save(context.TODO(), []byte("snapshot")))
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/\d{8}/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`, authorization)
+	data, err = objectStore.Load(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+
+	_, err = NewSnapshotStore("configmap://dns/")
+	assert.ErrorContains(t, err, "configmap://[namespace/]name")
+}
+
+func testManagedRecordTypes(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithManagedTypes("a", "CNAME", "TXT"))
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "www.example.com", Type: "AAAA", Content: "2001:db8::1", TTL: "600"},
+			{ID: "3", Name: "example.com", Type: "MX", Content: "mail.example.com", Prio: "10", TTL: "600"},
+			{ID: "4", Name: "a-www.example.com", Type: "TXT", Content: "heritage=external-dns", TTL: "600"},
+		},
+	})
+
+	// records of other types are not listed
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var listed []string
+	for _, ep := range endpoints {
+		listed = append(listed, ep.RecordType+" "+ep.DNSName)
+	}
+	assert.Equal(t, []string{"A www.example.com", "TXT a-www.example.com"}, listed)
+
+	// and endpoints of other types are dropped
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeAAAA, "2001:db8::1"),
+		endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "www.example.com"),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, adjusted, 2)
+	assert.Equal(t, endpoint.RecordTypeCNAME, adjusted[1].RecordType)
+
+	// without managed types all types are managed
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	assert.True(t, p.managesType("SRV"))
+}
+
+func testChangeSetMetrics(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org", "example.net"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	p.client = newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "old.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"}},
+		"example.org": {{ID: "2", Name: "www.example.org", Type: "A", Content: "192.0.2.2", TTL: "600"}},
+	})
+	histogram := func(observer prometheus.Observer) *dto.Histogram {
+		var m dto.Metric
+		assert.NoError(t, observer.(prometheus.Metric).Write(&m))
+		return m.GetHistogram()
+	}
+	creates := histogram(changeSetSize.WithLabelValues("create"))
+	updates := histogram(changeSetSize.WithLabelValues("update"))
+	deletes := histogram(changeSetSize.WithLabelValues("delete"))
+	zones := histogram(zonesPerSync)
+
+	// every sync with changes observes its number of creates, updates and deletes and the zones it touches
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "192.0.2.3"),
+			endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeA, "192.0.2.4"),
+		},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, creates.GetSampleCount()+1, histogram(changeSetSize.WithLabelValues("create")).GetSampleCount())
+	assert.Equal(t, creates.GetSampleSum()+2, histogram(changeSetSize.WithLabelValues("create")).GetSampleSum())
+	assert.Equal(t, updates.GetSampleSum()+1, histogram(changeSetSize.WithLabelValues("update")).GetSampleSum())
+	assert.Equal(t, deletes.GetSampleSum()+1, histogram(changeSetSize.WithLabelValues("delete")).GetSampleSum())
+	assert.Equal(t, zones.GetSampleCount()+1, histogram(zonesPerSync).GetSampleCount())
+	assert.Equal(t, zones.GetSampleSum()+2, histogram(zonesPerSync).GetSampleSum())
+
+	// syncs without changes are not observed
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{}))
+	assert.Equal(t, zones.GetSampleCount()+1, histogram(zonesPerSync).GetSampleCount())
+}
+
+func testExcludedRecordTypes(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithExcludedTypes("mx", "NS"))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "example.com", Type: "MX", Content: "mail.example.com", Prio: "10", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// excluded records are not listed
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, endpoint.RecordTypeA, endpoints[0].RecordType)
+
+	// nor written, even if a client sends changes for them
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "20 mx.example.net")})
+	assert.NoError(t, err)
+	assert.Empty(t, adjusted)
+	skipped := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonUnmanagedType))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.2")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mail.example.com")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, skipped+1, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonUnmanagedType)))
+	assert.Len(t, client.zones["example.com"], 3)
+	assert.NotContains(t, client.calls, "delete example.com 2")
+
+	// updates are dropped together with their old endpoint, so the remaining updates keep their pairs
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mail.example.com"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "20 mx.example.net"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.3"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, skipped+2, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonUnmanagedType)))
+	assert.Equal(t, "192.0.2.3", client.zones["example.com"][0].Content)
+	assert.Equal(t, "mail.example.com", client.zones["example.com"][1].Content)
+}
+
+func testRetryPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"read":{"attempts":3,"retryOn":["timeout"]}}`), 0o600))
+	_, err := LoadRetryPolicies(path)
+	assert.ErrorContains(t, err, `read: unknown retryOn class "timeout"`)
+	assert.NoError(t, os.WriteFile(path, []byte(`{"read":{"attempts":4,"baseDelay":"100ms","maxDelay":"250ms","retryOn":["network","rate_limit","server_error"]},"delete":{"attempts":1}}`), 0o600))
+	policies, err := LoadRetryPolicies(path)
+	assert.NoError(t, err)
+	assert.Equal(t, RetryPolicy{Attempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond, RetryOn: []string{"network", "rate_limit", "server_error"}}, policies.Read)
+	assert.True(t, policies.retries())
+
+	fake := newFakeClient(map[string][]pb.Record{"example.com": {}})
+	failures := 0
+	fake.fail = func(op string, zone string, id int) error {
+		switch {
+		case op == "retrieve" && failures < 3:
+			failures++
+			return &pb.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "unavailable"}
+		case op == "delete":
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	}
+	client := newRetryingClient(fake, policies, promslog.New(&promslog.Config{}))
+	var delays []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	// reads are retried with backoff up to the maximum delay
+	_, err = client.RetrieveRecords(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond}, delays)
+
+	// deletes are never retried
+	err = client.DeleteRecord(context.TODO(), "example.com", 1)
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Equal(t, []string{"retrieve example.com 0", "retrieve example.com 0", "retrieve example.com 0", "retrieve example.com 0", "delete example.com 1"}, fake.calls)
+
+	// errors reported by Porkbun are not retried
+	fake.calls = nil
+	fake.fail = func(op string, zone string, id int) error {
+		return pb.Status{Status: "ERROR", Message: "Invalid domain."}
+	}
+	_, err = client.RetrieveRecords(context.TODO(), "example.com")
+	assert.Error(t, err)
+	assert.Len(t, fake.calls, 1)
+
+	// every attempt of a retried call counts as an API call
+	domainFilter := []string{"example.com"}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithRetryPolicies(policies), WithRequestTimeout(0))
+	retrying := p.client.(*retryingClient)
+	retrying.sleep = client.sleep
+	fake = newFakeClient(map[string][]pb.Record{"example.com": {}})
+	failures = 0
+	fake.fail = func(op string, zone string, id int) error {
+		if failures < 2 {
+			failures++
+			return &pb.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "unavailable"}
+		}
+		return nil
+	}
+	retrying.client.(*meteredClient).client = fake
+	_, err = p.client.RetrieveRecords(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, p.usage.lastHour("example.com"))
+}
+
+func testAdjustEndpoints(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+
+	// names are normalized like the listed names, endpoints of types Porkbun does not support are dropped
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("WWW.Example.com.", endpoint.RecordTypeA, 60, "5.5.5.5"),
+		endpoint.NewEndpoint("5.5.5.5.in-addr.example.com", "PTR", "www.example.com"),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, adjusted, 1)
+	assert.Equal(t, "www.example.com", adjusted[0].DNSName)
+	assert.Equal(t, endpoint.TTL(porkbunMinTTL), adjusted[0].RecordTTL)
+
+	// internationalized names and host names in targets are converted to the ASCII form Porkbun lists them in
+	adjusted, err = p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("Bücher.example.com", endpoint.RecordTypeCNAME, "shop.Bücher.example"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mail.bücher.example"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "bücher"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "xn--bcher-kva.example.com", adjusted[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"shop.xn--bcher-kva.example"}, adjusted[0].Targets)
+	assert.Equal(t, endpoint.Targets{"10 mail.xn--bcher-kva.example"}, adjusted[1].Targets)
+	assert.Equal(t, endpoint.Targets{"bücher"}, adjusted[2].Targets)
+	assert.Equal(t, "10 mail.xn--bcher-kva.example", recordTarget(pb.Record{Type: "MX", Content: "mail.bücher.example", Prio: "10"}))
+	converted := convertToPorkbunRecord(&[]pb.Record{}, []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "bücher.example")}, "example.com", false)
+	assert.Equal(t, "xn--bcher-kva.example", (*converted)[0].Content)
+}
+
+func testRecordTemplates(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "MX", Content: "mail.example.net", Prio: "10", TTL: "3600"},
+			{ID: "2", Name: "example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+			{ID: "3", Name: "example.com", Type: "TXT", Content: "google-site-verification=abc", TTL: "600"},
+			{ID: "4", Name: "fm1._domainkey.example.com", Type: "CNAME", Content: "fm1.example.com.dkimfm.com", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	_, err := p.TemplateChanges(context.TODO(), "github-pages", "example.com", nil)
+	assert.ErrorContains(t, err, "missing template variables: user")
+	_, err = p.TemplateChanges(context.TODO(), "fastmail", "example.org", nil)
+	assert.ErrorContains(t, err, "not in the domain filter")
+
+	// existing records are updated, the SPF record replaces the SPF record only, records in place are left out
+	changes, err := p.TemplateChanges(context.TODO(), "fastmail", "example.com.", nil)
+	assert.NoError(t, err)
+	var created []string
+	for _, ep := range changes.Create {
+		created = append(created, ep.DNSName)
+	}
+	assert.Equal(t, []string{"fm2._domainkey.example.com", "fm3._domainkey.example.com"}, created)
+	assert.Len(t, changes.UpdateNew, 2)
+	assert.Equal(t, endpoint.TTL(3600), changes.UpdateNew[0].RecordTTL)
+	assert.Equal(t, endpoint.Targets{"v=spf1 include:spf.messagingengine.com ?all"}, changes.UpdateNew[1].Targets)
+	assert.Equal(t, "2", changes.UpdateNew[1].Labels[RecordIDLabelKey])
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	changes, err = p.TemplateChanges(context.TODO(), "fastmail", "example.com", nil)
+	assert.NoError(t, err)
+	assert.False(t, changes.HasChanges())
+	assert.Contains(t, client.zones["example.com"], pb.Record{ID: "3", Name: "example.com", Type: "TXT", Content: "google-site-verification=abc", TTL: "600"})
+}
+
+func testMaintenance(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "www.example.com", Type: "A", Content: "5.5.5.5", TTL: "600"}},
+	})
+	p.client = client
+	_, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// a maintenance answer pauses the changes and records are listed from the cache
+	inMaintenance := true
+	client.fail = func(op string, zone string, id int) error {
+		if inMaintenance {
+			return &pb.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "Porkbun is down for scheduled maintenance"}
+		}
+		return nil
+	}
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiMaintenance))
+
+	// the API is not called again until the recheck interval passed
+	client.calls = nil
+	skipped := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonMaintenance))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.2")}})
+	assert.NoError(t, err)
+	assert.Empty(t, client.calls)
+	assert.Equal(t, skipped+1, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonMaintenance)))
+
+	// once the API answers again, the changes resume
+	inMaintenance = false
+	clock.now = clock.now.Add(maintenanceRecheckInterval)
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.2")}})
+	assert.NoError(t, err)
+	assert.Contains(t, client.calls, "create example.com 0")
+	assert.Equal(t, 0.0, testutil.ToFloat64(apiMaintenance))
+	assert.False(t, isMaintenance(pb.Status{Status: "ERROR", Message: "Invalid domain."}))
+}
+
+func testUnderscoreLabels(t *testing.T) {
+	domainFilter := []string{"example.com", "bücher.example"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}, "xn--bcher-kva.example": {}})
+	p.client = client
+
+	// underscore labels are written relative to their zone
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("_acme-challenge.example.com.", endpoint.RecordTypeTXT, "token-1"),
+		endpoint.NewEndpoint("_acme-challenge.www.example.com", endpoint.RecordTypeTXT, "token-2"),
+		endpoint.NewEndpoint("_dmarc.bücher.example", endpoint.RecordTypeTXT, "v=DMARC1; p=none"),
+		endpoint.NewEndpoint("_matrix._tcp.example.com", endpoint.RecordTypeSRV, "10 5 8448 matrix.example.com"),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: desired}))
+	var names []string
+	for _, zone := range []string{"example.com", "xn--bcher-kva.example"} {
+		for _, rec := range client.zones[zone] {
+			names = append(names, rec.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"_acme-challenge.example.com", "_acme-challenge.www.example.com", "_dmarc.xn--bcher-kva.example", "_matrix._tcp.example.com"}, names)
+
+	// they are listed with their full name, also when Porkbun returns them in mixed case, an @ label that is not
+	// the whole name does not denote the apex
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var listed []string
+	for _, ep := range endpoints {
+		listed = append(listed, ep.DNSName+" "+ep.Targets[0])
+	}
+	assert.ElementsMatch(t, []string{
+		"_acme-challenge.example.com token-1",
+		"_acme-challenge.www.example.com token-2",
+		"_dmarc.xn--bcher-kva.example v=DMARC1; p=none",
+		"_matrix._tcp.example.com 10 5 8448 matrix.example.com",
+	}, listed)
+	assert.Equal(t, "_dmarc.example.com", listedName("_DMARC.Example.com.", "example.com"))
+	assert.Equal(t, "example.com", listedName("@", "example.com"))
+	assert.Equal(t, "@.www.example.com", listedName("@.www.example.com", "example.com"))
+
+	// and their records are found without the record ID label, e.g. to remove an ACME challenge
+	client.calls = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("_ACME-challenge.example.com", endpoint.RecordTypeTXT, "token-1"),
+		endpoint.NewEndpoint("_matrix._tcp.example.com.", endpoint.RecordTypeSRV, "10 5 8448 Matrix.example.com."),
+	}}))
+	var deletes int
+	for _, call := range client.calls {
+		if strings.HasPrefix(call, "delete ") {
+			deletes++
+		}
+	}
+	assert.Equal(t, 2, deletes)
+	assert.Len(t, client.zones["example.com"], 1)
+}
+
+func testDryRun(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	var changeLog bytes.Buffer
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", true, logger, WithChangeLog(&changeLog))
+	_, ok := p.client.(*meteredClient).client.(*recordingClient)
+	assert.True(t, ok)
+
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1", "192.0.2.2"),
+			endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "v=spf1 -all"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(changeLog.String(), "update add "))
+	assert.Contains(t, changeLog.String(), "IN A 192.0.2.2")
+
+	// the recorded records are listed back and resolved by ID for updates
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	var www *endpoint.Endpoint
+	for _, ep := range endpoints {
+		if ep.DNSName == "www.example.com" {
+			www = ep
+		}
+	}
+	if assert.NotNil(t, www) {
+		updated := www.DeepCopy()
+		updated.Targets = endpoint.Targets{"192.0.2.1", "192.0.2.3"}
+		changeLog.Reset()
+		err = p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: []*endpoint.Endpoint{www}, UpdateNew: []*endpoint.Endpoint{updated}})
+		assert.NoError(t, err)
+		assert.Contains(t, changeLog.String(), "update delete www.example.com. IN A 192.0.2.2")
+		assert.Contains(t, changeLog.String(), "IN A 192.0.2.3")
+	}
+}
+
+func testSyncRecord(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithClock(clock), WithSyncRecord("_extdns-sync"))
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}})
+	p.client = client
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+
+	// the first write to the zone creates the sync record
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, float64(now.Unix()), testutil.ToFloat64(zoneLastWrite.WithLabelValues("example.com")))
+	if assert.Len(t, client.zones["example.com"], 2) {
+		sync := client.zones["example.com"][1]
+		assert.Equal(t, "_extdns-sync.example.com", sync.Name)
+		assert.Equal(t, endpoint.RecordTypeTXT, sync.Type)
+		assert.True(t, strings.HasPrefix(sync.Content, "last-write=2025-06-01T12:00:00Z; writer="))
+		stamped, ok := lastModified(sync)
+		assert.True(t, ok)
+		assert.Equal(t, now, stamped)
+	}
+
+	// the sync record is not listed to external-dns
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+
+	// later writes edit it
+	clock.now = now.Add(time.Hour)
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, float64(clock.now.Unix()), testutil.ToFloat64(zoneLastWrite.WithLabelValues("example.com")))
+	assert.Len(t, client.zones["example.com"], 3)
+	assert.True(t, strings.HasPrefix(client.zones["example.com"][1].Content, "last-write=2025-06-01T13:00:00Z"))
+
+	// a failing write of the sync record does not fail the sync
+	client.fail = func(op string, zone string, id int) error {
+		if op == "edit" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, client.zones["example.com"], 4)
+}
+
+func testUnchangedUpdates(t *testing.T) {
+	recs := []pb.Record{
+		{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+		{ID: "2", Name: "example.com", Type: "MX", Content: "Mail.Example.com.", Prio: "10", TTL: "600"},
+		{ID: "3", Name: "example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600", Notes: "added by hand"},
+	}
+	updateOld := []pb.Record{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "1"}}
+	updateNew := []pb.Record{
+		{ID: "1", Name: "www", Type: "A", Content: "1.1.1.1", TTL: "600"},
+		{ID: "2", Name: "", Type: "MX", Content: "mail.example.com", Prio: "10", TTL: "600"},
+		{ID: "3", Name: "", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+		{ID: "1", Name: "www", Type: "A", Content: "1.1.1.1", TTL: "3600"},
+	}
+
+	// records only differing in notes, case or quoting are up to date, TTL changes are not
+	assert.Equal(t, 3, dropUnchanged("example.com", &updateNew, &updateOld, recs))
+	assert.Equal(t, []pb.Record{{ID: "1", Name: "www", Type: "A", Content: "1.1.1.1", TTL: "3600"}}, updateNew)
+	assert.Equal(t, []pb.Record{{ID: "1"}}, updateOld)
+
+	// unchanged updates cost no API call
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": recs[:1]})
+	p.client = client
+	before := testutil.ToFloat64(unchangedUpdatesTotal)
+	ep := endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 600, "1.1.1.1")
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: []*endpoint.Endpoint{ep}, UpdateNew: []*endpoint.Endpoint{ep}}))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0"}, client.calls)
+	assert.Equal(t, before+1, testutil.ToFloat64(unchangedUpdatesTotal))
+}
+
+func testEmptyTargets(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	zone := func() map[string][]pb.Record {
+		return map[string][]pb.Record{
+			"example.com": {
+				{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+				{ID: "2", Name: "www.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+				{ID: "3", Name: "api.example.com", Type: "A", Content: "3.3.3.3", TTL: "600"},
+			},
+		}
+	}
+	old := endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")
+	changes := &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA)},
+		UpdateOld: []*endpoint.Endpoint{old},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA)},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA)},
+	}
+
+	// by default the changes are skipped and reported
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(zone())
+	p.client = client
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonEmptyTargets))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, before+3, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonEmptyTargets)))
+	assert.Len(t, client.zones["example.com"], 3)
+
+	// with the delete policy, all records of the name and type are deleted, also those the old endpoint did not list
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithEmptyTargets(EmptyTargetsDelete))
+	client = newFakeClient(zone())
+	p.client = client
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Empty(t, client.zones["example.com"])
+	assert.NotContains(t, client.calls, "create example.com 0")
+}
+
+func testSingleEditUpdates(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+			{ID: "3", Name: "api.example.com", Type: "A", Content: "3.3.3.3", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// every update is one edit of the record of the old side, also without record ID labels and when the new
+	// content matches another record of the name
+	client.calls = nil
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "2.2.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5"),
+			endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "3.3.3.3"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "edit example.com 1", "edit example.com 2"}, client.calls)
+	assert.Equal(t, "5.5.5.5", client.zones["example.com"][0].Content)
+	assert.Equal(t, "3.3.3.3", client.zones["example.com"][1].Content)
+}
+
+func testTypeChanges(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "CNAME", Content: "lb.example.net", TTL: "600"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+		},
+	})
+	p.client = client
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// the CNAME is deleted before the A records are created, the update of the same type is edited in place
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: endpoints,
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "1.1.1.2"),
+			endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "3.3.3.3"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1", "create example.com 0", "create example.com 0", "edit example.com 2"}, client.calls)
+	types := map[string]string{}
+	for _, rec := range client.zones["example.com"] {
+		types[rec.Name+" "+rec.Content] = rec.Type
+	}
+	assert.Equal(t, map[string]string{"www.example.com 1.1.1.1": "A", "www.example.com 1.1.1.2": "A", "api.example.com 3.3.3.3": "A"}, types)
+}
+
+func testOutputEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	outputCipher, err := NewOutputCipher(key)
+	assert.NoError(t, err)
+	_, err = NewOutputCipher(key[:16])
+	assert.Error(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600))
+	_, err = LoadOutputCipher(keyFile)
+	assert.NoError(t, err)
+
+	// the change log is written as one encrypted line per block, which decrypts back to the script
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	var changeLog bytes.Buffer
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithChangeLog(&changeLog), WithOutputEncryption(outputCipher))
+	p.client = newFakeClient(map[string][]pb.Record{"example.com": {}})
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "secret-token")},
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, changeLog.String(), "secret-token")
+	assert.True(t, strings.HasPrefix(changeLog.String(), encryptedPrefix))
+	assert.Equal(t, 1, strings.Count(changeLog.String(), "\n"))
+
+	var decrypted bytes.Buffer
+	assert.NoError(t, outputCipher.Decrypt(bytes.NewReader(changeLog.Bytes()), &decrypted))
+	assert.Contains(t, decrypted.String(), `IN TXT "secret-token"`)
+
+	// a wrong key or modified data is detected
+	otherCipher, _ := NewOutputCipher(bytes.Repeat([]byte{8}, 32))
+	assert.Error(t, otherCipher.Decrypt(bytes.NewReader(changeLog.Bytes()), io.Discard))
+	sealed, err := outputCipher.seal([]byte("snapshot"))
+	assert.NoError(t, err)
+	sealed[len(sealed)-2] ^= 1
+	_, err = outputCipher.open(sealed)
+	assert.Error(t, err)
+
+	// snapshots round-trip through the cipher
+	sealed, err = outputCipher.seal([]byte("snapshot"))
+	assert.NoError(t, err)
+	opened, err := outputCipher.open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("snapshot"), opened)
+	_, err = outputCipher.open([]byte("snapshot"))
+	assert.Error(t, err)
+}
+
+func testNotesRoundTrip(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600", Notes: "ask the web team external-dns: last-modified=2025-06-01T12:00:00Z"},
+			{ID: "2", Name: "api.example.com", Type: "A", Content: "2.2.2.2", TTL: "600", Notes: "external-dns: last-modified=2025-06-01T12:00:00Z"},
+		},
+	})
+	p.client = client
+
+	// notes written in the console are listed as provider specific property, the record ID as label
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	notes, ok := current[0].GetProviderSpecificProperty(ProviderSpecificNotes)
+	assert.True(t, ok)
+	assert.Equal(t, "ask the web team", notes)
+	assert.Equal(t, "1", current[0].Labels[RecordIDLabelKey])
+	_, ok = current[1].GetProviderSpecificProperty(ProviderSpecificNotes)
+	assert.False(t, ok)
+
+	// desired endpoints without notes inherit them, so the plan has no changes
+	calculate := func(desired ...*endpoint.Endpoint) *plan.Changes {
+		desired, err := p.AdjustEndpoints(desired)
+		assert.NoError(t, err)
+		return (&plan.Plan{Current: current, Desired: desired, ManagedRecords: []string{endpoint.RecordTypeA}}).Calculate().Changes
+	}
+	changes := calculate(
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 600, "1.1.1.1"),
+		endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 600, "2.2.2.2"),
+	)
+	assert.False(t, changes.HasChanges())
+
+	// notes set on the source replace those of the record
+	desired := endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 600, "1.1.1.1")
+	desired.SetProviderSpecificProperty(ProviderSpecificNotes, "owned by the platform team")
+	changes = calculate(desired, endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 600, "2.2.2.2"))
+	assert.Len(t, changes.UpdateNew, 1)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, "owned by the platform team", operatorNotes(client.zones["example.com"][0].Notes))
+
+	// notes pushed from a source are written and listed on one line, without the marker of the metadata
+	sources := func() []*endpoint.Endpoint {
+		www := endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 600, "1.1.1.1")
+		www.SetProviderSpecificProperty(ProviderSpecificNotes, "owned by the platform team")
+		api := endpoint.NewEndpointWithTTL("api.example.com", endpoint.RecordTypeA, 600, "2.2.2.2")
+		api.SetProviderSpecificProperty(ProviderSpecificNotes, "managed by\nexternal-dns: cluster=prod")
+		return []*endpoint.Endpoint{www, api}
+	}
+	current, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	changes = calculate(sources()...)
+	assert.Len(t, changes.UpdateNew, 1)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	current, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	notes, _ = current[1].GetProviderSpecificProperty(ProviderSpecificNotes)
+	assert.Equal(t, "managed by external-dns cluster=prod", notes)
+	assert.False(t, calculate(sources()...).HasChanges())
+}
+
+func testOwnershipGuard(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	zone := func() map[string][]pb.Record {
+		return map[string][]pb.Record{
+			"example.com": {
+				{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+				{ID: "2", Name: "a-www.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-a", TTL: "600"},
+				{ID: "3", Name: "manual.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+				{ID: "4", Name: "other.example.com", Type: "A", Content: "3.3.3.3", TTL: "600"},
+				{ID: "5", Name: "a-other.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-b", TTL: "600"},
+			},
+		}
+	}
+	owned := func(ep *endpoint.Endpoint) *endpoint.Endpoint {
+		ep.Labels[endpoint.OwnerLabelKey] = "cluster-a"
+		return ep
+	}
+
+	// only the record with a registry TXT record of the owner of the sync is changed
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithOwnershipGuard(true))
+	client := newFakeClient(zone())
+	p.client = client
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonNotOwned))
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			owned(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")),
+			owned(endpoint.NewEndpoint("manual.example.com", endpoint.RecordTypeA, "2.2.2.2")),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			owned(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "5.5.5.5")),
+			owned(endpoint.NewEndpoint("manual.example.com", endpoint.RecordTypeA, "6.6.6.6")),
+		},
+		Delete: []*endpoint.Endpoint{
+			owned(endpoint.NewEndpoint("other.example.com", endpoint.RecordTypeA, "3.3.3.3")),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, before+2, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonNotOwned)))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "edit example.com 1"}, client.calls)
+	assert.Equal(t, "2.2.2.2", client.zones["example.com"][2].Content)
+	assert.Len(t, client.zones["example.com"], 5)
+
+	// registry TXT records are owned by their own content, without an owner ID any external-dns owner counts
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("other.example.com", endpoint.RecordTypeA, "3.3.3.3"),
+			endpoint.NewEndpoint("a-other.example.com", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=cluster-b\""),
+			endpoint.NewEndpoint("manual.example.com", endpoint.RecordTypeA, "2.2.2.2"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, client.calls, "delete example.com 4")
+	assert.Contains(t, client.calls, "delete example.com 5")
+	assert.NotContains(t, client.calls, "delete example.com 3")
+
+	// without the guard, records created in the console are changed like any other
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client = newFakeClient(zone())
+	p.client = client
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("manual.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, client.calls, "delete example.com 3")
+}
+
+func testMaxDeletesPerSync(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxDeletesPerSync(2))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "www.example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+		},
+		"example.org": {
+			{ID: "3", Name: "api.example.org", Type: "A", Content: "3.3.3.3", TTL: "600"},
+			{ID: "4", Name: "old.example.org", Type: "CNAME", Content: "lb.example.net", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// deletes over all zones, type changes and targets dropped by updates count against the limit
+	client.calls = nil
+	before := testutil.ToFloat64(deleteLimitExceededTotal)
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "5.5.5.5")},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"),
+			endpoint.NewEndpoint("old.example.org", endpoint.RecordTypeCNAME, "lb.example.net"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("old.example.org", endpoint.RecordTypeA, "6.6.6.6"),
+		},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeA, "3.3.3.3")},
+	})
+	assert.ErrorIs(t, err, errTooManyDeletes)
+	assert.Equal(t, before+1, testutil.ToFloat64(deleteLimitExceededTotal))
+	assert.Equal(t, []string{"ping  0"}, client.calls)
+	assert.Len(t, client.zones["example.com"], 2)
+	assert.Len(t, client.zones["example.org"], 2)
+
+	// changes within the limit are applied
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeA, "3.3.3.3")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, client.zones["example.com"], 1)
+	assert.Len(t, client.zones["example.org"], 1)
+	assert.Equal(t, before+1, testutil.ToFloat64(deleteLimitExceededTotal))
+}
+
+func testRecordProtection(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600", Notes: "external-dns: protected=true"},
+			{ID: "2", Name: "www.example.com", Type: "A", Content: "2.2.2.2", TTL: "600", Notes: "external-dns: protected=true"},
+			{ID: "3", Name: "api.example.com", Type: "CNAME", Content: "lb.example.net", TTL: "600", Notes: "external-dns: protected=true"},
+			{ID: "4", Name: "tmp.example.com", Type: "A", Content: "4.4.4.4", TTL: "600"},
+		},
+	})
+	p.client = client
+	protected := func(ep *endpoint.Endpoint) *endpoint.Endpoint {
+		ep.SetProviderSpecificProperty(ProviderSpecificProtected, "true")
+		return ep
+	}
+
+	// the protection is listed as provider specific property
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, current, 3)
+	value, ok := current[0].GetProviderSpecificProperty(ProviderSpecificProtected)
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+	_, ok = current[2].GetProviderSpecificProperty(ProviderSpecificProtected)
+	assert.False(t, ok)
+
+	// deletes and updates deleting protected records are skipped, also without the property on the endpoint
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonProtected))
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			protected(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2")),
+			endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "lb.example.net"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			protected(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")),
+			protected(endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "3.3.3.3")),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"),
+			endpoint.NewEndpoint("tmp.example.com", endpoint.RecordTypeA, "4.4.4.4"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, before+3, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonProtected)))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 4"}, client.calls)
+
+	// edits in place go through and keep the protection
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{protected(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"))},
+		UpdateNew: []*endpoint.Endpoint{protected(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "5.5.5.5"))},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "5.5.5.5", client.zones["example.com"][1].Content)
+	assert.True(t, recordProtected(client.zones["example.com"][1]))
+
+	// removing the property lifts the protection, after that the records can be deleted
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{protected(endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "lb.example.net"))},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "lb.example.net")},
+	})
+	assert.NoError(t, err)
+	assert.False(t, recordProtected(client.zones["example.com"][2]))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeCNAME, "lb.example.net")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, client.zones["example.com"], 2)
+
+	// records created from a protected endpoint are protected
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{protected(endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "6.6.6.6"))},
+	})
+	assert.NoError(t, err)
+	assert.True(t, recordProtected(client.zones["example.com"][2]))
+}
+
+func testExcludeDomains(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithExcludeDomains("Internal.example.com."))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "internal.example.com", Type: "A", Content: "10.0.0.1", TTL: "600"},
+			{ID: "3", Name: "db.internal.example.com", Type: "A", Content: "10.0.0.2", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// the domain filter sent to external-dns excludes the domain
+	assert.True(t, p.GetDomainFilter().Match("www.example.com"))
+	assert.False(t, p.GetDomainFilter().Match("db.internal.example.com"))
+	data, err := json.Marshal(p.GetDomainFilter())
+	assert.NoError(t, err)
+	assert.Contains(t, strings.ToLower(string(data)), "internal.example.com")
+
+	// records of the excluded domain are not listed
+	current, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, current, 1)
+	assert.Equal(t, "www.example.com", current[0].DNSName)
+
+	// nor changed
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.internal.example.com", endpoint.RecordTypeA, "10.0.0.3")},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("db.internal.example.com", endpoint.RecordTypeA, "10.0.0.2"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1"}, client.calls)
+
+	// the exclusions survive updates of the domain filter
+	assert.NoError(t, p.SetDomainFilter([]string{"example.com", "example.net"}))
+	assert.False(t, p.GetDomainFilter().Match("db.internal.example.com"))
+	assert.True(t, p.GetDomainFilter().Match("www.example.net"))
+}
+
+func testDelegatedSubzones(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithManageNS(true))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "example.com", Type: "NS", Content: "curitiba.ns.porkbun.com", TTL: "86400"},
+			{ID: "2", Name: "dev.example.com", Type: "NS", Content: "ns1.dev-dns.net", TTL: "600"},
+			{ID: "3", Name: "api.dev.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+			{ID: "4", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// records below the delegation are not listed, the delegation itself is
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var names []string
+	for _, ep := range endpoints {
+		names = append(names, ep.RecordType+" "+ep.DNSName)
+	}
+	assert.Equal(t, []string{"NS dev.example.com", "A www.example.com"}, names)
+
+	// changes below the delegation are skipped
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonDelegated))
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("new.dev.example.com", endpoint.RecordTypeA, "192.0.2.3")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("api.dev.example.com", endpoint.RecordTypeA, "192.0.2.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("api.dev.example.com", endpoint.RecordTypeA, "192.0.2.4")},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("api.dev.example.com", endpoint.RecordTypeA, "192.0.2.2"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, before+3, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonDelegated)))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 4"}, client.calls)
+}
+
+func testNameForms(t *testing.T) {
+	// names convert between the forms of Porkbun and external-dns in both directions
+	for _, name := range []string{"example.com", "www.example.com", "a.b.example.com", "_dmarc.example.com", "example.com.example.com"} {
+		assert.Equal(t, name, listedName(recordFQDN(relativeName(name, "example.com"), "example.com"), "example.com"), name)
+	}
+	assert.Equal(t, "a.b", relativeName("a.b.example.com", "example.com"))
+	assert.Equal(t, "a", relativeName("a.b.example.com", "b.example.com"))
+	assert.Equal(t, "", relativeName("example.com", "example.com"))
+	assert.Equal(t, "example.com", recordFQDN("@", "example.com"))
+	assert.Equal(t, "example.com", listedName("", "example.com"))
+	assert.Equal(t, "example.com", listedName("@.Example.com.", "example.com"))
+
+	// records listed with @ and fully qualified names are listed and resolved alike
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "@", Type: "A", Content: "1.1.1.1", TTL: "600"},
+			{ID: "2", Name: "A.b.Example.com", Type: "A", Content: "2.2.2.2", TTL: "600"},
+		},
+	})
+	p.client = client
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var names []string
+	for _, ep := range endpoints {
+		names = append(names, ep.DNSName)
+	}
+	assert.Equal(t, []string{"example.com", "a.b.example.com"}, names)
+
+	client.calls = nil
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("a.b.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("a.b.example.com", endpoint.RecordTypeA, "3.3.3.3")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("example.com.", endpoint.RecordTypeA, "1.1.1.1")},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, client.calls, "edit example.com 2")
+	assert.Contains(t, client.calls, "delete example.com 1")
+	assert.Len(t, client.zones["example.com"], 1)
+	assert.Equal(t, "a.b.example.com", client.zones["example.com"][0].Name)
+	assert.Equal(t, "3.3.3.3", client.zones["example.com"][0].Content)
+}
+
+func testZoneFailureIsolation(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org", "example.net"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}, "example.org": {}, "example.net": {}})
+	client.fail = func(op string, zone string, id int) error {
+		switch {
+		case zone == "example.com" && op == "retrieve":
+			return errors.New("connection reset")
+		case zone == "example.org" && op == "create":
+			return pb.Status{Status: "ERROR", Message: "Invalid content."}
+		}
+		return nil
+	}
+	p.client = client
+
+	// the failing zones don't keep the other one from being changed, the sync fails with the errors of both
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.2"),
+		endpoint.NewEndpoint("www.example.net", endpoint.RecordTypeA, "192.0.2.3"),
+	}})
+	assert.ErrorContains(t, err, "zone 'example.com'")
+	assert.ErrorContains(t, err, "connection reset")
+	assert.ErrorContains(t, err, "Invalid content.")
+	assert.NotContains(t, err.Error(), "example.net")
+	assert.Empty(t, client.zones["example.com"])
+	assert.Empty(t, client.zones["example.org"])
+	assert.Len(t, client.zones["example.net"], 1)
+}
+
+func testSoftErrors(t *testing.T) {
+	assert.True(t, isTransient(&pb.ServerError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, isTransient(&pb.ServerError{StatusCode: http.StatusBadGateway}))
+	assert.True(t, isTransient(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, isTransient(errMaintenance))
+	assert.False(t, isTransient(&pb.ServerError{StatusCode: http.StatusForbidden}))
+	assert.False(t, isTransient(pb.Status{Status: "ERROR", Message: "Invalid API key."}))
+	assert.False(t, isTransient(context.Canceled))
+	assert.False(t, isTransient(errors.Join(&pb.ServerError{StatusCode: http.StatusBadGateway}, errRecordConflict)))
+
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{"example.com": {}, "example.org": {}})
+	var failures map[string]error
+	client.fail = func(op string, zone string, id int) error {
+		return failures[op+" "+zone]
+	}
+	p.client = client
+
+	// transient failures are soft errors, external-dns retries them with the next sync
+	failures = map[string]error{"retrieve example.org": &pb.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "unavailable"}}
+	_, err := p.Records(context.TODO())
+	assert.ErrorIs(t, err, provider.SoftError)
+	failures = map[string]error{"create example.com": &pb.ServerError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.2"),
+	}}
+	err = p.ApplyChanges(context.TODO(), changes)
+	assert.ErrorIs(t, err, provider.SoftError)
+
+	// failures that recur with every sync stay hard errors, also next to transient ones
+	failures = map[string]error{"ping ": pb.Status{Status: "ERROR", Message: "Invalid API key."}}
+	_, err = p.Records(context.TODO())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, provider.SoftError)
+	failures = map[string]error{
+		"create example.com": &pb.ServerError{StatusCode: http.StatusTooManyRequests, Message: "slow down"},
+		"create example.org": pb.Status{Status: "ERROR", Message: "Invalid content."},
+	}
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeA, "192.0.2.2"),
+	}})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, provider.SoftError)
+}
+
+// hangingClient never answers record listings, like a Porkbun API call that hangs.
+type hangingClient struct {
+	*fakeClient
+}
+
+func (c hangingClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func testRequestTimeout(t *testing.T) {
+	client := timeoutClient{client: hangingClient{newFakeClient(map[string][]pb.Record{"example.com": {}})}, timeout: 10 * time.Millisecond}
+
+	// a hung call fails with its own deadline, the other calls are not affected
+	_, err := client.RetrieveRecords(context.Background(), "example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, isTransient(err))
+	_, err = client.Ping(context.Background())
+	assert.NoError(t, err)
+
+	// an attempt running into its deadline is retried while the caller still waits
+	logger := promslog.New(&promslog.Config{})
+	retrying := newRetryingClient(&client, RetryPolicies{Read: RetryPolicy{Attempts: 3, RetryOn: []string{RetryOnNetwork}}}, logger)
+	retries := 0
+	retrying.sleep = func(ctx context.Context, d time.Duration) error {
+		retries++
+		return nil
+	}
+	_, err = retrying.RetrieveRecords(context.Background(), "example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, retries)
+
+	// once the caller gave up, nothing is retried
+	retries = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = retrying.RetrieveRecords(ctx, "example.com")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, retries)
+
+	domainFilter := []string{"example.com"}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	assert.Equal(t, defaultRequestTimeout, p.requestTimeout)
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithRequestTimeout(0))
+	assert.Zero(t, p.requestTimeout)
+}
+
+func testRollbackOnFailure(t *testing.T) {
+	records := func() map[string][]pb.Record {
+		return map[string][]pb.Record{
+			"example.com": {
+				{ID: "1", Name: "old.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+				{ID: "2", Name: "api.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+				{ID: "3", Name: "web.example.com", Type: "A", Content: "192.0.2.3", TTL: "600"},
+			},
+		}
+	}
+	changes := func() *plan.Changes {
+		return &plan.Changes{
+			Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "192.0.2.9")},
+			UpdateOld: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.2"),
+				endpoint.NewEndpoint("web.example.com", endpoint.RecordTypeA, "192.0.2.3"),
+			},
+			UpdateNew: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.20"),
+				endpoint.NewEndpoint("web.example.com", endpoint.RecordTypeA, "192.0.2.30"),
+			},
+			Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+		}
+	}
+	failWeb := func(op string, zone string, id int) error {
+		if op == "edit" && id == 3 {
+			return pb.Status{Status: "ERROR", Message: "Unable to edit record."}
+		}
+		return nil
+	}
+	contents := func(recs []pb.Record) []string {
+		var list []string
+		for _, rec := range recs {
+			list = append(list, rec.Name+" "+rec.Content)
+		}
+		slices.Sort(list)
+		return list
+	}
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	// without rollback, the changes applied before the failure stay
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(records())
+	client.fail = failWeb
+	p.client = client
+	err := p.ApplyChanges(context.TODO(), changes())
+	assert.ErrorContains(t, err, "Unable to edit record.")
+	assert.Equal(t, []string{"api.example.com 192.0.2.20", "new.example.com 192.0.2.9", "web.example.com 192.0.2.3"}, contents(client.zones["example.com"]))
+
+	// with rollback, the zone is back to its records before the sync
+	before := testutil.ToFloat64(rollbacksTotal.WithLabelValues("example.com", "rolled_back"))
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithRollbackOnFailure(true))
+	client = newFakeClient(records())
+	client.fail = failWeb
+	p.client = client
+	err = p.ApplyChanges(context.TODO(), changes())
+	assert.ErrorContains(t, err, "Unable to edit record.")
+	assert.ErrorContains(t, err, "the 3 applied changes were rolled back")
+	assert.Equal(t, []string{"api.example.com 192.0.2.2", "old.example.com 192.0.2.1", "web.example.com 192.0.2.3"}, contents(client.zones["example.com"]))
+	assert.Equal(t, before+1, testutil.ToFloat64(rollbacksTotal.WithLabelValues("example.com", "rolled_back")))
+
+	// changes that can't be undone are reported
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithRollbackOnFailure(true))
+	client = newFakeClient(records())
+	failed := false
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" && failed {
+			return pb.Status{Status: "ERROR", Message: "Unable to create record."}
+		}
+		err := failWeb(op, zone, id)
+		failed = failed || err != nil
+		return err
+	}
+	p.client = client
+	err = p.ApplyChanges(context.TODO(), changes())
+	assert.ErrorContains(t, err, "rollback failed: unable to roll back delete of old.example.com A")
+}
+
+func testDuplicateChanges(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMaxDeletesPerSync(1))
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {{ID: "1", Name: "old.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"}},
+	})
+	p.client = client
+
+	// the same endpoint of two sources is created and deleted once, and deletes count once against the limit
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonDuplicate))
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.2", "192.0.2.3"),
+			endpoint.NewEndpoint("WWW.example.com.", endpoint.RecordTypeA, "192.0.2.3"),
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.4"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+			endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, before+2, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonDuplicate)))
+	assert.Equal(t, []string{"ping  0", "retrieve example.com 0", "delete example.com 1", "create example.com 0", "create example.com 0", "create example.com 0"}, client.calls)
+	var contents []string
+	for _, rec := range client.zones["example.com"] {
+		contents = append(contents, rec.Content)
+	}
+	assert.Equal(t, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, contents)
+}
+
+func testTargetValidation(t *testing.T) {
+	for _, tc := range []struct {
+		recordType string
+		target     string
+		valid      bool
+	}{
+		{"A", "192.0.2.1", true},
+		{"A", "2001:db8::1", false},
+		{"A", "192.0.2.256", false},
+		{"AAAA", "2001:db8::1", true},
+		{"AAAA", "192.0.2.1", false},
+		{"CNAME", "lb.example.net", true},
+		{"CNAME", "_acme-challenge.example.net.", true},
+		{"CNAME", "lb example.net", false},
+		{"CNAME", "-lb.example.net", false},
+		{"CNAME", strings.Repeat("a", 64) + ".example.net", false},
+		{"ALIAS", "lb.example.net", true},
+		{"NS", "ns1..example.net", false},
+		{"MX", "10 mail.example.com", true},
+		{"MX", "0 .", true},
+		{"MX", "mail.example.com", false},
+		{"MX", "10 mail@example.com", false},
+		{"SRV", "10 5 5060 sip.example.com", true},
+		{"SRV", "10 5 sip.example.com", false},
+		{"SRV", "10 5 70000 sip.example.com", false},
+		{"CAA", `0 issue "letsencrypt.org"`, true},
+		{"CAA", "letsencrypt.org", false},
+		{"TXT", strings.Repeat("a", 1000), true},
+		{"TXT", strings.Repeat("a", 65500), false},
+		{"TLSA", "anything", true},
+	} {
+		err := validateTarget(tc.recordType, tc.target)
+		assert.Equal(t, tc.valid, err == nil, "%s %s: %v", tc.recordType, tc.target, err)
+	}
+
+	// invalid endpoints are dropped when adjusted, the others are kept
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	before := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonInvalidTarget))
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1", "192.0.2.x"),
+		endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.2"),
+		endpoint.NewEndpoint("docs.example.com", endpoint.RecordTypeCNAME, "Bücher.example.net"),
+	})
+	assert.NoError(t, err)
+	var names []string
+	for _, ep := range adjusted {
+		names = append(names, ep.DNSName)
+	}
+	assert.Equal(t, []string{"api.example.com", "docs.example.com"}, names)
+	assert.Equal(t, before+1, testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues(skipReasonInvalidTarget)))
+}
+
+func testTXTGC(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithTXTGC(time.Hour, "default"))
+	heritage := "heritage=external-dns,external-dns/owner=default"
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "a-www.example.com", Type: "TXT", Content: heritage, TTL: "600"},
+			{ID: "3", Name: "api.example.com", Type: "CNAME", Content: "lb.example.net", TTL: "600"},
+			{ID: "4", Name: "api.example.com", Type: "TXT", Content: `"` + heritage + `"`, TTL: "600"},
+			{ID: "5", Name: "a-gone.example.com", Type: "TXT", Content: heritage, TTL: "600"},
+			{ID: "6", Name: "cname-gone.example.com", Type: "TXT", Content: "v=spf1 -all", TTL: "600"},
+			{ID: "7", Name: "a-kept.example.com", Type: "TXT", Content: heritage, TTL: "600", Notes: "external-dns: protected=true"},
+			{ID: "10", Name: "other-www.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=other", TTL: "600"},
+			{ID: "11", Name: "a-unowned.example.com", Type: "TXT", Content: "heritage=external-dns", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	// registry TXT records of existing records, in the current and the legacy naming, of other owners, e.g. with other
+	// affixes, and other TXT records are kept
+	assert.Equal(t, []string{"5"}, func() []string {
+		var ids []string
+		for _, rec := range p.orphanedTXT("example.com", client.zones["example.com"]) {
+			ids = append(ids, rec.ID)
+		}
+		return ids
+	}())
+
+	// an orphan is deleted by the second pass that finds it
+	deleted, err := p.collectOrphanedTXT(context.TODO())
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Len(t, client.zones["example.com"], 9)
+	before := testutil.ToFloat64(orphanedTXTDeletedTotal.WithLabelValues("example.com"))
+	deleted, err = p.collectOrphanedTXT(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Len(t, client.zones["example.com"], 8)
+	assert.Equal(t, before+1, testutil.ToFloat64(orphanedTXTDeletedTotal.WithLabelValues("example.com")))
+
+	// a registry TXT record whose record appears before the second pass is kept
+	client.zones["example.com"] = append(client.zones["example.com"], pb.Record{ID: "8", Name: "a-new.example.com", Type: "TXT", Content: heritage, TTL: "600"})
+	_, err = p.collectOrphanedTXT(context.TODO())
+	assert.NoError(t, err)
+	client.zones["example.com"] = append(client.zones["example.com"], pb.Record{ID: "9", Name: "new.example.com", Type: "A", Content: "192.0.2.9", TTL: "600"})
+	deleted, err = p.collectOrphanedTXT(context.TODO())
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Len(t, client.zones["example.com"], 10)
+}
+
+func testChangeResults(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	domainFilter := []string{"example.com"}
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+			{ID: "2", Name: "old.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+		},
+	})
+	failCreates := false
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" && failCreates {
+			return pb.Status{Status: "ERROR", Message: "Unable to create record."}
+		}
+		return nil
+	}
+	p.client = client
+
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "192.0.2.3")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.4")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.2")},
+	})
+	assert.NoError(t, err)
+
+	// every record change is reported with its outcome and record ID, the final line counts them
+	var changes []string
+	var completed map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		switch entry["msg"] {
+		case "record change":
+			assert.Equal(t, "example.com", entry["zone"])
+			assert.Equal(t, "A", entry["type"])
+			assert.NotEmpty(t, entry["latency"])
+			changes = append(changes, fmt.Sprintf("%s %s %s %s", entry["operation"], entry["name"], entry["outcome"], entry["id"]))
+		case "update completed":
+			completed = entry
+		}
+	}
+	assert.Equal(t, []string{
+		"delete old.example.com succeeded 2",
+		"create api.example.com succeeded 1001",
+		"update www.example.com succeeded 1",
+	}, changes)
+	assert.Equal(t, "INFO", completed["level"])
+	assert.Equal(t, []any{1.0, 1.0, 1.0, 0.0}, []any{completed["created"], completed["updated"], completed["deleted"], completed["failed"]})
+
+	// failed changes are reported and counted as well
+	logs.Reset()
+	failCreates = true
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("web.example.com", endpoint.RecordTypeA, "192.0.2.5")},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, logs.String(), `"msg":"record change","operation":"create","name":"web.example.com","type":"A","zone":"example.com","outcome":"failed"`)
+	assert.Contains(t, logs.String(), `"failedZones":["example.com"],"succeededZones":null,"created":0,"updated":0,"deleted":0,"failed":1`)
+}
+
+func testEndpointLabels(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{
+		"example.com": {
+			{ID: "1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: "600", Notes: "external-dns: owner=cluster-x; resource=ingress/default/old"},
+			{ID: "2", Name: "a-www.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-a,external-dns/resource=service/default/web", TTL: "600"},
+			{ID: "3", Name: "api.example.com", Type: "A", Content: "192.0.2.2", TTL: "600", Notes: "ask the api team external-dns: owner=cluster-b; resource=ingress/default/api"},
+			{ID: "4", Name: "old.example.com", Type: "CNAME", Content: "www.example.com", TTL: "600"},
+			{ID: "5", Name: "old.example.com", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=cluster-c", TTL: "600"},
+			{ID: "6", Name: "manual.example.com", Type: "A", Content: "192.0.2.3", TTL: "600"},
+		},
+	})
+	p.client = client
+
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	labels := map[string]endpoint.Labels{}
+	for _, ep := range endpoints {
+		labels[ep.RecordType+" "+ep.DNSName] = ep.Labels
+	}
+
+	// the registry TXT record wins over the notes
+	assert.Equal(t, "cluster-a", labels["A www.example.com"][endpoint.OwnerLabelKey])
+	assert.Equal(t, "service/default/web", labels["A www.example.com"][endpoint.ResourceLabelKey])
+	// records without one are labeled from their notes
+	assert.Equal(t, "cluster-b", labels["A api.example.com"][endpoint.OwnerLabelKey])
+	assert.Equal(t, "ingress/default/api", labels["A api.example.com"][endpoint.ResourceLabelKey])
+	// registry TXT records named like the record itself are read too
+	assert.Equal(t, "cluster-c", labels["CNAME old.example.com"][endpoint.OwnerLabelKey])
+	assert.Empty(t, labels["CNAME old.example.com"][endpoint.ResourceLabelKey])
+	// records created by hand and registry TXT records carry no labels
+	assert.NotContains(t, labels["A manual.example.com"], endpoint.OwnerLabelKey)
+	assert.NotContains(t, labels["TXT a-www.example.com"], endpoint.OwnerLabelKey)
+	assert.NotContains(t, labels["TXT old.example.com"], endpoint.OwnerLabelKey)
+
+	// the labels of a created endpoint are listed again
+	ep := endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "192.0.2.4")
+	ep.Labels[endpoint.OwnerLabelKey] = "cluster-a"
+	ep.Labels[endpoint.ResourceLabelKey] = "service/default/new"
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{ep}}))
+	endpoints, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	for _, listed := range endpoints {
+		if listed.DNSName == "new.example.com" {
+			assert.Equal(t, "cluster-a", listed.Labels[endpoint.OwnerLabelKey])
+			assert.Equal(t, "service/default/new", listed.Labels[endpoint.ResourceLabelKey])
+		}
+	}
+}
+
+func testChangeOrder(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	zone := func() map[string][]pb.Record {
+		return map[string][]pb.Record{
+			"example.com": {
+				{ID: "1", Name: "old.example.com", Type: "A", Content: "192.0.2.1", TTL: "600"},
+				{ID: "2", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: "600"},
+			},
+		}
+	}
+	// old is renamed to new, the A record of www is replaced by a CNAME
+	changes := func() *plan.Changes {
+		return &plan.Changes{
+			Create: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+				endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "new.example.com"),
+			},
+			Delete: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1"),
+				endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "192.0.2.2"),
+			},
+		}
+	}
+	ops := func(calls []string) []string {
+		var ops []string
+		for _, call := range calls {
+			if !strings.HasPrefix(call, "ping") && !strings.HasPrefix(call, "retrieve") {
+				ops = append(ops, call)
+			}
+		}
+		return ops
+	}
+
+	// by default all deletes come first
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(zone())
+	p.client = client
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes()))
+	assert.Equal(t, []string{"delete example.com 2", "delete example.com 1", "create example.com 0", "create example.com 0"}, ops(client.calls))
+
+	// creating first keeps the old name until the new one exists, the A record blocking the CNAME is still deleted first
+	p, _ = NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithChangeOrder(ChangeOrderCreateFirst))
+	client = newFakeClient(zone())
+	p.client = client
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes()))
+	assert.Equal(t, []string{"delete example.com 2", "create example.com 0", "create example.com 0", "delete example.com 1"}, ops(client.calls))
+
+	// a failed create keeps the records it would replace
+	client = newFakeClient(zone())
+	client.fail = func(op string, zone string, id int) error {
+		if op == "create" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	p.client = client
+	assert.Error(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "192.0.2.1")},
+	}))
+	assert.Equal(t, []string{"create example.com 0"}, ops(client.calls))
+
+	// the blocking deletes keep their order
+	blocking, deferred := splitBlockingDeletes("example.com",
+		[]pb.Record{
+			{ID: "1", Name: "old", Type: "A", Content: "192.0.2.1"},
+			{ID: "2", Name: "www", Type: "A", Content: "192.0.2.2"},
+			{ID: "3", Name: "api", Type: "TXT", Content: "v=1"},
+			{ID: "4", Name: "api", Type: "TXT", Content: "v=2"},
+		},
+		[]pb.Record{{Name: "www", Type: "CNAME", Content: "new.example.com"}},
+		[]pb.Record{{ID: "5", Name: "api", Type: "TXT", Content: "v=1"}},
+	)
+	assert.Equal(t, []string{"2", "3"}, []string{blocking[0].ID, blocking[1].ID})
+	assert.Equal(t, []string{"1", "4"}, []string{deferred[0].ID, deferred[1].ID})
+}
+
+func testSRVRecords(t *testing.T) {
+	priority, weight, port, host, ok := parseSRV("10 5\t5060  sip.example.com.")
+	assert.True(t, ok)
+	assert.Equal(t, []any{uint16(10), uint16(5), uint16(5060), "sip.example.com."}, []any{priority, weight, port, host})
+	_, _, _, _, ok = parseSRV("10 5 sip.example.com")
+	assert.False(t, ok)
+	_, _, _, _, ok = parseSRV("10 5 70000 sip.example.com")
+	assert.False(t, ok)
+	assert.Equal(t, "10 5 5060 sip.example.com", normalizeSRV("010  5 5060 SIP.example.com."))
+	assert.Equal(t, "0 0 0 .", normalizeSRV("0 0 0 ."))
+	assert.Equal(t, "not an srv target", normalizeSRV("not an srv target"))
+
+	// the priority goes into its own field, the host loses its trailing dot
+	content, prio := splitPriority(endpoint.RecordTypeSRV, "10 5 5060 sip.example.com.")
+	assert.Equal(t, "5 5060 sip.example.com", content)
+	assert.Equal(t, "10", prio)
+
+	// content entered with the priority is listed like content without
+	assert.Equal(t, "10 5 5060 sip.example.com", recordTarget(pb.Record{Type: "SRV", Content: "5 5060 sip.example.com", Prio: "10"}))
+	assert.Equal(t, "10 5 5060 sip.example.com", recordTarget(pb.Record{Type: "SRV", Content: "10 5 5060 sip.example.com"}))
+	assert.Equal(t, "10 5 5060 sip.example.com", recordTarget(pb.Record{Type: "SRV", Content: "10 5 5060 Sip.example.com.", Prio: "10"}))
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger)
+	client := newFakeClient(map[string][]pb.Record{})
+	p.client = client
+
+	// a desired SRV endpoint is written as a valid Porkbun record and listed as it was desired
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("_sip._tcp.example.com", endpoint.RecordTypeSRV, "10 5 5060 sip.example.com.", "20 0 5060 ."),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"10 5 5060 sip.example.com", "20 0 5060 ."}, adjusted[0].Targets)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: adjusted}))
+	assert.Equal(t, []pb.Record{
+		{ID: "1001", Name: "_sip._tcp.example.com", Type: "SRV", Content: "5 5060 sip.example.com", Prio: "10", TTL: pb.DefaultTTL},
+		{ID: "1002", Name: "_sip._tcp.example.com", Type: "SRV", Content: "0 5060 .", Prio: "20", TTL: pb.DefaultTTL},
+	}, stripNotes(client.zones["example.com"]))
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, adjusted[0].Targets, endpoints[0].Targets)
+}
+
+func testTTLProperty(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, _ := NewPorkbunProvider(&domainFilter, "KEY", "PASSWORD", false, logger, WithMinTTL(3600), WithDefaultTTL(7200))
+	withTTL := func(name string, ttl endpoint.TTL, property string) *endpoint.Endpoint {
+		ep := endpoint.NewEndpointWithTTL(name, endpoint.RecordTypeA, ttl, "192.0.2.1")
+		ep.SetProviderSpecificProperty(ProviderSpecificNotes, "failover")
+		if property != "" {
+			ep.SetProviderSpecificProperty(ProviderSpecificTTL, property)
+		}
+		return ep
+	}
+
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		withTTL("failover.example.com", 0, "900"),
+		withTTL("short.example.com", 3600, "60"),
+		withTTL("broken.example.com", 0, "soon"),
+		withTTL("www.example.com", 300, ""),
+	})
+	assert.NoError(t, err)
+	ttls := map[string]endpoint.TTL{}
+	for _, ep := range adjusted {
+		ttls[ep.DNSName] = ep.RecordTTL
+		// the property is not listed back, so it must not be desired either
+		_, ok := ep.GetProviderSpecificProperty(ProviderSpecificTTL)
+		assert.False(t, ok, ep.DNSName)
+		notes, _ := ep.GetProviderSpecificProperty(ProviderSpecificNotes)
+		assert.Equal(t, "failover", notes)
+	}
+	assert.Equal(t, map[string]endpoint.TTL{
+		// the property wins over the default and the minimum TTL
+		"failover.example.com": 900,
+		// but not over the Porkbun minimum
+		"short.example.com": 600,
+		// invalid properties are ignored
+		"broken.example.com": 7200,
+		"www.example.com":    3600,
+	}, ttls)
+}
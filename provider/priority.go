@@ -0,0 +1,55 @@
+package porkbun
+
+import (
+	"strconv"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// hasPriority reports whether records of the type carry a priority, which Porkbun keeps in the prio field
+// while external-dns puts it in front of the target.
+func hasPriority(recordType string) bool {
+	return recordType == endpoint.RecordTypeMX || recordType == endpoint.RecordTypeSRV
+}
+
+// splitPriority splits the priority off the target of MX and SRV endpoints, e.g. "10 mail.example.com" into
+// the content "mail.example.com" and the priority "10". SRV content is written in the form formatSRVContent returns.
+// Other targets are returned as content without priority.
+func splitPriority(recordType string, target string) (content string, prio string) {
+	if !hasPriority(recordType) {
+		return target, ""
+	}
+	if recordType == endpoint.RecordTypeSRV {
+		if priority, weight, port, host, ok := parseSRV(target); ok {
+			return formatSRVContent(weight, port, host), strconv.Itoa(int(priority))
+		}
+	}
+	first, rest, found := strings.Cut(strings.TrimSpace(target), " ")
+	if _, err := strconv.ParseUint(first, 10, 16); !found || err != nil {
+		return target, ""
+	}
+	return strings.TrimSpace(rest), first
+}
+
+// recordTarget returns the target of a record in the form external-dns uses, with the priority of MX and SRV records
+// as plain integer in front of the content, CAA and SRV values normalized, TXT content as plain value and host names
+// in ASCII.
+func recordTarget(rec pb.Record) string {
+	if rec.Type == recordTypeCAA {
+		return normalizeCAA(rec.Content)
+	}
+	if rec.Type == endpoint.RecordTypeSRV {
+		return srvTarget(rec)
+	}
+	if rec.Type == endpoint.RecordTypeTXT {
+		return parseTXT(rec.Content)
+	}
+	content := asciiTarget(rec.Type, rec.Content)
+	prio := normalizeNumber(rec.Prio)
+	if !hasPriority(rec.Type) || prio == "" {
+		return content
+	}
+	return prio + " " + content
+}
@@ -0,0 +1,83 @@
+package porkbun
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ProviderSpecificProtected is the provider specific property marking the records of an endpoint as protected
+// against deletion when set to true. It can be set on sources with the annotation
+// external-dns.alpha.kubernetes.io/webhook-porkbun-protected.
+const ProviderSpecificProtected = "webhook/porkbun-protected"
+
+// notesKeyProtected marks a record as protected in the metadata of its notes, so the protection outlives its source.
+const notesKeyProtected = "protected"
+
+// endpointProtected reports whether the endpoint asks for its records to be protected.
+func endpointProtected(ep *endpoint.Endpoint) bool {
+	value, _ := ep.GetProviderSpecificProperty(ProviderSpecificProtected)
+	return strings.EqualFold(strings.TrimSpace(value), "true")
+}
+
+// recordProtected reports whether the notes of the record mark it as protected.
+func recordProtected(rec pb.Record) bool {
+	return parseNotes(rec.Notes)[notesKeyProtected] == "true"
+}
+
+// setProtectedProperty lists the protection of a record on its endpoint, so it compares equal to the desired endpoint
+// of an annotated source and an update is planned once the annotation is removed.
+func setProtectedProperty(ep *endpoint.Endpoint, rec pb.Record) {
+	if recordProtected(rec) {
+		ep.SetProviderSpecificProperty(ProviderSpecificProtected, "true")
+	}
+}
+
+// protectRecords drops the deletes and destructive updates of protected endpoints. An endpoint is protected if it
+// carries the protected property or any record of its name and type in the zone is marked protected. Updates are
+// destructive if they change the record type or drop targets, both delete records; edits of targets in place and
+// updates removing the protection go through, so a protection is lifted by removing the annotation from the source.
+func (p *PorkbunProvider) protectRecords(ctx context.Context, zone string, c *plan.Changes, recs []pb.Record, skipped skipSummary) {
+	protected := func(ep *endpoint.Endpoint) bool {
+		if endpointProtected(ep) {
+			return true
+		}
+		name := normalizeName(ep.DNSName)
+		for _, rec := range recs {
+			if rec.Type == ep.RecordType && normalizeName(rec.Name) == name && recordProtected(rec) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var updateOld, updateNew []*endpoint.Endpoint
+	for i, ep := range c.UpdateOld {
+		if i < len(c.UpdateNew) {
+			desired := c.UpdateNew[i]
+			destructive := desired.RecordType != ep.RecordType || len(desired.Targets) < len(ep.Targets)
+			if destructive && endpointProtected(desired) && protected(ep) {
+				p.logger.WarnContext(ctx, "skipping update deleting protected records", "zone", zone, "endpoint", ep.String(), "desired", desired.String())
+				skipped.skip(skipReasonProtected, 1)
+				continue
+			}
+			updateNew = append(updateNew, desired)
+		}
+		updateOld = append(updateOld, ep)
+	}
+	c.UpdateOld, c.UpdateNew = updateOld, updateNew
+
+	deletes := make([]*endpoint.Endpoint, 0, len(c.Delete))
+	for _, ep := range c.Delete {
+		if protected(ep) {
+			p.logger.WarnContext(ctx, "skipping delete of protected records", "zone", zone, "endpoint", ep.String())
+			skipped.skip(skipReasonProtected, 1)
+			continue
+		}
+		deletes = append(deletes, ep)
+	}
+	c.Delete = deletes
+}
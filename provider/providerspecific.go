@@ -0,0 +1,75 @@
+package porkbun
+
+import (
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ProviderSpecificNotes is the provider specific property carrying the notes an operator wrote into a Porkbun record.
+// It can be set on sources with the annotation external-dns.alpha.kubernetes.io/webhook-porkbun-notes.
+const ProviderSpecificNotes = "webhook/porkbun-notes"
+
+// cleanNotes returns notes in the form they are written and listed in: on a single line, with the marker of the
+// metadata defused, so notes pushed from a source compare equal to the notes listed back.
+func cleanNotes(notes string) string {
+	notes = strings.ReplaceAll(notes, notesPrefix, strings.TrimSuffix(notesPrefix, ":"))
+	return strings.Join(strings.Fields(notes), " ")
+}
+
+// setNotesProperty lists the notes an operator wrote into the record on the endpoint, so they survive a round trip
+// through external-dns.
+func setNotesProperty(ep *endpoint.Endpoint, notes string) {
+	if operator := cleanNotes(operatorNotes(notes)); operator != "" {
+		ep.SetProviderSpecificProperty(ProviderSpecificNotes, operator)
+	}
+}
+
+// cleanNotesProperty brings the notes property of a desired endpoint into the form it is listed in. A property without
+// notes is dropped, it would never be listed back.
+func cleanNotesProperty(ep *endpoint.Endpoint) {
+	notes, ok := ep.GetProviderSpecificProperty(ProviderSpecificNotes)
+	if !ok {
+		return
+	}
+	if notes = cleanNotes(notes); notes != "" {
+		ep.SetProviderSpecificProperty(ProviderSpecificNotes, notes)
+		return
+	}
+	removeProviderSpecificProperty(ep, ProviderSpecificNotes)
+}
+
+// removeProviderSpecificProperty drops the provider specific property with the name from the endpoint.
+func removeProviderSpecificProperty(ep *endpoint.Endpoint, name string) {
+	kept := ep.ProviderSpecific[:0]
+	for _, property := range ep.ProviderSpecific {
+		if property.Name != name {
+			kept = append(kept, property)
+		}
+	}
+	ep.ProviderSpecific = kept
+}
+
+// inheritNotesProperty gives a desired endpoint without notes property the notes of its current records in the cache.
+// external-dns plans an update whenever the provider specific properties of the current and desired endpoint
+// differ, so without this every listed note would cause an update in every sync.
+func (p *PorkbunProvider) inheritNotesProperty(ep *endpoint.Endpoint, zones []string) {
+	if _, ok := ep.GetProviderSpecificProperty(ProviderSpecificNotes); ok {
+		return
+	}
+	zone := endpointZoneName(ep, zones)
+	if zone == "" {
+		return
+	}
+	cached, ok := p.cache.get(zone)
+	if !ok {
+		return
+	}
+	name := normalizeName(ep.DNSName)
+	for _, rec := range cached.records {
+		if rec.Type == ep.RecordType && strings.EqualFold(listedName(rec.Name, zone), name) {
+			setNotesProperty(ep, rec.Notes)
+			return
+		}
+	}
+}
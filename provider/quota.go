@@ -0,0 +1,117 @@
+package porkbun
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// accountZone is the zone label used for API calls that are not bound to a zone, like ping.
+const accountZone = "_account"
+
+// defaultAPICallWarningThreshold is the default number of API calls per zone and hour that triggers a warning.
+const defaultAPICallWarningThreshold = 1000
+
+// apiUsageWindow is the rolling window API calls are counted in.
+const apiUsageWindow = time.Hour
+
+// apiUsage tracks the API calls made per zone within a rolling hour.
+type apiUsage struct {
+	mu          sync.Mutex
+	calls       map[string][]time.Time
+	warned      map[string]bool
+	warnPerHour int
+	logger      *slog.Logger
+	clock       Clock
+}
+
+func newAPIUsage(warnPerHour int, clock Clock, logger *slog.Logger) *apiUsage {
+	return &apiUsage{
+		calls:       map[string][]time.Time{},
+		warned:      map[string]bool{},
+		warnPerHour: warnPerHour,
+		logger:      logger,
+		clock:       clock,
+	}
+}
+
+// record counts a single API call against the zone.
+// Once the calls of a zone within the last hour reach the warning threshold a warning is logged,
+// it is logged again only after the zone dropped below the threshold.
+func (u *apiUsage) record(ctx context.Context, zone string, operation string) {
+	counter := apiCallsTotal.WithLabelValues(zone, operation)
+	if id := correlationID(ctx); id != "" {
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{"correlation_id": id})
+	} else {
+		counter.Inc()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.clock.Now()
+	calls := append(prune(u.calls[zone], now.Add(-apiUsageWindow)), now)
+	u.calls[zone] = calls
+
+	if u.warnPerHour <= 0 {
+		return
+	}
+	if len(calls) < u.warnPerHour {
+		u.warned[zone] = false
+		return
+	}
+	if !u.warned[zone] {
+		u.warned[zone] = true
+		u.logger.Warn("approaching Porkbun API limits", "zone", zone, "callsLastHour", len(calls), "threshold", u.warnPerHour)
+	}
+}
+
+// lastHour returns the number of API calls made for the zone within the last hour.
+func (u *apiUsage) lastHour(zone string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	calls := prune(u.calls[zone], u.clock.Now().Add(-apiUsageWindow))
+	u.calls[zone] = calls
+	return len(calls)
+}
+
+// allLastHour returns the number of API calls made within the last hour for every zone that was called.
+func (u *apiUsage) allLastHour() map[string]int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	since := u.clock.Now().Add(-apiUsageWindow)
+	counts := make(map[string]int, len(u.calls))
+	for zone, calls := range u.calls {
+		calls = prune(calls, since)
+		u.calls[zone] = calls
+		counts[zone] = len(calls)
+	}
+	return counts
+}
+
+// atThreshold reports whether the API calls of any zone within the last hour reached the warning threshold.
+func (u *apiUsage) atThreshold() bool {
+	if u.warnPerHour <= 0 {
+		return false
+	}
+	for _, calls := range u.allLastHour() {
+		if calls >= u.warnPerHour {
+			return true
+		}
+	}
+	return false
+}
+
+// prune drops all timestamps before since, the timestamps are expected in ascending order.
+func prune(calls []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(calls) && calls[i].Before(since) {
+		i++
+	}
+	return calls[i:]
+}
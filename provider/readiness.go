@@ -0,0 +1,123 @@
+package porkbun
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ComponentStatus is the readiness of a single part of the provider.
+type ComponentStatus struct {
+	Ready  bool   `json:"ready"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Readiness is the readiness of the provider broken down by component.
+type Readiness struct {
+	Ready         bool                       `json:"ready"`
+	Components    map[string]ComponentStatus `json:"components"`
+	DegradedZones map[string]string          `json:"degradedZones"`
+	GoneZones     map[string]time.Time       `json:"goneZones"`
+}
+
+// providerHealth keeps the outcome of the latest login and zone fetches.
+type providerHealth struct {
+	mu            sync.RWMutex
+	loginChecked  bool
+	loginErr      error
+	degradedZones map[string]string
+}
+
+func newProviderHealth() *providerHealth {
+	return &providerHealth{degradedZones: map[string]string{}}
+}
+
+// setLogin records the result of the latest login.
+func (h *providerHealth) setLogin(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loginChecked = true
+	h.loginErr = err
+}
+
+// setZone records the result of the latest fetch of a zone.
+func (h *providerHealth) setZone(zone string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.degradedZones[zone] = err.Error()
+		return
+	}
+	delete(h.degradedZones, zone)
+}
+
+// Readiness reports the readiness of the credentials, the zone cache, the API usage and the zones.
+func (p *PorkbunProvider) Readiness() Readiness {
+	components := map[string]ComponentStatus{}
+
+	p.health.mu.RLock()
+	switch {
+	case p.dryRun:
+		components["credentials"] = ComponentStatus{Ready: true, Detail: "dry run"}
+	case !p.health.loginChecked:
+		components["credentials"] = ComponentStatus{Ready: false, Detail: "not verified yet"}
+	case p.health.loginErr != nil:
+		components["credentials"] = ComponentStatus{Ready: false, Detail: p.health.loginErr.Error()}
+	default:
+		components["credentials"] = ComponentStatus{Ready: true}
+	}
+	degraded := make(map[string]string, len(p.health.degradedZones))
+	for zone, reason := range p.health.degradedZones {
+		degraded[zone] = reason
+	}
+	p.health.mu.RUnlock()
+
+	zones := p.domainFilter.Zones()
+	gone := p.gone.list()
+	switch {
+	case !p.warmedUp.Load():
+		components["cache"] = ComponentStatus{Ready: false, Detail: "warming up"}
+	case !p.dryRun && !p.cache.complete(p.activeZones(zones)):
+		components["cache"] = ComponentStatus{Ready: true, Detail: "warm-up timed out, some zones were never fetched"}
+	default:
+		components["cache"] = ComponentStatus{Ready: true}
+	}
+
+	// the API usage is reported for information only, approaching the limit does not make the provider unready
+	busiest := 0
+	for _, zone := range append([]string{accountZone}, zones...) {
+		busiest = max(busiest, p.usage.lastHour(zone))
+	}
+	if p.usage.warnPerHour > 0 {
+		components["apiUsage"] = ComponentStatus{Ready: true, Detail: fmt.Sprintf("%d of %d calls per hour on the busiest zone", busiest, p.usage.warnPerHour)}
+	} else {
+		components["apiUsage"] = ComponentStatus{Ready: true, Detail: fmt.Sprintf("%d calls per hour on the busiest zone", busiest)}
+	}
+
+	// gone zones left the account on purpose, they are reported without making the provider unready
+	switch {
+	case len(degraded) > 0:
+		components["zones"] = ComponentStatus{Ready: false, Detail: fmt.Sprintf("%d of %d zones degraded", len(degraded), len(zones))}
+	case len(gone) > 0:
+		components["zones"] = ComponentStatus{Ready: true, Detail: fmt.Sprintf("%d of %d zones not in the Porkbun account", len(gone), len(zones))}
+	default:
+		components["zones"] = ComponentStatus{Ready: true}
+	}
+
+	ready := true
+	for _, status := range components {
+		ready = ready && status.Ready
+	}
+	return Readiness{Ready: ready, Components: components, DegradedZones: degraded, GoneZones: gone}
+}
+
+// ReadyzHandler serves the readiness as JSON, with status 503 Service Unavailable if any component is not ready.
+func (p *PorkbunProvider) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	readiness := p.Readiness()
+	if !readiness.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, readiness, p.logger)
+}
@@ -0,0 +1,74 @@
+package porkbun
+
+import (
+	"slices"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// porkbunRecordTypes are the record types Porkbun supports.
+var porkbunRecordTypes = []string{"A", "AAAA", "ALIAS", "CAA", "CNAME", "HTTPS", "MX", "NS", "SRV", "SVCB", "TLSA", "TXT"}
+
+// managesType reports whether records of the type are listed and written. All types are managed unless
+// --managed-record-types restricts them or --excluded-record-types excludes them.
+func (p *PorkbunProvider) managesType(recordType string) bool {
+	if slices.Contains(p.excludedTypes, recordType) {
+		return false
+	}
+	return len(p.managedTypes) == 0 || slices.Contains(p.managedTypes, recordType)
+}
+
+// rejectUnmanagedTypes drops the desired endpoints of types that Porkbun does not support or that are not managed,
+// so external-dns neither creates nor deletes records of other types.
+func (p *PorkbunProvider) rejectUnmanagedTypes(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	managed := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if !slices.Contains(porkbunRecordTypes, ep.RecordType) {
+			p.logger.Warn("rejecting endpoint of a record type Porkbun does not support", "endpoint", ep.DNSName, "type", ep.RecordType)
+			continue
+		}
+		if !p.managesType(ep.RecordType) {
+			p.logger.Debug("rejecting endpoint of unmanaged record type", "endpoint", ep.DNSName, "type", ep.RecordType)
+			continue
+		}
+		managed = append(managed, ep)
+	}
+	return managed
+}
+
+// rejectUnmanagedChanges drops the changes to endpoints of types that are not managed and counts them in skipped,
+// so records of these types are never written even if a client sends changes for them. Updates are dropped with
+// their old endpoint, so the old and new endpoints stay paired.
+func (p *PorkbunProvider) rejectUnmanagedChanges(changes *plan.Changes, skipped skipSummary) *plan.Changes {
+	if len(p.managedTypes) == 0 && len(p.excludedTypes) == 0 {
+		return changes
+	}
+	filter := func(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+		managed := p.rejectUnmanagedTypes(endpoints)
+		skipped.skip(skipReasonUnmanagedType, len(endpoints)-len(managed))
+		return managed
+	}
+	filtered := &plan.Changes{
+		Create: filter(changes.Create),
+		Delete: filter(changes.Delete),
+	}
+	for i, ep := range changes.UpdateNew {
+		update := []*endpoint.Endpoint{ep}
+		if i < len(changes.UpdateOld) {
+			update = append(update, changes.UpdateOld[i])
+		}
+		if len(p.rejectUnmanagedTypes(update)) < len(update) {
+			skipped.skip(skipReasonUnmanagedType, 1)
+			continue
+		}
+		filtered.UpdateNew = append(filtered.UpdateNew, ep)
+		if i < len(changes.UpdateOld) {
+			filtered.UpdateOld = append(filtered.UpdateOld, changes.UpdateOld[i])
+		}
+	}
+	if len(changes.UpdateOld) > len(changes.UpdateNew) {
+		filtered.UpdateOld = append(filtered.UpdateOld, p.rejectUnmanagedTypes(changes.UpdateOld[len(changes.UpdateNew):])...)
+	}
+	return filtered
+}
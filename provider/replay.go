@@ -0,0 +1,21 @@
+package porkbun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ReadChanges reads a captured ApplyChanges payload, the JSON body external-dns posts to /records.
+func ReadChanges(r io.Reader) (*plan.Changes, error) {
+	var changes plan.Changes
+	if err := json.NewDecoder(r).Decode(&changes); err != nil {
+		return nil, fmt.Errorf("unable to decode changes: %v", err)
+	}
+	if !changes.HasChanges() {
+		return nil, fmt.Errorf("payload contains no changes")
+	}
+	return &changes, nil
+}
@@ -0,0 +1,67 @@
+package porkbun
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// Outcomes of a record change, as reported per change and counted per sync.
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+)
+
+// changeResults counts the outcomes of the record changes of one sync by operation.
+type changeResults struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+type changeResultsKey struct{}
+
+// withChangeResults returns a context counting the outcomes of the record changes applied with it.
+func withChangeResults(ctx context.Context) (context.Context, *changeResults) {
+	results := &changeResults{counts: map[string]int{}}
+	return context.WithValue(ctx, changeResultsKey{}, results), results
+}
+
+// attrs returns the counts as log attributes: the records created, updated and deleted, and the failed changes.
+func (r *changeResults) attrs() []any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failed := r.counts["create "+outcomeFailed] + r.counts["update "+outcomeFailed] + r.counts["delete "+outcomeFailed]
+	return []any{
+		"created", r.counts["create "+outcomeSucceeded],
+		"updated", r.counts["update "+outcomeSucceeded],
+		"deleted", r.counts["delete "+outcomeSucceeded],
+		"failed", failed,
+	}
+}
+
+// reportChange logs the result of a change to a record started at start, one line per record, and counts it in the
+// results carried by the context, if any. The ID is the one Porkbun assigned to a created record or the one of the
+// changed record, if known.
+func (p *PorkbunProvider) reportChange(ctx context.Context, zone string, operation string, record pb.Record, start time.Time, err error) {
+	outcome := outcomeSucceeded
+	level := slog.LevelInfo
+	attrs := []any{"operation", operation, "name", recordFQDN(record.Name, zone), "type", record.Type, "zone", zone}
+	if err != nil {
+		outcome = outcomeFailed
+		level = slog.LevelWarn
+	}
+	attrs = append(attrs, "outcome", outcome, "id", record.ID, "latency", p.clock.Now().Sub(start).String())
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	p.logger.Log(ctx, level, "record change", attrs...)
+
+	if results, _ := ctx.Value(changeResultsKey{}).(*changeResults); results != nil {
+		results.mu.Lock()
+		results.counts[operation+" "+outcome]++
+		results.mu.Unlock()
+	}
+}
@@ -0,0 +1,233 @@
+package porkbun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// Classes of failed API calls a retry policy can retry.
+const (
+	// RetryOnNetwork are calls that did not get an answer, e.g. refused connections or timeouts.
+	RetryOnNetwork = "network"
+	// RetryOnRateLimit are calls answered with 429 Too Many Requests.
+	RetryOnRateLimit = "rate_limit"
+	// RetryOnServerError are calls answered with a 5xx status.
+	RetryOnServerError = "server_error"
+)
+
+// retryClasses are all classes a retry policy can retry.
+var retryClasses = []string{RetryOnNetwork, RetryOnRateLimit, RetryOnServerError}
+
+// RetryPolicy configures how a kind of API call is retried. Calls are not retried unless Attempts is above 1.
+// The delay before the nth retry is BaseDelay doubled n-1 times, at most MaxDelay.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryOn are the classes of failures that are retried, all other failures are returned at once
+	RetryOn []string
+}
+
+// RetryPolicies are the retry policies per kind of API call. Reads are pings and zone listings, updates are edits.
+type RetryPolicies struct {
+	Read   RetryPolicy
+	Create RetryPolicy
+	Update RetryPolicy
+	Delete RetryPolicy
+}
+
+// retries returns true if any kind of call is retried.
+func (r RetryPolicies) retries() bool {
+	for _, policy := range []RetryPolicy{r.Read, r.Create, r.Update, r.Delete} {
+		if policy.Attempts > 1 && len(policy.RetryOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicyFile is a retry policy as written in the retry policy file, with delays like "500ms".
+type retryPolicyFile struct {
+	Attempts  int      `json:"attempts"`
+	BaseDelay string   `json:"baseDelay"`
+	MaxDelay  string   `json:"maxDelay"`
+	RetryOn   []string `json:"retryOn"`
+}
+
+// LoadRetryPolicies reads the retry policies from a JSON file holding an object with a policy per kind of call:
+// read, create, update and delete. Kinds without a policy are not retried.
+func LoadRetryPolicies(path string) (RetryPolicies, error) {
+	var policies RetryPolicies
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policies, fmt.Errorf("unable to read retry policies: %v", err)
+	}
+	var file map[string]retryPolicyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return policies, fmt.Errorf("unable to parse retry policies '%s': %v", path, err)
+	}
+
+	targets := map[string]*RetryPolicy{"read": &policies.Read, "create": &policies.Create, "update": &policies.Update, "delete": &policies.Delete}
+	for kind, f := range file {
+		target, ok := targets[kind]
+		if !ok {
+			return policies, fmt.Errorf("retry policies '%s': unknown kind of call %q, must be read, create, update or delete", path, kind)
+		}
+		policy, err := f.policy()
+		if err != nil {
+			return policies, fmt.Errorf("retry policies '%s': %s: %v", path, kind, err)
+		}
+		*target = policy
+	}
+	return policies, nil
+}
+
+// policy validates the policy of the file and converts it.
+func (f retryPolicyFile) policy() (RetryPolicy, error) {
+	policy := RetryPolicy{Attempts: f.Attempts, RetryOn: f.RetryOn}
+	var err error
+	if f.BaseDelay != "" {
+		if policy.BaseDelay, err = time.ParseDuration(f.BaseDelay); err != nil {
+			return policy, fmt.Errorf("invalid baseDelay: %v", err)
+		}
+	}
+	policy.MaxDelay = policy.BaseDelay
+	if f.MaxDelay != "" {
+		if policy.MaxDelay, err = time.ParseDuration(f.MaxDelay); err != nil {
+			return policy, fmt.Errorf("invalid maxDelay: %v", err)
+		}
+	}
+	switch {
+	case policy.Attempts < 0:
+		return policy, fmt.Errorf("attempts must not be negative, got %d", policy.Attempts)
+	case policy.BaseDelay < 0 || policy.MaxDelay < policy.BaseDelay:
+		return policy, fmt.Errorf("delays must not be negative and maxDelay must be at least baseDelay")
+	}
+	for _, class := range policy.RetryOn {
+		if !slices.Contains(retryClasses, class) {
+			return policy, fmt.Errorf("unknown retryOn class %q, must be one of %s", class, strings.Join(retryClasses, ", "))
+		}
+	}
+	return policy, nil
+}
+
+// retryClass classifies a failed API call.
+// returns empty string for failures that are never retried, like errors reported by Porkbun or canceled calls
+func retryClass(ctx context.Context, err error) string {
+	var serverErr *pb.ServerError
+	var status pb.Status
+	switch {
+	case ctx.Err() != nil, errors.Is(err, context.Canceled):
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		// the caller still waits, so only the deadline of this attempt ran out
+		return RetryOnNetwork
+	case errors.As(err, &serverErr):
+		switch {
+		case serverErr.StatusCode == http.StatusTooManyRequests:
+			return RetryOnRateLimit
+		case serverErr.StatusCode >= http.StatusInternalServerError:
+			return RetryOnServerError
+		}
+		return ""
+	case errors.As(err, &status):
+		return ""
+	default:
+		return RetryOnNetwork
+	}
+}
+
+// retryingClient retries failed calls according to the policy of their kind before handing the failure on.
+type retryingClient struct {
+	client   porkbunClient
+	policies RetryPolicies
+	logger   *slog.Logger
+	// sleep waits before a retry, it returns early with the error of the context
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryingClient(client porkbunClient, policies RetryPolicies, logger *slog.Logger) *retryingClient {
+	return &retryingClient{client: client, policies: policies, logger: logger, sleep: sleepContext}
+}
+
+// sleepContext waits for the duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do runs the call until it succeeds, fails in a way the policy does not retry, or runs out of attempts.
+func (c *retryingClient) do(ctx context.Context, policy RetryPolicy, operation string, domain string, call func() error) error {
+	delay := policy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= policy.Attempts {
+			return err
+		}
+		class := retryClass(ctx, err)
+		if class == "" || !slices.Contains(policy.RetryOn, class) {
+			return err
+		}
+		c.logger.WarnContext(ctx, "retrying failed Porkbun API call", "operation", operation, "zone", domain, "attempt", attempt, "class", class, "delay", delay, "error", err.Error())
+		apiRetriesTotal.WithLabelValues(operation, class).Inc()
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		delay = min(2*delay, policy.MaxDelay)
+	}
+}
+
+func (c *retryingClient) Ping(ctx context.Context) (string, error) {
+	var ip string
+	err := c.do(ctx, c.policies.Read, "ping", "", func() (err error) {
+		ip, err = c.client.Ping(ctx)
+		return err
+	})
+	return ip, err
+}
+
+func (c *retryingClient) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	var id int
+	err := c.do(ctx, c.policies.Create, "create", domain, func() (err error) {
+		id, err = c.client.CreateRecord(ctx, domain, record)
+		return err
+	})
+	return id, err
+}
+
+func (c *retryingClient) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	return c.do(ctx, c.policies.Update, "edit", domain, func() error {
+		return c.client.EditRecord(ctx, domain, id, record)
+	})
+}
+
+func (c *retryingClient) DeleteRecord(ctx context.Context, domain string, id int) error {
+	return c.do(ctx, c.policies.Delete, "delete", domain, func() error {
+		return c.client.DeleteRecord(ctx, domain, id)
+	})
+}
+
+func (c *retryingClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	var records []pb.Record
+	err := c.do(ctx, c.policies.Read, "retrieve", domain, func() (err error) {
+		records, err = c.client.RetrieveRecords(ctx, domain)
+		return err
+	})
+	return records, err
+}
@@ -0,0 +1,128 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// rollbackJournal collects the changes applied to a zone within a sync, so they can be undone if a later change of
+// the zone fails.
+type rollbackJournal struct {
+	zone string
+	// previous are the records of the zone before the changes by ID, to restore deleted and edited records
+	previous map[string]pb.Record
+
+	mu      sync.Mutex
+	applied []appliedChange
+}
+
+// appliedChange is a change applied to a record, created records carry the ID Porkbun assigned to them.
+type appliedChange struct {
+	action string
+	record pb.Record
+}
+
+type rollbackJournalKey struct{}
+
+// withRollbackJournal returns a context collecting the changes applied to the zone into a new journal.
+func withRollbackJournal(ctx context.Context, zone string, recs []pb.Record) (context.Context, *rollbackJournal) {
+	journal := &rollbackJournal{zone: zone, previous: make(map[string]pb.Record, len(recs))}
+	for _, rec := range recs {
+		journal.previous[rec.ID] = rec
+	}
+	return context.WithValue(ctx, rollbackJournalKey{}, journal), journal
+}
+
+// recordRollbackJournal adds an applied change to the journal carried by the context, if any.
+func recordRollbackJournal(ctx context.Context, action string, record pb.Record) {
+	journal, _ := ctx.Value(rollbackJournalKey{}).(*rollbackJournal)
+	if journal == nil {
+		return
+	}
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	journal.applied = append(journal.applied, appliedChange{action: action, record: record})
+}
+
+// rollback undoes the changes in the journal, newest first: created records are deleted, deleted records are
+// created again and edited records get their previous content back. It is best effort, the remaining changes are
+// still undone if one fails, and the undo is not recorded in the journal again. Records created again get new IDs.
+// returns the joined errors of the changes that could not be undone
+func (p *PorkbunProvider) rollback(ctx context.Context, journal *rollbackJournal) error {
+	journal.mu.Lock()
+	applied := journal.applied
+	journal.applied = nil
+	journal.mu.Unlock()
+
+	// The sync may have failed with its context, the undo must not
+	ctx = context.WithValue(context.WithoutCancel(ctx), rollbackJournalKey{}, (*rollbackJournal)(nil))
+	zone := journal.zone
+	var errs []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+		record := change.record
+		var err error
+		switch change.action {
+		case "create":
+			id, ok := parseRecordID(record.ID)
+			if !ok {
+				err = fmt.Errorf("unable to parse record ID '%s'", record.ID)
+				break
+			}
+			if err = p.client.DeleteRecord(ctx, zone, id); err == nil || isRecordNotFound(err) {
+				err = nil
+				p.recordChange(ctx, zone, "delete", record)
+			}
+		case "delete":
+			if previous, ok := journal.previous[record.ID]; ok {
+				record = previous
+				record.Name = relativeName(previous.Name, zone)
+			}
+			record.ID = ""
+			var id int
+			if id, err = p.client.CreateRecord(ctx, zone, record); err == nil {
+				record.ID = strconv.Itoa(id)
+				p.recordChange(ctx, zone, "create", record)
+			}
+		case "update":
+			previous, ok := journal.previous[record.ID]
+			id, validID := parseRecordID(record.ID)
+			if !ok || !validID {
+				err = errors.New("previous content unknown")
+				break
+			}
+			previous.Name = relativeName(previous.Name, zone)
+			if err = p.client.EditRecord(ctx, zone, id, previous); err == nil {
+				p.recordChange(ctx, zone, "update", previous)
+			}
+		}
+		if err != nil {
+			p.logger.WarnContext(ctx, "unable to roll back change", "zone", zone, "action", change.action, "name", recordFQDN(record.Name, zone), "type", record.Type, "error", err.Error())
+			errs = append(errs, fmt.Errorf("unable to roll back %s of %s %s: %w", change.action, recordFQDN(record.Name, zone), record.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollbackZone undoes the changes applied to the zone after its changes failed with err.
+// returns err, noting whether the changes were rolled back
+func (p *PorkbunProvider) rollbackZone(ctx context.Context, journal *rollbackJournal, err error) error {
+	journal.mu.Lock()
+	n := len(journal.applied)
+	journal.mu.Unlock()
+	if n == 0 {
+		return err
+	}
+	p.logger.WarnContext(ctx, "changes failed, rolling back the applied changes", "zone", journal.zone, "changes", n, "error", err.Error())
+	if rollbackErr := p.rollback(ctx, journal); rollbackErr != nil {
+		rollbacksTotal.WithLabelValues(journal.zone, "rollback_failed").Inc()
+		return fmt.Errorf("%w, rollback failed: %v", err, rollbackErr)
+	}
+	rollbacksTotal.WithLabelValues(journal.zone, "rolled_back").Inc()
+	return fmt.Errorf("%w, the %d applied changes were rolled back", err, n)
+}
@@ -0,0 +1,70 @@
+package porkbun
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// Reasons endpoints are skipped for, used as reason label of skipped_endpoints_total.
+const (
+	// skipReasonNoZone is a change to an endpoint outside all managed zones.
+	skipReasonNoZone = "no_zone"
+	// skipReasonUnsupportedType is a listed record without a record type.
+	skipReasonUnsupportedType = "unsupported_type"
+	// skipReasonFiltered is a listed record whose name lies outside the zone it was listed for.
+	skipReasonFiltered = "filtered"
+	// skipReasonZoneGone is a change to a zone that is not in the Porkbun account any more.
+	skipReasonZoneGone = "zone_gone"
+	// skipReasonZoneLocked is a change to a zone whose lock record is held by another writer.
+	skipReasonZoneLocked = "zone_locked"
+	// skipReasonUnmanagedType is a change to an endpoint of a record type that is not managed.
+	skipReasonUnmanagedType = "unmanaged_type"
+	// skipReasonMaintenance is a change paused while the Porkbun API is in maintenance.
+	skipReasonMaintenance = "maintenance"
+	// skipReasonEmptyTargets is a change to an endpoint without targets.
+	skipReasonEmptyTargets = "empty_targets"
+	// skipReasonNotOwned is an update or delete of a record without a registry TXT record of external-dns.
+	skipReasonNotOwned = "not_owned"
+	// skipReasonProtected is a delete or destructive update of protected records.
+	skipReasonProtected = "protected"
+	// skipReasonDelegated is a change to an endpoint below a delegation point of its zone.
+	skipReasonDelegated = "delegated"
+	// skipReasonDuplicate is a create or delete of the same record as another change of the sync.
+	skipReasonDuplicate = "duplicate"
+	// skipReasonInvalidTarget is an endpoint rejected when adjusted since a target is invalid for its record type.
+	skipReasonInvalidTarget = "invalid_target"
+	// skipReasonCNAMETarget is a create or update of a CNAME or ALIAS record whose target --cname-target-check blocks.
+	skipReasonCNAMETarget = "cname_target"
+)
+
+// skipSummary counts the endpoints skipped during one sync by reason.
+// A nil summary counts nothing, e.g. when endpoints are converted again from the cache.
+type skipSummary map[string]int
+
+// skip counts an endpoint skipped for the reason.
+func (s skipSummary) skip(reason string, n int) {
+	if s == nil || n == 0 {
+		return
+	}
+	skippedEndpointsTotal.WithLabelValues(reason).Add(float64(n))
+	s[reason] += n
+}
+
+// report logs the skipped endpoints of the sync per reason in a single line,
+// so a misconfiguration like a missing domain filter is visible without debug logging.
+func (s skipSummary) report(ctx context.Context, logger *slog.Logger, operation string) {
+	if len(s) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(s))
+	for reason := range s {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	args := []any{"operation", operation}
+	for _, reason := range reasons {
+		args = append(args, reason, s[reason])
+	}
+	logger.WarnContext(ctx, "skipped endpoints", args...)
+}
@@ -0,0 +1,216 @@
+package porkbun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+const (
+	// defaultSnapshotMaxAge is the default age of cached zones beyond which they are not restored from a snapshot.
+	defaultSnapshotMaxAge = 10 * time.Minute
+	// defaultSnapshotInterval is the default minimum time between two snapshot writes.
+	defaultSnapshotInterval = time.Minute
+	// snapshotTimeout limits loading or saving a snapshot.
+	snapshotTimeout = 30 * time.Second
+	// maxSnapshotBytes limits the decompressed size of a snapshot.
+	maxSnapshotBytes = 256 << 20
+)
+
+// SnapshotStore keeps a snapshot of the zone cache, so a replica can restore the cache written by the previous
+// leader instead of fetching every zone again after a restart.
+type SnapshotStore interface {
+	// Load returns the latest snapshot, or nil if none has been written yet.
+	Load(ctx context.Context) ([]byte, error)
+	// Save replaces the snapshot.
+	Save(ctx context.Context, snapshot []byte) error
+}
+
+// cacheSnapshot is the content of a snapshot, stored as gzipped JSON.
+type cacheSnapshot struct {
+	WrittenAt time.Time               `json:"writtenAt"`
+	Zones     map[string]snapshotZone `json:"zones"`
+}
+
+// snapshotZone holds the cached records of one zone together with when they were fetched from Porkbun.
+type snapshotZone struct {
+	FetchedAt time.Time   `json:"fetchedAt"`
+	Records   []pb.Record `json:"records"`
+}
+
+// snapshotState restores the zone cache from a snapshot at startup and writes the cache back after listings.
+type snapshotState struct {
+	store    SnapshotStore
+	maxAge   time.Duration
+	interval time.Duration
+
+	mu      sync.Mutex
+	saving  bool
+	savedAt time.Time
+	// restored holds the zones restored from the snapshot that were not listed since
+	restored map[string]bool
+}
+
+// encodeSnapshot serializes the cached zones as gzipped JSON.
+func encodeSnapshot(snapshot cacheSnapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		return nil, fmt.Errorf("unable to encode cache snapshot: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress cache snapshot: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSnapshot reads a snapshot written by encodeSnapshot.
+func decodeSnapshot(data []byte) (cacheSnapshot, error) {
+	var snapshot cacheSnapshot
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return snapshot, fmt.Errorf("unable to decompress cache snapshot: %v", err)
+	}
+	if err := json.NewDecoder(io.LimitReader(gz, maxSnapshotBytes)).Decode(&snapshot); err != nil {
+		return snapshot, fmt.Errorf("unable to decode cache snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// restoreSnapshot fills the zone cache with the zones of the latest snapshot that were fetched within the maximum age.
+// Restored zones are served from the cache by the next record listing instead of being fetched from Porkbun.
+// returns true if every active zone was restored
+func (p *PorkbunProvider) restoreSnapshot(ctx context.Context) bool {
+	s := &p.snapshots
+	if s.store == nil || p.dryRun {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+	data, err := s.store.Load(ctx)
+	if err != nil {
+		p.logger.WarnContext(ctx, "unable to load cache snapshot, fetching all zones", "error", err.Error())
+		return false
+	}
+	if data == nil {
+		p.logger.InfoContext(ctx, "no cache snapshot found, fetching all zones")
+		return false
+	}
+	if p.outputCipher != nil {
+		if data, err = p.outputCipher.open(data); err != nil {
+			p.logger.WarnContext(ctx, "ignoring cache snapshot that can't be decrypted", "error", err.Error())
+			return false
+		}
+	}
+	snapshot, err := decodeSnapshot(data)
+	if err != nil {
+		p.logger.WarnContext(ctx, "ignoring invalid cache snapshot", "error", err.Error())
+		return false
+	}
+
+	now := p.clock.Now()
+	zones := p.activeZones(p.domainFilter.Zones())
+	restored := map[string]bool{}
+	for _, zone := range zones {
+		z, ok := snapshot.Zones[zone]
+		if !ok || now.Sub(z.FetchedAt) > s.maxAge {
+			continue
+		}
+		p.cacheSnapshotZone(ctx, zone, z)
+		restored[zone] = true
+	}
+	s.mu.Lock()
+	s.restored = restored
+	s.mu.Unlock()
+
+	p.logger.InfoContext(ctx, "restored zone cache from snapshot", "writtenAt", snapshot.WrittenAt, "restored", len(restored), "zones", len(zones))
+	return len(restored) == len(zones)
+}
+
+// cacheSnapshotZone caches the records of a zone restored from a snapshot, keeping when they were fetched.
+func (p *PorkbunProvider) cacheSnapshotZone(ctx context.Context, zone string, z snapshotZone) {
+	for _, evicted := range p.cache.set(zone, z.Records, z.FetchedAt) {
+		p.logger.WarnContext(ctx, "cached records limit reached - evicted least recently synced zone", "zone", evicted, "limit", p.cache.maxRecords)
+	}
+}
+
+// restoredRecords returns the records of a zone restored from the snapshot once, if they are still within the maximum age.
+// returns false if the zone has to be fetched from Porkbun
+func (p *PorkbunProvider) restoredRecords(zone string) ([]pb.Record, bool) {
+	s := &p.snapshots
+	s.mu.Lock()
+	restored := s.restored[zone]
+	delete(s.restored, zone)
+	s.mu.Unlock()
+	if !restored {
+		return nil, false
+	}
+
+	cached, ok := p.cache.get(zone)
+	if !ok || p.clock.Now().Sub(cached.fetchedAt) > s.maxAge {
+		return nil, false
+	}
+	return cached.records, true
+}
+
+// saveSnapshot writes the cached records of all active zones to the snapshot store,
+// at most once per interval and only once every zone is cached.
+func (p *PorkbunProvider) saveSnapshot(ctx context.Context) {
+	s := &p.snapshots
+	if s.store == nil || p.dryRun {
+		return
+	}
+
+	now := p.clock.Now()
+	s.mu.Lock()
+	if s.saving || (!s.savedAt.IsZero() && now.Sub(s.savedAt) < s.interval) {
+		s.mu.Unlock()
+		return
+	}
+	s.saving = true
+	s.mu.Unlock()
+	saved := false
+	defer func() {
+		s.mu.Lock()
+		s.saving = false
+		if saved {
+			s.savedAt = now
+		}
+		s.mu.Unlock()
+	}()
+
+	cached, _, ok := p.cache.snapshot(p.activeZones(p.domainFilter.Zones()))
+	if !ok {
+		p.logger.DebugContext(ctx, "not all zones are cached, skipping cache snapshot")
+		return
+	}
+	snapshot := cacheSnapshot{WrittenAt: now, Zones: make(map[string]snapshotZone, len(cached))}
+	for zone, z := range cached {
+		snapshot.Zones[zone] = snapshotZone{FetchedAt: z.fetchedAt, Records: z.records}
+	}
+	data, err := encodeSnapshot(snapshot)
+	if err == nil && p.outputCipher != nil {
+		data, err = p.outputCipher.seal(data)
+	}
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+		defer cancel()
+		err = s.store.Save(ctx, data)
+	}
+	if err != nil {
+		cacheSnapshotWritesTotal.WithLabelValues("failed").Inc()
+		p.logger.WarnContext(ctx, "unable to write cache snapshot", "error", err.Error())
+		return
+	}
+	saved = true
+	cacheSnapshotWritesTotal.WithLabelValues("succeeded").Inc()
+	p.logger.DebugContext(ctx, "wrote cache snapshot", "zones", len(snapshot.Zones), "bytes", len(data))
+}
@@ -0,0 +1,377 @@
+package porkbun
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Snapshot locations, given as configmap://[namespace/]name or s3://bucket/key.
+const (
+	SnapshotSchemeConfigMap = "configmap"
+	SnapshotSchemeS3        = "s3"
+)
+
+const (
+	// serviceAccountDir holds the credentials Kubernetes mounts into every pod.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// snapshotConfigMapKey is the binaryData key of the snapshot in its ConfigMap.
+	snapshotConfigMapKey = "snapshot.json.gz"
+	// maxSnapshotResponseBytes limits the size of a snapshot read from a store.
+	maxSnapshotResponseBytes = 64 << 20
+)
+
+// snapshotLocation is a parsed snapshot location.
+type snapshotLocation struct {
+	scheme string
+	// namespace of the ConfigMap, empty for the namespace of the pod, or the bucket
+	container string
+	// name of the ConfigMap or key of the object
+	name string
+}
+
+// parseSnapshotLocation parses a snapshot location given as configmap://[namespace/]name or s3://bucket/key.
+func parseSnapshotLocation(location string) (snapshotLocation, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		return snapshotLocation{}, fmt.Errorf("%q is not of the form configmap://[namespace/]name or s3://bucket/key", location)
+	}
+	switch scheme {
+	case SnapshotSchemeConfigMap:
+		namespace, name, found := strings.Cut(rest, "/")
+		if !found {
+			namespace, name = "", rest
+		}
+		if name == "" || strings.Contains(name, "/") || (found && namespace == "") {
+			return snapshotLocation{}, fmt.Errorf("%q is not of the form configmap://[namespace/]name", location)
+		}
+		return snapshotLocation{scheme: scheme, container: namespace, name: name}, nil
+	case SnapshotSchemeS3:
+		bucket, key, _ := strings.Cut(rest, "/")
+		if bucket == "" || key == "" {
+			return snapshotLocation{}, fmt.Errorf("%q is not of the form s3://bucket/key", location)
+		}
+		return snapshotLocation{scheme: scheme, container: bucket, name: key}, nil
+	default:
+		return snapshotLocation{}, fmt.Errorf("unsupported snapshot location scheme %q, must be %s or %s", scheme, SnapshotSchemeConfigMap, SnapshotSchemeS3)
+	}
+}
+
+// NewSnapshotStore creates the store of a snapshot location given as configmap://[namespace/]name or s3://bucket/key.
+// ConfigMaps are accessed with the service account of the pod, S3 objects with the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL_S3 environment variables.
+func NewSnapshotStore(location string) (SnapshotStore, error) {
+	loc, err := parseSnapshotLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	if loc.scheme == SnapshotSchemeS3 {
+		return newS3Store(loc.container, loc.name), nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("ConfigMap snapshots require running in a Kubernetes pod, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	namespace := loc.container
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the namespace of the pod: %v", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	caFile := serviceAccountDir + "/ca.crt"
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file '%s'", caFile)
+	}
+	httpClient := &http.Client{
+		Timeout:   snapshotTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}},
+	}
+	return newConfigMapStore("https://"+net.JoinHostPort(host, port), namespace, loc.name, serviceAccountDir+"/token", httpClient), nil
+}
+
+// configMapStore keeps the snapshot in the binaryData of a ConfigMap, using the Kubernetes API with the token
+// of the service account. The service account needs get, create and update on the ConfigMap.
+// ConfigMaps are limited to 1 MiB, enough for the gzipped snapshot of several thousand records.
+type configMapStore struct {
+	apiURL     string
+	namespace  string
+	name       string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+func newConfigMapStore(apiURL, namespace, name, tokenFile string, httpClient *http.Client) *configMapStore {
+	return &configMapStore{apiURL: strings.TrimSuffix(apiURL, "/"), namespace: namespace, name: name, tokenFile: tokenFile, httpClient: httpClient}
+}
+
+// configMap is the part of a Kubernetes ConfigMap the store reads and writes.
+type configMap struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   configMapMetadata `json:"metadata"`
+	BinaryData map[string][]byte `json:"binaryData,omitempty"`
+}
+
+type configMapMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func (s *configMapStore) Load(ctx context.Context) ([]byte, error) {
+	cm, err := s.get(ctx)
+	if err != nil || cm == nil {
+		return nil, err
+	}
+	return cm.BinaryData[snapshotConfigMapKey], nil
+}
+
+// Save replaces the ConfigMap, or creates it if it does not exist yet.
+func (s *configMapStore) Save(ctx context.Context, snapshot []byte) error {
+	cm := configMap{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   configMapMetadata{Name: s.name, Namespace: s.namespace},
+		BinaryData: map[string][]byte{snapshotConfigMapKey: snapshot},
+	}
+	status, body, err := s.do(ctx, http.MethodPut, s.configMapsURL()+"/"+url.PathEscape(s.name), cm)
+	if err == nil && status == http.StatusNotFound {
+		status, body, err = s.do(ctx, http.MethodPost, s.configMapsURL(), cm)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		return fmt.Errorf("writing ConfigMap %s/%s returned status %d: %s", s.namespace, s.name, status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// get reads the ConfigMap.
+// returns nil if it does not exist
+func (s *configMapStore) get(ctx context.Context) (*configMap, error) {
+	status, body, err := s.do(ctx, http.MethodGet, s.configMapsURL()+"/"+url.PathEscape(s.name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("reading ConfigMap %s/%s returned status %d: %s", s.namespace, s.name, status, strings.TrimSpace(string(body)))
+	}
+	var cm configMap
+	if err := json.Unmarshal(body, &cm); err != nil {
+		return nil, fmt.Errorf("unable to decode ConfigMap %s/%s: %v", s.namespace, s.name, err)
+	}
+	return &cm, nil
+}
+
+func (s *configMapStore) configMapsURL() string {
+	return s.apiURL + "/api/v1/namespaces/" + url.PathEscape(s.namespace) + "/configmaps"
+}
+
+// do sends a request to the Kubernetes API, the token is read again for every request so rotated tokens are picked up.
+func (s *configMapStore) do(ctx context.Context, method, target string, payload any) (int, []byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("unable to encode ConfigMap: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.tokenFile != "" {
+		token, err := os.ReadFile(s.tokenFile)
+		if err != nil {
+			return 0, nil, fmt.Errorf("unable to read token file: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return doSnapshotRequest(s.httpClient, req)
+}
+
+// doSnapshotRequest sends a request to a snapshot store and reads the response.
+func doSnapshotRequest(client *http.Client, req *http.Request) (int, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to call snapshot store: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotResponseBytes))
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to read snapshot store response: %v", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// s3Store keeps the snapshot in an object of an S3 compatible object storage, addressed in path style
+// and authenticated with AWS Signature Version 4.
+type s3Store struct {
+	endpoint     string
+	region       string
+	bucket       string
+	key          string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	httpClient   *http.Client
+	clock        Clock
+}
+
+// newS3Store creates the store of an S3 object with the settings of the AWS environment variables.
+func newS3Store(bucket, key string) *s3Store {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &s3Store{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		region:       region,
+		bucket:       bucket,
+		key:          key,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		httpClient:   &http.Client{Timeout: snapshotTimeout},
+		clock:        systemClock{},
+	}
+}
+
+func (s *s3Store) Load(ctx context.Context) ([]byte, error) {
+	status, body, err := s.do(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("reading s3://%s/%s returned status %d: %s", s.bucket, s.key, status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func (s *s3Store) Save(ctx context.Context, snapshot []byte) error {
+	status, body, err := s.do(ctx, http.MethodPut, snapshot)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("writing s3://%s/%s returned status %d: %s", s.bucket, s.key, status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// do sends a signed request for the object.
+func (s *s3Store) do(ctx context.Context, method string, payload []byte) (int, []byte, error) {
+	path := "/" + s3Escape(s.bucket) + "/" + s3Escape(s.key)
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to create request: %v", err)
+	}
+	req.ContentLength = int64(len(payload))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	s.sign(req, path, payload)
+	return doSnapshotRequest(s.httpClient, req)
+}
+
+// sign adds the AWS Signature Version 4 headers to a request with an empty query.
+func (s *s3Store) sign(req *http.Request, path string, payload []byte) {
+	now := s.clock.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+	if s.accessKey == "" {
+		return
+	}
+
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonical := strings.Join([]string{req.Method, path, "", headers.String(), signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes a bucket or key for the request path and the signature, keeping only unreserved
+// characters and the slashes of keys.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-_.~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package porkbun
+
+import (
+	"strconv"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// parseSRV parses an SRV target in the form external-dns uses, "priority weight port target", e.g.
+// "10 5 5060 sip.example.com.". The fields may be separated by any whitespace.
+// returns false if the target is not a valid SRV target
+func parseSRV(target string) (priority uint16, weight uint16, port uint16, host string, ok bool) {
+	fields := strings.Fields(target)
+	if len(fields) != 4 {
+		return 0, 0, 0, "", false
+	}
+	var numbers [3]uint16
+	for i, field := range fields[:3] {
+		n, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return 0, 0, 0, "", false
+		}
+		numbers[i] = uint16(n)
+	}
+	return numbers[0], numbers[1], numbers[2], fields[3], true
+}
+
+// srvHost returns the target host of an SRV record in the form Porkbun stores it, normalized like names with
+// normalizeName. The null target "." of a service that is not available is kept.
+func srvHost(host string) string {
+	if host == "." {
+		return host
+	}
+	return normalizeName(host)
+}
+
+// formatSRVContent formats the content of a Porkbun SRV record, which keeps the priority in the prio field:
+// the weight, port and target host, e.g. "5 5060 sip.example.com".
+func formatSRVContent(weight uint16, port uint16, host string) string {
+	return strconv.Itoa(int(weight)) + " " + strconv.Itoa(int(port)) + " " + srvHost(host)
+}
+
+// normalizeSRV returns an SRV target in the form it is listed in, single spaced with the host as Porkbun stores it,
+// so the targets of endpoints and records compare equal. Targets that can't be parsed are returned unchanged.
+func normalizeSRV(target string) string {
+	priority, weight, port, host, ok := parseSRV(target)
+	if !ok {
+		return target
+	}
+	return strconv.Itoa(int(priority)) + " " + formatSRVContent(weight, port, host)
+}
+
+// srvTarget returns the target of a Porkbun SRV record in the form external-dns uses, with the prio field in front of
+// the content. Content that already starts with the priority, e.g. entered like that in the Porkbun console, is
+// taken as is if the prio field is empty or holds the same priority.
+func srvTarget(rec pb.Record) string {
+	content := asciiTarget(rec.Type, strings.Join(strings.Fields(rec.Content), " "))
+	prio := normalizeNumber(rec.Prio)
+	if fields := strings.Fields(content); len(fields) == 4 && (prio == "" || normalizeNumber(fields[0]) == prio) {
+		return normalizeSRV(content)
+	}
+	if prio == "" {
+		return content
+	}
+	return normalizeSRV(prio + " " + content)
+}
@@ -0,0 +1,70 @@
+package porkbun
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultStaleAfter is used when no staleness threshold has been configured.
+const defaultStaleAfter = 90 * 24 * time.Hour
+
+// StaleRecord describes a managed record that has not been written by this provider for a while.
+type StaleRecord struct {
+	Zone         string    `json:"zone"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	Content      string    `json:"content"`
+	LastModified time.Time `json:"lastModified"`
+	Age          string    `json:"age"`
+}
+
+// StaleRecords lists all managed records whose last write is older than olderThan.
+// The report is built from the records cached by the last sync, zones not fetched yet are skipped.
+// Records without a last-modified stamp are not managed by this provider and are skipped.
+func (p *PorkbunProvider) StaleRecords(olderThan time.Duration) []StaleRecord {
+	stale := make([]StaleRecord, 0)
+
+	for _, zone := range p.domainFilter.Zones() {
+		cached, ok := p.cache.get(zone)
+		if !ok {
+			p.logger.Debug("zone not cached yet - skipping in staleness report", "zone", zone)
+			continue
+		}
+		for _, rec := range cached.records {
+			modified, ok := lastModified(rec)
+			if !ok {
+				continue
+			}
+			age := p.since(modified)
+			if age < olderThan {
+				continue
+			}
+			stale = append(stale, StaleRecord{
+				Zone:         zone,
+				Name:         rec.Name,
+				Type:         rec.Type,
+				Content:      recordTarget(rec),
+				LastModified: modified,
+				Age:          age.Round(time.Second).String(),
+			})
+		}
+	}
+	return stale
+}
+
+// StalenessHandler serves the staleness report as JSON.
+// The threshold can be overridden per request with the olderThan query parameter (e.g. ?olderThan=720h).
+func (p *PorkbunProvider) StalenessHandler(w http.ResponseWriter, r *http.Request) {
+	olderThan := p.staleAfter
+	if value := r.URL.Query().Get("olderThan"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid olderThan value: %v", err), http.StatusBadRequest)
+			return
+		}
+		olderThan = d
+	}
+
+	writeJSON(w, p.StaleRecords(olderThan), p.logger)
+}
@@ -0,0 +1,95 @@
+package porkbun
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// recentChangesLimit is the number of record changes kept for the status overview.
+const recentChangesLimit = 50
+
+// ZoneStatus is the state of a managed zone as of the last sync.
+type ZoneStatus struct {
+	Zone     string    `json:"zone"`
+	Records  int       `json:"records"`
+	LastSync time.Time `json:"lastSync,omitempty"`
+	Degraded string    `json:"degraded,omitempty"`
+	Gone     bool      `json:"gone,omitempty"`
+}
+
+// RecordChange is a record written to Porkbun by the provider.
+type RecordChange struct {
+	Time    time.Time `json:"time"`
+	Owner   string    `json:"owner,omitempty"`
+	Zone    string    `json:"zone"`
+	Action  string    `json:"action"`
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Content string    `json:"content"`
+}
+
+// Status is a human-oriented overview of the provider: the managed zones and the latest record changes.
+type Status struct {
+	Zones         []ZoneStatus   `json:"zones"`
+	RecentChanges []RecordChange `json:"recentChanges"`
+}
+
+// changeLog keeps the latest record changes, newest last.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []RecordChange
+}
+
+// add records a successful write of the record in a sync of the external-dns owner ID.
+func (l *changeLog) add(now time.Time, owner string, zone string, action string, record pb.Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, RecordChange{
+		Time:    now,
+		Owner:   owner,
+		Zone:    zone,
+		Action:  action,
+		Name:    recordFQDN(record.Name, zone),
+		Type:    record.Type,
+		Content: recordTarget(record),
+	})
+	if len(l.changes) > recentChangesLimit {
+		l.changes = append([]RecordChange(nil), l.changes[len(l.changes)-recentChangesLimit:]...)
+	}
+}
+
+// list returns the recorded changes, newest first.
+func (l *changeLog) list() []RecordChange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := make([]RecordChange, len(l.changes))
+	for i, change := range l.changes {
+		changes[len(l.changes)-1-i] = change
+	}
+	return changes
+}
+
+// Status reports the managed zones with their cached record counts and health, and the latest record changes.
+func (p *PorkbunProvider) Status() Status {
+	p.health.mu.RLock()
+	degraded := make(map[string]string, len(p.health.degradedZones))
+	for zone, reason := range p.health.degradedZones {
+		degraded[zone] = reason
+	}
+	p.health.mu.RUnlock()
+	gone := p.gone.list()
+
+	zones := make([]ZoneStatus, 0)
+	for _, zone := range p.domainFilter.Zones() {
+		status := ZoneStatus{Zone: zone, Degraded: degraded[zone]}
+		_, status.Gone = gone[zone]
+		if cached, ok := p.cache.get(zone); ok {
+			status.Records = len(cached.records)
+			status.LastSync = cached.fetchedAt
+		}
+		zones = append(zones, status)
+	}
+	return Status{Zones: zones, RecentChanges: p.changes.list()}
+}
@@ -0,0 +1,118 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Modes of the CNAME target check.
+const (
+	TargetCheckOff   = "off"
+	TargetCheckWarn  = "warn"
+	TargetCheckBlock = "block"
+)
+
+// recordTypeALIAS is the Porkbun ALIAS record type, which points at a name like a CNAME.
+const recordTypeALIAS = "ALIAS"
+
+// checkCNAMETargets looks for CNAME and ALIAS records about to be written whose target lies outside the managed zones,
+// or inside a managed zone but neither exists there nor is created by the same changes.
+// Existence is checked against the records cached by the last listing, zones not cached yet are not checked.
+// In warn mode the problems are logged, in block mode the creates and updates with a problem are dropped and counted
+// in skipped, so the other changes are still applied.
+func (p *PorkbunProvider) checkCNAMETargets(ctx context.Context, zones []string, changes *plan.Changes, skipped skipSummary) *plan.Changes {
+	if p.targetCheck == "" || p.targetCheck == TargetCheckOff {
+		return changes
+	}
+
+	written := map[string]bool{}
+	for _, ep := range append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...) {
+		written[normalizeName(ep.DNSName)] = true
+	}
+	deleted := map[string]bool{}
+	for _, ep := range changes.Delete {
+		deleted[normalizeName(ep.DNSName)] = true
+	}
+
+	keep := func(kind string, ep *endpoint.Endpoint) bool {
+		if ep.RecordType != endpoint.RecordTypeCNAME && ep.RecordType != recordTypeALIAS {
+			return true
+		}
+		var problems []string
+		for _, target := range ep.Targets {
+			name := normalizeName(target)
+			zone := nameZone(name, zones)
+			switch {
+			case zone == "":
+				problems = append(problems, fmt.Sprintf("%s %s points at %s outside the managed zones", ep.RecordType, ep.DNSName, target))
+			case written[name]:
+			case deleted[name] || !p.cachedNameExists(zone, name):
+				problems = append(problems, fmt.Sprintf("%s %s points at %s which does not exist", ep.RecordType, ep.DNSName, target))
+			}
+		}
+		if len(problems) == 0 {
+			return true
+		}
+		if p.targetCheck != TargetCheckBlock {
+			for _, problem := range problems {
+				p.logger.WarnContext(ctx, "suspicious CNAME target", "problem", problem)
+			}
+			return true
+		}
+		p.logger.WarnContext(ctx, "skipping change of endpoint with invalid CNAME target", "type", kind, "problem", strings.Join(problems, "; "))
+		skipped.skip(skipReasonCNAMETarget, 1)
+		return false
+	}
+
+	checked := &plan.Changes{Delete: changes.Delete}
+	for _, ep := range changes.Create {
+		if keep("create", ep) {
+			checked.Create = append(checked.Create, ep)
+		}
+	}
+	for i, ep := range changes.UpdateNew {
+		if !keep("update", ep) {
+			continue
+		}
+		checked.UpdateNew = append(checked.UpdateNew, ep)
+		if i < len(changes.UpdateOld) {
+			checked.UpdateOld = append(checked.UpdateOld, changes.UpdateOld[i])
+		}
+	}
+	if len(changes.UpdateOld) > len(changes.UpdateNew) {
+		checked.UpdateOld = append(checked.UpdateOld, changes.UpdateOld[len(changes.UpdateNew):]...)
+	}
+	return checked
+}
+
+// cachedNameExists reports whether the cached records of the zone contain the name.
+// Zones not cached yet are assumed to contain every name.
+func (p *PorkbunProvider) cachedNameExists(zone string, name string) bool {
+	cached, ok := p.cache.get(zone)
+	if !ok {
+		return true
+	}
+	for _, rec := range cached.records {
+		if normalizeName(rec.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nameZone returns the longest managed zone containing the name.
+// returns empty string if the name is outside all zones
+func nameZone(name string, zones []string) string {
+	match := ""
+	for _, zone := range zones {
+		zone = normalizeName(zone)
+		if inZone(name, zone) && len(zone) > len(match) {
+			match = zone
+		}
+	}
+	return match
+}
@@ -0,0 +1,187 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// recordTemplate is a named bundle of records for a common setup, e.g. the mail records of a mail provider.
+type recordTemplate struct {
+	description string
+	// vars are the variables the records refer to as ${name}, they must be given when applying the template
+	vars    []string
+	records []templateRecord
+}
+
+// templateRecord is a record of a template, named relative to the zone with @ for the zone apex.
+// Targets may refer to the zone as ${zone} and to the variables of the template.
+type templateRecord struct {
+	name       string
+	recordType string
+	targets    []string
+}
+
+// recordTemplates are the templates applied by the apply-template command by name.
+var recordTemplates = map[string]recordTemplate{
+	"google-workspace": {
+		description: "Google Workspace mail: MX and SPF",
+		records: []templateRecord{
+			{"@", endpoint.RecordTypeMX, []string{"1 smtp.google.com"}},
+			{"@", endpoint.RecordTypeTXT, []string{"v=spf1 include:_spf.google.com ~all"}},
+		},
+	},
+	"fastmail": {
+		description: "Fastmail mail: MX, SPF and the DKIM CNAMEs",
+		records: []templateRecord{
+			{"@", endpoint.RecordTypeMX, []string{"10 in1-smtp.messagingengine.com", "20 in2-smtp.messagingengine.com"}},
+			{"@", endpoint.RecordTypeTXT, []string{"v=spf1 include:spf.messagingengine.com ?all"}},
+			{"fm1._domainkey", endpoint.RecordTypeCNAME, []string{"fm1.${zone}.dkimfm.com"}},
+			{"fm2._domainkey", endpoint.RecordTypeCNAME, []string{"fm2.${zone}.dkimfm.com"}},
+			{"fm3._domainkey", endpoint.RecordTypeCNAME, []string{"fm3.${zone}.dkimfm.com"}},
+		},
+	},
+	"github-pages": {
+		description: "GitHub Pages site at the zone apex and www, needs user=<GitHub user or organization>",
+		vars:        []string{"user"},
+		records: []templateRecord{
+			{"@", endpoint.RecordTypeA, []string{"185.199.108.153", "185.199.109.153", "185.199.110.153", "185.199.111.153"}},
+			{"@", endpoint.RecordTypeAAAA, []string{"2606:50c0:8000::153", "2606:50c0:8001::153", "2606:50c0:8002::153", "2606:50c0:8003::153"}},
+			{"www", endpoint.RecordTypeCNAME, []string{"${user}.github.io"}},
+		},
+	},
+}
+
+// RecordTemplates returns the names of the record templates with their descriptions.
+func RecordTemplates() map[string]string {
+	templates := make(map[string]string, len(recordTemplates))
+	for name, template := range recordTemplates {
+		templates[name] = template.description
+	}
+	return templates
+}
+
+// ParseTemplateVars parses the variables of a record template given as name=value.
+func ParseTemplateVars(values []string) (map[string]string, error) {
+	vars := make(map[string]string, len(values))
+	for _, value := range values {
+		name, content, found := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || name == "zone" {
+			return nil, fmt.Errorf("invalid template variable '%s', expected name=value", value)
+		}
+		vars[name] = strings.TrimSpace(content)
+	}
+	return vars, nil
+}
+
+// endpoints returns the records of the template in the zone as endpoints.
+func (t recordTemplate) endpoints(zone string, vars map[string]string) ([]*endpoint.Endpoint, error) {
+	var missing []string
+	for _, name := range t.vars {
+		if vars[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if name == "zone" {
+				return zone
+			}
+			return vars[name]
+		})
+	}
+	endpoints := make([]*endpoint.Endpoint, 0, len(t.records))
+	for _, record := range t.records {
+		name := zone
+		if record.name != "@" {
+			name = record.name + "." + zone
+		}
+		targets := make([]string, 0, len(record.targets))
+		for _, target := range record.targets {
+			targets = append(targets, expand(target))
+		}
+		endpoints = append(endpoints, endpoint.NewEndpoint(name, record.recordType, targets...))
+	}
+	return endpoints, nil
+}
+
+// TemplateChanges returns the changes that apply the named record template to the zone. Records of the template
+// that exist with other targets are updated, keeping their TTL; a TXT record of the template replaces the TXT record
+// of the same kind, e.g. the SPF record, and leaves the other TXT records alone. Records already in place are left out.
+func (p *PorkbunProvider) TemplateChanges(ctx context.Context, name string, zone string, vars map[string]string) (*plan.Changes, error) {
+	template, ok := recordTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown record template '%s'", name)
+	}
+	zone = normalizeName(zone)
+	if !slices.Contains(p.domainFilter.Zones(), zone) {
+		return nil, fmt.Errorf("zone '%s' is not in the domain filter", zone)
+	}
+	desired, err := template.endpoints(zone, vars)
+	if err != nil {
+		return nil, fmt.Errorf("record template '%s': %v", name, err)
+	}
+	desired, err = p.AdjustEndpoints(desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(desired) < len(template.records) {
+		return nil, fmt.Errorf("record template '%s' has records of types that are not managed", name)
+	}
+
+	current, err := p.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := &plan.Changes{}
+	for _, ep := range desired {
+		existing := matchingEndpoint(current, ep)
+		switch {
+		case existing == nil:
+			changes.Create = append(changes.Create, ep)
+		case !sameTargets(existing.Targets, ep.Targets):
+			updated := endpoint.NewEndpointWithTTL(existing.DNSName, existing.RecordType, existing.RecordTTL, ep.Targets...)
+			for key, value := range existing.Labels {
+				updated.Labels[key] = value
+			}
+			changes.UpdateOld = append(changes.UpdateOld, existing)
+			changes.UpdateNew = append(changes.UpdateNew, updated)
+		}
+	}
+	return changes, nil
+}
+
+// matchingEndpoint returns the current endpoint a template endpoint replaces: the endpoint with the same name and
+// type, for TXT endpoints the one whose target starts with the same tag, e.g. v=spf1.
+func matchingEndpoint(current []*endpoint.Endpoint, ep *endpoint.Endpoint) *endpoint.Endpoint {
+	for _, c := range current {
+		if c.DNSName != ep.DNSName || c.RecordType != ep.RecordType {
+			continue
+		}
+		if ep.RecordType == endpoint.RecordTypeTXT && !sameTXTKind(c.Targets, ep.Targets) {
+			continue
+		}
+		return c
+	}
+	return nil
+}
+
+// sameTXTKind reports whether both TXT targets start with the same tag, the text up to the first space.
+func sameTXTKind(a, b endpoint.Targets) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	tagA, _, _ := strings.Cut(a[0], " ")
+	tagB, _, _ := strings.Cut(b[0], " ")
+	return tagA == tagB
+}
@@ -0,0 +1,48 @@
+package porkbun
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// defaultRequestTimeout bounds a single Porkbun API call, so a hung call fails instead of stalling the sync.
+const defaultRequestTimeout = 30 * time.Second
+
+// timeoutClient gives every API call its own deadline before handing it to the wrapped client. It sits below the
+// retries, so each attempt gets the full timeout.
+type timeoutClient struct {
+	client  porkbunClient
+	timeout time.Duration
+}
+
+func (c *timeoutClient) Ping(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.Ping(ctx)
+}
+
+func (c *timeoutClient) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.CreateRecord(ctx, domain, record)
+}
+
+func (c *timeoutClient) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.EditRecord(ctx, domain, id, record)
+}
+
+func (c *timeoutClient) DeleteRecord(ctx context.Context, domain string, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.DeleteRecord(ctx, domain, id)
+}
+
+func (c *timeoutClient) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.RetrieveRecords(ctx, domain)
+}
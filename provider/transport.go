@@ -0,0 +1,118 @@
+package porkbun
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/common/version"
+)
+
+// headerTransport adds static headers and the correlation ID of the sync to every request sent to Porkbun.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if id := correlationID(req.Context()); id != "" {
+		req.Header.Set(CorrelationHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// userAgent builds the User-Agent sent to Porkbun, so Porkbun support can identify the traffic.
+func userAgent(clusterID string) string {
+	v := version.Version
+	if v == "" {
+		// built without the version ldflags, e.g. by go build or go test
+		v = "dev"
+	}
+	ua := "external-dns-porkbun-webhook/" + v
+	if clusterID != "" {
+		ua += " (cluster=" + clusterID + ")"
+	}
+	return ua
+}
+
+// ParseRequestHeaders parses extra request headers given as "Name: value".
+func ParseRequestHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		name, content, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid request header '%s', expected Name: value", value)
+		}
+		headers.Add(name, strings.TrimSpace(content))
+	}
+	return headers, nil
+}
+
+// withHeaders wraps the transport of the HTTP client so every request carries the headers.
+func withHeaders(client *http.Client, headers http.Header) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &headerTransport{base: base, headers: headers}
+}
+
+// errResponseTooLarge is returned when a response of the Porkbun API exceeds the configured size limit.
+var errResponseTooLarge = errors.New("response exceeds size limit")
+
+// limitTransport fails reading response bodies beyond maxBytes, so a huge zone can't exhaust the memory.
+type limitTransport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBytes, limit: t.maxBytes}
+	return resp, nil
+}
+
+// limitedBody reads up to limit bytes and fails with errResponseTooLarge instead of returning more.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, fmt.Errorf("%w of %d bytes", errResponseTooLarge, b.limit)
+	}
+	// read one byte more than allowed to tell a body of exactly limit bytes from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), fmt.Errorf("%w of %d bytes", errResponseTooLarge, b.limit)
+	}
+	return n, err
+}
+
+// withResponseLimit wraps the transport of the HTTP client so responses larger than maxBytes fail.
+func withResponseLimit(client *http.Client, maxBytes int64) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &limitTransport{base: base, maxBytes: maxBytes}
+}
@@ -0,0 +1,114 @@
+package porkbun
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	pb "github.com/nrdcg/porkbun"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// porkbunMinTTL is the lowest TTL Porkbun accepts, lower TTLs are raised to it by Porkbun.
+const porkbunMinTTL = 600
+
+// ProviderSpecificTTL is the provider specific property overriding the TTL of the records of an endpoint, e.g. a short
+// TTL for a failover name. It can be set on sources with the annotation
+// external-dns.alpha.kubernetes.io/webhook-porkbun-ttl.
+const ProviderSpecificTTL = "webhook/porkbun-ttl"
+
+// AdjustEndpoints normalizes the names of the endpoints like the names listed from Porkbun, lowercase without
+// trailing dot and internationalized names in ASCII, as well as the host names in their targets. It raises TTLs below
+// the minimum TTL, by default the Porkbun minimum, to the minimum, so the desired TTL equals the one read back from
+// Porkbun and external-dns does not plan the same update with every sync.
+// Endpoints without a TTL get the default TTL if one is set, otherwise they keep the Porkbun default. The TTL property
+// of an endpoint overrides its TTL, the default and the minimum TTL. With apex aliases enabled, CNAME endpoints at a
+// zone apex become ALIAS endpoints, since Porkbun does not allow a CNAME there. CAA and SRV targets are normalized like
+// the targets listed from their records. Endpoints switched by a cutover get the targets of the active color.
+// Endpoints of types Porkbun does not support, of unmanaged types, NS endpoints that are not managed and endpoints
+// with a target Porkbun would refuse are dropped, each with a log line telling why.
+func (p *PorkbunProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	zones := p.domainFilter.Zones()
+	for _, ep := range endpoints {
+		if name := normalizeName(ep.DNSName); name != ep.DNSName {
+			if !isASCII(ep.DNSName) {
+				p.logger.Debug("converting internationalized name to ASCII", "endpoint", ep.DNSName, "name", name)
+			}
+			ep.DNSName = name
+		}
+		for i, target := range ep.Targets {
+			ep.Targets[i] = asciiTarget(ep.RecordType, target)
+		}
+		if !p.overrideTTL(ep) {
+			if !ep.RecordTTL.IsConfigured() && p.defaultTTL > 0 {
+				ep.RecordTTL = endpoint.TTL(p.defaultTTL)
+			}
+			if ep.RecordTTL.IsConfigured() && int64(ep.RecordTTL) < p.minTTL {
+				p.logger.Debug("raising TTL to the minimum TTL", "endpoint", ep.DNSName, "ttl", int64(ep.RecordTTL), "minTTL", p.minTTL)
+				ep.RecordTTL = endpoint.TTL(p.minTTL)
+			}
+		}
+		if p.apexAlias && ep.RecordType == endpoint.RecordTypeCNAME && slices.Contains(zones, normalizeName(ep.DNSName)) {
+			p.logger.Debug("converting CNAME at the zone apex into ALIAS", "endpoint", ep.DNSName)
+			ep.RecordType = recordTypeALIAS
+		}
+		if ep.RecordType == recordTypeCAA {
+			for i, target := range ep.Targets {
+				ep.Targets[i] = normalizeCAA(target)
+			}
+		}
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			for i, target := range ep.Targets {
+				ep.Targets[i] = parseTXT(target)
+			}
+		}
+		if ep.RecordType == endpoint.RecordTypeSRV {
+			for i, target := range ep.Targets {
+				ep.Targets[i] = normalizeSRV(target)
+			}
+		}
+		p.overrideCutoverTargets(ep)
+		cleanNotesProperty(ep)
+		p.inheritNotesProperty(ep, zones)
+	}
+	return p.rejectInvalidTargets(p.rejectUnmanagedNS(p.rejectUnmanagedTypes(endpoints), zones)), nil
+}
+
+// overrideTTL sets the TTL of the endpoint to the one of its TTL property and drops the property, which is never listed
+// back, so it does not cause an update with every sync. A TTL below the Porkbun minimum is still raised to it, since
+// Porkbun would raise it anyway. Invalid TTLs are ignored with a warning.
+// returns false if the endpoint has no valid TTL property
+func (p *PorkbunProvider) overrideTTL(ep *endpoint.Endpoint) bool {
+	value, ok := ep.GetProviderSpecificProperty(ProviderSpecificTTL)
+	if !ok {
+		return false
+	}
+	removeProviderSpecificProperty(ep, ProviderSpecificTTL)
+	ttl, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || ttl <= 0 {
+		p.logger.Warn("ignoring invalid TTL property", "endpoint", ep.DNSName, "ttl", value)
+		return false
+	}
+	if ttl < porkbunMinTTL {
+		p.logger.Debug("raising TTL of the TTL property to the Porkbun minimum", "endpoint", ep.DNSName, "ttl", ttl, "minTTL", porkbunMinTTL)
+		ttl = porkbunMinTTL
+	}
+	ep.RecordTTL = endpoint.TTL(ttl)
+	return true
+}
+
+// keepTTLs gives records written without a TTL the TTL of the existing record with the same ID, so updating
+// an endpoint without TTL keeps the TTL of its record, e.g. one set in the Porkbun console, instead of resetting it
+// to the Porkbun default. Records without a matching existing record are left to the Porkbun default.
+func keepTTLs(records *[]pb.Record, recs []pb.Record) {
+	ttls := make(map[string]string, len(recs))
+	for _, rec := range recs {
+		ttls[rec.ID] = normalizeNumber(rec.TTL)
+	}
+	for i, record := range *records {
+		if record.TTL == "" && record.ID != "" {
+			(*records)[i].TTL = ttls[record.ID]
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package porkbun
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TXT targets are handled as plain values, the form external-dns uses for TXT targets without quotes. Targets given
+// as quoted strings, like the `"heritage=external-dns,..."` targets of the TXT registry or `"v=spf1 -all"` from
+// annotations, are unquoted first, so every value has one form and compares equal however it was quoted.
+// Porkbun content holds the value as it is if that can't be mistaken for quoted strings, otherwise the quoted strings.
+
+// txtStringLimit is the maximum length of a character string in a TXT record, longer values like DKIM keys
+// have to be written as several strings, which resolvers hand out concatenated.
+const txtStringLimit = 255
+
+// txtEscaper escapes the characters that would end a quoted character string.
+var txtEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// formatTXT returns the Porkbun content of a TXT value: the value itself if it fits into one character string and
+// is not read back differently by parseTXT, otherwise the value written by quoteTXT.
+func formatTXT(value string) string {
+	if len(value) <= txtStringLimit && parseTXT(value) == value {
+		return value
+	}
+	return quoteTXT(value)
+}
+
+// quoteTXT writes a TXT value as quoted strings of at most 255 bytes each, e.g. `"v=DKIM1; k=rsa; p=MIIB..." "...IDAQAB"`.
+// Strings are only split between characters, quotes and backslashes in them are escaped.
+func quoteTXT(value string) string {
+	var b strings.Builder
+	for first := true; first || value != ""; first = false {
+		n := min(len(value), txtStringLimit)
+		for n < len(value) && n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('"')
+		b.WriteString(txtEscaper.Replace(value[:n]))
+		b.WriteByte('"')
+		value = value[n:]
+	}
+	return b.String()
+}
+
+// parseTXT returns the value of TXT content or a TXT target given as one or more quoted strings, separated by
+// whitespace, with the strings unescaped and joined. Content that is not a list of quoted strings is the value itself.
+func parseTXT(content string) string {
+	var joined strings.Builder
+	rest := strings.TrimSpace(content)
+	if rest == "" {
+		return content
+	}
+	for rest != "" {
+		if rest[0] != '"' {
+			return content
+		}
+		i := 1
+		for i < len(rest) && rest[i] != '"' {
+			if rest[i] == '\\' {
+				i++
+				if i == len(rest) {
+					return content
+				}
+			}
+			joined.WriteByte(rest[i])
+			i++
+		}
+		if i == len(rest) {
+			// the string is not closed
+			return content
+		}
+		next := strings.TrimLeft(rest[i+1:], " \t")
+		if next == rest[i+1:] && next != "" {
+			// strings must be separated by whitespace
+			return content
+		}
+		rest = next
+	}
+	return joined.String()
+}
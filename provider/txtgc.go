@@ -0,0 +1,152 @@
+package porkbun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/nrdcg/porkbun"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// txtGCState remembers the orphaned registry TXT records found by the last pass. A record is only deleted once a
+// second pass still finds it orphaned, so a registry TXT record written just before its record is not taken for an
+// orphan.
+type txtGCState struct {
+	mu       sync.Mutex
+	suspects map[string]bool
+}
+
+// orphanedTXT returns the registry TXT records of the zone whose records are gone: TXT records with
+// heritage=external-dns owned by one of the TXT garbage collection owners that are neither named like txtRecordName of
+// a record in the zone nor, as written by older external-dns versions, like the record itself. Registry TXT records of
+// other owners, e.g. of an external-dns with other TXT affixes, below excluded names or delegations and protected ones
+// are left alone.
+func (p *PorkbunProvider) orphanedTXT(zone string, recs []pb.Record) []pb.Record {
+	registered := map[string]bool{}
+	var registry []pb.Record
+	for _, rec := range recs {
+		name := normalizeName(rec.Name)
+		if rec.Type == endpoint.RecordTypeTXT {
+			if labels, err := endpoint.NewLabelsFromStringPlain(parseTXT(rec.Content)); err == nil {
+				if slices.Contains(p.txtGCOwners, labels[endpoint.OwnerLabelKey]) {
+					registry = append(registry, rec)
+				}
+				continue
+			}
+		}
+		registered[name] = true
+		registered[p.txtRecordName(name, rec.Type)] = true
+	}
+
+	points := delegations(zone, recs)
+	var orphaned []pb.Record
+	for _, rec := range registry {
+		name := normalizeName(rec.Name)
+		if registered[name] || p.domainFilter.Excluded(name) || delegated(name, points) || recordProtected(rec) {
+			continue
+		}
+		orphaned = append(orphaned, rec)
+	}
+	return orphaned
+}
+
+// collectOrphanedTXT runs one pass of the TXT garbage collection over all zones: it deletes the registry TXT records
+// found orphaned by this and the previous pass, and remembers the ones found orphaned for the first time. Zones held
+// by another zone lock are left for the next pass.
+// returns the number of deleted records and the joined errors of the zones that failed
+func (p *PorkbunProvider) collectOrphanedTXT(ctx context.Context) (int, error) {
+	if err := p.ensureLogin(ctx); err != nil {
+		return 0, err
+	}
+
+	p.txtGC.mu.Lock()
+	previous := p.txtGC.suspects
+	p.txtGC.mu.Unlock()
+	suspects := map[string]bool{}
+	deleted := 0
+	var errs []error
+	for _, zone := range p.domainFilter.Zones() {
+		if !p.gone.due(zone, p.clock.Now()) {
+			continue
+		}
+		recs, err := p.retrieveRecords(ctx, zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("zone '%s': unable to get DNS records: %w", zone, err))
+			continue
+		}
+		var orphans []pb.Record
+		for _, rec := range p.orphanedTXT(zone, recs) {
+			key := zone + "\x00" + rec.ID
+			if previous[key] {
+				orphans = append(orphans, rec)
+				continue
+			}
+			suspects[key] = true
+			p.logger.DebugContext(ctx, "found orphaned registry TXT record, deleting it with the next pass", "zone", zone, "name", rec.Name, "id", rec.ID)
+		}
+		if len(orphans) == 0 {
+			continue
+		}
+
+		release, err := p.lockZone(ctx, zone, recs)
+		if errors.Is(err, errZoneLocked) {
+			p.logger.InfoContext(ctx, "skipping TXT garbage collection of locked zone", "zone", zone, "error", err.Error())
+			for _, rec := range orphans {
+				suspects[zone+"\x00"+rec.ID] = true
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("zone '%s': %w", zone, err))
+			continue
+		}
+		for _, rec := range orphans {
+			id, ok := parseRecordID(rec.ID)
+			if !ok {
+				continue
+			}
+			if err := p.client.DeleteRecord(ctx, zone, id); err != nil && !isRecordNotFound(err) {
+				errs = append(errs, fmt.Errorf("zone '%s': unable to delete orphaned TXT record '%s': %w", zone, rec.Name, err))
+				continue
+			}
+			p.logger.InfoContext(ctx, "deleted orphaned registry TXT record", "zone", zone, "name", rec.Name, "content", rec.Content)
+			rec.Name = relativeName(rec.Name, zone)
+			p.recordChange(ctx, zone, "delete", rec)
+			orphanedTXTDeletedTotal.WithLabelValues(zone).Inc()
+			deleted++
+		}
+		release()
+	}
+
+	p.txtGC.mu.Lock()
+	p.txtGC.suspects = suspects
+	p.txtGC.mu.Unlock()
+	return deleted, errors.Join(errs...)
+}
+
+// RunTXTGC deletes orphaned registry TXT records every TXT garbage collection interval until the context is done.
+// It returns right away if the garbage collection is disabled.
+func (p *PorkbunProvider) RunTXTGC(ctx context.Context) {
+	if p.txtGCInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.txtGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deleted, err := p.collectOrphanedTXT(ctx)
+		if err != nil {
+			p.logger.WarnContext(ctx, "TXT garbage collection failed", "deleted", deleted, "error", err.Error())
+			continue
+		}
+		p.logger.DebugContext(ctx, "TXT garbage collection completed", "deleted", deleted)
+	}
+}
@@ -0,0 +1,25 @@
+package porkbun
+
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// splitTypeChanges takes the updates that change the record type of an endpoint, e.g. from CNAME to A, out of the
+// updates. Porkbun can't edit a record into another type, so they are applied as a delete of the old records and
+// a create of the new ones; deletes run before creates, so the new type does not conflict with the old one.
+// returns both sides of the remaining updates in the same order, and the creates and deletes
+func splitTypeChanges(updateOld []*endpoint.Endpoint, updateNew []*endpoint.Endpoint) (oldSide []*endpoint.Endpoint, newSide []*endpoint.Endpoint, creates []*endpoint.Endpoint, deletes []*endpoint.Endpoint) {
+	pairs := min(len(updateOld), len(updateNew))
+	for i := 0; i < pairs; i++ {
+		if updateOld[i].RecordType != updateNew[i].RecordType {
+			deletes = append(deletes, updateOld[i])
+			creates = append(creates, updateNew[i])
+			continue
+		}
+		oldSide = append(oldSide, updateOld[i])
+		newSide = append(newSide, updateNew[i])
+	}
+	oldSide = append(oldSide, updateOld[pairs:]...)
+	newSide = append(newSide, updateNew[pairs:]...)
+	return oldSide, newSide, creates, deletes
+}
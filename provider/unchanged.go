@@ -0,0 +1,45 @@
+package porkbun
+
+import (
+	pb "github.com/nrdcg/porkbun"
+)
+
+// dropUnchanged drops the updates whose record in the zone already has the desired name, content, priority and TTL,
+// so they don't cost an API call. The old side of the updates is dropped alongside, keeping both sides in the same order.
+// returns the number of dropped updates
+func dropUnchanged(zone string, updateNew *[]pb.Record, updateOld *[]pb.Record, recs []pb.Record) int {
+	byID := make(map[string]pb.Record, len(recs))
+	for _, rec := range recs {
+		byID[rec.ID] = rec
+	}
+	news := make([]pb.Record, 0, len(*updateNew))
+	olds := make([]pb.Record, 0, len(*updateOld))
+	for i, record := range *updateNew {
+		if existing, ok := byID[record.ID]; ok && record.ID != "" && sameRecord(zone, record, existing) {
+			continue
+		}
+		news = append(news, record)
+		if i < len(*updateOld) {
+			olds = append(olds, (*updateOld)[i])
+		}
+	}
+	if len(*updateOld) > len(*updateNew) {
+		olds = append(olds, (*updateOld)[len(*updateNew):]...)
+	}
+	dropped := len(*updateNew) - len(news)
+	*updateNew, *updateOld = news, olds
+	return dropped
+}
+
+// sameRecord reports whether writing the record would leave the existing record as it is, apart from the metadata
+// in its notes other than the protection. Operator notes only count if the record brings its own, otherwise the
+// existing ones are kept.
+func sameRecord(zone string, record pb.Record, existing pb.Record) bool {
+	notes := operatorNotes(record.Notes)
+	return normalizeName(recordFQDN(record.Name, zone)) == normalizeName(existing.Name) &&
+		record.Type == existing.Type &&
+		normalizeTarget(record.Type, recordTarget(record)) == normalizeTarget(existing.Type, recordTarget(existing)) &&
+		normalizeNumber(record.TTL) == normalizeNumber(existing.TTL) &&
+		(notes == "" || notes == operatorNotes(existing.Notes)) &&
+		recordProtected(record) == recordProtected(existing)
+}
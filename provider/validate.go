@@ -0,0 +1,106 @@
+package porkbun
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// hostLabelRegexp matches a label of a host name in ASCII. Underscores are allowed, since targets like
+// _acme-challenge.example.net are common in CNAME records.
+var hostLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?$`)
+
+// txtMaxRDATA is the maximum size of the data of a TXT record, holding the character strings with a length byte each.
+const txtMaxRDATA = 65535
+
+// validHostname reports whether the name is a host name Porkbun accepts as target, with or without trailing dot.
+func validHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !hostLabelRegexp.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTarget checks a target of the record type in the form AdjustEndpoints leaves it, with host names in ASCII
+// and TXT values unquoted. Types without a check, like HTTPS, SVCB and TLSA, are left to Porkbun.
+// returns an error telling why the target is invalid
+func validateTarget(recordType string, target string) error {
+	switch recordType {
+	case endpoint.RecordTypeA:
+		if addr, err := netip.ParseAddr(target); err != nil || !addr.Is4() {
+			return errors.New("not an IPv4 address")
+		}
+	case endpoint.RecordTypeAAAA:
+		if addr, err := netip.ParseAddr(target); err != nil || !addr.Is6() {
+			return errors.New("not an IPv6 address")
+		}
+	case endpoint.RecordTypeCNAME, recordTypeALIAS, endpoint.RecordTypeNS:
+		if !validHostname(target) {
+			return errors.New("not a valid host name")
+		}
+	case endpoint.RecordTypeMX:
+		host, prio := splitPriority(recordType, target)
+		if prio == "" {
+			return errors.New("no priority in front of the target")
+		}
+		// A single dot is the null target, e.g. of a domain that receives no mail
+		if host != "." && !validHostname(host) {
+			return errors.New("not a valid host name")
+		}
+	case endpoint.RecordTypeSRV:
+		_, _, _, host, ok := parseSRV(target)
+		if !ok {
+			return errors.New("not in the form priority weight port target, with numbers from 0 to 65535")
+		}
+		// A single dot is the null target of a service that is not available
+		if host != "." && !validHostname(host) {
+			return errors.New("not a valid host name")
+		}
+	case recordTypeCAA:
+		if _, _, _, ok := parseCAA(target); !ok {
+			return errors.New("not in the form flags tag value")
+		}
+	case endpoint.RecordTypeTXT:
+		strs := max(1, (len(target)+txtStringLimit-1)/txtStringLimit)
+		if len(target)+strs > txtMaxRDATA {
+			return fmt.Errorf("value of %d bytes exceeds the size of a TXT record", len(target))
+		}
+	}
+	return nil
+}
+
+// rejectInvalidTargets drops the endpoints with a target Porkbun would refuse, each with a log line telling why, so
+// the target fails when the endpoint is adjusted instead of halfway through applying the changes of its zone.
+func (p *PorkbunProvider) rejectInvalidTargets(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	valid := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if err := endpointTargetsError(ep); err != nil {
+			p.logger.Warn("rejecting endpoint with invalid target", "endpoint", ep.DNSName, "type", ep.RecordType, "error", err.Error())
+			skippedEndpointsTotal.WithLabelValues(skipReasonInvalidTarget).Inc()
+			continue
+		}
+		valid = append(valid, ep)
+	}
+	return valid
+}
+
+// endpointTargetsError returns the error of the first invalid target of the endpoint.
+func endpointTargetsError(ep *endpoint.Endpoint) error {
+	for _, target := range ep.Targets {
+		if err := validateTarget(ep.RecordType, target); err != nil {
+			return fmt.Errorf("target %q: %w", target, err)
+		}
+	}
+	return nil
+}
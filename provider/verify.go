@@ -0,0 +1,261 @@
+package porkbun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+const (
+	// ResolverSystem names the resolver configured for the host.
+	ResolverSystem = "system"
+	// ResolverPorkbun names the authoritative nameservers of Porkbun.
+	ResolverPorkbun = "porkbun"
+
+	defaultResolverTimeout = 5 * time.Second
+	defaultVerifyWindow    = 2 * time.Minute
+	verifyInterval         = 10 * time.Second
+)
+
+// porkbunNameservers are the authoritative nameservers of zones hosted by Porkbun.
+var porkbunNameservers = []string{
+	"curitiba.ns.porkbun.com:53",
+	"fortaleza.ns.porkbun.com:53",
+	"maceio.ns.porkbun.com:53",
+	"salvador.ns.porkbun.com:53",
+}
+
+// Resolver looks up the targets of a DNS name, e.g. to check whether a written record has propagated.
+type Resolver interface {
+	// Name identifies the resolver in logs and metrics.
+	Name() string
+	// Lookup returns the targets of the name in the form external-dns uses for endpoints of the record type.
+	Lookup(ctx context.Context, name string, recordType string) ([]string, error)
+}
+
+// dnsResolver is a Resolver querying a DNS server, or the resolver of the host if no address is set.
+type dnsResolver struct {
+	name     string
+	resolver *net.Resolver
+	timeout  time.Duration
+}
+
+// ParseResolvers builds the resolvers of a resolver spec: system, porkbun or the host:port of a DNS server,
+// optionally followed by =timeout (e.g. 1.1.1.1:53=2s). porkbun expands to all Porkbun nameservers.
+func ParseResolvers(spec string) ([]Resolver, error) {
+	address, timeoutValue, found := strings.Cut(strings.TrimSpace(spec), "=")
+	timeout := defaultResolverTimeout
+	if found {
+		d, err := time.ParseDuration(timeoutValue)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout of resolver '%s'", spec)
+		}
+		timeout = d
+	}
+
+	switch address {
+	case ResolverSystem:
+		return []Resolver{&dnsResolver{name: ResolverSystem, resolver: net.DefaultResolver, timeout: timeout}}, nil
+	case ResolverPorkbun:
+		resolvers := make([]Resolver, 0, len(porkbunNameservers))
+		for _, ns := range porkbunNameservers {
+			resolvers = append(resolvers, newDNSResolver(ns, timeout))
+		}
+		return resolvers, nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid resolver '%s', expected %s, %s or host:port", spec, ResolverSystem, ResolverPorkbun)
+	}
+	return []Resolver{newDNSResolver(address, timeout)}, nil
+}
+
+// newDNSResolver creates a resolver sending all queries to the DNS server at the address.
+func newDNSResolver(address string, timeout time.Duration) *dnsResolver {
+	return &dnsResolver{
+		name: address,
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+		},
+		timeout: timeout,
+	}
+}
+
+func (r *dnsResolver) Name() string {
+	return r.name
+}
+
+func (r *dnsResolver) Lookup(ctx context.Context, name string, recordType string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	name = normalizeName(name) + "."
+	var targets []string
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		network := "ip4"
+		if recordType == endpoint.RecordTypeAAAA {
+			network = "ip6"
+		}
+		addrs, err := r.resolver.LookupNetIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			targets = append(targets, addr.Unmap().String())
+		}
+	case endpoint.RecordTypeCNAME:
+		cname, err := r.resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, normalizeName(cname))
+	case endpoint.RecordTypeTXT:
+		txts, err := r.resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		targets = txts
+	case endpoint.RecordTypeMX:
+		mxs, err := r.resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			targets = append(targets, strconv.Itoa(int(mx.Pref))+" "+normalizeName(mx.Host))
+		}
+	default:
+		return nil, fmt.Errorf("record type %s can't be verified", recordType)
+	}
+	return targets, nil
+}
+
+// verifiable reports whether the propagation of the record type can be checked.
+func verifiable(recordType string) bool {
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT, endpoint.RecordTypeMX:
+		return true
+	}
+	return false
+}
+
+// canonicalTarget brings a target into the form returned by dnsResolver.Lookup.
+func canonicalTarget(recordType string, target string) string {
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		if addr, err := netip.ParseAddr(target); err == nil {
+			return addr.Unmap().String()
+		}
+	case endpoint.RecordTypeCNAME:
+		return normalizeName(target)
+	case endpoint.RecordTypeTXT:
+		return parseTXT(target)
+	case endpoint.RecordTypeMX:
+		if pref, host, found := strings.Cut(target, " "); found {
+			return pref + " " + normalizeName(host)
+		}
+	}
+	return target
+}
+
+// propagated reports whether the resolver answers with all targets of the endpoint.
+// Lookup errors are reported and count as not propagated.
+func propagated(ctx context.Context, resolver Resolver, ep *endpoint.Endpoint) (bool, error) {
+	start := time.Now()
+	found, err := resolver.Lookup(ctx, ep.DNSName, ep.RecordType)
+	verifyLookupSeconds.WithLabelValues(resolver.Name()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		verifyLookupsTotal.WithLabelValues(resolver.Name(), "error").Inc()
+		return false, err
+	}
+	for i := range found {
+		found[i] = canonicalTarget(ep.RecordType, found[i])
+	}
+	for _, target := range ep.Targets {
+		if !slices.Contains(found, canonicalTarget(ep.RecordType, target)) {
+			verifyLookupsTotal.WithLabelValues(resolver.Name(), "pending").Inc()
+			return false, nil
+		}
+	}
+	verifyLookupsTotal.WithLabelValues(resolver.Name(), "propagated").Inc()
+	return true, nil
+}
+
+// consensus queries all resolvers in parallel and reports whether at least the consensus share of them
+// answers with the targets of the endpoint.
+func (p *PorkbunProvider) consensus(ctx context.Context, ep *endpoint.Endpoint) bool {
+	var wg sync.WaitGroup
+	results := make([]bool, len(p.resolvers))
+	for i, resolver := range p.resolvers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := propagated(ctx, resolver, ep)
+			if err != nil {
+				p.logger.DebugContext(ctx, "propagation lookup failed", "resolver", resolver.Name(), "endpoint", ep.DNSName, "type", ep.RecordType, "error", err.Error())
+			}
+			results[i] = ok
+		}()
+	}
+	wg.Wait()
+
+	agreeing := 0
+	for _, ok := range results {
+		if ok {
+			agreeing++
+		}
+	}
+	return float64(agreeing) >= p.verifyConsensus*float64(len(p.resolvers))
+}
+
+// verifyPropagation checks the written endpoints against the configured resolvers until the consensus is reached
+// or the verify window has passed, and reports the outcome in logs and metrics. It does not change any record.
+func (p *PorkbunProvider) verifyPropagation(ctx context.Context, endpoints []*endpoint.Endpoint) {
+	ctx, cancel := context.WithTimeout(ctx, p.verifyWindow)
+	defer cancel()
+
+	start := time.Now()
+	pending := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if verifiable(ep.RecordType) {
+			pending = append(pending, ep)
+		}
+	}
+	for len(pending) > 0 {
+		remaining := pending[:0]
+		for _, ep := range pending {
+			if p.consensus(ctx, ep) {
+				verifyPropagationsTotal.WithLabelValues("propagated").Inc()
+				verifyPropagationSeconds.Observe(time.Since(start).Seconds())
+				p.logger.DebugContext(ctx, "record propagated", "endpoint", ep.DNSName, "type", ep.RecordType, "after", time.Since(start).Round(time.Second))
+				continue
+			}
+			remaining = append(remaining, ep)
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, ep := range pending {
+				verifyPropagationsTotal.WithLabelValues("timed_out").Inc()
+				p.logger.WarnContext(ctx, "record did not propagate within the verify window", "endpoint", ep.DNSName, "type", ep.RecordType, "window", p.verifyWindow)
+			}
+			return
+		case <-time.After(p.verifyRetryInterval):
+		}
+	}
+}
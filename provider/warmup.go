@@ -0,0 +1,64 @@
+package porkbun
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// warmupRetryInterval is the pause between failed initial zone fetches.
+const warmupRetryInterval = 5 * time.Second
+
+// defaultWarmupTimeout is the default maximum time the warm-up gate rejects record listings.
+const defaultWarmupTimeout = 2 * time.Minute
+
+// Warmup fetches all zones once so that the first /records response reflects the complete zones.
+// If every zone can be restored from a recent cache snapshot, only the login is checked instead.
+// Until it succeeds, or until timeout elapses, WarmupGate rejects record listings.
+// A timeout of 0 disables the gate.
+func (p *PorkbunProvider) Warmup(ctx context.Context, timeout time.Duration) {
+	defer p.warmedUp.Store(true)
+
+	restored := p.restoreSnapshot(ctx)
+	if timeout <= 0 || p.dryRun {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if restored && p.ensureLogin(ctx) == nil {
+		p.logger.Info("warm-up completed from cache snapshot, serving records")
+		return
+	}
+
+	for {
+		_, err := p.Records(ctx)
+		if err == nil {
+			p.logger.Info("warm-up completed, serving records")
+			return
+		}
+		p.logger.Warn("warm-up zone fetch failed, retrying", "error", err.Error())
+
+		select {
+		case <-ctx.Done():
+			p.logger.Warn("warm-up timed out, serving records without a complete initial fetch", "timeout", timeout)
+			return
+		case <-time.After(warmupRetryInterval):
+		}
+	}
+}
+
+// WarmupGate wraps the records handler and answers record listings with 503 Service Unavailable
+// until the warm-up has finished, so external-dns never plans against an empty or partial view.
+func (p *PorkbunProvider) WarmupGate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && !p.warmedUp.Load() {
+			p.logger.Debug("rejecting records request during warm-up")
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "provider is warming up", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
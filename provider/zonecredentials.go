@@ -0,0 +1,147 @@
+package porkbun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	pb "github.com/nrdcg/porkbun"
+)
+
+// ZoneCredentials is a domain-scoped Porkbun API key pair, used for all calls concerning its zone.
+type ZoneCredentials struct {
+	Zone      string `json:"zone"`
+	APIKey    string `json:"apiKey"`
+	APISecret string `json:"secretApiKey"`
+}
+
+// LoadZoneCredentials reads the zone credentials from a JSON file holding a list of zones with their keys.
+func LoadZoneCredentials(path string) ([]ZoneCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read zone credentials: %v", err)
+	}
+	var credentials []ZoneCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("unable to parse zone credentials '%s': %v", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, c := range credentials {
+		zone := normalizeName(c.Zone)
+		switch {
+		case zone == "" || seen[zone]:
+			return nil, fmt.Errorf("zone credentials '%s': empty or duplicate zone %q", path, c.Zone)
+		case c.APIKey == "" || c.APISecret == "":
+			return nil, fmt.Errorf("zone credentials '%s': zone %s needs apiKey and secretApiKey", path, zone)
+		}
+		seen[zone] = true
+		credentials[i].Zone = zone
+	}
+	return credentials, nil
+}
+
+// zoneClients routes the calls concerning a zone to the client with the keys of the zone, and all other calls
+// to the client with the account keys.
+type zoneClients struct {
+	// account is nil if every zone has its own keys
+	account porkbunClient
+	zones   map[string]porkbunClient
+	// order are the zones with own keys sorted, the first one answers pings without account keys
+	order []string
+}
+
+func newZoneClients(account porkbunClient, zones map[string]porkbunClient) *zoneClients {
+	c := &zoneClients{account: account, zones: zones}
+	for zone := range zones {
+		c.order = append(c.order, zone)
+	}
+	slices.Sort(c.order)
+	return c
+}
+
+func (c *zoneClients) clientFor(domain string) porkbunClient {
+	if client, ok := c.zones[normalizeName(domain)]; ok || c.account == nil {
+		return client
+	}
+	return c.account
+}
+
+func (c *zoneClients) Ping(ctx context.Context) (string, error) {
+	if c.account == nil {
+		return c.zones[c.order[0]].Ping(ctx)
+	}
+	return c.account.Ping(ctx)
+}
+
+func (c *zoneClients) CreateRecord(ctx context.Context, domain string, record pb.Record) (int, error) {
+	client := c.clientFor(domain)
+	if client == nil {
+		return 0, noZoneCredentials(domain)
+	}
+	return client.CreateRecord(ctx, domain, record)
+}
+
+func (c *zoneClients) EditRecord(ctx context.Context, domain string, id int, record pb.Record) error {
+	client := c.clientFor(domain)
+	if client == nil {
+		return noZoneCredentials(domain)
+	}
+	return client.EditRecord(ctx, domain, id, record)
+}
+
+func (c *zoneClients) DeleteRecord(ctx context.Context, domain string, id int) error {
+	client := c.clientFor(domain)
+	if client == nil {
+		return noZoneCredentials(domain)
+	}
+	return client.DeleteRecord(ctx, domain, id)
+}
+
+func (c *zoneClients) RetrieveRecords(ctx context.Context, domain string) ([]pb.Record, error) {
+	client := c.clientFor(domain)
+	if client == nil {
+		return nil, noZoneCredentials(domain)
+	}
+	return client.RetrieveRecords(ctx, domain)
+}
+
+// noZoneCredentials is returned for calls concerning a zone without keys if no account keys are configured.
+func noZoneCredentials(domain string) error {
+	return fmt.Errorf("no API keys configured for zone '%s'", domain)
+}
+
+// coversZones reports whether every zone has its own keys.
+func coversZones(credentials []ZoneCredentials, zones []string) bool {
+	for _, zone := range zones {
+		if !slices.ContainsFunc(credentials, func(c ZoneCredentials) bool { return c.Zone == normalizeName(zone) }) {
+			return false
+		}
+	}
+	return len(zones) > 0
+}
+
+// CheckZoneCredentials verifies that the keys of every zone with its own keys have access to the zone, by
+// listing its records. Porkbun only grants domain-scoped keys access to the domains API access was enabled for.
+func (p *PorkbunProvider) CheckZoneCredentials(ctx context.Context) error {
+	if p.dryRun {
+		return nil
+	}
+	var errs []error
+	zones := p.domainFilter.Zones()
+	for _, c := range p.zoneCredentials {
+		if !slices.Contains(zones, c.Zone) {
+			p.logger.WarnContext(ctx, "zone credentials for zone that is not in the domain filter", "zone", c.Zone)
+			continue
+		}
+		if _, err := p.client.RetrieveRecords(ctx, c.Zone); err != nil {
+			errs = append(errs, fmt.Errorf("API key of zone '%s' has no access to the zone: %v", c.Zone, err))
+			continue
+		}
+		p.logger.InfoContext(ctx, "verified API key of zone", "zone", c.Zone)
+	}
+	return errors.Join(errs...)
+}
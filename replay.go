@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+)
+
+// replay applies the ApplyChanges payload captured in the file once.
+func replay(ctx context.Context, pbProvider *porkbun.PorkbunProvider, path string, logger *slog.Logger) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	changes, err := porkbun.ReadChanges(f)
+	if err != nil {
+		return err
+	}
+	logger.Info("replaying changes", "file", path, "create", len(changes.Create), "updateOld", len(changes.UpdateOld), "updateNew", len(changes.UpdateNew), "delete", len(changes.Delete))
+	return pbProvider.ApplyChanges(ctx, changes)
+}
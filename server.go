@@ -0,0 +1,203 @@
+//go:build !lite
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/konnektr-io/external-dns-porkbun-webhook/config"
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
+	cversion "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// addMetricsServer adds the server providing metrics, the landing page and the admin endpoints to the run group,
+// protected by basic auth if configured.
+func addMetricsServer(g *run.Group, cfg *config.Config, pbProvider *porkbun.PorkbunProvider, logger *slog.Logger) {
+	prometheus.DefaultRegisterer.MustRegister(cversion.NewCollector("external_dns_netcup"))
+
+	metricsMux := buildMetricsServer(prometheus.DefaultGatherer, cfg, pbProvider, logger)
+	metricsServer := http.Server{
+		Handler:           adminAuth(metricsMux, cfg),
+		ReadHeaderTimeout: 5 * time.Second}
+
+	g.Add(func() error {
+		logger.Info("Started external-dns-porkbun-webhook metrics server", "address", cfg.MetricsListenAddress)
+		return listenAndServe(&metricsServer, cfg.MetricsListenAddress, cfg.TLSConfig, logger)
+	}, func(error) {
+		ctxShutDown, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_ = metricsServer.Shutdown(ctxShutDown)
+	})
+}
+
+// mountMetricsServer mounts the metrics, the landing page and the admin endpoints on the webhook server under the
+// admin path prefix for --single-listener, protected by their own basic auth if configured.
+func mountMetricsServer(mux *http.ServeMux, cfg *config.Config, pbProvider *porkbun.PorkbunProvider, logger *slog.Logger) {
+	prometheus.DefaultRegisterer.MustRegister(cversion.NewCollector("external_dns_netcup"))
+
+	metricsMux := buildMetricsServer(prometheus.DefaultGatherer, cfg, pbProvider, logger)
+	mux.Handle(cfg.AdminPathPrefix+"/", adminAuth(metricsMux, cfg))
+	logger.Info("serving metrics and admin endpoints on the webhook server", "prefix", cfg.AdminPathPrefix)
+}
+
+// adminAuth protects the handler with basic auth if --admin-username and --admin-password are set.
+func adminAuth(handler http.Handler, cfg *config.Config) http.Handler {
+	if cfg.AdminUsername == "" {
+		return handler
+	}
+	return basicAuth(handler, cfg.AdminUsername, cfg.AdminPassword)
+}
+
+// basicAuth only passes requests carrying the username and password on to the handler.
+func basicAuth(handler http.Handler, username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 || subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// listenAndServe serves on the address using the exporter-toolkit, which adds TLS and basic auth from --tls-config.
+func listenAndServe(server *http.Server, address, tlsConfig string, logger *slog.Logger) error {
+	flags := web.FlagConfig{
+		WebListenAddresses: &[]string{address},
+		WebSystemdSocket:   new(bool),
+		WebConfigFile:      &tlsConfig,
+	}
+	return web.ListenAndServe(server, &flags, logger)
+}
+
+// buildMetricsServer builds the mux of the metrics, the landing page and the admin endpoints.
+// All routes are served below the admin path prefix if they share the webhook server.
+// With --metrics-only every path but the metrics answers 404. The admin endpoints that call Porkbun on behalf of the
+// caller are only served if they are protected by basic auth.
+func buildMetricsServer(registry prometheus.Gatherer, cfg *config.Config, pbProvider *porkbun.PorkbunProvider, logger *slog.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	routePrefix := ""
+	if cfg.SingleListener {
+		routePrefix = cfg.AdminPathPrefix
+	}
+
+	var metricsPath = "/metrics"
+	var stalenessPath = "/staleness"
+	var domainCheckPath = "/domains/check"
+	var domainPricingPath = "/domains/pricing"
+	var dashboardPath = "/dashboard"
+	var cutoverPath = "/cutover"
+	var ownershipPath = "/ownership"
+	var rootPath = "/"
+
+	// Add metricsPath
+	mux.Handle(routePrefix+metricsPath, metricsHandler(registry, logger))
+
+	if cfg.MetricsOnly {
+		return mux
+	}
+
+	// Add stalenessPath
+	mux.HandleFunc(routePrefix+stalenessPath, pbProvider.StalenessHandler)
+	// Add dashboardPath
+	mux.HandleFunc(routePrefix+dashboardPath, dashboardHandler(pbProvider, logger))
+
+	if cfg.AdminUsername != "" {
+		// Add domainCheckPath
+		mux.HandleFunc(routePrefix+domainCheckPath, pbProvider.DomainCheckHandler)
+		// Add domainPricingPath
+		mux.HandleFunc(routePrefix+domainPricingPath, pbProvider.DomainPricingHandler)
+		// Add cutoverPath
+		mux.HandleFunc(routePrefix+cutoverPath, pbProvider.CutoverHandler)
+		// Add ownershipPath
+		mux.HandleFunc(routePrefix+ownershipPath, pbProvider.OwnershipHandler)
+	} else {
+		logger.Warn("admin endpoints calling Porkbun are disabled, set --admin-username and --admin-password to serve them", "paths", []string{domainCheckPath, domainPricingPath, cutoverPath, ownershipPath})
+	}
+
+	if !cfg.LandingPage {
+		return mux
+	}
+
+	// Add index
+	landingConfig := web.LandingConfig{
+		RoutePrefix: routePrefix,
+		Name:        "external-dns-porkbun-webhook",
+		Description: "external-dns webhook provider for Porkbun",
+		Version:     version.Info(),
+		Links: []web.LandingLinks{
+			{
+				Address:     dashboardPath,
+				Text:        "Dashboard",
+				Description: "Zones, last syncs and recent changes",
+			},
+			{
+				Address: metricsPath,
+				Text:    "Metrics",
+			},
+			{
+				Address: stalenessPath,
+				Text:    "Stale records",
+			},
+		},
+	}
+	landingPage, err := web.NewLandingPage(landingConfig)
+	if err != nil {
+		logger.Error("failed to create landing page", "error", err.Error())
+	}
+	mux.Handle(routePrefix+rootPath, landingPage)
+
+	return mux
+}
+
+// metricsHandler serves the metrics of the registry. OpenMetrics is written with the _created series of counters
+// and histograms and with the units of the metrics, which promhttp does not write, other formats are left to promhttp.
+func metricsHandler(registry prometheus.Gatherer, logger *slog.Logger) http.Handler {
+	gatherer := porkbun.UnitGatherer(registry)
+	fallback := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: true,
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		families, err := gatherer.Gather()
+		if err != nil {
+			logger.Error("error gathering metrics", "error", err.Error())
+			if len(families) == 0 {
+				http.Error(w, "error gathering metrics", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", string(format))
+		encoder := expfmt.NewEncoder(w, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+		for _, family := range families {
+			if err := encoder.Encode(family); err != nil {
+				logger.Error("error encoding metric family", "family", family.GetName(), "error", err.Error())
+				return
+			}
+		}
+		if closer, ok := encoder.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Error("error closing metrics encoder", "error", err.Error())
+			}
+		}
+	})
+}
@@ -0,0 +1,27 @@
+//go:build lite
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/konnektr-io/external-dns-porkbun-webhook/config"
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"github.com/oklog/run"
+)
+
+// addMetricsServer does nothing, the lite build has no metrics server, landing page or admin endpoints.
+func addMetricsServer(_ *run.Group, _ *config.Config, _ *porkbun.PorkbunProvider, logger *slog.Logger) {
+	logger.Info("metrics server is not available in the lite build")
+}
+
+// mountMetricsServer does nothing, the lite build rejects --single-listener during validation.
+func mountMetricsServer(_ *http.ServeMux, _ *config.Config, _ *porkbun.PorkbunProvider, _ *slog.Logger) {
+}
+
+// listenAndServe serves plain HTTP on the address, the lite build rejects --tls-config during validation.
+func listenAndServe(server *http.Server, address, _ string, _ *slog.Logger) error {
+	server.Addr = address
+	return server.ListenAndServe()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	porkbun "github.com/konnektr-io/external-dns-porkbun-webhook/provider"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// applyTemplate applies the named record template to the zone once, or only prints the changes with preview.
+func applyTemplate(ctx context.Context, pbProvider *porkbun.PorkbunProvider, name string, zone string, vars map[string]string, preview bool, out io.Writer, logger *slog.Logger) error {
+	changes, err := pbProvider.TemplateChanges(ctx, name, zone, vars)
+	if err != nil {
+		return err
+	}
+	if !changes.HasChanges() {
+		logger.Info("records of the template are already in place", "template", name, "zone", zone)
+		return nil
+	}
+	if preview {
+		printChanges(out, "create", changes.Create)
+		printChanges(out, "delete", changes.UpdateOld)
+		printChanges(out, "add", changes.UpdateNew)
+		return nil
+	}
+	logger.Info("applying record template", "template", name, "zone", zone, "create", len(changes.Create), "update", len(changes.UpdateNew))
+	return pbProvider.ApplyChanges(ctx, changes)
+}
+
+// printChanges prints one line per endpoint of the changes, prefixed with the action.
+func printChanges(out io.Writer, action string, endpoints []*endpoint.Endpoint) {
+	for _, ep := range endpoints {
+		fmt.Fprintf(out, "%s %s %s %s\n", action, ep.DNSName, ep.RecordType, strings.Join(ep.Targets, " "))
+	}
+}